@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const commandTimeout = 5 * time.Minute
+
+// command is a maintenance task run instead of the server, e.g. `flashcards indexes status`.
+type command func(ctx context.Context, application pkg.Application, args []string) int
+
+var commands = map[string]command{
+	"backup":            backup,
+	"indexes ensure":    ensureIndexes,
+	"indexes status":    indexStatus,
+	"migrations status": migrationStatus,
+	"migrations up":     migrateUp,
+}
+
+// runCommand runs the command named by args and returns the process exit code.
+func runCommand(application pkg.Application, args []string) int {
+	for name, run := range commands {
+		words := strings.Fields(name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == name {
+			ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+			defer cancel()
+			return run(ctx, application, args[len(words):])
+		}
+	}
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "unknown command %q, expected one of: %s\n", strings.Join(args, " "), strings.Join(names, ", "))
+	return 2
+}
+
+func ensureIndexes(ctx context.Context, application pkg.Application, _ []string) int {
+	if !hasIndexes(application) {
+		return 2
+	}
+	if err := application.Indexes.Ensure(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("indexes are in place")
+	return 0
+}
+
+// indexStatus prints the differences between the declared and the existing indexes,
+// it exits with 1 when there are any so it can gate a deployment.
+func indexStatus(ctx context.Context, application pkg.Application, _ []string) int {
+	if !hasIndexes(application) {
+		return 2
+	}
+	drift, err := application.Indexes.Drift(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(drift) == 0 {
+		fmt.Println("indexes match the declared ones")
+		return 0
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		return drift[i].Collection+drift[i].Name < drift[j].Collection+drift[j].Name
+	})
+	for _, d := range drift {
+		fmt.Printf("%s.%s: %s\n", d.Collection, d.Name, d.Problem)
+	}
+	return 1
+}
+
+// migrationStatus prints every migration and when it was applied, it exits with 1 when
+// some are pending.
+func migrationStatus(ctx context.Context, application pkg.Application, _ []string) int {
+	if !hasMigrations(application) {
+		return 2
+	}
+	states, err := application.Migrator.Status(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	code := 0
+	for _, state := range states {
+		applied := "pending"
+		if state.AppliedAt != nil {
+			applied = "applied " + state.AppliedAt.Format(time.RFC3339)
+		} else {
+			code = 1
+		}
+		fmt.Printf("%4d  %-30s %s\n", state.Version, applied, state.Description)
+	}
+	return code
+}
+
+// migrateUp applies the pending migrations, with -dry-run it prints how many documents
+// or rows each would change and writes nothing.
+func migrateUp(ctx context.Context, application pkg.Application, args []string) int {
+	if !hasMigrations(application) {
+		return 2
+	}
+	flags := flag.NewFlagSet("migrations up", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "count the documents or rows each pending migration would change")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	states, err := application.Migrator.Up(ctx, *dryRun)
+	for _, state := range states {
+		fmt.Printf("%4d  %d changed  %s\n", state.Version, state.Changed, state.Description)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(states) == 0 {
+		fmt.Println("no pending migrations")
+	}
+	return 0
+}
+
+// backup copies the SQLite database to the file given as argument, the server may keep
+// running meanwhile.
+func backup(ctx context.Context, application pkg.Application, args []string) int {
+	if application.Backup == nil {
+		fmt.Fprintln(os.Stderr, "the configured storage has no backup, use -storage sqlite")
+		return 2
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: backup <file>")
+		return 2
+	}
+
+	if err := application.Backup.Backup(ctx, args[0]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("backup written to %s\n", args[0])
+	return 0
+}
+
+// hasIndexes tells whether the application runs on MongoDB, the only storage with
+// indexes to manage.
+func hasIndexes(application pkg.Application) bool {
+	if application.Indexes == nil {
+		fmt.Fprintln(os.Stderr, "the configured storage has no indexes to manage, use -storage mongodb")
+		return false
+	}
+	return true
+}
+
+// hasMigrations tells whether the configured storage has migrations, the in-memory one
+// starts empty every time.
+func hasMigrations(application pkg.Application) bool {
+	if application.Migrator == nil {
+		fmt.Fprintln(os.Stderr, "the configured storage has no migrations, use -storage mongodb or postgres")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,16 @@
+package backoff
+
+import "time"
+
+// Exponential is how long to wait after the given number of failed attempts: initial
+// doubled for each attempt after the first, up to max.
+func Exponential(initial, max time.Duration, attempts int) time.Duration {
+	wait := initial
+	for i := 1; i < attempts && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
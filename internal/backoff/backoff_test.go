@@ -0,0 +1,13 @@
+package backoff
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestExponentialIsCapped(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Exponential(30*time.Second, time.Hour, 1))
+	assert.Equal(t, 4*time.Minute, Exponential(30*time.Second, time.Hour, 4))
+	assert.Equal(t, time.Hour, Exponential(30*time.Second, time.Hour, 20))
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"strconv"
+	"time"
+)
+
+// Cache holds encoded values by key. LRU keeps them in the process, a cache shared by
+// several instances implements the same methods. A cache that fails behaves as a miss,
+// so its errors are logged by the implementation and not returned.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set keeps value for ttl, zero keeps it until it is evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	Delete(ctx context.Context, keys ...string)
+}
+
+// Load decodes the value of key into value and records the lookup for entity. A value
+// that does not decode is a miss.
+func Load(ctx context.Context, c Cache, entity, key string, value interface{}) bool {
+	encoded, hit := c.Get(ctx, key)
+	if hit && json.Unmarshal(encoded, value) != nil {
+		hit = false
+	}
+	metrics.ObserveCache(entity, hit)
+	return hit
+}
+
+// Store encodes value under key for ttl.
+func Store(ctx context.Context, c Cache, key string, value interface{}, ttl time.Duration) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.FromContext(ctx).Warnw("Caching has failed", "key", key, "error", err.Error())
+		return
+	}
+	c.Set(ctx, key, encoded, ttl)
+}
+
+// Generation names the current version of a group of keys that cannot be deleted one
+// by one, such as every user's list of public decks. The keys of the group include it
+// and Invalidate starts a new one, the old keys are left to expire.
+func Generation(ctx context.Context, c Cache, group string) string {
+	if generation, ok := c.Get(ctx, group+":generation"); ok {
+		return string(generation)
+	}
+	return Invalidate(ctx, c, group)
+}
+
+// Invalidate starts a new generation of group and returns it.
+func Invalidate(ctx context.Context, c Cache, group string) string {
+	generation := strconv.FormatInt(time.Now().UnixNano(), 36)
+	c.Set(ctx, group+":generation", []byte(generation), 0)
+	return generation
+}
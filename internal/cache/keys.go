@@ -0,0 +1,28 @@
+package cache
+
+// The keys of the cached repositories, kept together because a change to one entity can
+// invalidate the keys of another: deleting a deck also removes its cards.
+const (
+	DeckGroup     = "decks"
+	PlaylistGroup = "playlists"
+)
+
+func DeckKey(id string) string {
+	return "deck:" + id
+}
+
+// PlaylistKey includes the generation of the playlists, deleting a deck removes it from
+// the playlists without going through them.
+func PlaylistKey(generation, id string) string {
+	return "playlist:" + generation + ":" + id
+}
+
+// CardsKey holds every card of the deck, whoever can see them.
+func CardsKey(deckId string) string {
+	return "cards:" + deckId
+}
+
+// PublicKey holds what userId lists of a group at its generation.
+func PublicKey(group, generation, userId string) string {
+	return group + ":public:" + generation + ":" + userId
+}
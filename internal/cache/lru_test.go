@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsTheLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), 0)
+
+	_, _ = c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), 0)
+
+	_, hit := c.Get(ctx, "b")
+	assert.False(t, hit)
+	value, hit := c.Get(ctx, "a")
+	assert.True(t, hit)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRUExpiresValues(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRU(10)
+	c.now = func() time.Time { return now }
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+
+	now = now.Add(59 * time.Second)
+	_, hit := c.Get(ctx, "a")
+	assert.True(t, hit)
+
+	now = now.Add(time.Second)
+	_, hit = c.Get(ctx, "a")
+	assert.False(t, hit)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestInvalidateStartsANewGeneration(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10)
+
+	first := Generation(ctx, c, "decks")
+	assert.Equal(t, first, Generation(ctx, c, "decks"))
+
+	time.Sleep(time.Millisecond)
+	Invalidate(ctx, c, "decks")
+	assert.NotEqual(t, first, Generation(ctx, c, "decks"))
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"github.com/go-playground/validator"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	envConfigFile  = "CONFIG_FILE"
+	flagConfigFile = "config"
+)
+
+// field is a single setting of Config, addressable so every source can write to it.
+type field struct {
+	value     reflect.Value
+	namespace string
+	path      string
+	env       string
+	flag      string
+}
+
+// Load builds the configuration from the defaults, the YAML file named by -config or
+// CONFIG_FILE, the environment (a .env file is read when there is one) and the flags
+// in args, then validates it. The arguments left after the flags are returned.
+func Load(args []string) (*Config, []string, error) {
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("reading .env: %w", err)
+	}
+
+	cfg := Default()
+	fields := settings(reflect.ValueOf(&cfg).Elem(), "Config", "")
+
+	flags := flag.NewFlagSet("flashcards", flag.ContinueOnError)
+	configFile := flags.String(flagConfigFile, os.Getenv(envConfigFile), "path to a YAML configuration file")
+	byFlag := make(map[string]field)
+	for _, f := range fields {
+		if f.flag != "" {
+			flags.String(f.flag, "", fmt.Sprintf("%s, overrides %s", f.path, f.env))
+			byFlag[f.flag] = f
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		content, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading configuration file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(content, &cfg); err != nil {
+			return nil, nil, fmt.Errorf("parsing configuration file %s: %w", *configFile, err)
+		}
+	}
+
+	for _, f := range fields {
+		value, exists := os.LookupEnv(f.env)
+		if !exists || value == "" {
+			continue
+		}
+		if err := set(f.value, value); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", f.env, err)
+		}
+	}
+
+	var flagErr error
+	flags.Visit(func(fl *flag.Flag) {
+		if f, ok := byFlag[fl.Name]; ok && flagErr == nil {
+			if err := set(f.value, fl.Value.String()); err != nil {
+				flagErr = fmt.Errorf("invalid -%s: %w", fl.Name, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	cfg.Log.Level = strings.ToLower(cfg.Log.Level)
+	if err := validate(&cfg, fields); err != nil {
+		return nil, nil, err
+	}
+	// Any website could make credentialed calls with the origin reflected to it.
+	if cfg.CORS.AllowCredentials && cfg.CORS.AllowsAnyOrigin() {
+		return nil, nil, fmt.Errorf(`invalid configuration: cors.allowCredentials (CORS_ALLOW_CREDENTIALS) cannot be used with "*" in cors.allowedOrigins`)
+	}
+	return &cfg, flags.Args(), nil
+}
+
+// settings lists the leaf fields of v, the nested structs only group them.
+func settings(v reflect.Value, namespace, path string) []field {
+	result := make([]field, 0)
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		fieldPath := structField.Tag.Get("yaml")
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+		fieldNamespace := namespace + "." + structField.Name
+
+		if structField.Type.Kind() == reflect.Struct {
+			result = append(result, settings(v.Field(i), fieldNamespace, fieldPath)...)
+			continue
+		}
+		result = append(result, field{
+			value:     v.Field(i),
+			namespace: fieldNamespace,
+			path:      fieldPath,
+			env:       structField.Tag.Get("env"),
+			flag:      structField.Tag.Get("flag"),
+		})
+	}
+	return result
+}
+
+func set(v reflect.Value, value string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(duration))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported setting type %s", v.Type())
+		}
+		items := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Bool:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		v.SetBool(enabled)
+	case reflect.Int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		v.SetInt(int64(number))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func validate(cfg *Config, fields []field) error {
+	err := validator.New().Struct(cfg)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	byNamespace := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byNamespace[f.namespace] = f
+	}
+
+	messages := make([]string, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		f, exists := byNamespace[fieldError.StructNamespace()]
+		switch {
+		case !exists:
+			messages = append(messages, fmt.Sprintf("%s %s", fieldError.StructNamespace(), rule(fieldError)))
+		case f.env == "":
+			messages = append(messages, fmt.Sprintf("%s %s", f.path, rule(fieldError)))
+		default:
+			messages = append(messages, fmt.Sprintf("%s (%s) %s", f.path, f.env, rule(fieldError)))
+		}
+	}
+	return fmt.Errorf("invalid configuration: %s", strings.Join(messages, "; "))
+}
+
+func rule(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of " + fieldError.Param()
+	case "min":
+		return "must be at least " + fieldError.Param()
+	case "max":
+		return "must be at most " + fieldError.Param()
+	case "gt":
+		return "must be greater than " + fieldError.Param()
+	default:
+		return "failed on the " + fieldError.Tag() + " rule"
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "server:\n  port: 9000\n  shutdownTimeout: 5s\nmongodb:\n  database: from-file\n  address: mongodb://file\n"
+	assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0600))
+	t.Setenv("MONGODB_DATABASE", "from-env")
+	t.Setenv("LOG_LEVEL", "DEBUG")
+
+	cfg, args, err := Load([]string{"-config", file, "-port", "9100", "indexes", "status"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"indexes", "status"}, args)
+	assert.Equal(t, 9100, cfg.Server.Port)
+	assert.Equal(t, 5*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, "from-env", cfg.MongoDB.Database)
+	assert.Equal(t, "mongodb://file", cfg.MongoDB.Address)
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, "decks", cfg.MongoDB.Collections.Deck)
+}
+
+func TestLoadReportsInvalidSettings(t *testing.T) {
+	t.Setenv("MONGODB_ADDRESS", "")
+	t.Setenv("SERVER_PORT", "70000")
+	t.Setenv("LOG_LEVEL", "verbose")
+
+	_, _, err := Load(nil)
+
+	assert.EqualError(t, err, "invalid configuration: server.port (SERVER_PORT) must be at most 65535; "+
+		"log.level (LOG_LEVEL) must be one of debug info warn error panic fatal")
+}
+
+func TestLoadRejectsCredentialsForAnyOrigin(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com,*")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+	_, _, err := Load(nil)
+
+	assert.EqualError(t, err, `invalid configuration: cors.allowCredentials (CORS_ALLOW_CREDENTIALS) cannot be used with "*" in cors.allowedOrigins`)
+}
+
+func TestLoadRejectsMalformedValues(t *testing.T) {
+	t.Setenv("MONGODB_READ_TIMEOUT", "ten seconds")
+	_, _, err := Load(nil)
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("server:\n  prot: 9000\n"), 0600))
+	_, _, err = Load([]string{"-config", file})
+	assert.Error(t, err)
+}
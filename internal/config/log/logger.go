@@ -4,7 +4,6 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	defaultLog "log"
-	"os"
 	"strings"
 	"sync"
 )
@@ -14,20 +13,27 @@ var (
 	Logger *zap.SugaredLogger
 )
 
+// SetupLogger installs a production logger at info level, so Logger can be used
+// before the configuration is loaded. Configure replaces it afterwards.
 func SetupLogger() {
 	once.Do(func() {
-		log, err := newLogger()
-		if err != nil {
+		if err := Configure("production", "info"); err != nil {
 			defaultLog.Fatalf("Can't initialize logger: %v", err)
 		}
-		Logger = log.Sugar()
-		Logger.Debug("Created new logger")
 	})
 }
 
-func newLogger() (*zap.Logger, error) {
-	env := os.Getenv("ENV")
+func Configure(env, level string) error {
+	log, err := newLogger(env, level)
+	if err != nil {
+		return err
+	}
+	Logger = log.Sugar()
+	Logger.Debug("Created new logger")
+	return nil
+}
 
+func newLogger(env, level string) (*zap.Logger, error) {
 	var config zap.Config
 	if env == "development" {
 		config = zap.NewDevelopmentConfig()
@@ -35,9 +41,8 @@ func newLogger() (*zap.Logger, error) {
 		config = zap.NewProductionConfig()
 	}
 
-	if lvl, exists := os.LookupEnv("LOG_LEVEL"); exists {
-		lvl = strings.ToLower(lvl)
-		switch lvl {
+	if level != "" {
+		switch strings.ToLower(level) {
 		case "debug":
 			config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
 		case "info":
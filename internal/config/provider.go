@@ -5,4 +5,7 @@ import (
 	"github.com/google/wire"
 )
 
-var AppConfigSet = wire.NewSet(validator.NewValidate)
+var AppConfigSet = wire.NewSet(
+	validator.NewValidate,
+	wire.FieldsOf(new(*Config), "Server", "Log", "Tracing", "MongoDB", "RateLimit", "CORS", "Security", "Events", "Stream", "Sync", "GraphQL", "Webhooks"),
+)
@@ -10,11 +10,21 @@ import (
 
 func NewValidate() *validator.Validate{
 	validate := validator.New()
+	validate.RegisterTagNameFunc(jsonFieldName)
 	err := validate.RegisterValidation("rfe", requireIfFieldIsEqual)
 	panicIf(err)
 	return validate
 }
 
+// jsonFieldName reports fields by their json name, so validation errors match the payload.
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
 func requireIfFieldIsEqual(fl validator.FieldLevel) bool {
 	param := strings.Split(fl.Param(), `:`)
 	paramField := param[0]
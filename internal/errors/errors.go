@@ -1,6 +1,8 @@
 package errors
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"github.com/pkg/errors"
 )
@@ -10,6 +12,11 @@ var ErrInvalidPayload = &InvalidPayload{Err: fmt.Errorf("invalid payload")}
 var ErrInternalServer = &InternalServerError{Err: fmt.Errorf("internal server error")}
 var ErrUnauthorized = &Unauthorized{Err: fmt.Errorf("unauthorized")}
 var ErrBadRequest = &BadRequest{Err: fmt.Errorf("internal server error")}
+var ErrConflict = &Conflict{Err: fmt.Errorf("conflict")}
+var ErrVersionMismatch = &Conflict{Err: fmt.Errorf("version mismatch")}
+var ErrTimeout = &Timeout{Err: fmt.Errorf("timeout")}
+var ErrCanceled = &Canceled{Err: fmt.Errorf("canceled")}
+var ErrTooManyRequests = &TooManyRequests{Err: fmt.Errorf("too many requests")}
 
 type stackTracer interface {
 	StackTrace() errors.StackTrace
@@ -31,6 +38,19 @@ func WrapWithMessage(err error, msg string) error {
 	return errors.Wrap(err, msg)
 }
 
+// WrapCause wraps cause under the given error kind, unless cause comes from an expired
+// or canceled context: a timed out lookup is not a missing entity.
+func WrapCause(err error, cause error) error {
+	switch {
+	case stderrors.Is(cause, context.DeadlineExceeded):
+		err = ErrTimeout
+	case stderrors.Is(cause, context.Canceled):
+		err = ErrCanceled
+	}
+
+	return WrapWithMessage(err, cause.Error())
+}
+
 func Cause(err error) error {
 	return errors.Cause(err)
 }
@@ -52,3 +52,39 @@ type Unauthorized struct {
 func (e *Unauthorized) Error() string {
 	return e.Err.Error()
 }
+
+/* UnsupportedMediaType */
+type UnsupportedMediaType struct {
+	Err error
+}
+
+func (e *UnsupportedMediaType) Error() string {
+	return e.Err.Error()
+}
+
+/* Timeout */
+type Timeout struct {
+	Err error
+}
+
+func (e *Timeout) Error() string {
+	return e.Err.Error()
+}
+
+/* Canceled */
+type Canceled struct {
+	Err error
+}
+
+func (e *Canceled) Error() string {
+	return e.Err.Error()
+}
+
+/* TooManyRequests */
+type TooManyRequests struct {
+	Err error
+}
+
+func (e *TooManyRequests) Error() string {
+	return e.Err.Error()
+}
@@ -0,0 +1,199 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Index is an index a repository needs. Keys take 1, -1 or "text" values.
+type Index struct {
+	Collection string
+	Name       string
+	Keys       bson.D
+	Unique     bool
+	// ExpireAfter makes it a TTL index: documents are removed that long after the date in its single key.
+	ExpireAfter time.Duration
+}
+
+// IndexRegistry is every index declared by the repositories.
+type IndexRegistry []Index
+
+// IndexDrift is a difference between the declared indexes and the ones in the database.
+type IndexDrift struct {
+	Collection string
+	Name       string
+	Problem    string
+}
+
+const (
+	DriftMissing     = "missing"
+	DriftKeys        = "different keys"
+	DriftOptions     = "different options"
+	DriftUndeclared  = "not declared"
+	defaultIndexName = "_id_"
+)
+
+// storedIndex is an entry of listIndexes, decoded into a struct so the keys keep their order.
+type storedIndex struct {
+	Name               string      `bson:"name"`
+	Key                bson.D      `bson:"key"`
+	Unique             bool        `bson:"unique"`
+	ExpireAfterSeconds interface{} `bson:"expireAfterSeconds"`
+}
+
+type IndexManager struct {
+	client   *mongo.Client
+	database string
+	indexes  IndexRegistry
+
+	mu  sync.RWMutex
+	err error
+}
+
+func NewIndexManager(mongoClient *mongo.Client, cfg config.MongoDB, indexes IndexRegistry) *IndexManager {
+	return &IndexManager{client: mongoClient, database: cfg.Database, indexes: indexes}
+}
+
+// Ensure creates the missing indexes. An index that exists with other keys or options
+// is left alone and makes Ensure fail, it has to be dropped by hand first. The
+// outcome is kept for Check.
+func (m *IndexManager) Ensure(ctx context.Context) error {
+	err := m.ensure(ctx)
+
+	m.mu.Lock()
+	m.err = err
+	m.mu.Unlock()
+	return err
+}
+
+func (m *IndexManager) ensure(ctx context.Context) error {
+	for collection, indexes := range m.byCollection() {
+		models := make([]mongo.IndexModel, 0, len(indexes))
+		for _, index := range indexes {
+			models = append(models, index.model())
+		}
+
+		names, err := m.client.Database(m.database).Collection(collection).Indexes().CreateMany(ctx, models)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Create indexes has failed", "collection", collection, "error", err.Error())
+			return fmt.Errorf("creating indexes on %s: %w", collection, err)
+		}
+		log.FromContext(ctx).Infow("Indexes ensured", "collection", collection, "indexes", names)
+	}
+	return nil
+}
+
+// Check reports the error of the last Ensure, readiness fails while indexes are missing.
+func (m *IndexManager) Check(_ context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.err
+}
+
+// Drift compares the declared indexes with the ones in the database.
+func (m *IndexManager) Drift(ctx context.Context) ([]IndexDrift, error) {
+	drift := make([]IndexDrift, 0)
+	for collection, indexes := range m.byCollection() {
+		cursor, err := m.client.Database(m.database).Collection(collection).Indexes().List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing indexes of %s: %w", collection, err)
+		}
+		var existing []storedIndex
+		if err := cursor.All(ctx, &existing); err != nil {
+			return nil, fmt.Errorf("listing indexes of %s: %w", collection, err)
+		}
+
+		drift = append(drift, compare(collection, indexes, existing)...)
+	}
+	return drift, nil
+}
+
+func (m *IndexManager) byCollection() map[string][]Index {
+	result := make(map[string][]Index)
+	for _, index := range m.indexes {
+		result[index.Collection] = append(result[index.Collection], index)
+	}
+	return result
+}
+
+func (index Index) model() mongo.IndexModel {
+	indexOptions := options.Index().SetName(index.Name)
+	if index.Unique {
+		indexOptions.SetUnique(true)
+	}
+	if index.ExpireAfter > 0 {
+		indexOptions.SetExpireAfterSeconds(int32(index.ExpireAfter.Seconds()))
+	}
+	return mongo.IndexModel{Keys: index.Keys, Options: indexOptions}
+}
+
+func (index Index) text() bool {
+	for _, key := range index.Keys {
+		if key.Value == "text" {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(collection string, declared []Index, existing []storedIndex) []IndexDrift {
+	drift := make([]IndexDrift, 0)
+	byName := make(map[string]storedIndex, len(existing))
+	for _, index := range existing {
+		byName[index.Name] = index
+	}
+
+	for _, index := range declared {
+		current, exists := byName[index.Name]
+		delete(byName, index.Name)
+		switch {
+		case !exists:
+			drift = append(drift, IndexDrift{Collection: collection, Name: index.Name, Problem: DriftMissing})
+		// The server stores text indexes under internal keys, they are matched by name only.
+		case !index.text() && !sameKeys(index.Keys, current.Key):
+			drift = append(drift, IndexDrift{Collection: collection, Name: index.Name, Problem: DriftKeys})
+		case index.Unique != current.Unique || int64(index.ExpireAfter.Seconds()) != number(current.ExpireAfterSeconds):
+			drift = append(drift, IndexDrift{Collection: collection, Name: index.Name, Problem: DriftOptions})
+		}
+	}
+
+	for name := range byName {
+		if name != defaultIndexName {
+			drift = append(drift, IndexDrift{Collection: collection, Name: name, Problem: DriftUndeclared})
+		}
+	}
+	return drift
+}
+
+func sameKeys(declared bson.D, keys bson.D) bool {
+	if len(keys) != len(declared) {
+		return false
+	}
+	for i := range declared {
+		if declared[i].Key != keys[i].Key || number(declared[i].Value) != number(keys[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// number reads the numbers the server returns as int32, int64 or double alike.
+func number(value interface{}) int64 {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Float32, reflect.Float64:
+		return int64(v.Float())
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,33 @@
+package mongodb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+func TestCompareIndexes(t *testing.T) {
+	declared := []Index{
+		{Collection: "cards", Name: "deckId", Keys: bson.D{{Key: "deckId", Value: 1}}},
+		{Collection: "cards", Name: "userId_lastUpdate", Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastUpdate", Value: -1}}},
+		{Collection: "cards", Name: "lastUpdate_ttl", Keys: bson.D{{Key: "lastUpdate", Value: 1}}, ExpireAfter: time.Hour},
+		{Collection: "cards", Name: "missing", Keys: bson.D{{Key: "color", Value: 1}}},
+	}
+	existing := []storedIndex{
+		{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}},
+		{Name: "deckId", Key: bson.D{{Key: "deckId", Value: int32(1)}}},
+		{Name: "userId_lastUpdate", Key: bson.D{{Key: "lastUpdate", Value: int32(-1)}, {Key: "userId", Value: int32(1)}}},
+		{Name: "lastUpdate_ttl", Key: bson.D{{Key: "lastUpdate", Value: float64(1)}}, ExpireAfterSeconds: int32(60)},
+		{Name: "manual", Key: bson.D{{Key: "name", Value: int32(1)}}},
+	}
+
+	drift := compare("cards", declared, existing)
+
+	assert.ElementsMatch(t, []IndexDrift{
+		{Collection: "cards", Name: "userId_lastUpdate", Problem: DriftKeys},
+		{Collection: "cards", Name: "lastUpdate_ttl", Problem: DriftOptions},
+		{Collection: "cards", Name: "missing", Problem: DriftMissing},
+		{Collection: "cards", Name: "manual", Problem: DriftUndeclared},
+	}, drift)
+}
@@ -2,11 +2,13 @@ package mongodb
 
 import (
 	"errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 	"golang.org/x/net/context"
-	"os"
 	"time"
 )
 
@@ -15,19 +17,26 @@ const (
 	ASC  = 1
 )
 
-func NewMongoDbClient() (*mongo.Client, error) {
-	// Set client options
-	address := os.Getenv("MONGODB_ADDRESS")
-	username := os.Getenv("MONGODB_USERNAME")
-	password := os.Getenv("MONGODB_PASSWORD")
+// VersionFilter matches documents at the given version. Documents stored
+// before versioning have no version field and count as version 0.
+func VersionFilter(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
 
-	clientOptions := options.Client().ApplyURI(address)
+// NewMongoDbClient connects to MongoDB, the returned func disconnects it once the
+// server has drained.
+func NewMongoDbClient(cfg config.MongoDB) (*mongo.Client, func(), error) {
+	// Set client options
+	clientOptions := options.Client().ApplyURI(cfg.Address).SetMonitor(otelmongo.NewMonitor())
 
-	if username != "" && password != "" {
+	if cfg.Username != "" && cfg.Password != "" {
 		clientOptions.
 			SetAuth(options.Credential{
-				Username: username,
-				Password: password,
+				Username: cfg.Username,
+				Password: cfg.Password,
 			})
 	}
 
@@ -35,7 +44,7 @@ func NewMongoDbClient() (*mongo.Client, error) {
 
 	if err != nil {
 		log.Logger.Errorw("Error on create MongoDB Client", "error", err.Error())
-		return &mongo.Client{}, errors.New("Error on create MongoDB Client: " + err.Error())
+		return &mongo.Client{}, nil, errors.New("Error on create MongoDB Client: " + err.Error())
 	}
 
 	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
@@ -43,15 +52,22 @@ func NewMongoDbClient() (*mongo.Client, error) {
 
 	if err != nil {
 		log.Logger.Errorw("Connect failed: connection was not established", "error", err.Error())
-		return &mongo.Client{}, errors.New("Error to create MongoDB connection: " + err.Error())
+		return &mongo.Client{}, nil, errors.New("Error to create MongoDB connection: " + err.Error())
 	}
 
 	// Check the connection
 	err = client.Ping(ctx, nil)
 	if err != nil {
 		log.Logger.Errorw("Ping failed: connection was not established", "error", err.Error())
-		return &mongo.Client{}, errors.New("Error on check MongoDB connection: " + err.Error())
+		return &mongo.Client{}, nil, errors.New("Error on check MongoDB connection: " + err.Error())
 	}
 
-	return client, nil
+	cleanup := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := client.Disconnect(ctx); err != nil {
+			log.Logger.Errorw("Disconnect from MongoDB has failed", "error", err.Error())
+		}
+	}
+	return client, cleanup, nil
 }
@@ -0,0 +1,116 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/migration"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"time"
+)
+
+const migrationsCollection = "migrations"
+
+// Migration changes the stored documents from one shape to the next. Up must be
+// idempotent, it only touches the documents still in the old shape, so a migration
+// interrupted half way can run again. With dryRun it counts them instead.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database, dryRun bool) (changed int64, err error)
+}
+
+// Migrations is every migration, in any order, Migrator sorts them by version.
+type Migrations []Migration
+
+type Migrator struct {
+	client     *mongo.Client
+	database   string
+	migrations Migrations
+}
+
+func NewMigrator(mongoClient *mongo.Client, cfg config.MongoDB, migrations Migrations) *Migrator {
+	sorted := make(Migrations, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	return &Migrator{client: mongoClient, database: cfg.Database, migrations: sorted}
+}
+
+// Status lists every migration with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]migration.State, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]migration.State, 0, len(m.migrations))
+	for _, step := range m.migrations {
+		state, exists := applied[step.Version]
+		if !exists {
+			state = migration.State{Version: step.Version, Description: step.Description}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// Up applies the pending migrations in version order and stops at the first failure.
+// With dryRun nothing is written, the states tell how many documents each would change.
+func (m *Migrator) Up(ctx context.Context, dryRun bool) ([]migration.State, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := m.client.Database(m.database)
+	result := make([]migration.State, 0)
+	for _, step := range m.migrations {
+		if _, exists := applied[step.Version]; exists {
+			continue
+		}
+
+		changed, err := step.Up(ctx, db, dryRun)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Migration has failed", "version", step.Version, "error", err.Error())
+			return result, fmt.Errorf("migration %d (%s): %w", step.Version, step.Description, err)
+		}
+
+		state := migration.State{Version: step.Version, Description: step.Description, Changed: changed}
+		if !dryRun {
+			now := time.Now()
+			state.AppliedAt = &now
+			// Another instance may have applied it meanwhile, Up being idempotent that is fine.
+			_, err = db.Collection(migrationsCollection).InsertOne(ctx, state)
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				return result, fmt.Errorf("recording migration %d: %w", step.Version, err)
+			}
+			log.FromContext(ctx).Infow("Migration applied", "version", step.Version, "changed", changed)
+		}
+		result = append(result, state)
+	}
+	return result, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]migration.State, error) {
+	cursor, err := m.client.Database(m.database).Collection(migrationsCollection).
+		Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: ASC}}))
+	if err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+
+	var states []migration.State
+	if err := cursor.All(ctx, &states); err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+
+	applied := make(map[int]migration.State, len(states))
+	for _, state := range states {
+		applied[state.Version] = state
+	}
+	return applied, nil
+}
@@ -0,0 +1,20 @@
+package mongodb
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewMigratorOrdersByVersion(t *testing.T) {
+	migrations := Migrations{{Version: 3}, {Version: 1}, {Version: 2}}
+
+	migrator := NewMigrator(nil, config.MongoDB{Database: "flashcards"}, migrations)
+
+	versions := make([]int, 0)
+	for _, migration := range migrator.migrations {
+		versions = append(versions, migration.Version)
+	}
+	assert.Equal(t, []int{1, 2, 3}, versions)
+	assert.Equal(t, 3, migrations[0].Version, "the given list is left as it is")
+}
@@ -1,5 +0,0 @@
-package mongodb
-
-import "github.com/google/wire"
-
-var MongoDatabaseSet = wire.NewSet(NewMongoDbClient)
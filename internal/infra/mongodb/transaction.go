@@ -0,0 +1,64 @@
+package mongodb
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/net/context"
+	"time"
+)
+
+type TransactionManager struct {
+	client    *mongo.Client
+	supported bool
+	timeout   time.Duration
+}
+
+// NewTransactionManager checks whether the deployment accepts multi-document
+// transactions. Standalone servers (like the docker-compose one) do not, so
+// the callback runs without a session there; single document $inc updates
+// remain atomic on their own.
+func NewTransactionManager(mongoClient *mongo.Client, cfg config.MongoDB) TransactionManager {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var hello bson.M
+	err := mongoClient.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+	if err != nil {
+		log.Logger.Warnw("Could not detect transaction support", "error", err.Error())
+	}
+
+	_, isReplicaSet := hello["setName"]
+	supported := isReplicaSet || hello["msg"] == "isdbgrid"
+	if !supported {
+		log.Logger.Warn("MongoDB deployment does not support transactions, running writes without them")
+	}
+
+	return TransactionManager{
+		client:    mongoClient,
+		supported: supported,
+		timeout:   cfg.Timeouts.Transaction,
+	}
+}
+
+func (t TransactionManager) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	if !t.supported {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		log.FromContext(ctx).Errorw("Start session has failed", "error", err.Error())
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
@@ -0,0 +1,43 @@
+package sqldb
+
+import (
+	"errors"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect holds what differs between the SQL databases the repositories run on. The
+// statements are written with $n parameters, which every supported driver accepts.
+type Dialect struct {
+	Name   string
+	Driver string
+	// Matches is the condition of column matching the regular expression in param,
+	// ignoring case, as the MongoDB searches do. It is nil when the database has no
+	// regular expressions, the repositories then match the rows they read.
+	Matches func(column, param string) string
+	// IsForeignKeyViolation tells whether err is a write referencing a missing row.
+	IsForeignKeyViolation func(err error) bool
+}
+
+var Postgres = Dialect{
+	Name:   "postgres",
+	Driver: "postgres",
+	Matches: func(column, param string) string {
+		return column + " ~* " + param
+	},
+	IsForeignKeyViolation: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23503"
+	},
+}
+
+// SQLite runs in the process on a local file, see OpenSQLite.
+var SQLite = Dialect{
+	Name:   "sqlite",
+	Driver: "sqlite",
+	IsForeignKeyViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	},
+}
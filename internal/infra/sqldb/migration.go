@@ -0,0 +1,203 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/migration"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version     INTEGER PRIMARY KEY,
+	description TEXT      NOT NULL,
+	applied_at  TIMESTAMP NOT NULL,
+	changed     BIGINT    NOT NULL
+)`
+
+// fileName is a migration file, its version and a description with underscores.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.sql$`)
+
+type sqlMigration struct {
+	version     int
+	description string
+	statements  string
+}
+
+// Migrator applies the SQL files of a directory, named like 0001_create_tables.sql, in
+// version order. Each file runs in a transaction together with its record in
+// schema_migrations, so a failed one leaves nothing behind.
+type Migrator struct {
+	db         *sql.DB
+	migrations []sqlMigration
+}
+
+// NewMigrator reads the migrations in files, a wrong file name is an error so a
+// misnamed migration is not silently skipped.
+func NewMigrator(db *sql.DB, files fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	migrations := make([]sqlMigration, 0, len(entries))
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named like 0001_description.sql", entry.Name())
+		}
+		content, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", entry.Name(), err)
+		}
+		version, _ := strconv.Atoi(match[1])
+		migrations = append(migrations, sqlMigration{
+			version:     version,
+			description: strings.ReplaceAll(match[2], "_", " "),
+			statements:  string(content),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Status lists every migration with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]migration.State, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]migration.State, 0, len(m.migrations))
+	for _, step := range m.migrations {
+		state, exists := applied[step.version]
+		if !exists {
+			state = migration.State{Version: step.version, Description: step.description}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// Up applies the pending migrations in version order and stops at the first failure.
+// With dryRun each one runs and is rolled back, the states tell how many rows its last
+// statement changed.
+func (m *Migrator) Up(ctx context.Context, dryRun bool) ([]migration.State, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]migration.State, 0)
+	for _, step := range m.migrations {
+		if _, exists := applied[step.version]; exists {
+			continue
+		}
+
+		state, err := m.apply(ctx, step, dryRun)
+		if err != nil {
+			// Another instance may have applied it meanwhile, its record says so.
+			if again, readErr := m.applied(ctx); readErr == nil {
+				if _, exists := again[step.version]; exists {
+					continue
+				}
+			}
+			log.FromContext(ctx).Errorw("Migration has failed", "version", step.version, "error", err.Error())
+			return result, fmt.Errorf("migration %d (%s): %w", step.version, step.description, err)
+		}
+		if !dryRun {
+			log.FromContext(ctx).Infow("Migration applied", "version", step.version, "changed", state.Changed)
+		}
+		result = append(result, state)
+	}
+	return result, nil
+}
+
+// Check fails while migrations are pending, the schema is not the one the repositories use.
+func (m *Migrator) Check(ctx context.Context) error {
+	states, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations are pending", pending)
+	}
+	return nil
+}
+
+// apply records the migration first, so a concurrent run of the same version waits on
+// the record and then fails instead of running the statements twice.
+func (m *Migrator) apply(ctx context.Context, step sqlMigration, dryRun bool) (migration.State, error) {
+	state := migration.State{Version: step.version, Description: step.description}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return state, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	appliedAt := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, description, applied_at, changed) VALUES ($1, $2, $3, 0)",
+		step.version, step.description, appliedAt)
+	if err != nil {
+		return state, err
+	}
+
+	result, err := tx.ExecContext(ctx, step.statements)
+	if err != nil {
+		return state, err
+	}
+	if state.Changed, err = result.RowsAffected(); err != nil {
+		state.Changed = 0
+	}
+
+	if dryRun {
+		return state, nil
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE schema_migrations SET changed = $1 WHERE version = $2", state.Changed, step.version)
+	if err != nil {
+		return state, err
+	}
+	if err = tx.Commit(); err != nil {
+		return state, err
+	}
+	state.AppliedAt = &appliedAt
+	return state, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]migration.State, error) {
+	if _, err := m.db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, description, applied_at, changed FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]migration.State)
+	for rows.Next() {
+		var state migration.State
+		var appliedAt time.Time
+		if err := rows.Scan(&state.Version, &state.Description, &appliedAt, &state.Changed); err != nil {
+			return nil, fmt.Errorf("reading applied migrations: %w", err)
+		}
+		state.AppliedAt = &appliedAt
+		applied[state.Version] = state
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,35 @@
+package sqldb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewMigratorReadsFilesInVersionOrder(t *testing.T) {
+	files := fstest.MapFS{
+		"0002_add_cards.sql":     {Data: []byte("CREATE TABLE cards (id TEXT)")},
+		"0001_create_tables.sql": {Data: []byte("CREATE TABLE decks (id TEXT)")},
+	}
+
+	migrator, err := NewMigrator(nil, files)
+
+	assert.NoError(t, err)
+	assert.Len(t, migrator.migrations, 2)
+	assert.Equal(t, 1, migrator.migrations[0].version)
+	assert.Equal(t, "create tables", migrator.migrations[0].description)
+	assert.Equal(t, "CREATE TABLE cards (id TEXT)", migrator.migrations[1].statements)
+}
+
+func TestNewMigratorRejectsMisnamedFiles(t *testing.T) {
+	files := fstest.MapFS{"create_tables.sql": {Data: []byte("CREATE TABLE decks (id TEXT)")}}
+
+	_, err := NewMigrator(nil, files)
+
+	assert.Error(t, err)
+}
+
+func TestPlaceholders(t *testing.T) {
+	assert.Equal(t, "$3, $4, $5", Placeholders(3, 3))
+	assert.Equal(t, "", Placeholders(1, 0))
+}
@@ -0,0 +1,69 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"strings"
+	"time"
+)
+
+// Queryer runs statements, it is the database or the transaction in the context.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Scanner is a *sql.Row or *sql.Rows, so one function reads a row of either.
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type txKey struct{}
+
+// Conn returns the transaction started by TransactionManager in ctx, or db outside of one.
+func Conn(ctx context.Context, db *sql.DB) Queryer {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// Open connects to the database and checks the connection, the returned func closes
+// it once the server has drained.
+func Open(dialect Dialect, dsn string, maxOpenConns, maxIdleConns int, connMaxLifetime time.Duration) (*sql.DB, func(), error) {
+	db, err := sql.Open(dialect.Driver, dsn)
+	if err != nil {
+		log.Logger.Errorw("Error on create database client", "driver", dialect.Driver, "error", err.Error())
+		return nil, nil, fmt.Errorf("error on create %s client: %w", dialect.Name, err)
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(connMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		log.Logger.Errorw("Ping failed: connection was not established", "driver", dialect.Driver, "error", err.Error())
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("error on check %s connection: %w", dialect.Name, err)
+	}
+
+	cleanup := func() {
+		if err := db.Close(); err != nil {
+			log.Logger.Errorw("Closing the database has failed", "error", err.Error())
+		}
+	}
+	return db, cleanup, nil
+}
+
+// Placeholders returns count parameters starting at $from, as in "$3, $4, $5".
+func Placeholders(from, count int) string {
+	placeholders := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", from+i))
+	}
+	return strings.Join(placeholders, ", ")
+}
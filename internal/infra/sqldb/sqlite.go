@@ -0,0 +1,49 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"net/url"
+	"os"
+	"time"
+)
+
+// OpenSQLite opens the database file at path, creating it when missing. A single
+// connection is kept: SQLite has one writer at a time and ":memory:" is a different
+// database on every connection. The foreign keys are off unless enabled per connection.
+func OpenSQLite(path string, busyTimeout time.Duration) (*sql.DB, func(), error) {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	if path != ":memory:" {
+		query.Add("_pragma", "journal_mode(WAL)")
+	}
+	// Times are written in a format that sorts like the times themselves.
+	query.Set("_time_format", "sqlite")
+
+	return Open(SQLite, "file:"+path+"?"+query.Encode(), 1, 1, 0)
+}
+
+// SQLiteBackup copies a SQLite database while the server keeps using it.
+type SQLiteBackup struct {
+	db *sql.DB
+}
+
+func NewSQLiteBackup(db *sql.DB) SQLiteBackup {
+	return SQLiteBackup{db: db}
+}
+
+// Backup writes a consistent copy of the database to path, an existing file is never
+// overwritten.
+func (b SQLiteBackup) Backup(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup %s already exists", path)
+	}
+	if _, err := b.db.ExecContext(ctx, "VACUUM INTO $1", path); err != nil {
+		log.FromContext(ctx).Errorw("Backup has failed", "path", path, "error", err.Error())
+		return fmt.Errorf("backup to %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package sqldb
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStatementsKeepTheCancellationOfTheirContext(t *testing.T) {
+	db, closeDB, err := OpenSQLite(":memory:", time.Second)
+	require.NoError(t, err)
+	defer closeDB()
+
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err = db.ExecContext(ctx, "SELECT 1")
+		cancel()
+		require.NoError(t, err, "a context done after its statement does not fail the next one")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n")
+	assert.Error(t, err, "the deadline stops a statement that runs too long")
+}
@@ -0,0 +1,42 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"time"
+)
+
+type TransactionManager struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewTransactionManager(db *sql.DB, timeout time.Duration) TransactionManager {
+	return TransactionManager{db: db, timeout: timeout}
+}
+
+// WithTransaction commits what fn wrote when it returns nil and rolls it back otherwise.
+// Within fn the repositories find the transaction through Conn.
+func (t TransactionManager) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Begin transaction has failed", "error", err.Error())
+		return err
+	}
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.FromContext(ctx).Errorw("Rollback has failed", "error", rollbackErr.Error())
+		}
+		return err
+	}
+	return tx.Commit()
+}
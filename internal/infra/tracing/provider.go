@@ -0,0 +1,5 @@
+package tracing
+
+import "github.com/google/wire"
+
+var TracingSet = wire.NewSet(NewTracerProvider)
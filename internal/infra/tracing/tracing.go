@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ServiceName         = "flashcards"
+	instrumentationName = "github.com/Guilhermemzlima/FlashCardsBackEnd"
+)
+
+// NewTracerProvider registers the global tracer provider and the W3C trace-context propagator.
+// Spans are exported over OTLP/HTTP when cfg has an endpoint, otherwise they are only used to
+// propagate trace ids. The returned func flushes pending spans on shutdown.
+func NewTracerProvider(cfg config.Tracing) (*sdktrace.TracerProvider, func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceNameKey.String(ServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		log.Logger.Errorw("Error on create tracing resource", "error", err.Error())
+		return nil, nil, err
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	exporting := false
+	if cfg.Endpoint != "" {
+		exporter, err := otlptracehttp.New(ctx, exporterOptions(cfg.Endpoint)...)
+		if err != nil {
+			log.Logger.Errorw("Error on create OTLP exporter", "error", err.Error())
+			return nil, nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+		exporting = true
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	// Without a span processor Shutdown has nothing to flush and only reports an error.
+	cleanup := func() {
+		if !exporting {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Logger.Errorw("Error on shutdown tracer provider", "error", err.Error())
+		}
+	}
+	return provider, cleanup, nil
+}
+
+// exporterOptions sends the spans to the traces path under endpoint, as the collector
+// expects of a base URL.
+func exporterOptions(endpoint string) []otlptracehttp.Option {
+	// The config validation has checked it is a URL.
+	u, _ := url.Parse(endpoint)
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + "/v1/traces"),
+	}
+	if u.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	return options
+}
+
+// Start opens a span named after the calling use-case method.
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name)
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strconv"
+	"time"
+)
+
+const namespace = "flashcards"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongodb_operation_duration_seconds",
+		Help:      "MongoDB latency by repository method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 10},
+	}, []string{"repository", "operation"})
+
+	mongoErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongodb_operation_errors_total",
+		Help:      "MongoDB errors by repository method, documents not found are not errors.",
+	}, []string{"repository", "operation"})
+
+	sqlDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sql_operation_duration_seconds",
+		Help:      "SQL database latency by repository method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 10},
+	}, []string{"repository", "operation"})
+
+	sqlErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sql_operation_errors_total",
+		Help:      "SQL database errors by repository method, rows not found are not errors.",
+	}, []string{"repository", "operation"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Repository cache lookups by cached entity and result, hit or miss.",
+	}, []string{"entity", "result"})
+
+	ReviewsStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reviews_started_total",
+		Help:      "Review sessions started by origin type.",
+	}, []string{"origin"})
+
+	AnswersRecorded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "review_answers_total",
+		Help:      "Answers recorded in review sessions by result.",
+	}, []string{"result"})
+
+	CardsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cards_created_total",
+		Help:      "Cards created, one by one or imported.",
+	})
+
+	WebhookAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_attempts_total",
+		Help:      "Attempts to post an event to a webhook by event and outcome: succeeded, retried or failed.",
+	}, []string{"event", "outcome"})
+
+	EventsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_handled_total",
+		Help:      "Domain events handed to a subscriber by type, subscriber and outcome: succeeded, retried or failed.",
+	}, []string{"type", "subscriber", "outcome"})
+
+	StreamsOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_streams_open",
+		Help:      "Clients listening to the events of their user.",
+	})
+)
+
+// ObserveHTTP records a served request.
+func ObserveHTTP(route, method string, status int, start time.Time) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveMongo records a repository call, it is meant to be deferred with the named error result:
+//
+//	defer metrics.ObserveMongo("deck", "FindById", time.Now(), &err)
+func ObserveMongo(repository, operation string, start time.Time, err *error) {
+	mongoDuration.WithLabelValues(repository, operation).Observe(time.Since(start).Seconds())
+	if err != nil && *err != nil && !errors.Is(*err, mongo.ErrNoDocuments) {
+		mongoErrors.WithLabelValues(repository, operation).Inc()
+	}
+}
+
+// ObserveSQL records a call of a SQL repository, deferred like ObserveMongo.
+func ObserveSQL(repository, operation string, start time.Time, err *error) {
+	sqlDuration.WithLabelValues(repository, operation).Observe(time.Since(start).Seconds())
+	if err != nil && *err != nil && !errors.Is(*err, sql.ErrNoRows) {
+		sqlErrors.WithLabelValues(repository, operation).Inc()
+	}
+}
+
+// ObserveCache records a lookup in the repository cache.
+func ObserveCache(entity string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(entity, result).Inc()
+}
+
+// Answer is the result label of AnswersRecorded.
+func Answer(isRight bool) string {
+	if isRight {
+		return "right"
+	}
+	return "wrong"
+}
@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"context"
+	"time"
+)
+
+// State is a migration and when it was applied, AppliedAt is nil while it is pending.
+// Changed counts the documents or rows it changed.
+type State struct {
+	Version     int        `bson:"_id"`
+	Description string     `bson:"description"`
+	AppliedAt   *time.Time `bson:"appliedAt"`
+	Changed     int64      `bson:"changed"`
+}
+
+// Migrator applies the versioned changes of a storage in order. With dryRun Up writes
+// nothing and the states tell what each pending migration would change.
+type Migrator interface {
+	Status(ctx context.Context) ([]State, error)
+	Up(ctx context.Context, dryRun bool) ([]State, error)
+}
@@ -0,0 +1,101 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	jsonpatch "github.com/evanphx/json-patch"
+	"mime"
+	"reflect"
+)
+
+const (
+	ContentTypeJSON       = "application/json"
+	ContentTypeMergePatch = "application/merge-patch+json"
+	ContentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// Apply applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to
+// original, according to contentType, and decodes the result into target.
+// Plain application/json bodies are handled as merge patches. The patch may not
+// change any of the readOnly JSON fields.
+func Apply(contentType string, original interface{}, document []byte, target interface{}, readOnly ...string) error {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+	}
+
+	patchedJSON, err := apply(contentType, originalJSON, document)
+	if err != nil {
+		return err
+	}
+
+	if err = checkReadOnly(originalJSON, patchedJSON, readOnly); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patchedJSON))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(target); err != nil {
+		return &errors.InvalidPayload{Err: fmt.Errorf("patched document is invalid: %v", err)}
+	}
+	return nil
+}
+
+func apply(contentType string, originalJSON, document []byte) ([]byte, error) {
+	mediaType := ContentTypeJSON
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, &errors.UnsupportedMediaType{Err: fmt.Errorf("invalid content type %q", contentType)}
+		}
+		mediaType = parsed
+	}
+
+	switch mediaType {
+	case ContentTypeJSON, ContentTypeMergePatch:
+		if !json.Valid(document) || bytes.TrimSpace(document)[0] != '{' {
+			return nil, &errors.BadRequest{Err: fmt.Errorf("merge patch must be a JSON object")}
+		}
+		patched, err := jsonpatch.MergePatch(originalJSON, document)
+		if err != nil {
+			return nil, &errors.BadRequest{Err: fmt.Errorf("invalid merge patch: %v", err)}
+		}
+		return patched, nil
+	case ContentTypeJSONPatch:
+		operations, err := jsonpatch.DecodePatch(document)
+		if err != nil {
+			return nil, &errors.BadRequest{Err: fmt.Errorf("invalid json patch: %v", err)}
+		}
+		patched, err := operations.Apply(originalJSON)
+		if err != nil {
+			return nil, &errors.InvalidPayload{Err: fmt.Errorf("json patch cannot be applied: %v", err)}
+		}
+		return patched, nil
+	default:
+		return nil, &errors.UnsupportedMediaType{Err: fmt.Errorf("content type %q is not supported, use %s or %s",
+			mediaType, ContentTypeMergePatch, ContentTypeJSONPatch)}
+	}
+}
+
+func checkReadOnly(originalJSON, patchedJSON []byte, readOnly []string) error {
+	if len(readOnly) == 0 {
+		return nil
+	}
+
+	var original, patched map[string]interface{}
+	if err := json.Unmarshal(originalJSON, &original); err != nil {
+		return errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+	}
+	if err := json.Unmarshal(patchedJSON, &patched); err != nil {
+		return &errors.InvalidPayload{Err: fmt.Errorf("patched document must be a JSON object")}
+	}
+
+	for _, field := range readOnly {
+		if !reflect.DeepEqual(original[field], patched[field]) {
+			return &errors.InvalidPayload{Err: fmt.Errorf("%s is read-only", field)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package patch
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type document struct {
+	Name      string   `json:"name"`
+	IsPrivate bool     `json:"isPrivate"`
+	Tags      []string `json:"tags"`
+	UserId    string   `json:"userId"`
+}
+
+var original = document{Name: "deck", IsPrivate: true, Tags: []string{"a", "b"}, UserId: "user"}
+
+func TestApplyMergePatchSetsZeroValues(t *testing.T) {
+	var result document
+	err := Apply(ContentTypeMergePatch, original, []byte(`{"isPrivate":false,"tags":[]}`), &result, "userId")
+
+	assert.Nil(t, err)
+	assert.False(t, result.IsPrivate)
+	assert.Empty(t, result.Tags)
+	assert.Equal(t, "deck", result.Name)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	var result document
+	err := Apply(ContentTypeJSONPatch, original,
+		[]byte(`[{"op":"replace","path":"/isPrivate","value":false},{"op":"remove","path":"/tags/0"}]`), &result, "userId")
+
+	assert.Nil(t, err)
+	assert.False(t, result.IsPrivate)
+	assert.Equal(t, []string{"b"}, result.Tags)
+}
+
+func TestApplyRejectsReadOnlyFields(t *testing.T) {
+	var result document
+	err := Apply(ContentTypeMergePatch, original, []byte(`{"userId":"other"}`), &result, "userId")
+	assert.IsType(t, &errors.InvalidPayload{}, err)
+
+	err = Apply(ContentTypeJSONPatch, original, []byte(`[{"op":"remove","path":"/userId"}]`), &result, "userId")
+	assert.IsType(t, &errors.InvalidPayload{}, err)
+}
+
+func TestApplyRejectsUnknownFields(t *testing.T) {
+	var result document
+	err := Apply(ContentTypeMergePatch, original, []byte(`{"cardsCount":3}`), &result)
+	assert.IsType(t, &errors.InvalidPayload{}, err)
+}
+
+func TestApplyRejectsMalformedPatches(t *testing.T) {
+	var result document
+	err := Apply(ContentTypeMergePatch, original, []byte(`[]`), &result)
+	assert.IsType(t, &errors.BadRequest{}, err)
+
+	err = Apply(ContentTypeJSONPatch, original, []byte(`{}`), &result)
+	assert.IsType(t, &errors.BadRequest{}, err)
+
+	err = Apply(ContentTypeJSONPatch, original, []byte(`[{"op":"replace","path":"/missing","value":1}]`), &result)
+	assert.IsType(t, &errors.InvalidPayload{}, err)
+}
+
+func TestApplyRejectsUnsupportedContentType(t *testing.T) {
+	var result document
+	err := Apply("text/plain", original, []byte(`{}`), &result)
+	assert.IsType(t, &errors.UnsupportedMediaType{}, err)
+}
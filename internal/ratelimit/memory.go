@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepEvery = 1000
+
+type bucket struct {
+	// tat is the theoretical arrival time of the next request (GCRA), the bucket is
+	// full once it is in the past.
+	tat time.Time
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	interval := policy.interval()
+	capacity := interval * time.Duration(policy.Burst)
+
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{tat: now}
+		s.buckets[key] = b
+	}
+
+	tat := b.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+
+	result := Result{Limit: policy.Burst}
+	if next.Sub(now) > capacity {
+		result.RetryAfter = next.Sub(now) - capacity
+		result.Reset = tat.Sub(now)
+		return result, nil
+	}
+
+	b.tat = next
+	result.Allowed = true
+	result.Reset = next.Sub(now)
+	result.Remaining = int((capacity - result.Reset) / interval)
+	return result, nil
+}
+
+// sweep drops the buckets that refilled completely, they behave like new ones anyway.
+func (s *MemoryStore) sweep(now time.Time) {
+	s.takes++
+	if s.takes < sweepEvery {
+		return
+	}
+	s.takes = 0
+	for key, b := range s.buckets {
+		if b.tat.Before(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
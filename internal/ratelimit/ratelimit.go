@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Policy is a token bucket: Burst requests at once, refilled at Requests per Period.
+type Policy struct {
+	Requests int           `yaml:"requests" validate:"min=1"`
+	Period   time.Duration `yaml:"period" validate:"gt=0"`
+	Burst    int           `yaml:"burst" validate:"min=1"`
+}
+
+// interval is the time it takes to refill a single token.
+func (p Policy) interval() time.Duration {
+	return p.Period / time.Duration(p.Requests)
+}
+
+// Result is the state of a bucket after a request was counted against it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next request is allowed, zero when it already is.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps the buckets. MemoryStore is enough for a single instance, replicas
+// behind a load balancer need a shared implementation (e.g. Redis) to agree on the counts.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// The headers of every posted event. Receivers check the signature with their secret and
+// drop the events they have already seen by their id.
+const (
+	HeaderEvent     = "X-Flashcards-Event"
+	HeaderEventId   = "X-Flashcards-Event-Id"
+	HeaderDelivery  = "X-Flashcards-Delivery"
+	HeaderSignature = "X-Flashcards-Signature"
+)
+
+// Sign is the value of HeaderSignature for payload: "sha256=" and the hex HMAC-SHA256 of
+// the payload keyed with the secret of the webhook.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Request is an event to post to a webhook.
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	EventId    string
+	DeliveryId string
+	Payload    []byte
+}
+
+// Sender posts events to the webhooks. Any response other than 2xx is a failure, the
+// response status is returned with it.
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender posts with timeout for the whole exchange. Unless allowPrivateNetworks is set,
+// the connections to loopback, private and link-local addresses are refused once the
+// name is resolved, so a webhook cannot reach the services next to the server.
+func NewSender(timeout time.Duration, allowPrivateNetworks bool) Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateNetworks {
+		dialer.Control = refusePrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return Sender{client: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// A redirect would be followed without the checks of the dialer on the first URL.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+func (s Sender) Send(ctx context.Context, request Request) (status int, err error) {
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, request.URL, bytes.NewReader(request.Payload))
+	if err != nil {
+		return 0, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("User-Agent", "FlashCards-Webhooks/1.0")
+	httpRequest.Header.Set(HeaderEvent, request.Event)
+	httpRequest.Header.Set(HeaderEventId, request.EventId)
+	httpRequest.Header.Set(HeaderDelivery, request.DeliveryId)
+	httpRequest.Header.Set(HeaderSignature, Sign(request.Secret, request.Payload))
+
+	response, err := s.client.Do(httpRequest)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("webhook address %s is not public", host)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Sign("key", []byte("The quick brown fox jumps over the lazy dog")))
+}
+
+func TestSendSignsThePayload(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	status, err := NewSender(time.Second, true).Send(context.Background(), Request{
+		URL: server.URL, Secret: "secret", Event: "deck.created", EventId: "event", DeliveryId: "delivery", Payload: []byte(`{"a":1}`),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Equal(t, `{"a":1}`, string(body))
+	assert.Equal(t, "deck.created", received.Header.Get(HeaderEvent))
+	assert.Equal(t, "event", received.Header.Get(HeaderEventId))
+	assert.Equal(t, "delivery", received.Header.Get(HeaderDelivery))
+	assert.Equal(t, Sign("secret", body), received.Header.Get(HeaderSignature))
+}
+
+func TestSendFailsOnErrorStatusAndRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	sender := NewSender(time.Second, true)
+
+	status, err := sender.Send(context.Background(), Request{URL: server.URL})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	status, err = sender.Send(context.Background(), Request{URL: server.URL + "/moved"})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusFound, status)
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := NewSender(time.Second, false).Send(context.Background(), Request{URL: server.URL})
+
+	assert.Error(t, err)
+	assert.False(t, called)
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
 	log2 "github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
-	"github.com/joho/godotenv"
 	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 )
 
 //func main() {
@@ -22,33 +27,106 @@ import (
 //}
 
 func init() {
-	var err = godotenv.Load()
-	if err != nil {
-		panic("Error to start application: cannot load environment variables: " + err.Error())
-	}
 	log2.SetupLogger()
 }
 
 func main() {
-	application, err := SetupApplication()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error to start application:", err)
+		os.Exit(2)
+	}
+	if err = log2.Configure(cfg.Environment, cfg.Log.Level); err != nil {
+		fmt.Fprintln(os.Stderr, "Error to start application: cannot create logger:", err)
+		os.Exit(2)
+	}
+
+	application, cleanup, err := SetupApplication(cfg)
 	if err != nil {
 		log2.Logger.Fatal("Setup Application Error", err)
 		panic("Error to start application")
 	}
+	defer cleanup()
+
+	if len(args) > 0 {
+		code := runCommand(application, args)
+		cleanup()
+		os.Exit(code)
+	}
+
+	// The new code may not read the old shapes, so a failed migration stops the start.
+	if application.Migrator != nil && cfg.MigrateOnStartup() {
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		_, err = application.Migrator.Up(ctx, false)
+		cancel()
+		if err != nil {
+			log2.Logger.Errorw("Migrations have failed", "error", err)
+			cleanup()
+			os.Exit(1)
+		}
+	}
 
-	port, exists := os.LookupEnv("SERVER_PORT")
-	if !exists {
-		port = "8080"
+	// A failure here is reported by the readiness check, the API still serves slowly.
+	if cfg.Storage == config.StorageMongoDB && cfg.MongoDB.EnsureIndexes {
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		_ = application.Indexes.Ensure(ctx)
+		cancel()
 	}
 
+	port := strconv.Itoa(cfg.Server.Port)
 	handler := application.SystemRoutes.SetupHandler()
 	server := &http.Server{
 		Handler:      handler,
 		Addr:         ":" + port,
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+	}
+	// The event streams never finish by themselves, they are ended for Shutdown.
+	server.RegisterOnShutdown(application.Stream.Close)
+
+	// The events and the queued deliveries are handled while the server is up, the ones
+	// still in flight at the shutdown are handled again by the next instance to run. The
+	// stream reads the events for the clients listening here.
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	var dispatchers sync.WaitGroup
+	for _, run := range []func(context.Context){application.Events.Run, application.Webhooks.Run, application.Stream.Run} {
+		dispatchers.Add(1)
+		go func(run func(context.Context)) {
+			defer dispatchers.Done()
+			run(dispatchCtx)
+		}(run)
 	}
+	defer func() {
+		stopDispatch()
+		dispatchers.Wait()
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log2.Logger.Infof("Application listening on port %s", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
 
-	//log.Logger.Infof("Application listening on port %s", port)
-	log2.Logger.Fatal(server.ListenAndServe())
+	select {
+	case err = <-serverErr:
+		log2.Logger.Errorw("Server has stopped", "error", err)
+		return
+	case sig := <-stop:
+		log2.Logger.Infow("Shutting down", "signal", sig.String(), "timeout", cfg.Server.ShutdownTimeout.String())
+	}
+
+	// Readiness fails first so no new requests are routed here, Shutdown then stops
+	// accepting connections and waits for the in-flight ones up to the deadline.
+	application.HealthHandler.Drain()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err = server.Shutdown(ctx); err != nil {
+		log2.Logger.Errorw("Graceful shutdown has failed", "error", err)
+		return
+	}
+	log2.Logger.Info("Server stopped")
 }
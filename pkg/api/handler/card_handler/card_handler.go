@@ -8,12 +8,14 @@ import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/card_usecase"
 	"github.com/gorilla/mux"
+	"io"
 	"net/http"
 )
 
 const (
-	headerUserId = "userId"
-	pathVarID    = "id"
+	headerUserId      = "userId"
+	headerContentType = "Content-Type"
+	pathVarID         = "id"
 )
 
 type CardHandler struct {
@@ -27,84 +29,170 @@ func NewCardHandler(service card_usecase.ICardUseCase) CardHandler {
 func (handler *CardHandler) Post(w http.ResponseWriter, r *http.Request) {
 	requestBody, err := handler.extractBody(r)
 	if err != nil {
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(errors.ErrInvalidPayload))
+		render.Error(w, r, &errors.BadRequest{Err: err})
 		return
 	}
 	id := mux.Vars(r)[pathVarID]
 
 	userID := r.Header.Get(headerUserId)
-	result, err := handler.cardUseCase.Create(userID, id, requestBody)
+	result, err := handler.cardUseCase.Create(r.Context(), userID, id, requestBody)
 	if err != nil {
-		log.Logger.Errorw("Failed to create card", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to create card", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Card has created successfully")
+	log.FromContext(r.Context()).Debug("Card has created successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusCreated)
 }
 
+func (handler *CardHandler) Import(w http.ResponseWriter, r *http.Request) {
+	var requestBody []*card.Card
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+	id := mux.Vars(r)[pathVarID]
+
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.cardUseCase.Import(r.Context(), userID, id, requestBody)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to import cards", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Cards have imported successfully")
+	render.Response(w, result, http.StatusCreated)
+}
+
+func (handler *CardHandler) Move(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		DeckId string `json:"deckId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+	id := mux.Vars(r)[pathVarID]
+
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.cardUseCase.Move(r.Context(), id, userID, requestBody.DeckId)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to move card", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Card has moved successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
+	render.Response(w, result, http.StatusOK)
+}
+
 func (handler *CardHandler) FindByDeckId(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
 
-	result, err := handler.cardUseCase.FindByDeckId(userID, id)
+	result, err := handler.cardUseCase.FindByDeckId(r.Context(), userID, id)
 	if err != nil {
-		log.Logger.Errorw("Failed to find card", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find card", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Card has find successfully")
+	log.FromContext(r.Context()).Debug("Card has find successfully")
 	render.Response(w, result, http.StatusOK)
 }
 
 func (handler *CardHandler) FindById(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get(headerUserId)
 	id := mux.Vars(r)[pathVarID]
-	result, err := handler.cardUseCase.FindById(userID, id)
+	result, err := handler.cardUseCase.FindById(r.Context(), userID, id)
 	if err != nil {
-		log.Logger.Errorw("Failed to find card", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find card", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusOK)
 }
 
-func (handler *CardHandler) Patch(w http.ResponseWriter, r *http.Request) {
+func (handler *CardHandler) Put(w http.ResponseWriter, r *http.Request) {
 	requestBody, err := handler.extractBody(r)
 	if err != nil {
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(errors.ErrInvalidPayload))
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
 		return
 	}
 
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
-	result, err := handler.cardUseCase.Update(id, userID, true, requestBody)
+	result, err := handler.cardUseCase.Update(r.Context(), id, userID, requestBody, version)
 	if err != nil {
-		log.Logger.Errorw("Failed to update card", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to update card", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Card has updated successfully")
+	log.FromContext(r.Context()).Debug("Card has updated successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *CardHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	document, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to read payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	id := mux.Vars(r)[pathVarID]
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.cardUseCase.Patch(r.Context(), id, userID, r.Header.Get(headerContentType), document, version)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to update card", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Card has updated successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusOK)
 }
 
 func (handler *CardHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	customerID := r.Header.Get(headerUserId)
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
 
 	id := mux.Vars(r)[pathVarID]
-	_, err := handler.cardUseCase.Delete(id, customerID)
+	_, err = handler.cardUseCase.Delete(r.Context(), id, customerID, version)
 	if err != nil {
-		log.Logger.Errorw("Failed to remove card", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to remove card", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Card has removed successfully")
+	log.FromContext(r.Context()).Debug("Card has removed successfully")
 	render.Response(w, nil, http.StatusNoContent)
 }
 
@@ -112,22 +200,21 @@ func (handler *CardHandler) extractBody(r *http.Request) (*card.Card, error) {
 	var cardJSON card.Card
 
 	if err := json.NewDecoder(r.Body).Decode(&cardJSON); err != nil {
-		log.Logger.Errorw("Error trying to parse payload", "error", err)
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
 		return nil, err
 	}
 
 	return &cardJSON, nil
 }
 
-func GenerateHTTPErrorStatusCode(err error) int {
-	switch errors.Cause(err).(type) {
-	case *errors.NotFound:
-		return http.StatusNotFound
-	case *errors.InvalidPayload:
-		return http.StatusPreconditionFailed
-	case *errors.BadRequest:
-		return http.StatusBadRequest
-	default:
-		return http.StatusInternalServerError
+// currentVersion reads the version the card of the request is at, for an If-Match with
+// several tags.
+func (handler *CardHandler) currentVersion(r *http.Request) func() (int64, error) {
+	return func() (int64, error) {
+		found, err := handler.cardUseCase.FindById(r.Context(), r.Header.Get(headerUserId), mux.Vars(r)[pathVarID])
+		if err != nil {
+			return 0, err
+		}
+		return found.Version, nil
 	}
 }
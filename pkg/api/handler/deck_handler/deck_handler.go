@@ -8,13 +8,15 @@ import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
 	"github.com/gorilla/mux"
+	"io"
 	"net/http"
 	"strconv"
 )
 
 const (
-	headerUserId = "userId"
-	pathVarID    = "id"
+	headerUserId      = "userId"
+	headerContentType = "Content-Type"
+	pathVarID         = "id"
 )
 
 type DeckHandler struct {
@@ -28,19 +30,20 @@ func NewDeckHandler(service deck_usecase.IDeckUseCase) DeckHandler {
 func (handler *DeckHandler) Post(w http.ResponseWriter, r *http.Request) {
 	requestBody, err := handler.extractBody(r)
 	if err != nil {
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(errors.ErrInvalidPayload))
+		render.Error(w, r, &errors.BadRequest{Err: err})
 		return
 	}
 
 	userID := r.Header.Get(headerUserId)
-	result, err := handler.deckUseCase.Create(userID, requestBody)
+	result, err := handler.deckUseCase.Create(r.Context(), userID, requestBody)
 	if err != nil {
-		log.Logger.Errorw("Failed to create deck", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to create deck", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Deck has created successfully")
+	log.FromContext(r.Context()).Debug("Deck has created successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusCreated)
 }
 
@@ -48,25 +51,25 @@ func (handler *DeckHandler) FindById(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
 
-	result, err := handler.deckUseCase.FindById(userID, id)
+	result, err := handler.deckUseCase.FindById(r.Context(), userID, id)
 	if err != nil {
-		log.Logger.Errorw("Failed to find deck", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find deck", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Deck has find successfully")
+	log.FromContext(r.Context()).Debug("Deck has find successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusOK)
 }
 
 func (handler *DeckHandler) FindByUserIdAndPublic(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get(headerUserId)
 
-	result, count, err := handler.deckUseCase.FindByUserIdAndPublic(userID)
+	result, count, err := handler.deckUseCase.FindByUserIdAndPublic(r.Context(), userID)
 	if err != nil {
-		log.Logger.Errorw("Failed to find deck", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find deck", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -77,11 +80,10 @@ func (handler *DeckHandler) FindByUserIdAndPublic(w http.ResponseWriter, r *http
 func (handler *DeckHandler) FindRecent(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get(headerUserId)
 
-	result, count, err := handler.deckUseCase.FindRecent(userID)
+	result, count, err := handler.deckUseCase.FindRecent(r.Context(), userID)
 	if err != nil {
-		log.Logger.Errorw("Failed to find deck", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find deck", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -89,13 +91,27 @@ func (handler *DeckHandler) FindRecent(w http.ResponseWriter, r *http.Request) {
 	render.Response(w, result, http.StatusOK)
 }
 
+func (handler *DeckHandler) RepairCardsCount(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get(headerUserId)
+
+	result, err := handler.deckUseCase.RepairCardsCount(r.Context(), userID)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to repair cards count", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Cards count has repaired successfully")
+	render.Response(w, result, http.StatusOK)
+}
+
 func (handler *DeckHandler) FindByUserId(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get(headerUserId)
 
-	result, count, err := handler.deckUseCase.FindByUserId(userID)
+	result, count, err := handler.deckUseCase.FindByUserId(r.Context(), userID)
 	if err != nil {
-		log.Logger.Errorw("Failed to find deck", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find deck", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -103,38 +119,78 @@ func (handler *DeckHandler) FindByUserId(w http.ResponseWriter, r *http.Request)
 	render.Response(w, result, http.StatusOK)
 }
 
-func (handler *DeckHandler) Patch(w http.ResponseWriter, r *http.Request) {
+func (handler *DeckHandler) Put(w http.ResponseWriter, r *http.Request) {
 	requestBody, err := handler.extractBody(r)
 	if err != nil {
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(errors.ErrInvalidPayload))
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
 		return
 	}
 
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
-	result, err := handler.deckUseCase.Update(id, userID, true, requestBody)
+	result, err := handler.deckUseCase.Update(r.Context(), id, userID, requestBody, version)
 	if err != nil {
-		log.Logger.Errorw("Failed to update deck", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to update deck", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Deck has updated successfully")
+	log.FromContext(r.Context()).Debug("Deck has updated successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *DeckHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	document, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to read payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	id := mux.Vars(r)[pathVarID]
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.deckUseCase.Patch(r.Context(), id, userID, r.Header.Get(headerContentType), document, version)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to update deck", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Deck has updated successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusCreated)
 }
 
 func (handler *DeckHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	customerID := r.Header.Get(headerUserId)
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
 
 	id := mux.Vars(r)[pathVarID]
-	_, err := handler.deckUseCase.Delete(id, customerID)
+	_, err = handler.deckUseCase.Delete(r.Context(), id, customerID, version)
 	if err != nil {
-		log.Logger.Errorw("Failed to remove deck", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to remove deck", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Deck has removed successfully")
+	log.FromContext(r.Context()).Debug("Deck has removed successfully")
 	render.Response(w, nil, http.StatusNoContent)
 }
 
@@ -142,22 +198,21 @@ func (handler *DeckHandler) extractBody(r *http.Request) (*deck.Deck, error) {
 	var deckJSON deck.Deck
 
 	if err := json.NewDecoder(r.Body).Decode(&deckJSON); err != nil {
-		log.Logger.Errorw("Error trying to parse payload", "error", err)
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
 		return nil, err
 	}
 
 	return &deckJSON, nil
 }
 
-func GenerateHTTPErrorStatusCode(err error) int {
-	switch errors.Cause(err).(type) {
-	case *errors.NotFound:
-		return http.StatusNotFound
-	case *errors.InvalidPayload:
-		return http.StatusPreconditionFailed
-	case *errors.BadRequest:
-		return http.StatusBadRequest
-	default:
-		return http.StatusInternalServerError
+// currentVersion reads the version the deck of the request is at, for an If-Match with
+// several tags.
+func (handler *DeckHandler) currentVersion(r *http.Request) func() (int64, error) {
+	return func() (int64, error) {
+		found, err := handler.deckUseCase.FindById(r.Context(), r.Header.Get(headerUserId), mux.Vars(r)[pathVarID])
+		if err != nil {
+			return 0, err
+		}
+		return found.Version, nil
 	}
 }
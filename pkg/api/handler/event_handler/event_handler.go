@@ -0,0 +1,118 @@
+package event_handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
+	"net/http"
+	"time"
+)
+
+const (
+	headerUserId      = "userId"
+	headerLastEventId = "Last-Event-ID"
+	// eventReset tells the client to fetch its data again, the events it missed are gone.
+	eventReset = "reset"
+	// retryMillis is how long the client waits to reconnect once a stream ends.
+	retryMillis = 1000
+)
+
+// message is what a client gets of an event, the entity after the change or before a
+// removal in data.
+type message struct {
+	Id         string          `json:"id"`
+	Type       string          `json:"type"`
+	EntityId   string          `json:"entityId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type EventHandler struct {
+	stream    event_usecase.IEventStream
+	heartbeat time.Duration
+	// duration ends each stream before the server write timeout would cut it, the client
+	// resumes it from the last event it got.
+	duration time.Duration
+}
+
+func NewEventHandler(stream event_usecase.IEventStream, cfg config.Stream, server config.Server) EventHandler {
+	return EventHandler{
+		stream:    stream,
+		heartbeat: cfg.Heartbeat,
+		duration:  server.WriteTimeout * 9 / 10,
+	}
+}
+
+// Stream sends the changes to the entities of the user as Server-Sent Events, each with
+// its cursor as id so that a client reconnecting with Last-Event-ID gets the ones it missed.
+func (handler *EventHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		render.Error(w, r, errors.WrapWithMessage(errors.ErrInternalServer, "streaming is not supported"))
+		return
+	}
+
+	userID := r.Header.Get(headerUserId)
+	subscription, err := handler.stream.Subscribe(r.Context(), userID, r.Header.Get(headerLastEventId))
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to subscribe to events", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+	defer handler.stream.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Proxies such as nginx would otherwise hold the events back.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	if subscription.Reset {
+		_, _ = fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventReset)
+	}
+	for _, e := range subscription.Replay {
+		if err = write(w, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(handler.heartbeat)
+	defer heartbeat.Stop()
+	end := time.NewTimer(handler.duration)
+	defer end.Stop()
+	for {
+		select {
+		case e, open := <-subscription.Events:
+			if !open {
+				return
+			}
+			err = write(w, e)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case <-end.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Infow("Stream has been closed", "error", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func write(w http.ResponseWriter, e *event.Event) error {
+	data, err := json.Marshal(message{Id: e.Id.Hex(), Type: e.Type, EntityId: e.EntityId, OccurredAt: e.OccurredAt, Data: e.Data})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.Cursor(), e.Type, data)
+	return err
+}
@@ -0,0 +1,56 @@
+package event_handler
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stream struct {
+	subscription *event_usecase.Subscription
+	lastEventId  string
+	unsubscribed bool
+}
+
+func (s *stream) Subscribe(_ context.Context, _, lastEventId string) (*event_usecase.Subscription, error) {
+	s.lastEventId = lastEventId
+	return s.subscription, nil
+}
+
+func (s *stream) Unsubscribe(_ *event_usecase.Subscription) {
+	s.unsubscribed = true
+}
+
+func TestStreamWritesTheReplayThenTheEvents(t *testing.T) {
+	log.SetupLogger()
+	id := primitive.NewObjectID()
+	replayed := &event.Event{Id: &id, Type: event.CardCreated, EntityId: "card", Data: []byte(`{"front":"hola"}`), OccurredAt: time.Unix(10, 0)}
+	events := make(chan *event.Event, 1)
+	events <- &event.Event{Id: &id, Type: event.CardDeleted, EntityId: "card", Data: []byte(`{}`), OccurredAt: time.Unix(20, 0)}
+	close(events)
+	fake := &stream{subscription: &event_usecase.Subscription{Reset: true, Replay: []*event.Event{replayed}, Events: events}}
+	handler := NewEventHandler(fake, config.Stream{Heartbeat: time.Minute}, config.Server{WriteTimeout: time.Minute})
+
+	request := httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/events", nil)
+	request.Header.Set(headerLastEventId, "10-cursor")
+	recorder := httptest.NewRecorder()
+	handler.Stream(recorder, request)
+
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "10-cursor", fake.lastEventId)
+	assert.True(t, fake.unsubscribed)
+	body := recorder.Body.String()
+	assert.True(t, strings.HasPrefix(body, "retry: 1000\n\nevent: reset\ndata: {}\n\n"), body)
+	assert.Contains(t, body, "id: "+replayed.Cursor().String()+"\nevent: card.created\ndata: {\"id\":\""+id.Hex()+"\"")
+	assert.Contains(t, body, "event: card.deleted\n")
+	assert.Less(t, strings.Index(body, "card.created"), strings.Index(body, "card.deleted"))
+}
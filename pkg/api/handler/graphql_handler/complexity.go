@@ -0,0 +1,112 @@
+package graphql_handler
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"strings"
+)
+
+// meter measures an operation before it runs: its depth is how deeply its fields are
+// nested and its complexity how many fields it resolves, each field under a list counted
+// listSize times for every list above it. It stops once a limit is passed, so a query
+// built to be expensive to measure is not measured to the end.
+type meter struct {
+	schema        graphql.Schema
+	fragments     map[string]*ast.FragmentDefinition
+	listSize      int
+	maxDepth      int
+	maxComplexity int
+
+	depth      int
+	complexity int
+}
+
+// measure checks the operation named operationName of a valid document against the limits.
+func (m *meter) measure(document *ast.Document, operationName string) error {
+	m.fragments = make(map[string]*ast.FragmentDefinition)
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	// The executor refuses a missing operation, there is nothing to measure.
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return nil
+	}
+
+	m.selectionSet(operation.SelectionSet, m.schema.QueryType(), 1, 1)
+	if m.depth > m.maxDepth {
+		return fmt.Errorf("query nests fields more than %d levels deep", m.maxDepth)
+	}
+	if m.complexity > m.maxComplexity {
+		return fmt.Errorf("query resolves more than %d fields, counting %d for each list", m.maxComplexity, m.listSize)
+	}
+	return nil
+}
+
+func (m *meter) selectionSet(set *ast.SelectionSet, parent graphql.Type, multiplier, depth int) {
+	if set == nil {
+		return
+	}
+	for _, selection := range set.Selections {
+		if m.depth > m.maxDepth || m.complexity > m.maxComplexity {
+			return
+		}
+		switch selection := selection.(type) {
+		case *ast.Field:
+			m.field(selection, parent, multiplier, depth)
+		case *ast.InlineFragment:
+			fragmentType := parent
+			if selection.TypeCondition != nil {
+				fragmentType = m.schema.Type(selection.TypeCondition.Name.Value)
+			}
+			m.selectionSet(selection.SelectionSet, fragmentType, multiplier, depth)
+		case *ast.FragmentSpread:
+			if fragment, exists := m.fragments[selection.Name.Value]; exists {
+				m.selectionSet(fragment.SelectionSet, m.schema.Type(fragment.TypeCondition.Name.Value), multiplier, depth)
+			}
+		}
+	}
+}
+
+func (m *meter) field(field *ast.Field, parent graphql.Type, multiplier, depth int) {
+	m.complexity += multiplier
+	if depth > m.depth {
+		m.depth = depth
+	}
+	// What is under an introspection field is not counted, reading the schema is not limited.
+	object, isObject := parent.(*graphql.Object)
+	if !isObject || strings.HasPrefix(field.Name.Value, "__") {
+		return
+	}
+	definition, exists := object.Fields()[field.Name.Value]
+	if !exists {
+		return
+	}
+
+	fieldType := definition.Type
+	for {
+		switch wrapped := fieldType.(type) {
+		case *graphql.NonNull:
+			fieldType = wrapped.OfType
+			continue
+		case *graphql.List:
+			// Past the limit the exact count does not matter, it is kept from overflowing.
+			multiplier *= m.listSize
+			if multiplier > m.maxComplexity {
+				multiplier = m.maxComplexity + 1
+			}
+			fieldType = wrapped.OfType
+			continue
+		}
+		break
+	}
+	m.selectionSet(field.SelectionSet, fieldType, multiplier, depth+1)
+}
@@ -0,0 +1,95 @@
+package graphql_handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/card_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/review_usecase"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"net/http"
+)
+
+const headerUserId = "userId"
+
+// Request is the body of a GraphQL request.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type GraphQLHandler struct {
+	schema    graphql.Schema
+	resolvers resolvers
+	cfg       config.GraphQL
+}
+
+func NewGraphQLHandler(decks deck_usecase.IDeckUseCase, cards card_usecase.ICardUseCase, playlists playlist_usecase.IPlaylistUseCase,
+	reviews review_usecase.IReviewUseCase, cfg config.GraphQL) (GraphQLHandler, error) {
+	r := resolvers{decks: decks, cards: cards, playlists: playlists, reviews: reviews}
+	schema, err := newSchema(r)
+	if err != nil {
+		return GraphQLHandler{}, err
+	}
+	return GraphQLHandler{schema: schema, resolvers: r, cfg: cfg}, nil
+}
+
+func (handler *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var requestBody Request
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+	if requestBody.Query == "" {
+		render.Error(w, r, errors.WrapWithMessage(errors.ErrInvalidPayload, "query is required"))
+		return
+	}
+
+	userID := r.Header.Get(headerUserId)
+	result, status := handler.execute(r.Context(), userID, &requestBody)
+	if len(result.Errors) > 0 {
+		log.FromContext(r.Context()).Infow("GraphQL query has errors", "operationName", requestBody.OperationName, "errors", result.Errors)
+	}
+	render.Response(w, result, status)
+}
+
+// execute runs a query for userId. A query that cannot run, because it is malformed,
+// invalid or past the limits, is answered with 400; one that ran with 200, with the errors
+// of the fields that failed next to the data of the others.
+func (handler *GraphQLHandler) execute(ctx context.Context, userId string, request *Request) (*graphql.Result, int) {
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(request.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, http.StatusBadRequest
+	}
+
+	validation := graphql.ValidateDocument(&handler.schema, document, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}, http.StatusBadRequest
+	}
+
+	m := meter{schema: handler.schema, listSize: handler.cfg.ListSize, maxDepth: handler.cfg.MaxDepth, maxComplexity: handler.cfg.MaxComplexity}
+	if err = m.measure(document, request.OperationName); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, http.StatusBadRequest
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        handler.schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       handler.resolvers.newRequest(ctx, userId),
+	}), http.StatusOK
+}
@@ -0,0 +1,165 @@
+package graphql_handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/card_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/deck_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/playlist_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/review_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/card_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/review_usecase"
+	"github.com/go-playground/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// countingDecks counts the batched deck reads.
+type countingDecks struct {
+	deck_usecase.IDeckUseCase
+	calls int
+}
+
+func (c *countingDecks) FindByIds(ctx context.Context, userId string, ids []string) ([]*deck.Deck, error) {
+	c.calls++
+	return c.IDeckUseCase.FindByIds(ctx, userId, ids)
+}
+
+// countingCards counts the batched card reads.
+type countingCards struct {
+	card_usecase.ICardUseCase
+	calls int
+}
+
+func (c *countingCards) FindByDeckIds(ctx context.Context, userId string, deckIds []string) ([]*card.Card, error) {
+	c.calls++
+	return c.ICardUseCase.FindByDeckIds(ctx, userId, deckIds)
+}
+
+type fixture struct {
+	handler   GraphQLHandler
+	decks     *countingDecks
+	cards     *countingCards
+	playlists playlist_usecase.IPlaylistUseCase
+}
+
+func newFixture(t *testing.T, cfg config.GraphQL) fixture {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	validate := validator.New()
+	events := event_usecase.NewEventPublisher(outbox_repository.NewOutboxMemoryRepository())
+	tx := transaction.NewMemoryTransactionManager()
+	deckRepo := deck_repository.NewDeckMemoryRepository()
+	cardRepo := card_repository.NewCardMemoryRepository()
+	playlistRepo := playlist_repository.NewPlaylistMemoryRepository()
+	reviewRepo := review_repository.NewReviewMemoryRepository()
+
+	decks := deck_usecase.NewDeckUseCase(deckRepo, cardRepo, playlistRepo, reviewRepo, tx, events, validate)
+	cards := card_usecase.NewCardUseCase(cardRepo, deckRepo, tx, decks, events, validate)
+	playlists := playlist_usecase.NewPlaylistUseCase(playlistRepo, decks, tx, events, validate)
+	reviews := review_usecase.NewReviewUseCase(playlists, reviewRepo, cards, decks, tx, events)
+
+	f := fixture{decks: &countingDecks{IDeckUseCase: decks}, cards: &countingCards{ICardUseCase: cards}, playlists: playlists}
+	handler, err := NewGraphQLHandler(f.decks, f.cards, playlists, reviews, cfg)
+	require.NoError(t, err)
+	f.handler = handler
+	return f
+}
+
+func (f fixture) query(t *testing.T, userId, query string, variables map[string]interface{}) (int, map[string]interface{}) {
+	body, err := json.Marshal(Request{Query: query, Variables: variables})
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/flashcards/api/v1/graphql", bytes.NewReader(body))
+	r.Header.Set(headerUserId, userId)
+	w := httptest.NewRecorder()
+	f.handler.Query(w, r)
+
+	var result map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	return w.Code, result
+}
+
+func TestStudyScreenIsFetchedInBatches(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, config.GraphQL{MaxDepth: 8, MaxComplexity: 2000, ListSize: 10})
+
+	var deckIds []string
+	for _, name := range []string{"Verbs", "Nouns", "Empty"} {
+		created, err := f.decks.Create(ctx, "owner", &deck.Deck{Name: name, ImageURL: "image.png", Description: name})
+		require.NoError(t, err)
+		deckIds = append(deckIds, created.Id.Hex())
+	}
+	for _, deckId := range deckIds[:2] {
+		for _, front := range []string{"first", "second"} {
+			_, err := f.cards.Create(ctx, "owner", deckId, &card.Card{Front: front, Back: "back"})
+			require.NoError(t, err)
+		}
+	}
+	created, err := f.playlists.Create(ctx, "owner", &playlist.Playlist{Name: "Languages", ImageURL: "image.png", Description: "Study"})
+	require.NoError(t, err)
+	_, err = f.playlists.AddDecksToPlaylist(ctx, created.Id.Hex(), "owner", deckIds, nil)
+	require.NoError(t, err)
+
+	status, result := f.query(t, "owner", `query Study($id: ID!) {
+		playlist(id: $id) { name decks { id name cards { front deck { name } } } }
+		me { id }
+	}`, map[string]interface{}{"id": created.Id.Hex()})
+
+	require.Equal(t, http.StatusOK, status, result)
+	assert.Nil(t, result["errors"])
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, "owner", data["me"].(map[string]interface{})["id"])
+	screen := data["playlist"].(map[string]interface{})
+	assert.Equal(t, "Languages", screen["name"])
+	decks := screen["decks"].([]interface{})
+	require.Len(t, decks, 3)
+	for i, d := range decks {
+		assert.Equal(t, deckIds[i], d.(map[string]interface{})["id"], "the decks keep the playlist order")
+	}
+	verbs := decks[0].(map[string]interface{})
+	assert.Len(t, verbs["cards"], 2)
+	assert.Equal(t, "Verbs", verbs["cards"].([]interface{})[0].(map[string]interface{})["deck"].(map[string]interface{})["name"])
+	assert.Empty(t, decks[2].(map[string]interface{})["cards"])
+
+	assert.Equal(t, 1, f.decks.calls, "the decks are read in one batch, and the deck of each card from it")
+	assert.Equal(t, 1, f.cards.calls, "the cards of every deck are read in one batch")
+}
+
+func TestQueriesPastTheLimitsAreRefused(t *testing.T) {
+	f := newFixture(t, config.GraphQL{MaxDepth: 4, MaxComplexity: 50, ListSize: 10})
+
+	status, result := f.query(t, "owner", `{ me { playlists { decks { cards { front } } } } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, result["errors"].([]interface{})[0].(map[string]interface{})["message"], "more than 50 fields")
+	assert.Nil(t, result["data"])
+
+	status, result = f.query(t, "owner", `fragment Nested on Card { deck { cards { deck { name } } } }
+		{ card(id: "5f1b0c3e2a9d4b0012345678") { ...Nested } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, result["errors"].([]interface{})[0].(map[string]interface{})["message"], "more than 4 levels")
+
+	status, result = f.query(t, "owner", `{ me { decks { name } } deck(id: "5f1b0c3e2a9d4b0012345678") { name } }`, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Nil(t, result["errors"])
+	assert.Nil(t, result["data"].(map[string]interface{})["deck"], "a missing deck is null")
+
+	status, _ = f.query(t, "owner", `{ me { unknown } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,97 @@
+package graphql_handler
+
+import (
+	"context"
+	"sync"
+)
+
+// fetch reads the values of keys at once, the keys without a value are left out.
+type fetch func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+// loader batches the reads of a request. Loading a key returns a thunk the executor calls
+// once every field of a level asked for its keys, the first call fetches all of them.
+type loader struct {
+	fetch fetch
+
+	mutex   sync.Mutex
+	pending []string
+	values  map[string]interface{}
+	errs    map[string]error
+}
+
+func newLoader(fetch fetch) *loader {
+	return &loader{fetch: fetch, values: make(map[string]interface{}), errs: make(map[string]error)}
+}
+
+func (l *loader) load(ctx context.Context, key string) func() (interface{}, error) {
+	l.mutex.Lock()
+	l.enqueue(key)
+	l.mutex.Unlock()
+
+	return func() (interface{}, error) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.dispatch(ctx)
+		return l.values[key], l.errs[key]
+	}
+}
+
+// loadMany loads keys in the same batch, the values found are returned in their order.
+func (l *loader) loadMany(ctx context.Context, keys []string) func() (interface{}, error) {
+	l.mutex.Lock()
+	for _, key := range keys {
+		l.enqueue(key)
+	}
+	l.mutex.Unlock()
+
+	return func() (interface{}, error) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.dispatch(ctx)
+		result := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			if err := l.errs[key]; err != nil {
+				return nil, err
+			}
+			if value := l.values[key]; value != nil {
+				result = append(result, value)
+			}
+		}
+		return result, nil
+	}
+}
+
+// enqueue is called with the mutex held.
+func (l *loader) enqueue(key string) {
+	if _, loaded := l.values[key]; loaded {
+		return
+	}
+	if _, failed := l.errs[key]; failed {
+		return
+	}
+	for _, pending := range l.pending {
+		if pending == key {
+			return
+		}
+	}
+	l.pending = append(l.pending, key)
+}
+
+// dispatch is called with the mutex held. A key not found is remembered as nil, so it is
+// not fetched again.
+func (l *loader) dispatch(ctx context.Context) {
+	if len(l.pending) == 0 {
+		return
+	}
+	keys := l.pending
+	l.pending = nil
+
+	values, err := l.fetch(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+			continue
+		}
+		l.values[key] = values[key]
+	}
+}
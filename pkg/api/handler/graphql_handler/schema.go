@@ -0,0 +1,314 @@
+package graphql_handler
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/Enums"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/review"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/card_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/review_usecase"
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type requestKey struct{}
+
+// request is what the resolvers of one query share: the user asking and the loaders
+// batching the decks by id and the cards by deck id.
+type request struct {
+	userId string
+	decks  *loader
+	cards  *loader
+}
+
+func fromContext(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// user is the source of the User type, the one asking.
+type user struct {
+	id string
+}
+
+type resolvers struct {
+	decks     deck_usecase.IDeckUseCase
+	cards     card_usecase.ICardUseCase
+	playlists playlist_usecase.IPlaylistUseCase
+	reviews   review_usecase.IReviewUseCase
+}
+
+// newRequest starts the loaders of a query by userId.
+func (r resolvers) newRequest(ctx context.Context, userId string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{
+		userId: userId,
+		decks: newLoader(func(ctx context.Context, ids []string) (map[string]interface{}, error) {
+			found, err := r.decks.FindByIds(ctx, userId, ids)
+			if err != nil {
+				return nil, err
+			}
+			values := make(map[string]interface{}, len(found))
+			for _, d := range found {
+				values[d.Id.Hex()] = d
+			}
+			return values, nil
+		}),
+		cards: newLoader(func(ctx context.Context, deckIds []string) (map[string]interface{}, error) {
+			found, err := r.cards.FindByDeckIds(ctx, userId, deckIds)
+			if err != nil {
+				return nil, err
+			}
+			grouped := make(map[string][]*card.Card, len(deckIds))
+			for _, c := range found {
+				grouped[c.DeckId] = append(grouped[c.DeckId], c)
+			}
+			// Every deck gets its list, an empty one is not fetched again.
+			values := make(map[string]interface{}, len(deckIds))
+			for _, deckId := range deckIds {
+				cards := grouped[deckId]
+				if cards == nil {
+					cards = []*card.Card{}
+				}
+				values[deckId] = cards
+			}
+			return values, nil
+		}),
+	})
+}
+
+func newSchema(r resolvers) (graphql.Schema, error) {
+	deckType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Deck",
+		Description: "A deck of cards.",
+		Fields: graphql.Fields{
+			"id":               &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: id(func(source interface{}) *primitive.ObjectID { return source.(*deck.Deck).Id })},
+			"name":             &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"imageURL":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"isPrivate":        &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"studySuggestions": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"cardsCount":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"userId":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"version":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"lastUpdate":       &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	cardType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Card",
+		Description: "A card of a deck.",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: id(func(source interface{}) *primitive.ObjectID { return source.(*card.Card).Id })},
+			"front":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"back":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"color":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"deckId":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"isPrivate":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"userId":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"version":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"lastUpdate": &graphql.Field{Type: graphql.DateTime},
+			"deck": &graphql.Field{
+				Type:        deckType,
+				Description: "The deck of the card, null when it cannot be seen.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return fromContext(p.Context).decks.load(p.Context, p.Source.(*card.Card).DeckId), nil
+				},
+			},
+		},
+	})
+
+	// The cards refer to their deck and the decks to their cards.
+	deckType.AddFieldConfig("cards", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(cardType))),
+		Description: "The cards of the deck.",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return fromContext(p.Context).cards.load(p.Context, p.Source.(*deck.Deck).Id.Hex()), nil
+		},
+	})
+
+	playlistType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Playlist",
+		Description: "A playlist of decks.",
+		Fields: graphql.Fields{
+			"id":               &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: id(func(source interface{}) *primitive.ObjectID { return source.(*playlist.Playlist).Id })},
+			"name":             &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"imageURL":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"isPrivate":        &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"studySuggestions": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"userId":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"version":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"lastUpdate":       &graphql.Field{Type: graphql.DateTime},
+			"decks": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(deckType))),
+				Description: "The decks of the playlist in its order, leaving out the ones that cannot be seen.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					previews := p.Source.(*playlist.Playlist).Decks
+					ids := make([]string, 0, len(previews))
+					for _, preview := range previews {
+						ids = append(ids, preview.Id)
+					}
+					return fromContext(p.Context).decks.loadMany(p.Context, ids), nil
+				},
+			},
+		},
+	})
+
+	reviewType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "ReviewSession",
+		Description: "A review session of a deck or playlist, with the cards answered right and wrong.",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: id(func(source interface{}) *primitive.ObjectID { return source.(*review.Review).Id })},
+			"originType":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"originId":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"userId":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"hists":         &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(cardType)))},
+			"histsCount":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"mistakes":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(cardType)))},
+			"mistakesCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"cardsCount":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"lastUpdate":    &graphql.Field{Type: graphql.DateTime},
+			"completed": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Whether every card the session started with has an answer.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					session := p.Source.(*review.Review)
+					return session.CardsCount > 0 && session.HistsCount+session.MistakesCount >= session.CardsCount, nil
+				},
+			},
+			"deck": &graphql.Field{
+				Type:        deckType,
+				Description: "The deck reviewed, null for a playlist session.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					session := p.Source.(*review.Review)
+					if session.OriginType != Enums.Deck {
+						return nil, nil
+					}
+					return fromContext(p.Context).decks.load(p.Context, session.OriginId), nil
+				},
+			},
+			"playlist": &graphql.Field{
+				Type:        playlistType,
+				Description: "The playlist reviewed, null for a deck session.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					session := p.Source.(*review.Review)
+					if session.OriginType != Enums.Playlist {
+						return nil, nil
+					}
+					return found(r.playlists.FindById(p.Context, fromContext(p.Context).userId, session.OriginId))
+				},
+			},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "The user asking, with what they own.",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(user).id, nil
+				},
+			},
+			"decks": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(deckType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					result, _, err := r.decks.FindByUserId(p.Context, p.Source.(user).id)
+					return result, err
+				},
+			},
+			"playlists": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(playlistType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					result, _, err := r.playlists.FindByUserId(p.Context, p.Source.(user).id)
+					return result, err
+				},
+			},
+			"recentReviews": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reviewType))),
+				Description: "The last deck review sessions.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.reviews.FindRecentDecks(p.Context, p.Source.(user).id)
+				},
+			},
+		},
+	})
+
+	idArgs := graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}}
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return user{id: fromContext(p.Context).userId}, nil
+				},
+			},
+			"deck": &graphql.Field{
+				Type:        deckType,
+				Description: "A deck of the user or a public one, null when there is none.",
+				Args:        idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					deckId := p.Args["id"].(string)
+					if !primitive.IsValidObjectID(deckId) {
+						return nil, errors.WrapWithMessage(errors.ErrInvalidPayload, "invalid deck id")
+					}
+					return fromContext(p.Context).decks.load(p.Context, deckId), nil
+				},
+			},
+			"card": &graphql.Field{
+				Type:        cardType,
+				Description: "A card of the user or a public one, null when there is none.",
+				Args:        idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return found(r.cards.FindById(p.Context, fromContext(p.Context).userId, p.Args["id"].(string)))
+				},
+			},
+			"playlist": &graphql.Field{
+				Type:        playlistType,
+				Description: "A playlist of the user or a public one, null when there is none.",
+				Args:        idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return found(r.playlists.FindById(p.Context, fromContext(p.Context).userId, p.Args["id"].(string)))
+				},
+			},
+			"review": &graphql.Field{
+				Type:        reviewType,
+				Description: "A review session of the user, null when there is none.",
+				Args:        idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return found(r.reviews.FindById(p.Context, fromContext(p.Context).userId, p.Args["id"].(string)))
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// id resolves the id of the entity get reads from the source.
+func id(get func(source interface{}) *primitive.ObjectID) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		objectID := get(p.Source)
+		if objectID == nil {
+			return nil, nil
+		}
+		return objectID.Hex(), nil
+	}
+}
+
+// found turns the not found error of a lookup into null.
+func found(result interface{}, err error) (interface{}, error) {
+	if err != nil {
+		if errors.Cause(err) == errors.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package health_handler
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+
+	pingTimeout = 2 * time.Second
+)
+
+// Pinger is the database readiness is checked against.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Checker is an additional readiness check, like the MongoDB indexes being in place.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+type Health struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type HealthHandler struct {
+	database Pinger
+	indexes  Checker
+	draining *int32
+}
+
+func NewHealthHandler(database Pinger, indexes Checker) HealthHandler {
+	return HealthHandler{database: database, indexes: indexes, draining: new(int32)}
+}
+
+// Live reports the process is up, it never checks dependencies so a database
+// outage does not get every pod restarted.
+func (handler *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	render.Response(w, Health{Status: StatusUp}, http.StatusOK)
+}
+
+// Ready reports whether the pod should receive traffic: the database answers, its
+// indexes were created and the server is not shutting down.
+func (handler *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(handler.draining) == 1 {
+		render.Response(w, Health{Status: StatusDown, Checks: map[string]string{"server": "shutting down"}}, http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+	defer cancel()
+
+	if err := handler.database.Ping(ctx); err != nil {
+		log.FromContext(r.Context()).Warnw("Readiness check has failed", "error", err.Error())
+		render.Response(w, Health{Status: StatusDown, Checks: map[string]string{"database": StatusDown}}, http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := handler.indexes.Check(ctx); err != nil {
+		log.FromContext(r.Context()).Warnw("Readiness check has failed", "error", err.Error())
+		render.Response(w, Health{Status: StatusDown, Checks: map[string]string{"database": StatusUp, "indexes": StatusDown}}, http.StatusServiceUnavailable)
+		return
+	}
+
+	render.Response(w, Health{Status: StatusUp, Checks: map[string]string{"database": StatusUp, "indexes": StatusUp}}, http.StatusOK)
+}
+
+// Drain makes readiness fail from now on, so the load balancer stops sending
+// requests while the in-flight ones finish.
+func (handler *HealthHandler) Drain() {
+	atomic.StoreInt32(handler.draining, 1)
+}
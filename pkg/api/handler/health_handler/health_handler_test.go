@@ -0,0 +1,53 @@
+package health_handler
+
+import (
+	"context"
+	"errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type pinger struct {
+	err error
+}
+
+func (p pinger) Ping(_ context.Context) error {
+	return p.err
+}
+
+type checker struct {
+	err error
+}
+
+func (c checker) Check(_ context.Context) error {
+	return c.err
+}
+
+func ready(handler HealthHandler) int {
+	recorder := httptest.NewRecorder()
+	handler.Ready(recorder, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	return recorder.Code
+}
+
+func TestReady(t *testing.T) {
+	log.SetupLogger()
+
+	assert.Equal(t, http.StatusOK, ready(NewHealthHandler(pinger{}, checker{})))
+	assert.Equal(t, http.StatusServiceUnavailable, ready(NewHealthHandler(pinger{err: errors.New("no reachable servers")}, checker{})))
+	assert.Equal(t, http.StatusServiceUnavailable, ready(NewHealthHandler(pinger{}, checker{err: errors.New("index build failed")})))
+}
+
+func TestReadyFailsWhileDraining(t *testing.T) {
+	log.SetupLogger()
+	handler := NewHealthHandler(pinger{}, checker{})
+	handler.Drain()
+
+	assert.Equal(t, http.StatusServiceUnavailable, ready(handler))
+
+	recorder := httptest.NewRecorder()
+	handler.Live(recorder, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
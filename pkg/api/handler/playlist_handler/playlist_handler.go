@@ -8,13 +8,16 @@ import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
 	"github.com/gorilla/mux"
+	"io"
 	"net/http"
 	"strconv"
 )
 
 const (
-	headerUserId = "userId"
-	pathVarID    = "id"
+	headerUserId      = "userId"
+	headerContentType = "Content-Type"
+	pathVarID         = "id"
+	pathVarDeckID     = "deckId"
 )
 
 type PlaylistHandler struct {
@@ -28,19 +31,20 @@ func NewPlaylistHandler(service playlist_usecase.IPlaylistUseCase) PlaylistHandl
 func (handler *PlaylistHandler) Post(w http.ResponseWriter, r *http.Request) {
 	requestBody, err := handler.extractBody(r)
 	if err != nil {
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(errors.ErrInvalidPayload))
+		render.Error(w, r, &errors.BadRequest{Err: err})
 		return
 	}
 
 	userID := r.Header.Get(headerUserId)
-	result, err := handler.playlistUseCase.Create(userID, requestBody)
+	result, err := handler.playlistUseCase.Create(r.Context(), userID, requestBody)
 	if err != nil {
-		log.Logger.Errorw("Failed to create playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to create playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Playlist has created successfully")
+	log.FromContext(r.Context()).Debug("Playlist has created successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusCreated)
 }
 
@@ -48,24 +52,25 @@ func (handler *PlaylistHandler) FindById(w http.ResponseWriter, r *http.Request)
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
 
-	result, err := handler.playlistUseCase.FindById(userID, id)
+	result, err := handler.playlistUseCase.FindById(r.Context(), userID, id)
 	if err != nil {
-		log.Logger.Errorw("Failed to find playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Playlist has find successfully")
+	log.FromContext(r.Context()).Debug("Playlist has find successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusOK)
 }
 
 func (handler *PlaylistHandler) FindByUserIdAndPublic(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get(headerUserId)
 
-	result, count, err := handler.playlistUseCase.FindByUserIdAndPublic(userID)
+	result, count, err := handler.playlistUseCase.FindByUserIdAndPublic(r.Context(), userID)
 	if err != nil {
-		log.Logger.Errorw("Failed to find playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -75,10 +80,10 @@ func (handler *PlaylistHandler) FindByUserIdAndPublic(w http.ResponseWriter, r *
 func (handler *PlaylistHandler) FindByUserId(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get(headerUserId)
 
-	result, count, err := handler.playlistUseCase.FindByUserId(userID)
+	result, count, err := handler.playlistUseCase.FindByUserId(r.Context(), userID)
 	if err != nil {
-		log.Logger.Errorw("Failed to find playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -86,38 +91,78 @@ func (handler *PlaylistHandler) FindByUserId(w http.ResponseWriter, r *http.Requ
 	render.Response(w, result, http.StatusOK)
 }
 
-func (handler *PlaylistHandler) Patch(w http.ResponseWriter, r *http.Request) {
+func (handler *PlaylistHandler) Put(w http.ResponseWriter, r *http.Request) {
 	requestBody, err := handler.extractBody(r)
 	if err != nil {
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(errors.ErrInvalidPayload))
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
 		return
 	}
 
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
-	result, err := handler.playlistUseCase.Update(id, userID, true, requestBody)
+	result, err := handler.playlistUseCase.Update(r.Context(), id, userID, requestBody, version)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to update playlist", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Playlist has updated successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *PlaylistHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	document, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to read payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	version, err := render.IfMatch(r, handler.currentVersion(r))
 	if err != nil {
-		log.Logger.Errorw("Failed to update playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Playlist has updated successfully")
+	id := mux.Vars(r)[pathVarID]
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.playlistUseCase.Patch(r.Context(), id, userID, r.Header.Get(headerContentType), document, version)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to update playlist", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Playlist has updated successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusCreated)
 }
 
 func (handler *PlaylistHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	customerID := r.Header.Get(headerUserId)
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
 
 	id := mux.Vars(r)[pathVarID]
-	_, err := handler.playlistUseCase.Delete(id, customerID)
+	_, err = handler.playlistUseCase.Delete(r.Context(), id, customerID, version)
 	if err != nil {
-		log.Logger.Errorw("Failed to remove playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to remove playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Playlist has removed successfully")
+	log.FromContext(r.Context()).Debug("Playlist has removed successfully")
 	render.Response(w, nil, http.StatusNoContent)
 }
 
@@ -125,7 +170,7 @@ func (handler *PlaylistHandler) extractBody(r *http.Request) (*playlist.Playlist
 	var playlistJSON playlist.Playlist
 
 	if err := json.NewDecoder(r.Body).Decode(&playlistJSON); err != nil {
-		log.Logger.Errorw("Error trying to parse payload", "error", err)
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
 		return nil, err
 	}
 
@@ -133,29 +178,118 @@ func (handler *PlaylistHandler) extractBody(r *http.Request) (*playlist.Playlist
 }
 
 func (handler *PlaylistHandler) PatchDeck(w http.ResponseWriter, r *http.Request) {
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
 	deckId := r.Header.Get("deckId")
-	result, err := handler.playlistUseCase.AddDeckToPlaylist(id, userID, deckId)
+	result, err := handler.playlistUseCase.AddDeckToPlaylist(r.Context(), id, userID, deckId, version)
 	if err != nil {
-		log.Logger.Errorw("Failed to update playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to update playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Playlist has updated successfully")
+	log.FromContext(r.Context()).Debug("Playlist has updated successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
 	render.Response(w, result, http.StatusCreated)
 }
 
-func GenerateHTTPErrorStatusCode(err error) int {
-	switch errors.Cause(err).(type) {
-	case *errors.NotFound:
-		return http.StatusNotFound
-	case *errors.InvalidPayload:
-		return http.StatusPreconditionFailed
-	case *errors.BadRequest:
-		return http.StatusBadRequest
-	default:
-		return http.StatusInternalServerError
+func (handler *PlaylistHandler) PostDecks(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		DeckIds []string `json:"deckIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	id := mux.Vars(r)[pathVarID]
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.playlistUseCase.AddDecksToPlaylist(r.Context(), id, userID, requestBody.DeckIds, version)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to add decks to playlist", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Decks have added successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *PlaylistHandler) DeleteDeck(w http.ResponseWriter, r *http.Request) {
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	id := mux.Vars(r)[pathVarID]
+	deckId := mux.Vars(r)[pathVarDeckID]
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.playlistUseCase.RemoveDeckFromPlaylist(r.Context(), id, userID, deckId, version)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to remove deck from playlist", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Deck has removed from playlist successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *PlaylistHandler) PatchDeckPosition(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		Position *int `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil || requestBody.Position == nil {
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
+		render.Error(w, r, errors.WrapWithMessage(errors.ErrInvalidPayload, "position is required"))
+		return
+	}
+
+	version, err := render.IfMatch(r, handler.currentVersion(r))
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	id := mux.Vars(r)[pathVarID]
+	deckId := mux.Vars(r)[pathVarDeckID]
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.playlistUseCase.MoveDeckInPlaylist(r.Context(), id, userID, deckId, *requestBody.Position, version)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to move deck in playlist", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Deck has moved successfully")
+	w.Header().Set(render.HeaderETag, render.ETag(result.Version))
+	render.Response(w, result, http.StatusOK)
+}
+
+// currentVersion reads the version the playlist of the request is at, for an If-Match with
+// several tags.
+func (handler *PlaylistHandler) currentVersion(r *http.Request) func() (int64, error) {
+	return func() (int64, error) {
+		found, err := handler.playlistUseCase.FindById(r.Context(), r.Header.Get(headerUserId), mux.Vars(r)[pathVarID])
+		if err != nil {
+			return 0, err
+		}
+		return found.Version, nil
 	}
 }
@@ -3,9 +3,14 @@ package handlers
 import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/card_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/deck_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/event_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/graphql_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/health_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/playlist_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/review_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/search_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/sync_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/webhook_handler"
 	"github.com/google/wire"
 )
 
@@ -15,4 +20,9 @@ var ApplicationHandlersSet = wire.NewSet(
 	card_handler.NewCardHandler,
 	review_handler.NewReviewHandler,
 	search_handler.NewSearchHandler,
+	webhook_handler.NewWebhookHandler,
+	event_handler.NewEventHandler,
+	sync_handler.NewSyncHandler,
+	graphql_handler.NewGraphQLHandler,
+	health_handler.NewHealthHandler,
 )
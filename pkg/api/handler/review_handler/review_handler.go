@@ -3,7 +3,6 @@ package review_handler
 import (
 	"encoding/json"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
-	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/review_usecase"
@@ -28,14 +27,14 @@ func (handler *ReviewHandler) ReviewPlaylist(w http.ResponseWriter, r *http.Requ
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
 
-	result, err := handler.reviewUseCase.ReviewPlaylists(id, userID)
+	result, err := handler.reviewUseCase.ReviewPlaylists(r.Context(), id, userID)
 	if err != nil {
-		log.Logger.Errorw("Failed to review playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to review playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Playlist has find successfully")
+	log.FromContext(r.Context()).Debug("Playlist has find successfully")
 	render.Response(w, result, http.StatusOK)
 }
 
@@ -43,14 +42,14 @@ func (handler *ReviewHandler) ReviewDeck(w http.ResponseWriter, r *http.Request)
 	id := mux.Vars(r)[pathVarID]
 	userID := r.Header.Get(headerUserId)
 
-	result, err := handler.reviewUseCase.ReviewDecks(id, userID)
+	result, err := handler.reviewUseCase.ReviewDecks(r.Context(), id, userID)
 	if err != nil {
-		log.Logger.Errorw("Failed to review playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to review playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Deck has find successfully")
+	log.FromContext(r.Context()).Debug("Deck has find successfully")
 	render.Response(w, result, http.StatusOK)
 }
 
@@ -59,14 +58,14 @@ func (handler *ReviewHandler) CardResultRight(w http.ResponseWriter, r *http.Req
 	requestBody, err := handler.extractBody(r)
 	userID := r.Header.Get(headerUserId)
 
-	result, err := handler.reviewUseCase.AddCardResult(id, userID, requestBody, true)
+	result, err := handler.reviewUseCase.AddCardResult(r.Context(), id, userID, requestBody, true)
 	if err != nil {
-		log.Logger.Errorw("Failed to review playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to review playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Playlist has find successfully")
+	log.FromContext(r.Context()).Debug("Playlist has find successfully")
 	render.Response(w, result, http.StatusOK)
 }
 func (handler *ReviewHandler) CardResultWrong(w http.ResponseWriter, r *http.Request) {
@@ -74,49 +73,35 @@ func (handler *ReviewHandler) CardResultWrong(w http.ResponseWriter, r *http.Req
 	requestBody, err := handler.extractBody(r)
 	userID := r.Header.Get(headerUserId)
 
-	result, err := handler.reviewUseCase.AddCardResult(id, userID, requestBody, false)
+	result, err := handler.reviewUseCase.AddCardResult(r.Context(), id, userID, requestBody, false)
 	if err != nil {
-		log.Logger.Errorw("Failed to review playlist", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to review playlist", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Playlist has find successfully")
+	log.FromContext(r.Context()).Debug("Playlist has find successfully")
 	render.Response(w, result, http.StatusOK)
 }
 
 func (handler *ReviewHandler) FindById(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get(headerUserId)
 	id := mux.Vars(r)[pathVarID]
-	result, err := handler.reviewUseCase.FindById(userID, id)
+	result, err := handler.reviewUseCase.FindById(r.Context(), userID, id)
 	if err != nil {
-		log.Logger.Errorw("Failed to find card", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find card", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
 	render.Response(w, result, http.StatusOK)
 }
 
-func GenerateHTTPErrorStatusCode(err error) int {
-	switch errors.Cause(err).(type) {
-	case *errors.NotFound:
-		return http.StatusNotFound
-	case *errors.InvalidPayload:
-		return http.StatusPreconditionFailed
-	case *errors.BadRequest:
-		return http.StatusBadRequest
-	default:
-		return http.StatusInternalServerError
-	}
-}
-
 func (handler *ReviewHandler) extractBody(r *http.Request) (*card.Card, error) {
 	var cardJSON card.Card
 
 	if err := json.NewDecoder(r.Body).Decode(&cardJSON); err != nil {
-		log.Logger.Errorw("Error trying to parse payload", "error", err)
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
 		return nil, err
 	}
 
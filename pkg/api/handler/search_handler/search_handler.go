@@ -2,7 +2,6 @@ package search_handler
 
 import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
-	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/search_usecase"
 	"net/http"
@@ -23,26 +22,13 @@ func NewSearchHandler(service search_usecase.ISearchUseCase) SearchHandler {
 func (handler *SearchHandler) FindByFilters(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get(headerUserId)
 	filter := r.URL.Query().Get("filter")
-	search, err := handler.uc.Search(filter, userID)
+	search, err := handler.uc.Search(r.Context(), filter, userID)
 	if err != nil {
-		log.Logger.Errorw("Failed to find by filters", "error", err)
-		render.ResponseError(w, err, GenerateHTTPErrorStatusCode(err))
+		log.FromContext(r.Context()).Errorw("Failed to find by filters", "error", err)
+		render.Error(w, r, err)
 		return
 	}
 
-	log.Logger.Debug("Deck has find successfully")
+	log.FromContext(r.Context()).Debug("Deck has find successfully")
 	render.Response(w, search, http.StatusOK)
 }
-
-func GenerateHTTPErrorStatusCode(err error) int {
-	switch errors.Cause(err).(type) {
-	case *errors.NotFound:
-		return http.StatusNotFound
-	case *errors.InvalidPayload:
-		return http.StatusPreconditionFailed
-	case *errors.BadRequest:
-		return http.StatusBadRequest
-	default:
-		return http.StatusInternalServerError
-	}
-}
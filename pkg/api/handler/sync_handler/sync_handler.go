@@ -0,0 +1,57 @@
+package sync_handler
+
+import (
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/delta"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/sync_usecase"
+	"net/http"
+)
+
+const (
+	headerUserId    = "userId"
+	queryCheckpoint = "checkpoint"
+)
+
+type SyncHandler struct {
+	syncUseCase sync_usecase.ISyncUseCase
+}
+
+func NewSyncHandler(service sync_usecase.ISyncUseCase) SyncHandler {
+	return SyncHandler{syncUseCase: service}
+}
+
+func (handler *SyncHandler) Pull(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get(headerUserId)
+
+	result, err := handler.syncUseCase.Pull(r.Context(), userID, r.URL.Query().Get(queryCheckpoint))
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to pull changes", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *SyncHandler) Push(w http.ResponseWriter, r *http.Request) {
+	var requestBody delta.Push
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.syncUseCase.Push(r.Context(), userID, &requestBody)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to push changes", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Changes have been pushed successfully")
+	render.Response(w, result, http.StatusOK)
+}
@@ -0,0 +1,117 @@
+package webhook_handler
+
+import (
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/webhook"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/webhook_usecase"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+const (
+	headerUserId      = "userId"
+	pathVarID         = "id"
+	pathVarDeliveryID = "deliveryId"
+)
+
+type WebhookHandler struct {
+	webhookUseCase webhook_usecase.IWebhookUseCase
+}
+
+func NewWebhookHandler(service webhook_usecase.IWebhookUseCase) WebhookHandler {
+	return WebhookHandler{webhookUseCase: service}
+}
+
+func (handler *WebhookHandler) Post(w http.ResponseWriter, r *http.Request) {
+	var requestBody webhook.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		log.FromContext(r.Context()).Errorw("Error trying to parse payload", "error", err)
+		render.Error(w, r, &errors.BadRequest{Err: err})
+		return
+	}
+
+	userID := r.Header.Get(headerUserId)
+	result, err := handler.webhookUseCase.Create(r.Context(), userID, &requestBody)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to create webhook", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Webhook has created successfully")
+	render.Response(w, result, http.StatusCreated)
+}
+
+func (handler *WebhookHandler) FindByUserId(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get(headerUserId)
+
+	result, err := handler.webhookUseCase.FindByUserId(r.Context(), userID)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to find webhooks", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *WebhookHandler) FindById(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)[pathVarID]
+	userID := r.Header.Get(headerUserId)
+
+	result, err := handler.webhookUseCase.FindById(r.Context(), userID, id)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to find webhook", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)[pathVarID]
+	userID := r.Header.Get(headerUserId)
+
+	_, err := handler.webhookUseCase.Delete(r.Context(), id, userID)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to remove webhook", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Webhook has removed successfully")
+	render.Response(w, nil, http.StatusNoContent)
+}
+
+func (handler *WebhookHandler) FindDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)[pathVarID]
+	userID := r.Header.Get(headerUserId)
+
+	result, err := handler.webhookUseCase.FindDeliveries(r.Context(), userID, id)
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to find deliveries", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	render.Response(w, result, http.StatusOK)
+}
+
+func (handler *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := r.Header.Get(headerUserId)
+
+	result, err := handler.webhookUseCase.Redeliver(r.Context(), userID, vars[pathVarID], vars[pathVarDeliveryID])
+	if err != nil {
+		log.FromContext(r.Context()).Errorw("Failed to redeliver", "error", err)
+		render.Error(w, r, err)
+		return
+	}
+
+	log.FromContext(r.Context()).Debug("Delivery has queued again successfully")
+	render.Response(w, result, http.StatusAccepted)
+}
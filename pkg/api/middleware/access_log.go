@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// AccessLog writes one entry per request once it is answered. It runs after Trace,
+// so the entry carries the request id.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := newResponseRecorder(w)
+		next.ServeHTTP(recorder, r)
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		logger := log.FromContext(r.Context())
+		fields := []interface{}{
+			"method", r.Method,
+			"route", route,
+			"status", recorder.status,
+			"size", recorder.size,
+			"latency", time.Since(start),
+			"userId", r.Header.Get("userId"),
+		}
+		if recorder.status >= http.StatusInternalServerError {
+			logger.Errorw("request", fields...)
+			return
+		}
+		logger.Infow("request", fields...)
+	})
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogCarriesTheRequestId(t *testing.T) {
+	core, entries := observer.New(zap.InfoLevel)
+	previous := log.Logger
+	log.Logger = zap.New(core).Sugar()
+	defer func() { log.Logger = previous }()
+
+	handler := Trace(AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.FromContext(r.Context()).Infow("in use case")
+		w.WriteHeader(http.StatusCreated)
+	})))
+
+	request := httptest.NewRequest(http.MethodPost, "/flashcards/api/v1/decks", nil)
+	request.Header.Set(headerRequestId, "request-1")
+	request.Header.Set("userId", "user")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	logged := entries.All()
+	assert.Len(t, logged, 2)
+	for _, entry := range logged {
+		assert.Equal(t, "request-1", entry.ContextMap()["requestId"])
+	}
+	access := logged[1].ContextMap()
+	assert.Equal(t, int64(http.StatusCreated), access["status"])
+	assert.Equal(t, "user", access["userId"])
+	assert.Equal(t, "/flashcards/api/v1/decks", access["route"])
+}
+
+func TestTraceReplacesAnInvalidRequestId(t *testing.T) {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	defer func() { log.Logger = previous }()
+
+	handler := Trace(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, requestId := range []string{"bad id\nforged: entry", strings.Repeat("a", 129), "<script>"} {
+		request := httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/decks", nil)
+		request.Header.Set(headerRequestId, requestId)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		generated := recorder.Header().Get(headerRequestId)
+		assert.NotEqual(t, requestId, generated)
+		assert.Regexp(t, "^[0-9a-f]{32}$", generated)
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseNetworks reads addresses and CIDR networks, an address is a network of its own.
+// The entries are validated with the configuration, one that does not parse is skipped.
+func parseNetworks(entries []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// clientIP is the address of the client that sent r. The peer is the client unless it is
+// a trusted proxy; then the addresses of its Forwarded or X-Forwarded-For header are read
+// from the last one, added by the nearest proxy, back to the first one not trusted. The
+// ones before it are chosen by the client and never read.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	peer = normalize(peer)
+	if !contains(trusted, peer) {
+		return peer
+	}
+
+	hops := forwardedFor(r.Header)
+	if len(hops) == 0 {
+		hops = listValues(r.Header.Values("X-Forwarded-For"))
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !contains(trusted, hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return peer
+}
+
+// forwardedFor lists the for parameters of the Forwarded header (RFC 7239), without the
+// quotes, brackets and ports around the addresses.
+func forwardedFor(header http.Header) []string {
+	hops := make([]string, 0)
+	for _, element := range listValues(header.Values("Forwarded")) {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, found := cut(strings.TrimSpace(pair), "=")
+			if !found || !strings.EqualFold(key, "for") {
+				continue
+			}
+			value = strings.Trim(value, `"`)
+			if strings.HasPrefix(value, "[") {
+				value = strings.TrimPrefix(value, "[")
+				value, _, _ = cut(value, "]")
+			} else if host, _, err := net.SplitHostPort(value); err == nil {
+				value = host
+			}
+			hops = append(hops, normalize(value))
+		}
+	}
+	return hops
+}
+
+// listValues splits the comma separated values of a header, over all its lines.
+func listValues(lines []string) []string {
+	values := make([]string, 0)
+	for _, line := range lines {
+		for _, value := range strings.Split(line, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, normalize(value))
+			}
+		}
+	}
+	return values
+}
+
+// normalize writes an address the same way whatever the header, so it keeps its bucket.
+// Anything else, as the "unknown" of Forwarded, is kept as it is.
+func normalize(value string) string {
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.String()
+	}
+	return value
+}
+
+func contains(networks []*net.IPNet, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func cut(s, separator string) (before, after string, found bool) {
+	if i := strings.Index(s, separator); i >= 0 {
+		return s[:i], s[i+len(separator):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CORS struct {
+	config  config.CORS
+	origins map[string]bool
+	any     bool
+}
+
+func NewCORS(config config.CORS) *CORS {
+	cors := &CORS{config: config, origins: make(map[string]bool), any: config.AllowsAnyOrigin()}
+	for _, origin := range config.AllowedOrigins {
+		cors.origins[strings.ToLower(origin)] = true
+	}
+	return cors
+}
+
+// Handle answers preflight requests itself, before the router rejects the OPTIONS
+// method, and adds the Access-Control headers to the actual requests from allowed origins.
+func (c *CORS) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		header := w.Header()
+		header.Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if origin == "" || !c.allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header.Set("Access-Control-Allow-Origin", origin)
+		// Credentials are never allowed to any origin, the configuration refuses it as well.
+		if c.config.AllowCredentials && !c.any {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(c.config.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(c.config.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", strings.Join(c.config.AllowedMethods, ", "))
+		if len(c.config.AllowedHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(c.config.AllowedHeaders, ", "))
+		}
+		if c.config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.config.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (c *CORS) allowed(origin string) bool {
+	return c.any || c.origins[strings.ToLower(origin)]
+}
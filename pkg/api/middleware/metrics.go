@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// Metrics counts requests and measures their latency by route template, so /decks/{id}
+// is a single series whatever the id.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := newResponseRecorder(w)
+		next.ServeHTTP(recorder, r)
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		metrics.ObserveHTTP(route, r.Method, recorder.status, start)
+	})
+}
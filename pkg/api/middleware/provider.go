@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/ratelimit"
+	"github.com/google/wire"
+)
+
+var MiddlewareSet = wire.NewSet(
+	NewRateLimiter,
+	NewCORS,
+	NewSecurityHeaders,
+	ratelimit.NewMemoryStore,
+	wire.Bind(new(ratelimit.Store), new(*ratelimit.MemoryStore)),
+)
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/ratelimit"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
+	"github.com/gorilla/mux"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+type RateLimiter struct {
+	config         config.RateLimit
+	store          ratelimit.Store
+	trustedProxies []*net.IPNet
+}
+
+func NewRateLimiter(config config.RateLimit, store ratelimit.Store) *RateLimiter {
+	return &RateLimiter{config: config, store: store, trustedProxies: parseNetworks(config.TrustedProxies)}
+}
+
+// Limit counts each request against the bucket of its route policy and client IP, taken
+// from the forwarding headers only when the peer is a trusted proxy. The userId header is
+// not used, a client sending a new one on each request would get a new bucket each time. When the store fails the request goes through, an outage of the
+// limiter must not take the API down.
+func (l *RateLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.config.Enabled || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name, policy := l.policy(r)
+		result, err := l.store.Take(r.Context(), name+"|ip:"+clientIP(r, l.trustedProxies), policy)
+		if err != nil {
+			log.FromContext(r.Context()).Warnw("Rate limit store has failed", "error", err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+		header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+		header.Set(HeaderRateLimitReset, seconds(result.Reset))
+		if !result.Allowed {
+			header.Set(HeaderRetryAfter, seconds(result.RetryAfter))
+			render.Error(w, r, errors.WrapWithMessage(errors.ErrTooManyRequests,
+				fmt.Sprintf("rate limit exceeded, retry in %s seconds", seconds(result.RetryAfter))))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// policy picks the route specific policy, keyed by method and path template, falling
+// back to the read or write one.
+func (l *RateLimiter) policy(r *http.Request) (string, ratelimit.Policy) {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			name := r.Method + " " + template
+			if policy, exists := l.config.Routes[name]; exists {
+				return name, policy
+			}
+		}
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return "read", l.config.Read
+	}
+	return "write", l.config.Write
+}
+
+func seconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitIgnoresTheUserIdOfTheClient(t *testing.T) {
+	policy := ratelimit.Policy{Requests: 1, Period: time.Minute, Burst: 1}
+	limiter := NewRateLimiter(config.RateLimit{Enabled: true, Read: policy, Write: policy}, ratelimit.NewMemoryStore())
+	handler := limiter.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	status := func(userId, remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/decks", nil)
+		r.Header.Set("userId", userId)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, status("first", "192.0.2.1:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, status("second", "192.0.2.1:5678"), "a new userId does not get a new bucket")
+	assert.Equal(t, http.StatusOK, status("first", "192.0.2.2:1234"))
+}
+
+func TestRateLimitReadsTheClientIPFromTrustedProxies(t *testing.T) {
+	policy := ratelimit.Policy{Requests: 1, Period: time.Minute, Burst: 1}
+	limiter := NewRateLimiter(config.RateLimit{Enabled: true, Read: policy, Write: policy, TrustedProxies: []string{"10.0.0.0/8", "2001:db8::1"}},
+		ratelimit.NewMemoryStore())
+	handler := limiter.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	status := func(remoteAddr, header, value string) int {
+		r := httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/decks", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, status("10.0.0.5:1234", "X-Forwarded-For", "192.0.2.1"))
+	assert.Equal(t, http.StatusOK, status("10.0.0.6:1234", "X-Forwarded-For", "192.0.2.2"), "each client behind the proxy has a bucket")
+	assert.Equal(t, http.StatusTooManyRequests, status("[2001:db8::1]:443", "Forwarded", `for=192.0.2.1;proto=https`))
+
+	assert.Equal(t, http.StatusOK, status("192.0.2.9:1234", "X-Forwarded-For", "192.0.2.3"))
+	assert.Equal(t, http.StatusTooManyRequests, status("192.0.2.9:5678", "X-Forwarded-For", "192.0.2.4"),
+		"the header of a peer that is not trusted is not read")
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := parseNetworks([]string{"10.0.0.0/8", "2001:db8::1"})
+	tests := []struct {
+		name, remoteAddr, header, value, expected string
+	}{
+		{"no proxy", "192.0.2.1:1234", "", "", "192.0.2.1"},
+		{"spoofed by the client", "192.0.2.1:1234", "X-Forwarded-For", "198.51.100.1", "192.0.2.1"},
+		{"one proxy", "10.0.0.1:1234", "X-Forwarded-For", "198.51.100.1", "198.51.100.1"},
+		{"proxy chain", "10.0.0.1:1234", "X-Forwarded-For", "203.0.113.7, 198.51.100.1, 10.0.0.2", "198.51.100.1"},
+		{"all trusted", "10.0.0.1:1234", "X-Forwarded-For", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"trusted without header", "10.0.0.1:1234", "", "", "10.0.0.1"},
+		{"forwarded", "[2001:db8::1]:443", "Forwarded", `for=203.0.113.7, for="[2001:DB8::2]:4711";proto=https`, "2001:db8::2"},
+		{"forwarded with port", "10.0.0.1:1234", "Forwarded", `For="198.51.100.1:4711"`, "198.51.100.1"},
+		{"forwarded unknown", "10.0.0.1:1234", "Forwarded", `for=unknown`, "unknown"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+		assert.Equal(t, test.expected, clientIP(r, trusted), test.name)
+	}
+}
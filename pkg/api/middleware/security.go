@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type SecurityHeaders struct {
+	config config.Security
+}
+
+func NewSecurityHeaders(config config.Security) *SecurityHeaders {
+	return &SecurityHeaders{config: config}
+}
+
+func (s *SecurityHeaders) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", s.config.FrameOptions)
+		header.Set("Referrer-Policy", "no-referrer")
+		if s.config.HSTSMaxAge > 0 {
+			header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(s.config.HSTSMaxAge.Seconds()))+"; includeSubDomains")
+		}
+		next.ServeHTTP(&htmlPolicyWriter{ResponseWriter: w, policy: s.config.ContentSecurityPolicy}, r)
+	})
+}
+
+// htmlPolicyWriter adds the Content-Security-Policy once the handler has chosen the
+// content type, the JSON responses have no use for it.
+type htmlPolicyWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (w *htmlPolicyWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			w.Header().Set("Content-Security-Policy", w.policy)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *htmlPolicyWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *htmlPolicyWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
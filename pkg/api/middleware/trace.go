@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"regexp"
+)
+
+const headerRequestId = "X-Request-ID"
+
+// requestIdPattern is what a client supplied id can look like, it goes to the response,
+// every log entry and the span.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Trace gives every request an id, taken from X-Request-ID when the client sends a valid one,
+// so error responses can be matched with the server logs: the logger returned by
+// log.FromContext adds it to every entry. Without the header the OpenTelemetry
+// trace id is used, which also finds the request in the collector.
+func Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceId := r.Header.Get(headerRequestId)
+		if !requestIdPattern.MatchString(traceId) {
+			traceId = newTraceId(r)
+		}
+
+		w.Header().Set(headerRequestId, traceId)
+		ctx := render.WithTraceId(r.Context(), traceId)
+		ctx = log.NewContext(ctx, log.Logger.With("requestId", traceId))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newTraceId(r *http.Request) string {
+	if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
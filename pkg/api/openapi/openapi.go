@@ -0,0 +1,115 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/patch"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/render"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"net/http"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+//go:embed docs.html
+var docsHTML []byte
+
+func init() {
+	// PATCH bodies are plain JSON documents, validated against the patch schemas.
+	jsonDecoder := openapi3filter.RegisteredBodyDecoder(patch.ContentTypeJSON)
+	openapi3filter.RegisterBodyDecoder(patch.ContentTypeMergePatch, jsonDecoder)
+	openapi3filter.RegisterBodyDecoder(patch.ContentTypeJSONPatch, jsonDecoder)
+}
+
+// Spec is the OpenAPI document of the API, served to clients and used to validate requests.
+type Spec struct {
+	doc      *openapi3.T
+	router   routers.Router
+	specJSON []byte
+}
+
+func NewSpec() (*Spec, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(specYAML)
+	if err != nil {
+		return nil, errors.WrapWithMessage(err, "cannot load openapi spec")
+	}
+
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, errors.WrapWithMessage(err, "invalid openapi spec")
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, errors.WrapWithMessage(err, "cannot route openapi spec")
+	}
+
+	specJSON, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.WrapWithMessage(err, "cannot encode openapi spec")
+	}
+
+	return &Spec{doc: doc, router: router, specJSON: specJSON}, nil
+}
+
+// Documents tells whether method and path match an operation of the spec.
+func (s *Spec) Documents(method, path string) bool {
+	request, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		return false
+	}
+	_, _, err = s.router.FindRoute(request)
+	return err == nil
+}
+
+func (s *Spec) ServeYAML(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(specYAML)
+}
+
+func (s *Spec) ServeJSON(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(s.specJSON)
+}
+
+func (s *Spec) ServeDocs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(docsHTML)
+}
+
+// Validate rejects requests whose parameters or body do not match the spec.
+// Requests to routes the spec does not describe are left to the router.
+func (s *Spec) Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := s.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Clients have always been allowed to send JSON without saying so.
+		if r.ContentLength != 0 && r.Header.Get("Content-Type") == "" {
+			r.Header.Set("Content-Type", patch.ContentTypeJSON)
+		}
+
+		err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+		})
+		if err != nil {
+			log.FromContext(r.Context()).Errorw("Request does not match the openapi spec", "error", err.Error())
+			render.Error(w, r, &errors.BadRequest{Err: err})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
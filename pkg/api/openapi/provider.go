@@ -0,0 +1,5 @@
+package openapi
+
+import "github.com/google/wire"
+
+var SpecSet = wire.NewSet(NewSpec)
@@ -0,0 +1,59 @@
+package render
+
+import (
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderETag    = "ETag"
+	HeaderIfMatch = "If-Match"
+)
+
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// IfMatch returns the version the client expects the entity to be at, or nil when the
+// request has no If-Match header or accepts any version ("*"). The tags are compared
+// strongly, a weak one never matches. With several tags current reads the version the
+// entity is at, which is expected when it is one of them.
+func IfMatch(r *http.Request, current func() (int64, error)) (*int64, error) {
+	header := strings.TrimSpace(r.Header.Get(HeaderIfMatch))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		version, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(tag, `"`), `"`), 10, 64)
+		if err != nil || len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, &errors.BadRequest{Err: fmt.Errorf("invalid %s header: %s", HeaderIfMatch, header)}
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, errors.WrapWithMessage(errors.ErrVersionMismatch, "a weak entity tag never matches")
+	}
+	if len(versions) == 1 {
+		return &versions[0], nil
+	}
+
+	version, err := current()
+	if err != nil {
+		return nil, err
+	}
+	for _, expected := range versions {
+		if expected == version {
+			return &version, nil
+		}
+	}
+	return nil, errors.WrapWithMessage(errors.ErrVersionMismatch, fmt.Sprintf("the entity is at version %d", version))
+}
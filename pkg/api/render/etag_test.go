@@ -0,0 +1,58 @@
+package render
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatchComparesStrongly(t *testing.T) {
+	reads := 0
+	current := func() (int64, error) {
+		reads++
+		return 4, nil
+	}
+	ifMatch := func(header string) (*int64, error) {
+		r := httptest.NewRequest(http.MethodPut, "/", nil)
+		r.Header.Set(HeaderIfMatch, header)
+		return IfMatch(r, current)
+	}
+
+	version, err := ifMatch("")
+	require.NoError(t, err)
+	assert.Nil(t, version)
+	version, err = ifMatch("*")
+	require.NoError(t, err)
+	assert.Nil(t, version)
+
+	version, err = ifMatch(`"3"`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *version)
+	assert.Zero(t, reads, "a single tag is left to the conditional write")
+
+	version, err = ifMatch(`"3", "4"`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), *version, "any tag of a list matches")
+	version, err = ifMatch(`W/"4", "5"`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *version, "the weak tag is left out of the list")
+	version, err = ifMatch(`"2", "3"`)
+	assert.Nil(t, version)
+	assert.Equal(t, http.StatusPreconditionFailed, statusOf(err))
+
+	_, err = ifMatch(`W/"4"`)
+	assert.Equal(t, http.StatusPreconditionFailed, statusOf(err), "a weak tag never matches")
+
+	for _, malformed := range []string{`4`, `"4`, `"four"`, `"3", 4`} {
+		_, err = ifMatch(malformed)
+		assert.IsType(t, &errors.BadRequest{}, err, malformed)
+	}
+}
+
+func statusOf(err error) int {
+	status, _ := StatusCode(err)
+	return status
+}
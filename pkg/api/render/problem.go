@@ -0,0 +1,195 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/go-playground/validator"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const ContentTypeProblem = "application/problem+json"
+
+// StatusClientClosedRequest is the non standard status, borrowed from nginx, recorded
+// when the client went away before the response was written.
+const StatusClientClosedRequest = 499
+
+// Stable error codes returned in Problem.Code, clients can rely on them.
+const (
+	CodeNotFound             = "not_found"
+	CodeValidationFailed     = "validation_failed"
+	CodeBadRequest           = "bad_request"
+	CodeConflict             = "conflict"
+	CodeVersionMismatch      = "version_mismatch"
+	CodeUnauthorized         = "unauthorized"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeTimeout              = "timeout"
+	CodeClientClosedRequest  = "client_closed_request"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	TraceId  string       `json:"traceId,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes a single field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type traceIdKey struct{}
+
+func WithTraceId(ctx context.Context, traceId string) context.Context {
+	return context.WithValue(ctx, traceIdKey{}, traceId)
+}
+
+func TraceId(ctx context.Context) string {
+	traceId, _ := ctx.Value(traceIdKey{}).(string)
+	return traceId
+}
+
+// StatusCode maps an error returned by the use cases to its HTTP status and error code.
+func StatusCode(err error) (int, string) {
+	cause := errors.Cause(err)
+	if cause == errors.ErrVersionMismatch {
+		return http.StatusPreconditionFailed, CodeVersionMismatch
+	}
+
+	switch cause.(type) {
+	case *errors.NotFound:
+		return http.StatusNotFound, CodeNotFound
+	case *errors.InvalidPayload:
+		return http.StatusUnprocessableEntity, CodeValidationFailed
+	case *errors.BadRequest:
+		return http.StatusBadRequest, CodeBadRequest
+	case *errors.Conflict:
+		return http.StatusConflict, CodeConflict
+	case *errors.Unauthorized:
+		return http.StatusUnauthorized, CodeUnauthorized
+	case *errors.UnsupportedMediaType:
+		return http.StatusUnsupportedMediaType, CodeUnsupportedMediaType
+	case *errors.TooManyRequests:
+		return http.StatusTooManyRequests, CodeRateLimited
+	case *errors.Timeout:
+		return http.StatusGatewayTimeout, CodeTimeout
+	case *errors.Canceled:
+		return StatusClientClosedRequest, CodeClientClosedRequest
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
+
+// Error writes err as an application/problem+json response.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := StatusCode(err)
+	// Whatever the failure looks like, it is the client leaving that caused it.
+	if r.Context().Err() == context.Canceled {
+		status, code = StatusClientClosedRequest, CodeClientClosedRequest
+	}
+
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    statusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+		Code:     code,
+		TraceId:  TraceId(r.Context()),
+		Errors:   fieldErrors(err),
+	}
+
+	if len(problem.Errors) > 0 {
+		problem.Detail = "one or more fields are invalid"
+	}
+	// Internal errors carry driver messages that are of no use to clients.
+	if status == http.StatusInternalServerError {
+		problem.Detail = ""
+	}
+
+	writeProblem(w, problem)
+}
+
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+func writeProblem(w http.ResponseWriter, problem Problem) {
+	jsonResponse, _ := json.Marshal(problem)
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(problem.Status)
+	_, _ = w.Write(jsonResponse)
+}
+
+func fieldErrors(err error) []FieldError {
+	invalidPayload, ok := errors.Cause(err).(*errors.InvalidPayload)
+	if !ok {
+		return nil
+	}
+
+	validationErrors, ok := invalidPayload.Err.(validator.ValidationErrors)
+	if !ok {
+		return nil
+	}
+
+	result := make([]FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		result = append(result, FieldError{
+			Field:   fieldName(fieldError),
+			Rule:    fieldError.Tag(),
+			Message: fieldMessage(fieldError),
+		})
+	}
+	return result
+}
+
+// fieldName drops the struct name from the namespace, "Playlist.decks[0]._id" becomes "decks[0]._id".
+func fieldName(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fieldError validator.FieldError) string {
+	unit := ""
+	switch fieldError.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		unit = " items"
+	}
+
+	switch fieldError.Tag() {
+	case "required", "rfe":
+		return "is required"
+	case "max":
+		return fmt.Sprintf("must have at most %s%s", fieldError.Param(), unit)
+	case "min":
+		return fmt.Sprintf("must have at least %s%s", fieldError.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must have exactly %s%s", fieldError.Param(), unit)
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", fieldError.Param())
+	default:
+		return fmt.Sprintf("failed on the %s rule", fieldError.Tag())
+	}
+}
@@ -0,0 +1,66 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/validator"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorWritesFieldErrors(t *testing.T) {
+	err := validator.NewValidate().Struct(&deck.Deck{Name: strings.Repeat("a", 101), ImageURL: "image", Description: "description"})
+	request := httptest.NewRequest(http.MethodPost, "/flashcards/deck", nil)
+	request = request.WithContext(WithTraceId(request.Context(), "trace"))
+	recorder := httptest.NewRecorder()
+
+	Error(recorder, request, &errors.InvalidPayload{Err: err})
+
+	var problem Problem
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Equal(t, ContentTypeProblem, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, CodeValidationFailed, problem.Code)
+	assert.Equal(t, "trace", problem.TraceId)
+	assert.Equal(t, []FieldError{{Field: "name", Rule: "max", Message: "must have at most 100 characters"}}, problem.Errors)
+}
+
+func TestStatusCode(t *testing.T) {
+	cases := map[error]int{
+		errors.WrapWithMessage(errors.ErrNotFound, "id"):               http.StatusNotFound,
+		errors.WrapWithMessage(errors.ErrVersionMismatch, "id"):        http.StatusPreconditionFailed,
+		errors.WrapWithMessage(errors.ErrConflict, "id"):               http.StatusConflict,
+		errors.ErrUnauthorized:                                         http.StatusUnauthorized,
+		errors.ErrTooManyRequests:                                      http.StatusTooManyRequests,
+		&errors.BadRequest{Err: errors.New("bad")}:                     http.StatusBadRequest,
+		errors.WrapCause(errors.ErrNotFound, context.DeadlineExceeded): http.StatusGatewayTimeout,
+		errors.WrapCause(errors.ErrNotFound, context.Canceled):         StatusClientClosedRequest,
+		errors.WrapCause(errors.ErrNotFound, errors.New("missing")):    http.StatusNotFound,
+		errors.New("boom"):                                             http.StatusInternalServerError,
+	}
+
+	for err, expected := range cases {
+		status, _ := StatusCode(err)
+		assert.Equal(t, expected, status, err.Error())
+	}
+}
+
+func TestErrorWhenClientWentAway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := httptest.NewRequest(http.MethodGet, "/flashcards/deck", nil).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+
+	Error(recorder, request, errors.WrapWithMessage(errors.ErrInternalServer, "query failed"))
+
+	var problem Problem
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(t, StatusClientClosedRequest, recorder.Code)
+	assert.Equal(t, CodeClientClosedRequest, problem.Code)
+	assert.Equal(t, "Client Closed Request", problem.Title)
+}
@@ -2,14 +2,23 @@ package routers
 
 import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/card_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/deck_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/event_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/graphql_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/health_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/playlist_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/review_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/search_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/sync_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/webhook_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/middleware"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/openapi"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/routers"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 	"net/http"
 )
 
@@ -19,32 +28,66 @@ type SystemRoutes struct {
 	cardHandler     card_handler.CardHandler
 	reviewHandler   review_handler.ReviewHandler
 	searchHandler   search_handler.SearchHandler
+	webhookHandler  webhook_handler.WebhookHandler
+	eventHandler    event_handler.EventHandler
+	syncHandler     sync_handler.SyncHandler
+	graphqlHandler  graphql_handler.GraphQLHandler
+	healthHandler   health_handler.HealthHandler
+	spec            *openapi.Spec
+	rateLimiter     *middleware.RateLimiter
+	cors            *middleware.CORS
+	security        *middleware.SecurityHeaders
 }
 
+// SetupHandler wraps the router with CORS, which has to see the preflight requests the
+// router would answer with 405, and the security headers.
 func (sys *SystemRoutes) SetupHandler() http.Handler {
-	r := mux.NewRouter().PathPrefix(routers.BasePath).Subrouter()
+	return sys.cors.Handle(sys.security.Handle(sys.router()))
+}
+
+func (sys *SystemRoutes) router() *mux.Router {
+	root := mux.NewRouter()
+	// The spec and its docs are public, they are served outside the userId checks.
+	root.HandleFunc(routers.BasePath+routers.OpenAPIYAMLPath, sys.spec.ServeYAML).Methods(http.MethodGet)
+	root.HandleFunc(routers.BasePath+routers.OpenAPIJSONPath, sys.spec.ServeJSON).Methods(http.MethodGet)
+	root.HandleFunc(routers.BasePath+routers.DocsPath, sys.spec.ServeDocs).Methods(http.MethodGet)
+
+	root.Handle(routers.MetricsPath, promhttp.Handler()).Methods(http.MethodGet)
+	root.HandleFunc(routers.LivenessPath, sys.healthHandler.Live).Methods(http.MethodGet)
+	root.HandleFunc(routers.ReadinessPath, sys.healthHandler.Ready).Methods(http.MethodGet)
+
+	r := root.PathPrefix(routers.BasePath).Subrouter()
 
 	r.HandleFunc(routers.PlaylistPath, sys.playlistHandler.Post).Methods(http.MethodPost)
 	r.HandleFunc(routers.PlaylistPathAll, sys.playlistHandler.FindByUserIdAndPublic).Methods(http.MethodGet)
 	r.HandleFunc(routers.PlaylistPathId, sys.playlistHandler.FindById).Methods(http.MethodGet)
 	r.HandleFunc(routers.PlaylistPath, sys.playlistHandler.FindByUserId).Methods(http.MethodGet)
 	r.HandleFunc(routers.PlaylistPathId, sys.playlistHandler.Delete).Methods(http.MethodDelete)
+	r.HandleFunc(routers.PlaylistPathId, sys.playlistHandler.Put).Methods(http.MethodPut)
 	r.HandleFunc(routers.PlaylistPathId, sys.playlistHandler.Patch).Methods(http.MethodPatch)
 	r.HandleFunc(routers.PlaylistPathAdd, sys.playlistHandler.PatchDeck).Methods(http.MethodPatch)
+	r.HandleFunc(routers.PlaylistDecksPath, sys.playlistHandler.PostDecks).Methods(http.MethodPost)
+	r.HandleFunc(routers.PlaylistDeckPathId, sys.playlistHandler.DeleteDeck).Methods(http.MethodDelete)
+	r.HandleFunc(routers.PlaylistDeckPositionPath, sys.playlistHandler.PatchDeckPosition).Methods(http.MethodPatch)
 
 	r.HandleFunc(routers.DeckPath, sys.deckHandler.Post).Methods(http.MethodPost)
 	r.HandleFunc(routers.DeckPathAll, sys.deckHandler.FindByUserIdAndPublic).Methods(http.MethodGet)
 	r.HandleFunc(routers.DeckPathId, sys.deckHandler.FindById).Methods(http.MethodGet)
 	r.HandleFunc(routers.DeckPath, sys.deckHandler.FindByUserId).Methods(http.MethodGet)
 	r.HandleFunc(routers.DeckPathId, sys.deckHandler.Delete).Methods(http.MethodDelete)
+	r.HandleFunc(routers.DeckPathId, sys.deckHandler.Put).Methods(http.MethodPut)
 	r.HandleFunc(routers.DeckPathId, sys.deckHandler.Patch).Methods(http.MethodPatch)
 	r.HandleFunc(routers.DeckRecentPath, sys.deckHandler.FindRecent).Methods(http.MethodGet)
+	r.HandleFunc(routers.DeckCardsCountRepairPath, sys.deckHandler.RepairCardsCount).Methods(http.MethodPost)
 
 	r.HandleFunc(routers.CardPathId, sys.cardHandler.Post).Methods(http.MethodPost)
 	r.HandleFunc(routers.CardDeckPathId, sys.cardHandler.FindByDeckId).Methods(http.MethodGet)
+	r.HandleFunc(routers.CardPathId, sys.cardHandler.Put).Methods(http.MethodPut)
 	r.HandleFunc(routers.CardPathId, sys.cardHandler.Patch).Methods(http.MethodPatch)
 	r.HandleFunc(routers.CardPathId, sys.cardHandler.Delete).Methods(http.MethodDelete)
 	r.HandleFunc(routers.CardPathId, sys.cardHandler.FindById).Methods(http.MethodGet)
+	r.HandleFunc(routers.CardDeckImport, sys.cardHandler.Import).Methods(http.MethodPost)
+	r.HandleFunc(routers.CardMovePath, sys.cardHandler.Move).Methods(http.MethodPost)
 
 	r.HandleFunc(routers.PlaylistReviewPath, sys.reviewHandler.ReviewPlaylist).Methods(http.MethodGet)
 	r.HandleFunc(routers.DeckReviewPath, sys.reviewHandler.ReviewDeck).Methods(http.MethodGet)
@@ -54,11 +97,27 @@ func (sys *SystemRoutes) SetupHandler() http.Handler {
 
 	r.HandleFunc(routers.SearchPath, sys.searchHandler.FindByFilters).Methods(http.MethodGet)
 
-	r.Use(middleware.Header)
-	return r
+	r.HandleFunc(routers.WebhookPath, sys.webhookHandler.Post).Methods(http.MethodPost)
+	r.HandleFunc(routers.WebhookPath, sys.webhookHandler.FindByUserId).Methods(http.MethodGet)
+	r.HandleFunc(routers.WebhookPathId, sys.webhookHandler.FindById).Methods(http.MethodGet)
+	r.HandleFunc(routers.WebhookPathId, sys.webhookHandler.Delete).Methods(http.MethodDelete)
+	r.HandleFunc(routers.WebhookDeliveriesPath, sys.webhookHandler.FindDeliveries).Methods(http.MethodGet)
+	r.HandleFunc(routers.WebhookRedeliverPath, sys.webhookHandler.Redeliver).Methods(http.MethodPost)
+
+	r.HandleFunc(routers.EventStreamPath, sys.eventHandler.Stream).Methods(http.MethodGet)
+
+	r.HandleFunc(routers.SyncPath, sys.syncHandler.Pull).Methods(http.MethodGet)
+	r.HandleFunc(routers.SyncPath, sys.syncHandler.Push).Methods(http.MethodPost)
+
+	r.HandleFunc(routers.GraphQLPath, sys.graphqlHandler.Query).Methods(http.MethodPost)
+
+	r.Use(otelmux.Middleware(tracing.ServiceName), middleware.Metrics, middleware.Trace, middleware.AccessLog, sys.rateLimiter.Limit, middleware.Header, sys.spec.Validate)
+	return root
 }
 func NewSystemRoutes(playlistHandler playlist_handler.PlaylistHandler, deckHandler deck_handler.DeckHandler,
-	cardHandler card_handler.CardHandler, reviewHandler review_handler.ReviewHandler, searchHandler search_handler.SearchHandler) SystemRoutes {
+	cardHandler card_handler.CardHandler, reviewHandler review_handler.ReviewHandler, searchHandler search_handler.SearchHandler,
+	webhookHandler webhook_handler.WebhookHandler, eventHandler event_handler.EventHandler, syncHandler sync_handler.SyncHandler, graphqlHandler graphql_handler.GraphQLHandler, healthHandler health_handler.HealthHandler, spec *openapi.Spec, rateLimiter *middleware.RateLimiter,
+	cors *middleware.CORS, security *middleware.SecurityHeaders) SystemRoutes {
 	log.Logger.Info("Creating System Main Routers")
 	return SystemRoutes{
 		playlistHandler: playlistHandler,
@@ -66,5 +125,14 @@ func NewSystemRoutes(playlistHandler playlist_handler.PlaylistHandler, deckHandl
 		cardHandler:     cardHandler,
 		reviewHandler:   reviewHandler,
 		searchHandler:   searchHandler,
+		webhookHandler:  webhookHandler,
+		eventHandler:    eventHandler,
+		syncHandler:     syncHandler,
+		graphqlHandler:  graphqlHandler,
+		healthHandler:   healthHandler,
+		spec:            spec,
+		rateLimiter:     rateLimiter,
+		cors:            cors,
+		security:        security,
 	}
 }
@@ -0,0 +1,184 @@
+package routers
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/ratelimit"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/middleware"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/openapi"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/routers"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var pathVariable = regexp.MustCompile(`{[^}]+}`)
+
+// undocumented are the routes that serve the API itself rather than being part of it.
+var undocumented = map[string]bool{
+	routers.BasePath + routers.OpenAPIYAMLPath: true,
+	routers.BasePath + routers.OpenAPIJSONPath: true,
+	routers.BasePath + routers.DocsPath:        true,
+	routers.MetricsPath:                        true,
+	routers.LivenessPath:                       true,
+	routers.ReadinessPath:                      true,
+}
+
+func newSystemRoutes(t *testing.T) SystemRoutes {
+	log.SetupLogger()
+	spec, err := openapi.NewSpec()
+	assert.Nil(t, err)
+	cfg := config.Default()
+	cfg.CORS.AllowedOrigins = []string{"https://app.flashcards.dev"}
+	return SystemRoutes{
+		spec:        spec,
+		rateLimiter: middleware.NewRateLimiter(cfg.RateLimit, ratelimit.NewMemoryStore()),
+		cors:        middleware.NewCORS(cfg.CORS),
+		security:    middleware.NewSecurityHeaders(cfg.Security),
+	}
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	sys := newSystemRoutes(t)
+	handler := sys.router()
+
+	err := handler.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		if undocumented[template] {
+			return nil
+		}
+
+		path := pathVariable.ReplaceAllString(template, "0123456789abcdef01234567")
+		for _, method := range methods {
+			assert.True(t, sys.spec.Documents(method, path), "%s %s is not in the openapi spec", method, template)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestInvalidRequestsAreRejected(t *testing.T) {
+	sys := newSystemRoutes(t)
+	handler := sys.SetupHandler()
+
+	request := httptest.NewRequest(http.MethodPost, "/flashcards/api/v1/decks", strings.NewReader(`{"name": 1}`))
+	request.Header.Set("userId", "user")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	request = httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/decks/not-an-id", nil)
+	request.Header.Set("userId", "user")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestSpecIsServedWithoutUserId(t *testing.T) {
+	sys := newSystemRoutes(t)
+	handler := sys.SetupHandler()
+
+	for _, path := range []string{"/flashcards/api/v1/openapi.yaml", "/flashcards/api/v1/openapi.json", "/flashcards/api/v1/docs"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, path)
+	}
+}
+
+func TestMetricsAreLabelledByRouteTemplate(t *testing.T) {
+	sys := newSystemRoutes(t)
+	handler := sys.SetupHandler()
+
+	request := httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/decks/not-an-id", nil)
+	request.Header.Set("userId", "user")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(),
+		`flashcards_http_requests_total{method="GET",route="/flashcards/api/v1/decks/{id}",status="400"}`)
+}
+
+func TestRequestsAreRateLimitedPerClient(t *testing.T) {
+	sys := newSystemRoutes(t)
+	rateLimit := config.Default().RateLimit
+	rateLimit.Read = ratelimit.Policy{Requests: 1, Period: time.Minute, Burst: 1}
+	sys.rateLimiter = middleware.NewRateLimiter(rateLimit, ratelimit.NewMemoryStore())
+	handler := sys.SetupHandler()
+
+	get := func(userId, remoteAddr string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/decks/not-an-id", nil)
+		request.Header.Set("userId", userId)
+		request.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("user", "192.0.2.1:1234").Code)
+	recorder := get("user", "192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "60", recorder.Header().Get(middleware.HeaderRetryAfter))
+	assert.Equal(t, "1", recorder.Header().Get(middleware.HeaderRateLimitLimit))
+	assert.Equal(t, "0", recorder.Header().Get(middleware.HeaderRateLimitRemaining))
+	assert.Equal(t, http.StatusTooManyRequests, get("other", "192.0.2.1:5678").Code, "another userId does not get another bucket")
+	assert.Equal(t, http.StatusBadRequest, get("user", "192.0.2.2:1234").Code)
+}
+
+func TestPreflightAndCORSHeaders(t *testing.T) {
+	sys := newSystemRoutes(t)
+	handler := sys.SetupHandler()
+
+	request := httptest.NewRequest(http.MethodOptions, "/flashcards/api/v1/decks/0123456789abcdef01234567", nil)
+	request.Header.Set("Origin", "https://app.flashcards.dev")
+	request.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, "https://app.flashcards.dev", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, recorder.Header().Get("Access-Control-Allow-Methods"), http.MethodPatch)
+	assert.Contains(t, recorder.Header().Get("Access-Control-Allow-Headers"), "If-Match")
+	assert.Equal(t, "600", recorder.Header().Get("Access-Control-Max-Age"))
+
+	request = httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/decks/not-an-id", nil)
+	request.Header.Set("Origin", "https://app.flashcards.dev")
+	request.Header.Set("userId", "user")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, "https://app.flashcards.dev", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, recorder.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+	assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+	assert.Empty(t, recorder.Header().Get("Content-Security-Policy"))
+
+	request = httptest.NewRequest(http.MethodOptions, "/flashcards/api/v1/decks", nil)
+	request.Header.Set("Origin", "https://evil.example")
+	request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestDocsHaveAContentSecurityPolicy(t *testing.T) {
+	sys := newSystemRoutes(t)
+	recorder := httptest.NewRecorder()
+	sys.SetupHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/flashcards/api/v1/docs", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Security-Policy"), "frame-ancestors 'none'")
+	assert.NotEmpty(t, recorder.Header().Get("Strict-Transport-Security"))
+}
@@ -2,8 +2,11 @@ package pkg
 
 import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
-	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
 	handlers "github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/health_handler"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/middleware"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/openapi"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/routers"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase"
@@ -15,7 +18,11 @@ var Container = wire.NewSet(
 	ApplicationSet,
 	repository.Set,
 	usecase.Set,
-	mongodb.MongoDatabaseSet,
+	tracing.TracingSet,
 	handlers.ApplicationHandlersSet,
 	routers.RoutesSet,
+	openapi.SpecSet,
+	middleware.MiddlewareSet,
+	wire.Bind(new(health_handler.Pinger), new(repository.Pinger)),
+	wire.Bind(new(health_handler.Checker), new(repository.Checker)),
 )
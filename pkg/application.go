@@ -2,16 +2,39 @@ package pkg
 
 import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/migration"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/handler/health_handler"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/api/routers"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/webhook_usecase"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type Application struct {
-	SystemRoutes routers.SystemRoutes
+	SystemRoutes   routers.SystemRoutes
+	HealthHandler  health_handler.HealthHandler
+	Indexes        *mongodb.IndexManager
+	Migrator       migration.Migrator
+	Backup         repository.Backuper
+	Webhooks       *webhook_usecase.Dispatcher
+	Events         *event_usecase.Dispatcher
+	Stream         *event_usecase.Stream
+	TracerProvider *sdktrace.TracerProvider
 }
 
-func NewApplication(systemRoutes routers.SystemRoutes) Application {
+func NewApplication(systemRoutes routers.SystemRoutes, healthHandler health_handler.HealthHandler, indexes *mongodb.IndexManager, migrator migration.Migrator, backup repository.Backuper, webhooks *webhook_usecase.Dispatcher, events *event_usecase.Dispatcher, stream *event_usecase.Stream, tracerProvider *sdktrace.TracerProvider) Application {
 	log.Logger.Info("Creating System Main Routers")
 	return Application{
-		SystemRoutes: systemRoutes,
+		SystemRoutes:   systemRoutes,
+		HealthHandler:  healthHandler,
+		Indexes:        indexes,
+		Migrator:       migrator,
+		Backup:         backup,
+		Webhooks:       webhooks,
+		Events:         events,
+		Stream:         stream,
+		TracerProvider: tracerProvider,
 	}
 }
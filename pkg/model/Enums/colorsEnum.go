@@ -1,10 +1,10 @@
 package Enums
 
-type Color int
+type Color string
 
 const (
-	Blue   = "Blue"
-	Red    = "Red"
-	Green  = "Green"
-	Yellow = "Yellow"
+	Blue   Color = "Blue"
+	Red    Color = "Red"
+	Green  Color = "Green"
+	Yellow Color = "Yellow"
 )
@@ -11,8 +11,9 @@ type Card struct {
 	Front      string              `json:"front" bson:"front" validate:"required,max=400"`
 	Back       string              `json:"back" bson:"back" validate:"max=400"`
 	UserId     string              `json:"userId" bson:"userId"`
-	Color      Enums.Color         `json:"color" bson:"color"`
+	Color      Enums.Color         `json:"color" bson:"color" validate:"omitempty,oneof=Blue Red Green Yellow"`
 	DeckId     string              `json:"deckId" bson:"deckId"`
 	IsPrivate  bool                `json:"isPrivate" bson:"isPrivate"`
+	Version    int64               `json:"version" bson:"version"`
 	LastUpdate time.Time           `json:"lastUpdate,omitempty" bson:"lastUpdate,omitempty"`
 }
@@ -14,5 +14,6 @@ type Deck struct {
 	StudySuggestions []string            `json:"studySuggestions" bson:"studySuggestions"`
 	CardsCount       int64               `json:"cardsCount" bson:"cardsCount"`
 	UserId           string              `json:"userId" bson:"userId"`
+	Version          int64               `json:"version" bson:"version"`
 	LastUpdate       time.Time           `json:"lastUpdate,omitempty" bson:"lastUpdate,omitempty"`
 }
@@ -0,0 +1,86 @@
+package delta
+
+import (
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"time"
+)
+
+// The entities a client keeps offline.
+const (
+	Deck     = "deck"
+	Card     = "card"
+	Playlist = "playlist"
+	Review   = "review"
+)
+
+// The operations a client sends. A review session is created on a deck or playlist and
+// answered one card at a time, its other changes belong to the server.
+const (
+	Create = "create"
+	Update = "update"
+	Delete = "delete"
+	Answer = "answer"
+)
+
+// The outcomes of an operation. A conflicting one left the entity as the server had it,
+// the client takes Data in its place or removes the entity when Data is null.
+const (
+	Applied  = "applied"
+	Conflict = "conflict"
+	Rejected = "rejected"
+)
+
+// Change is an entity created or updated since a checkpoint, as it is now, or the
+// tombstone of one deleted, with the entity as it was before the removal.
+type Change struct {
+	Entity    string          `json:"entity"`
+	Id        string          `json:"id"`
+	Deleted   bool            `json:"deleted"`
+	ChangedAt time.Time       `json:"changedAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Pull are the changes since the checkpoint of a client. With Reset the checkpoint was
+// too old or missing, Changes hold every entity and the client replaces what it kept.
+// With HasMore the client pulls again from Checkpoint right away.
+type Pull struct {
+	Checkpoint string    `json:"checkpoint"`
+	Reset      bool      `json:"reset"`
+	HasMore    bool      `json:"hasMore"`
+	Changes    []*Change `json:"changes"`
+}
+
+// Operation is a change a client made offline. Id is chosen by the client on create, so
+// the operations after it can refer to the entity. Version is the one the client changed,
+// 1 for an entity it created, and ChangedAt when it did; without Version the operation
+// only applies when ChangedAt is after the last change on the server.
+type Operation struct {
+	Entity    string          `json:"entity" validate:"required,oneof=deck card playlist review"`
+	Op        string          `json:"op" validate:"required,oneof=create update delete answer"`
+	Id        string          `json:"id" validate:"required"`
+	Version   *int64          `json:"version,omitempty"`
+	ChangedAt time.Time       `json:"changedAt" validate:"required"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// Push is a batch of offline operations, applied in order.
+type Push struct {
+	Operations []*Operation `json:"operations" validate:"required,dive,required"`
+}
+
+// CardResult is the data of an answer operation, the card as the client reviewed it.
+type CardResult struct {
+	Card    *card.Card `json:"card" validate:"required"`
+	IsRight bool       `json:"isRight"`
+}
+
+// Outcome is what became of an operation, Data is the entity as the server keeps it.
+type Outcome struct {
+	Entity string          `json:"entity"`
+	Op     string          `json:"op"`
+	Id     string          `json:"id"`
+	Status string          `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
@@ -0,0 +1,108 @@
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The types of the domain events. A card moved to another deck is updated, a review
+// session is updated on every answer and completed once every card it started with has one.
+const (
+	DeckCreated     = "deck.created"
+	DeckUpdated     = "deck.updated"
+	DeckDeleted     = "deck.deleted"
+	CardCreated     = "card.created"
+	CardUpdated     = "card.updated"
+	CardDeleted     = "card.deleted"
+	PlaylistCreated = "playlist.created"
+	PlaylistUpdated = "playlist.updated"
+	PlaylistDeleted = "playlist.deleted"
+	ReviewStarted   = "review.started"
+	ReviewUpdated   = "review.updated"
+	ReviewCompleted = "review.completed"
+)
+
+// The states of an event in the outbox. A pending event is handed to the subscribers
+// that have not handled it yet at NextAttempt, it fails once every attempt has.
+const (
+	StatusPending    = "pending"
+	StatusDispatched = "dispatched"
+	StatusFailed     = "failed"
+)
+
+// Event is a change to an entity of a user. It is written to the outbox in the
+// transaction of the change and handed to every subscriber at least once, so a
+// subscriber may see it again and tells the repeats by Id.
+type Event struct {
+	Id         *primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Type       string              `json:"type" bson:"type"`
+	UserId     string              `json:"userId" bson:"userId"`
+	EntityId   string              `json:"entityId" bson:"entityId"`
+	Data       json.RawMessage     `json:"data" bson:"data"`
+	OccurredAt time.Time           `json:"occurredAt" bson:"occurredAt"`
+	Status     string              `json:"status" bson:"status"`
+	// Handled names the subscribers done with the event, the next attempt skips them.
+	Handled     []string  `json:"handled" bson:"handled"`
+	Attempts    int       `json:"attempts" bson:"attempts"`
+	Error       string    `json:"error,omitempty" bson:"error,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt" bson:"nextAttempt"`
+}
+
+// HandledBy tells whether subscriber is done with the event.
+func (e *Event) HandledBy(subscriber string) bool {
+	for _, name := range e.Handled {
+		if name == subscriber {
+			return true
+		}
+	}
+	return false
+}
+
+// Cursor is the place of an event among the others, ordered by when they occurred and
+// then by Id.
+type Cursor struct {
+	OccurredAt time.Time
+	Id         primitive.ObjectID
+}
+
+// Cursor is the place of the event, it has to be read from the outbox: the databases
+// keep the times with less precision than the clock.
+func (e *Event) Cursor() Cursor {
+	return Cursor{OccurredAt: e.OccurredAt, Id: *e.Id}
+}
+
+// Before tells whether the event at c comes before the one at other.
+func (c Cursor) Before(other Cursor) bool {
+	if !c.OccurredAt.Equal(other.OccurredAt) {
+		return c.OccurredAt.Before(other.OccurredAt)
+	}
+	return bytes.Compare(c.Id[:], other.Id[:]) < 0
+}
+
+// String writes the cursor as the nanoseconds since the epoch and the hex Id, joined
+// by a dash.
+func (c Cursor) String() string {
+	return strconv.FormatInt(c.OccurredAt.UnixNano(), 10) + "-" + c.Id.Hex()
+}
+
+// ParseCursor reads a cursor written by String.
+func ParseCursor(s string) (Cursor, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return Cursor{}, fmt.Errorf("cursor %q is not a time and an id", s)
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("cursor %q: %w", s, err)
+	}
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return Cursor{}, fmt.Errorf("cursor %q: %w", s, err)
+	}
+	return Cursor{OccurredAt: time.Unix(0, nanos).UTC(), Id: id}, nil
+}
@@ -15,5 +15,6 @@ type Playlist struct {
 	StudySuggestions []string            `json:"studySuggestions" bson:"studySuggestions"`
 	Decks            []deck.DeckPreview  `json:"decks" bson:"decks"`
 	UserId           string              `json:"userId" bson:"userId"`
+	Version          int64               `json:"version" bson:"version"`
 	LastUpdate       time.Time           `json:"lastUpdate,omitempty" bson:"lastUpdate,omitempty"`
 }
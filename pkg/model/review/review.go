@@ -15,5 +15,8 @@ type Review struct {
 	HistsCount    int64               `json:"histsCount" bson:"histsCount"`
 	Mistakes      []*card.Card        `json:"mistakes" bson:"mistakes"`
 	MistakesCount int64               `json:"mistakesCount" bson:"mistakesCount"`
-	LastUpdate    time.Time           `json:"lastUpdate" bson:"lastUpdate"`
+	// CardsCount is the number of cards the session started with, it is completed once
+	// each has an answer. Sessions started before it was kept have 0 and never complete.
+	CardsCount int64     `json:"cardsCount" bson:"cardsCount"`
+	LastUpdate time.Time `json:"lastUpdate" bson:"lastUpdate"`
 }
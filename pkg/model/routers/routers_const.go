@@ -11,15 +11,23 @@ const (
 	PlaylistRecentPath = PlaylistPathId + "/recent"
 	PlaylistPathAdd    = PlaylistPathId + "/deck/add"
 
+	PlaylistDecksPath        = PlaylistPathId + "/decks"
+	PlaylistDeckPathId       = PlaylistDecksPath + "/{deckId}"
+	PlaylistDeckPositionPath = PlaylistDeckPathId + "/position"
+
 	DeckPath       = ApiPath + "/decks"
 	DeckPathId     = DeckPath + "/{id}"
 	DeckReviewPath = DeckPathId + "/review"
 	DeckPathAll    = DeckPath + "/all"
 	DeckRecentPath = DeckPath + "/recent/"
 
+	DeckCardsCountRepairPath = DeckPath + "/cards-count/repair"
+
 	CardPath       = ApiPath + "/cards"
 	CardPathId     = CardPath + "/{id}"
 	CardDeckPathId = CardPath + "/decks" + "/{id}"
+	CardDeckImport = CardDeckPathId + "/import"
+	CardMovePath   = CardPathId + "/move"
 
 	ReviewPath        = ApiPath + "/review"
 	ReviewPathId      = ReviewPath + "/{id}"
@@ -27,4 +35,23 @@ const (
 	ReviewPathIdRight = ReviewPath + "/{id}/right"
 
 	SearchPath = ApiPath + "/search"
+
+	WebhookPath           = ApiPath + "/webhooks"
+	WebhookPathId         = WebhookPath + "/{id}"
+	WebhookDeliveriesPath = WebhookPathId + "/deliveries"
+	WebhookRedeliverPath  = WebhookDeliveriesPath + "/{deliveryId}/redeliver"
+
+	EventStreamPath = ApiPath + "/events"
+
+	SyncPath = ApiPath + "/sync"
+
+	GraphQLPath = ApiPath + "/graphql"
+
+	OpenAPIYAMLPath = ApiPath + "/openapi.yaml"
+	OpenAPIJSONPath = ApiPath + "/openapi.json"
+	DocsPath        = ApiPath + "/docs"
+
+	MetricsPath   = "/metrics"
+	LivenessPath  = "/health/live"
+	ReadinessPath = "/health/ready"
 )
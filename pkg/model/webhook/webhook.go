@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// The events a webhook can subscribe to, the domain events of its user.
+const (
+	DeckCreated     = event.DeckCreated
+	DeckUpdated     = event.DeckUpdated
+	DeckDeleted     = event.DeckDeleted
+	CardCreated     = event.CardCreated
+	CardUpdated     = event.CardUpdated
+	CardDeleted     = event.CardDeleted
+	PlaylistCreated = event.PlaylistCreated
+	PlaylistUpdated = event.PlaylistUpdated
+	PlaylistDeleted = event.PlaylistDeleted
+	ReviewCompleted = event.ReviewCompleted
+)
+
+// The states of a delivery. A pending delivery is sent again at NextAttempt, it fails
+// once every attempt has.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Webhook is a URL the events of its user are posted to. The secret signs every payload,
+// it is only returned when the webhook is created.
+type Webhook struct {
+	Id        *primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	URL       string              `json:"url" bson:"url" validate:"required,url,max=2000"`
+	Events    []string            `json:"events" bson:"events" validate:"required,min=1,dive,oneof=deck.created deck.updated deck.deleted card.created card.updated card.deleted playlist.created playlist.updated playlist.deleted review.completed"`
+	Secret    string              `json:"secret,omitempty" bson:"secret" validate:"omitempty,min=16,max=200"`
+	UserId    string              `json:"userId" bson:"userId"`
+	CreatedAt time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// Subscribes tells whether the webhook receives event.
+func (w *Webhook) Subscribes(event string) bool {
+	for _, subscribed := range w.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is an event posted, or to be posted, to a webhook. It keeps the outcome of the
+// last attempt. A redelivery is a new delivery of the same payload and EventId, which
+// receivers use to drop the duplicates.
+type Delivery struct {
+	Id             *primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	WebhookId      string              `json:"webhookId" bson:"webhookId"`
+	UserId         string              `json:"userId" bson:"userId"`
+	EventId        string              `json:"eventId" bson:"eventId"`
+	Event          string              `json:"event" bson:"event"`
+	Payload        json.RawMessage     `json:"payload" bson:"payload"`
+	Status         string              `json:"status" bson:"status"`
+	Attempts       int                 `json:"attempts" bson:"attempts"`
+	ResponseStatus int                 `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	Error          string              `json:"error,omitempty" bson:"error,omitempty"`
+	NextAttempt    time.Time           `json:"nextAttempt" bson:"nextAttempt"`
+	LastAttempt    time.Time           `json:"lastAttempt,omitempty" bson:"lastAttempt,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt" bson:"createdAt"`
+}
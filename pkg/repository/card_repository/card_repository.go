@@ -1,37 +1,51 @@
 package card_repository
 
 import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/net/context"
-	"os"
 	"time"
 )
 
 type ICardRepository interface {
-	Persist(cardToPersist *card.Card) (*card.Card, error)
-	FindById(userId string, id *primitive.ObjectID, private bool) (result *card.Card, err error)
-	FindByDeckId(userId, deckId string, private bool) (cardReturn []*card.Card, err error)
-	Update(id *primitive.ObjectID, userId string, cardToSave *card.Card) (*card.Card, error)
-	Count(userId string) (count int64, err error)
-	Delete(userId string, id *primitive.ObjectID) (result *card.Card, err error)
+	Persist(ctx context.Context, cardToPersist *card.Card) (*card.Card, error)
+	PersistMany(ctx context.Context, cardsToPersist []*card.Card) ([]*card.Card, error)
+	FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (result *card.Card, err error)
+	FindByDeckId(ctx context.Context, userId, deckId string, private bool) (cardReturn []*card.Card, err error)
+	// FindByDeckIds returns the cards of every deck among deckIds that userId can see.
+	FindByDeckIds(ctx context.Context, userId string, deckIds []string, private bool) (cardReturn []*card.Card, err error)
+	// Update saves cardToSave if the stored version still equals cardToSave.Version and bumps it.
+	// A nil result means nothing matched.
+	Update(ctx context.Context, id *primitive.ObjectID, userId string, cardToSave *card.Card) (*card.Card, error)
+	// UpdateDeckId moves the card to deckId and returns it as it was before. A nil result
+	// means nothing matched.
+	UpdateDeckId(ctx context.Context, userId string, id *primitive.ObjectID, deckId string) (previous *card.Card, err error)
+	Count(ctx context.Context, userId string) (count int64, err error)
+	CountByDeckIds(ctx context.Context, deckIds []string) (counts map[string]int64, err error)
+	// Delete removes the card, only at expectedVersion when it is given. A nil result means nothing matched.
+	Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *card.Card, err error)
 }
 
 type CardRepository struct {
 	client         *mongo.Client
 	database       string
 	cardCollection string
+	timeouts       config.Timeouts
 }
 
-func NewCardRepository(mongoClient *mongo.Client) CardRepository {
+func NewCardRepository(mongoClient *mongo.Client, cfg config.MongoDB) CardRepository {
 	return CardRepository{
 		client:         mongoClient,
-		database:       os.Getenv("MONGODB_DATABASE"),
-		cardCollection: os.Getenv("MONGODB_CARD_COLLECTION"),
+		database:       cfg.Database,
+		cardCollection: cfg.Collections.Card,
+		timeouts:       cfg.Timeouts,
 	}
 }
 
@@ -39,13 +53,22 @@ const (
 	errorString = "error trying to decode result"
 )
 
-func (a CardRepository) Persist(card *card.Card) (*card.Card, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// Indexes are the indexes the queries below rely on.
+func (a CardRepository) Indexes() []mongodb.Index {
+	return []mongodb.Index{
+		{Collection: a.cardCollection, Name: "deckId_userId", Keys: bson.D{{Key: "deckId", Value: 1}, {Key: "userId", Value: 1}}},
+		{Collection: a.cardCollection, Name: "userId", Keys: bson.D{{Key: "userId", Value: 1}}},
+	}
+}
+
+func (a CardRepository) Persist(ctx context.Context, card *card.Card) (saved *card.Card, err error) {
+	defer metrics.ObserveMongo("card", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
 	result, err := a.client.Database(a.database).Collection(a.cardCollection).InsertOne(ctx, card)
 	if err != nil {
-		log.Logger.Errorw("Persist has failed", "error", err)
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
 		return nil, errors.Wrap(err, "error trying to persist entity")
 	}
 	id := result.InsertedID.(primitive.ObjectID)
@@ -54,8 +77,33 @@ func (a CardRepository) Persist(card *card.Card) (*card.Card, error) {
 	return card, nil
 }
 
-func (a CardRepository) FindById(userId string, id *primitive.ObjectID, private bool) (cardReturn *card.Card, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a CardRepository) PersistMany(ctx context.Context, cards []*card.Card) (saved []*card.Card, err error) {
+	defer metrics.ObserveMongo("card", "PersistMany", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	documents := make([]interface{}, 0, len(cards))
+	for _, cardElement := range cards {
+		documents = append(documents, cardElement)
+	}
+
+	result, err := a.client.Database(a.database).Collection(a.cardCollection).InsertMany(ctx, documents)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Persist many has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to persist entities")
+	}
+
+	for i, insertedID := range result.InsertedIDs {
+		id := insertedID.(primitive.ObjectID)
+		cards[i].Id = &id
+	}
+
+	return cards, nil
+}
+
+func (a CardRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (cardReturn *card.Card, err error) {
+	defer metrics.ObserveMongo("card", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 	privateResult := bson.M{}
 	if !private {
@@ -70,20 +118,21 @@ func (a CardRepository) FindById(userId string, id *primitive.ObjectID, private
 	}}
 	result := col.FindOne(ctx, query)
 	if result.Err() != nil {
-		log.Logger.Warn("Find cardReturn by id has failed", "Error", result.Err())
+		log.FromContext(ctx).Warn("Find cardReturn by id has failed", "Error", result.Err())
 		return nil, errors.Wrap(result.Err(), "error trying to find cardReturn by id")
 	}
 
 	err = result.Decode(&cardReturn)
 	if err != nil {
-		log.Logger.Errorw(errorString, "error", err)
+		log.FromContext(ctx).Errorw(errorString, "error", err)
 		return nil, errors.Wrap(err, errorString)
 	}
 
 	return cardReturn, nil
 }
-func (a CardRepository) FindByDeckId(userId, deckId string, private bool) (cardReturn []*card.Card, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a CardRepository) FindByDeckId(ctx context.Context, userId, deckId string, private bool) (cardReturn []*card.Card, err error) {
+	defer metrics.ObserveMongo("card", "FindByDeckId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 	privateResult := bson.M{}
 	if !private {
@@ -98,7 +147,7 @@ func (a CardRepository) FindByDeckId(userId, deckId string, private bool) (cardR
 	}}
 	result, err := col.Find(ctx, query)
 	if err != nil {
-		log.Logger.Errorw("Find has failed", errorString, err.Error())
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
 		return nil, errors.Wrap(err, "error trying to find card")
 	}
 
@@ -107,7 +156,7 @@ func (a CardRepository) FindByDeckId(userId, deckId string, private bool) (cardR
 		var cardElement *card.Card
 		err := result.Decode(&cardElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Card has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Card has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Card")
 		}
 		cardReturn = append(cardReturn, cardElement)
@@ -115,21 +164,22 @@ func (a CardRepository) FindByDeckId(userId, deckId string, private bool) (cardR
 
 	err = result.Close(ctx)
 	if err != nil {
-		log.Logger.Errorw("Error closing context...", "Error", err.Error())
+		log.FromContext(ctx).Errorw("Error closing context...", "Error", err.Error())
 		return nil, errors.Wrap(err, "error trying to find card")
 	}
 	return cardReturn, nil
 }
 
-func (a CardRepository) FindByUserId(userId string) (cardResult []*card.Card, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a CardRepository) FindByUserId(ctx context.Context, userId string) (cardResult []*card.Card, err error) {
+	defer metrics.ObserveMongo("card", "FindByUserId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 
 	col := a.client.Database(a.database).Collection(a.cardCollection)
 	result, err := col.Find(ctx, bson.M{"userId": userId})
 
 	if err != nil {
-		log.Logger.Errorw("Find has failed", errorString, err.Error())
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
 		return nil, errors.Wrap(err, "error trying to find card")
 	}
 
@@ -138,7 +188,7 @@ func (a CardRepository) FindByUserId(userId string) (cardResult []*card.Card, er
 		var cardElement *card.Card
 		err := result.Decode(&cardElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Card has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Card has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Card")
 		}
 		cardResult = append(cardResult, cardElement)
@@ -146,65 +196,188 @@ func (a CardRepository) FindByUserId(userId string) (cardResult []*card.Card, er
 
 	err = result.Close(ctx)
 	if err != nil {
-		log.Logger.Errorw("Error closing context...", "Error", err.Error())
+		log.FromContext(ctx).Errorw("Error closing context...", "Error", err.Error())
 		return nil, errors.Wrap(err, "error trying to find card")
 	}
 
 	return
 }
 
-func (a CardRepository) Count(userId string) (count int64, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a CardRepository) Count(ctx context.Context, userId string) (count int64, err error) {
+	defer metrics.ObserveMongo("card", "Count", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 
 	count, err = a.client.Database(a.database).Collection(a.cardCollection).CountDocuments(ctx, bson.M{"userId": userId})
 	if err != nil {
-		log.Logger.Errorw("Count Cards has failed", "error", err.Error())
+		log.FromContext(ctx).Errorw("Count Cards has failed", "error", err.Error())
 		return 0, errors.Wrap(err, "error trying to count Cards")
 	}
 
 	return count, nil
 }
 
-func (a CardRepository) Delete(userId string, id *primitive.ObjectID) (result *card.Card, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a CardRepository) FindByDeckIds(ctx context.Context, userId string, deckIds []string, private bool) (cardReturn []*card.Card, err error) {
+	defer metrics.ObserveMongo("card", "FindByDeckIds", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+	privateResult := bson.M{}
+	if !private {
+		privateResult = bson.M{"isPrivate": false}
+	}
+
+	col := a.client.Database(a.database).Collection(a.cardCollection)
+
+	query := bson.M{"deckId": bson.M{"$in": deckIds}, "$or": []interface{}{
+		privateResult,
+		bson.M{"userId": userId},
+	}}
+	result, err := col.Find(ctx, query)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
+		return nil, errors.Wrap(err, "error trying to find cards by deck")
+	}
+
+	cardReturn = make([]*card.Card, 0)
+	for result.Next(ctx) {
+		var cardElement *card.Card
+		err := result.Decode(&cardElement)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Card has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Card")
+		}
+		cardReturn = append(cardReturn, cardElement)
+	}
+
+	err = result.Close(ctx)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error closing context...", "Error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find cards by deck")
+	}
+	return cardReturn, nil
+}
+
+func (a CardRepository) CountByDeckIds(ctx context.Context, deckIds []string) (counts map[string]int64, err error) {
+	defer metrics.ObserveMongo("card", "CountByDeckIds", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deckId": bson.M{"$in": deckIds}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$deckId", "count": bson.M{"$sum": 1}}}},
+	}
+	result, err := a.client.Database(a.database).Collection(a.cardCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Count Cards by deck has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to count Cards by deck")
+	}
+
+	counts = make(map[string]int64, len(deckIds))
+	for result.Next(ctx) {
+		var group struct {
+			DeckId string `bson:"_id"`
+			Count  int64  `bson:"count"`
+		}
+		err := result.Decode(&group)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Card count has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Card count")
+		}
+		counts[group.DeckId] = group.Count
+	}
+
+	err = result.Close(ctx)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error closing context...", "Error", err.Error())
+		return nil, errors.Wrap(err, "error trying to count Cards by deck")
+	}
+
+	return counts, nil
+}
+
+func (a CardRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *card.Card, err error) {
+	defer metrics.ObserveMongo("card", "Delete", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
 	query := bson.M{"_id": id,
 		"userId": userId,
 	}
+	if expectedVersion != nil {
+		query["version"] = mongodb.VersionFilter(*expectedVersion)
+	}
 	singleResult := a.client.Database(a.database).
 		Collection(a.cardCollection).FindOneAndDelete(ctx, query)
 
+	if singleResult.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
 	if singleResult.Err() != nil {
-		log.Logger.Errorw("Delete has failed", "Error", err)
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
 		return nil, errors.Wrap(singleResult.Err(), "error trying to delete entity")
 	}
 
 	err = singleResult.Decode(&result)
 	if err != nil {
-		log.Logger.Errorw("Parser Card has failed", "Error", err)
+		log.FromContext(ctx).Errorw("Parser Card has failed", "Error", err)
 		return nil, errors.Wrap(err, "Parser Card has failed")
 	}
 
-	log.Logger.Debug("Card deleted: ", result)
+	log.FromContext(ctx).Debug("Card deleted: ", result)
 	return result, err
 }
 
-func (a CardRepository) Update(id *primitive.ObjectID, userId string, cardToSave *card.Card) (*card.Card, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a CardRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, cardToSave *card.Card) (saved *card.Card, err error) {
+	defer metrics.ObserveMongo("card", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
-	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId}}
+	expectedVersion := cardToSave.Version
+	cardToSave.Version = expectedVersion + 1
+
+	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId},
+		"version": mongodb.VersionFilter(expectedVersion)}
 	updateResult, err := a.client.Database(a.database).Collection(a.cardCollection).ReplaceOne(ctx, filterQuery, cardToSave)
 	if err != nil {
-		log.Logger.Errorw("Update has failed", "error", err)
+		cardToSave.Version = expectedVersion
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
 		return nil, errors.Wrap(err, "error trying to update entity")
 	}
 
 	if updateResult != nil && updateResult.MatchedCount == 0 {
+		cardToSave.Version = expectedVersion
 		return nil, nil
 	}
 	cardToSave.Id = id
 	return cardToSave, nil
 }
+
+func (a CardRepository) UpdateDeckId(ctx context.Context, userId string, id *primitive.ObjectID, deckId string) (previous *card.Card, err error) {
+	defer metrics.ObserveMongo("card", "UpdateDeckId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId}}
+	update := bson.M{
+		"$set": bson.M{"deckId": deckId, "lastUpdate": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+
+	singleResult := a.client.Database(a.database).
+		Collection(a.cardCollection).FindOneAndUpdate(ctx, filterQuery, update)
+	if singleResult.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if singleResult.Err() != nil {
+		log.FromContext(ctx).Errorw("Update deck id has failed", "Error", singleResult.Err())
+		return nil, errors.Wrap(singleResult.Err(), "error trying to update entity")
+	}
+
+	err = singleResult.Decode(&previous)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Parser Card has failed", "Error", err)
+		return nil, errors.Wrap(err, "Parser Card has failed")
+	}
+
+	return previous, nil
+}
@@ -0,0 +1,136 @@
+package card_repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/cache"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/net/context"
+	"time"
+)
+
+// CardCacheRepository reads the cards of a deck through the cache, the list every
+// review of the deck starts from. All the cards of the deck are cached together and
+// filtered by visibility on every read. Single cards are not cached: deleting a deck
+// removes its cards without going through this repository.
+type CardCacheRepository struct {
+	ICardRepository
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+func NewCardCacheRepository(repository ICardRepository, c cache.Cache, ttl time.Duration) CardCacheRepository {
+	return CardCacheRepository{ICardRepository: repository, cache: c, ttl: ttl}
+}
+
+func (a CardCacheRepository) FindByDeckId(ctx context.Context, userId, deckId string, private bool) ([]*card.Card, error) {
+	var cards []*card.Card
+	if !cache.Load(ctx, a.cache, "cards", cache.CardsKey(deckId), &cards) {
+		var err error
+		cards, err = a.ICardRepository.FindByDeckId(ctx, userId, deckId, true)
+		if err != nil {
+			return nil, err
+		}
+		cache.Store(ctx, a.cache, cache.CardsKey(deckId), cards, a.ttl)
+	}
+	if private {
+		return cards, nil
+	}
+
+	visible := make([]*card.Card, 0, len(cards))
+	for _, c := range cards {
+		if !c.IsPrivate || c.UserId == userId {
+			visible = append(visible, c)
+		}
+	}
+	return visible, nil
+}
+
+// FindByDeckIds reads the cards of the decks missing from the cache at once.
+func (a CardCacheRepository) FindByDeckIds(ctx context.Context, userId string, deckIds []string, private bool) ([]*card.Card, error) {
+	cards := make([]*card.Card, 0)
+	missing := make([]string, 0)
+	for _, deckId := range deckIds {
+		var deckCards []*card.Card
+		if cache.Load(ctx, a.cache, "cards", cache.CardsKey(deckId), &deckCards) {
+			cards = append(cards, deckCards...)
+		} else {
+			missing = append(missing, deckId)
+		}
+	}
+	if len(missing) > 0 {
+		found, err := a.ICardRepository.FindByDeckIds(ctx, userId, missing, true)
+		if err != nil {
+			return nil, err
+		}
+		byDeck := make(map[string][]*card.Card, len(missing))
+		for _, deckId := range missing {
+			byDeck[deckId] = make([]*card.Card, 0)
+		}
+		for _, c := range found {
+			byDeck[c.DeckId] = append(byDeck[c.DeckId], c)
+		}
+		for deckId, deckCards := range byDeck {
+			cache.Store(ctx, a.cache, cache.CardsKey(deckId), deckCards, a.ttl)
+		}
+		cards = append(cards, found...)
+	}
+	if private {
+		return cards, nil
+	}
+
+	visible := make([]*card.Card, 0, len(cards))
+	for _, c := range cards {
+		if !c.IsPrivate || c.UserId == userId {
+			visible = append(visible, c)
+		}
+	}
+	return visible, nil
+}
+
+func (a CardCacheRepository) Persist(ctx context.Context, cardToPersist *card.Card) (*card.Card, error) {
+	defer a.invalidate(ctx, cardToPersist.DeckId)
+	return a.ICardRepository.Persist(ctx, cardToPersist)
+}
+
+func (a CardCacheRepository) PersistMany(ctx context.Context, cardsToPersist []*card.Card) ([]*card.Card, error) {
+	deckIds := make([]string, 0, len(cardsToPersist))
+	for _, c := range cardsToPersist {
+		deckIds = append(deckIds, c.DeckId)
+	}
+	defer a.invalidate(ctx, deckIds...)
+	return a.ICardRepository.PersistMany(ctx, cardsToPersist)
+}
+
+// Update never moves the card, it stays in cardToSave.DeckId.
+func (a CardCacheRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, cardToSave *card.Card) (*card.Card, error) {
+	defer a.invalidate(ctx, cardToSave.DeckId)
+	return a.ICardRepository.Update(ctx, id, userId, cardToSave)
+}
+
+func (a CardCacheRepository) UpdateDeckId(ctx context.Context, userId string, id *primitive.ObjectID, deckId string) (*card.Card, error) {
+	previous, err := a.ICardRepository.UpdateDeckId(ctx, userId, id, deckId)
+	a.invalidate(ctx, deckId)
+	if previous != nil {
+		a.invalidate(ctx, previous.DeckId)
+	}
+	return previous, err
+}
+
+func (a CardCacheRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (*card.Card, error) {
+	deleted, err := a.ICardRepository.Delete(ctx, userId, id, expectedVersion)
+	if deleted != nil {
+		a.invalidate(ctx, deleted.DeckId)
+	}
+	return deleted, err
+}
+
+// invalidate removes the cards of the decks once the transaction of ctx has ended, a read
+// made before the commit would cache them again as they were.
+func (a CardCacheRepository) invalidate(ctx context.Context, deckIds ...string) {
+	keys := make([]string, 0, len(deckIds))
+	for _, deckId := range deckIds {
+		keys = append(keys, cache.CardsKey(deckId))
+	}
+	transaction.AfterCommit(ctx, func() { a.cache.Delete(ctx, keys...) })
+}
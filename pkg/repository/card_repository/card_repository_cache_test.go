@@ -0,0 +1,42 @@
+package card_repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/cache"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestCacheFiltersTheCardsOfADeckByVisibility(t *testing.T) {
+	ctx := context.Background()
+	repository := NewCardCacheRepository(NewCardMemoryRepository(), cache.NewLRU(10), time.Minute)
+	_, _ = repository.Persist(ctx, &card.Card{DeckId: "deck", UserId: "owner", Front: "public"})
+	_, _ = repository.Persist(ctx, &card.Card{DeckId: "deck", UserId: "owner", Front: "private", IsPrivate: true})
+
+	owned, err := repository.FindByDeckId(ctx, "owner", "deck", false)
+	assert.NoError(t, err)
+	assert.Len(t, owned, 2)
+
+	visible, err := repository.FindByDeckId(ctx, "other", "deck", false)
+	assert.NoError(t, err)
+	assert.Len(t, visible, 1)
+	assert.Equal(t, "public", visible[0].Front)
+}
+
+func TestCacheInvalidatesBothDecksOnMove(t *testing.T) {
+	ctx := context.Background()
+	repository := NewCardCacheRepository(NewCardMemoryRepository(), cache.NewLRU(10), time.Minute)
+	saved, _ := repository.Persist(ctx, &card.Card{DeckId: "from", UserId: "owner", Front: "a"})
+	_, _ = repository.FindByDeckId(ctx, "owner", "from", false)
+	_, _ = repository.FindByDeckId(ctx, "owner", "to", false)
+
+	_, err := repository.UpdateDeckId(ctx, "owner", saved.Id, "to")
+	assert.NoError(t, err)
+
+	from, _ := repository.FindByDeckId(ctx, "owner", "from", false)
+	to, _ := repository.FindByDeckId(ctx, "owner", "to", false)
+	assert.Empty(t, from)
+	assert.Len(t, to, 1)
+}
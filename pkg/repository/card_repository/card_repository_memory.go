@@ -0,0 +1,185 @@
+package card_repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+// CardMemoryRepository keeps the cards in memory with the same visibility and version
+// rules as CardRepository, so the server runs without MongoDB. Cards are stored and
+// returned as copies, like documents going through the driver.
+type CardMemoryRepository struct {
+	mutex sync.RWMutex
+	cards []*card.Card
+}
+
+func NewCardMemoryRepository() *CardMemoryRepository {
+	return &CardMemoryRepository{cards: make([]*card.Card, 0)}
+}
+
+func (a *CardMemoryRepository) Persist(_ context.Context, cardToPersist *card.Card) (*card.Card, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := a.insert(cardToPersist); err != nil {
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+	return cardToPersist, nil
+}
+
+// PersistMany stops at the first duplicate id, the cards before it stay saved as with
+// an ordered InsertMany.
+func (a *CardMemoryRepository) PersistMany(_ context.Context, cardsToPersist []*card.Card) ([]*card.Card, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, cardElement := range cardsToPersist {
+		if err := a.insert(cardElement); err != nil {
+			return nil, errors.Wrap(err, "error trying to persist entities")
+		}
+	}
+	return cardsToPersist, nil
+}
+
+func (a *CardMemoryRepository) FindById(_ context.Context, userId string, id *primitive.ObjectID, private bool) (*card.Card, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	i := a.find(id)
+	if i < 0 || !visible(a.cards[i], userId, private) {
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "error trying to find cardReturn by id")
+	}
+	return cloneCard(a.cards[i]), nil
+}
+
+func (a *CardMemoryRepository) FindByDeckId(_ context.Context, userId, deckId string, private bool) ([]*card.Card, error) {
+	return a.filter(func(c *card.Card) bool { return c.DeckId == deckId && visible(c, userId, private) }), nil
+}
+
+func (a *CardMemoryRepository) Update(_ context.Context, id *primitive.ObjectID, userId string, cardToSave *card.Card) (*card.Card, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.cards[i].UserId != userId || a.cards[i].Version != cardToSave.Version {
+		return nil, nil
+	}
+
+	cardToSave.Version++
+	cardToSave.Id = id
+	a.cards[i] = cloneCard(cardToSave)
+	return cardToSave, nil
+}
+
+// UpdateDeckId returns the card as it was before the move.
+func (a *CardMemoryRepository) UpdateDeckId(_ context.Context, userId string, id *primitive.ObjectID, deckId string) (*card.Card, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.cards[i].UserId != userId {
+		return nil, nil
+	}
+
+	previous := cloneCard(a.cards[i])
+	a.cards[i].DeckId = deckId
+	a.cards[i].LastUpdate = time.Now()
+	a.cards[i].Version++
+	return previous, nil
+}
+
+func (a *CardMemoryRepository) Count(_ context.Context, userId string) (int64, error) {
+	return int64(len(a.filter(func(c *card.Card) bool { return c.UserId == userId }))), nil
+}
+
+func (a *CardMemoryRepository) FindByDeckIds(_ context.Context, userId string, deckIds []string, private bool) ([]*card.Card, error) {
+	wanted := make(map[string]bool, len(deckIds))
+	for _, deckId := range deckIds {
+		wanted[deckId] = true
+	}
+	return a.filter(func(c *card.Card) bool { return wanted[c.DeckId] && visible(c, userId, private) }), nil
+}
+
+func (a *CardMemoryRepository) CountByDeckIds(_ context.Context, deckIds []string) (map[string]int64, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	wanted := make(map[string]bool, len(deckIds))
+	for _, deckId := range deckIds {
+		wanted[deckId] = true
+	}
+
+	counts := make(map[string]int64, len(deckIds))
+	for _, c := range a.cards {
+		if wanted[c.DeckId] {
+			counts[c.DeckId]++
+		}
+	}
+	return counts, nil
+}
+
+func (a *CardMemoryRepository) Delete(_ context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (*card.Card, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.cards[i].UserId != userId || (expectedVersion != nil && a.cards[i].Version != *expectedVersion) {
+		return nil, nil
+	}
+	deleted := a.cards[i]
+	a.cards = append(a.cards[:i], a.cards[i+1:]...)
+	return deleted, nil
+}
+
+func (a *CardMemoryRepository) insert(cardToPersist *card.Card) error {
+	if cardToPersist.Id == nil {
+		id := primitive.NewObjectID()
+		cardToPersist.Id = &id
+	} else if a.find(cardToPersist.Id) >= 0 {
+		return errors.New("duplicate key " + cardToPersist.Id.Hex())
+	}
+	a.cards = append(a.cards, cloneCard(cardToPersist))
+	return nil
+}
+
+func (a *CardMemoryRepository) find(id *primitive.ObjectID) int {
+	for i, c := range a.cards {
+		if id != nil && *c.Id == *id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *CardMemoryRepository) filter(matches func(c *card.Card) bool) []*card.Card {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	result := make([]*card.Card, 0)
+	for _, c := range a.cards {
+		if matches(c) {
+			result = append(result, cloneCard(c))
+		}
+	}
+	return result
+}
+
+// visible is the $or of the queries above: the owner sees everything, others only the
+// public cards unless private is set.
+func visible(c *card.Card, userId string, private bool) bool {
+	return private || !c.IsPrivate || c.UserId == userId
+}
+
+func cloneCard(c *card.Card) *card.Card {
+	clone := *c
+	if c.Id != nil {
+		id := *c.Id
+		clone.Id = &id
+	}
+	return &clone
+}
@@ -0,0 +1,300 @@
+package card_repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	appErrors "github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/sqldb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/Enums"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/net/context"
+	"strings"
+	"time"
+)
+
+const cardColumns = "id, deck_id, user_id, front, back, color, is_private, version, last_update"
+
+// CardSQLRepository stores the cards in the cards table, each references its deck so
+// a card cannot be saved in a deck that does not exist.
+type CardSQLRepository struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+	timeout time.Duration
+}
+
+func NewCardSQLRepository(db *sql.DB, dialect sqldb.Dialect, timeout time.Duration) CardSQLRepository {
+	return CardSQLRepository{db: db, dialect: dialect, timeout: timeout}
+}
+
+func (a CardSQLRepository) Persist(ctx context.Context, cardToPersist *card.Card) (saved *card.Card, err error) {
+	defer metrics.ObserveSQL("card", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	if err = a.insert(ctx, cardToPersist); err != nil {
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
+		return nil, a.writeError(err, cardToPersist.DeckId, "error trying to persist entity")
+	}
+	return cardToPersist, nil
+}
+
+func (a CardSQLRepository) PersistMany(ctx context.Context, cardsToPersist []*card.Card) (saved []*card.Card, err error) {
+	defer metrics.ObserveSQL("card", "PersistMany", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	for _, cardElement := range cardsToPersist {
+		if err = a.insert(ctx, cardElement); err != nil {
+			log.FromContext(ctx).Errorw("Persist many has failed", "error", err)
+			return nil, a.writeError(err, cardElement.DeckId, "error trying to persist entities")
+		}
+	}
+	return cardsToPersist, nil
+}
+
+func (a CardSQLRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (cardReturn *card.Card, err error) {
+	defer metrics.ObserveSQL("card", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	query, args := "SELECT "+cardColumns+" FROM cards WHERE id = $1", []interface{}{id.Hex()}
+	if !private {
+		query, args = query+" AND (NOT is_private OR user_id = $2)", append(args, userId)
+	}
+
+	cardReturn, err = scanCard(sqldb.Conn(ctx, a.db).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		log.FromContext(ctx).Warn("Find cardReturn by id has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to find cardReturn by id")
+	}
+	return cardReturn, nil
+}
+
+func (a CardSQLRepository) FindByDeckId(ctx context.Context, userId, deckId string, private bool) (cardReturn []*card.Card, err error) {
+	defer metrics.ObserveSQL("card", "FindByDeckId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	query, args := "SELECT "+cardColumns+" FROM cards WHERE deck_id = $1", []interface{}{deckId}
+	if !private {
+		query, args = query+" AND (NOT is_private OR user_id = $2)", append(args, userId)
+	}
+
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
+		return nil, errors.Wrap(err, "error trying to find card")
+	}
+	defer rows.Close()
+
+	cardReturn = make([]*card.Card, 0)
+	for rows.Next() {
+		cardElement, err := scanCard(rows)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Card has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Card")
+		}
+		cardReturn = append(cardReturn, cardElement)
+	}
+	return cardReturn, rows.Err()
+}
+
+func (a CardSQLRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, cardToSave *card.Card) (saved *card.Card, err error) {
+	defer metrics.ObserveSQL("card", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	updateResult, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, `UPDATE cards SET deck_id = $1, user_id = $2, front = $3,
+		back = $4, color = $5, is_private = $6, last_update = $7, version = $8
+		WHERE id = $9 AND user_id = $10 AND version = $11`,
+		cardToSave.DeckId, cardToSave.UserId, cardToSave.Front, cardToSave.Back, string(cardToSave.Color),
+		cardToSave.IsPrivate, cardToSave.LastUpdate, cardToSave.Version+1, id.Hex(), userId, cardToSave.Version)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return nil, a.writeError(err, cardToSave.DeckId, "error trying to update entity")
+	}
+
+	if matched, err := updateResult.RowsAffected(); err != nil || matched == 0 {
+		return nil, err
+	}
+	cardToSave.Id = id
+	cardToSave.Version++
+	return cardToSave, nil
+}
+
+// UpdateDeckId returns the card as it was before the move.
+func (a CardSQLRepository) UpdateDeckId(ctx context.Context, userId string, id *primitive.ObjectID, deckId string) (previous *card.Card, err error) {
+	defer metrics.ObserveSQL("card", "UpdateDeckId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	conn := sqldb.Conn(ctx, a.db)
+	previous, err = scanCard(conn.QueryRowContext(ctx, "SELECT "+cardColumns+" FROM cards WHERE id = $1 AND user_id = $2", id.Hex(), userId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update deck id has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to update entity")
+	}
+
+	_, err = conn.ExecContext(ctx, "UPDATE cards SET deck_id = $1, last_update = $2, version = version + 1 WHERE id = $3 AND user_id = $4",
+		deckId, time.Now(), id.Hex(), userId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update deck id has failed", "Error", err)
+		return nil, a.writeError(err, deckId, "error trying to update entity")
+	}
+	return previous, nil
+}
+
+func (a CardSQLRepository) Count(ctx context.Context, userId string) (count int64, err error) {
+	defer metrics.ObserveSQL("card", "Count", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	err = sqldb.Conn(ctx, a.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM cards WHERE user_id = $1", userId).Scan(&count)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Count Cards has failed", "error", err.Error())
+		return 0, errors.Wrap(err, "error trying to count Cards")
+	}
+	return count, nil
+}
+
+func (a CardSQLRepository) FindByDeckIds(ctx context.Context, userId string, deckIds []string, private bool) (cardReturn []*card.Card, err error) {
+	defer metrics.ObserveSQL("card", "FindByDeckIds", time.Now(), &err)
+	cardReturn = make([]*card.Card, 0)
+	if len(deckIds) == 0 {
+		return cardReturn, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	args := make([]interface{}, 0, len(deckIds)+1)
+	for _, deckId := range deckIds {
+		args = append(args, deckId)
+	}
+	query := "SELECT " + cardColumns + " FROM cards WHERE deck_id IN (" + sqldb.Placeholders(1, len(args)) + ")"
+	if !private {
+		query, args = query+" AND (NOT is_private OR user_id = "+sqldb.Placeholders(len(args)+1, 1)+")", append(args, userId)
+	}
+
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
+		return nil, errors.Wrap(err, "error trying to find cards by deck")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cardElement, err := scanCard(rows)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Card has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Card")
+		}
+		cardReturn = append(cardReturn, cardElement)
+	}
+	return cardReturn, rows.Err()
+}
+
+func (a CardSQLRepository) CountByDeckIds(ctx context.Context, deckIds []string) (counts map[string]int64, err error) {
+	defer metrics.ObserveSQL("card", "CountByDeckIds", time.Now(), &err)
+	counts = make(map[string]int64, len(deckIds))
+	if len(deckIds) == 0 {
+		return counts, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	args := make([]interface{}, 0, len(deckIds))
+	for _, deckId := range deckIds {
+		args = append(args, deckId)
+	}
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx,
+		"SELECT deck_id, COUNT(*) FROM cards WHERE deck_id IN ("+sqldb.Placeholders(1, len(args))+") GROUP BY deck_id", args...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Count Cards by deck has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to count Cards by deck")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deckId string
+		var count int64
+		if err := rows.Scan(&deckId, &count); err != nil {
+			log.FromContext(ctx).Errorw("Parser Card count has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Card count")
+		}
+		counts[strings.TrimSpace(deckId)] = count
+	}
+	return counts, rows.Err()
+}
+
+func (a CardSQLRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *card.Card, err error) {
+	defer metrics.ObserveSQL("card", "Delete", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	query, args := "DELETE FROM cards WHERE id = $1 AND user_id = $2", []interface{}{id.Hex(), userId}
+	if expectedVersion != nil {
+		query, args = query+" AND version = $3", append(args, *expectedVersion)
+	}
+
+	result, err = scanCard(sqldb.Conn(ctx, a.db).QueryRowContext(ctx, query+" RETURNING "+cardColumns, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to delete entity")
+	}
+
+	log.FromContext(ctx).Debug("Card deleted: ", result)
+	return result, nil
+}
+
+func (a CardSQLRepository) insert(ctx context.Context, cardToPersist *card.Card) error {
+	id := primitive.NewObjectID()
+	if cardToPersist.Id != nil {
+		id = *cardToPersist.Id
+	}
+
+	_, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, "INSERT INTO cards ("+cardColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		id.Hex(), cardToPersist.DeckId, cardToPersist.UserId, cardToPersist.Front, cardToPersist.Back,
+		string(cardToPersist.Color), cardToPersist.IsPrivate, cardToPersist.Version, cardToPersist.LastUpdate)
+	if err != nil {
+		return err
+	}
+	cardToPersist.Id = &id
+	return nil
+}
+
+// writeError reports a card written to a missing deck as the deck not being found,
+// like the cards count update does on MongoDB.
+func (a CardSQLRepository) writeError(err error, deckId, message string) error {
+	if a.dialect.IsForeignKeyViolation(err) {
+		return appErrors.WrapWithMessage(appErrors.ErrNotFound, fmt.Sprintf("deck %s not found", deckId))
+	}
+	return errors.Wrap(err, message)
+}
+
+func scanCard(row sqldb.Scanner) (*card.Card, error) {
+	var c card.Card
+	var id, deckId, color string
+	err := row.Scan(&id, &deckId, &c.UserId, &c.Front, &c.Back, &color, &c.IsPrivate, &c.Version, &c.LastUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	c.Id = &objectID
+	c.DeckId = strings.TrimSpace(deckId)
+	c.Color = Enums.Color(color)
+	return &c, nil
+}
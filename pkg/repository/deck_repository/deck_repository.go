@@ -1,39 +1,50 @@
 package deck_repository
 
 import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/net/context"
-	"os"
 	"time"
 )
 
 type IDeckRepository interface {
-	Persist(deckToPersist *deck.Deck) (*deck.Deck, error)
-	FindById(userId string, id *primitive.ObjectID, private bool) (result *deck.Deck, err error)
-	FindByUserIdAndPublic(userId string) (result []*deck.Deck, err error)
-	FindByUserId(userId string) (result []*deck.Deck, err error)
-	Count(userId string) (count int64, err error)
-	Delete(userId string, id *primitive.ObjectID) (result *deck.Deck, err error)
-	Update(id *primitive.ObjectID, userId string, deckToSave *deck.Deck) (*deck.Deck, error)
-	FindByFilters(filter, userId string) (deckResult []map[string]interface{}, err error)
+	Persist(ctx context.Context, deckToPersist *deck.Deck) (*deck.Deck, error)
+	FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (result *deck.Deck, err error)
+	// FindByIds returns the decks among ids that userId can see, the missing ones are left out.
+	FindByIds(ctx context.Context, userId string, ids []*primitive.ObjectID, private bool) (result []*deck.Deck, err error)
+	FindByUserIdAndPublic(ctx context.Context, userId string) (result []*deck.Deck, err error)
+	FindByUserId(ctx context.Context, userId string) (result []*deck.Deck, err error)
+	Count(ctx context.Context, userId string) (count int64, err error)
+	// Delete removes the deck, only at expectedVersion when it is given. A nil result means nothing matched.
+	Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *deck.Deck, err error)
+	// Update saves deckToSave if the stored version still equals deckToSave.Version and bumps it.
+	// A nil result means nothing matched.
+	Update(ctx context.Context, id *primitive.ObjectID, userId string, deckToSave *deck.Deck) (*deck.Deck, error)
+	IncrementCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, delta int64) (updated bool, err error)
+	SetCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, count int64) (updated bool, err error)
+	FindByFilters(ctx context.Context, filter, userId string) (deckResult []map[string]interface{}, err error)
 }
 
 type DeckRepository struct {
 	client         *mongo.Client
 	database       string
 	deckCollection string
+	timeouts       config.Timeouts
 }
 
-func NewDeckRepository(mongoClient *mongo.Client) DeckRepository {
+func NewDeckRepository(mongoClient *mongo.Client, cfg config.MongoDB) DeckRepository {
 	return DeckRepository{
 		client:         mongoClient,
-		database:       os.Getenv("MONGODB_DATABASE"),
-		deckCollection: os.Getenv("MONGODB_DECK_COLLECTION"),
+		database:       cfg.Database,
+		deckCollection: cfg.Collections.Deck,
+		timeouts:       cfg.Timeouts,
 	}
 }
 
@@ -41,13 +52,22 @@ const (
 	errorString = "error trying to decode result"
 )
 
-func (a DeckRepository) Persist(deck *deck.Deck) (*deck.Deck, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// Indexes are the indexes the queries below rely on.
+func (a DeckRepository) Indexes() []mongodb.Index {
+	return []mongodb.Index{
+		{Collection: a.deckCollection, Name: "userId", Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Collection: a.deckCollection, Name: "isPrivate", Keys: bson.D{{Key: "isPrivate", Value: 1}}},
+	}
+}
+
+func (a DeckRepository) Persist(ctx context.Context, deck *deck.Deck) (saved *deck.Deck, err error) {
+	defer metrics.ObserveMongo("deck", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
 	result, err := a.client.Database(a.database).Collection(a.deckCollection).InsertOne(ctx, deck)
 	if err != nil {
-		log.Logger.Errorw("Persist has failed", "error", err)
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
 		return nil, errors.Wrap(err, "error trying to persist entity")
 	}
 	id := result.InsertedID.(primitive.ObjectID)
@@ -56,8 +76,9 @@ func (a DeckRepository) Persist(deck *deck.Deck) (*deck.Deck, error) {
 	return deck, nil
 }
 
-func (a DeckRepository) FindById(userId string, id *primitive.ObjectID, private bool) (deckReturn *deck.Deck, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a DeckRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (deckReturn *deck.Deck, err error) {
+	defer metrics.ObserveMongo("deck", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 	privateResult := bson.M{}
 	if !private {
@@ -72,28 +93,29 @@ func (a DeckRepository) FindById(userId string, id *primitive.ObjectID, private
 	}}
 	result := col.FindOne(ctx, query)
 	if result.Err() != nil {
-		log.Logger.Warn("Find deckReturn by id has failed", "Error", result.Err())
+		log.FromContext(ctx).Warn("Find deckReturn by id has failed", "Error", result.Err())
 		return nil, errors.Wrap(result.Err(), "error trying to find deckReturn by id")
 	}
 
 	err = result.Decode(&deckReturn)
 	if err != nil {
-		log.Logger.Errorw(errorString, "error", err)
+		log.FromContext(ctx).Errorw(errorString, "error", err)
 		return nil, errors.Wrap(err, errorString)
 	}
 
 	return deckReturn, nil
 }
 
-func (a DeckRepository) FindByUserId(userId string) (deckResult []*deck.Deck, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a DeckRepository) FindByUserId(ctx context.Context, userId string) (deckResult []*deck.Deck, err error) {
+	defer metrics.ObserveMongo("deck", "FindByUserId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 
 	col := a.client.Database(a.database).Collection(a.deckCollection)
 	result, err := col.Find(ctx, bson.M{"userId": userId})
 
 	if err != nil {
-		log.Logger.Errorw("Find has failed", errorString, err.Error())
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
 		return nil, errors.Wrap(err, "error trying to find deck")
 	}
 
@@ -102,7 +124,7 @@ func (a DeckRepository) FindByUserId(userId string) (deckResult []*deck.Deck, er
 		var deckElement *deck.Deck
 		err := result.Decode(&deckElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Deck has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Deck has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Deck")
 		}
 		deckResult = append(deckResult, deckElement)
@@ -110,15 +132,56 @@ func (a DeckRepository) FindByUserId(userId string) (deckResult []*deck.Deck, er
 
 	err = result.Close(ctx)
 	if err != nil {
-		log.Logger.Errorw("Error closing context...", "Error", err.Error())
+		log.FromContext(ctx).Errorw("Error closing context...", "Error", err.Error())
 		return nil, errors.Wrap(err, "error trying to find decks")
 	}
 
 	return
 }
 
-func (a DeckRepository) FindByUserIdAndPublic(userId string) (deckResult []*deck.Deck, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a DeckRepository) FindByIds(ctx context.Context, userId string, ids []*primitive.ObjectID, private bool) (deckResult []*deck.Deck, err error) {
+	defer metrics.ObserveMongo("deck", "FindByIds", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+	privateResult := bson.M{}
+	if !private {
+		privateResult = bson.M{"isPrivate": false}
+	}
+
+	col := a.client.Database(a.database).Collection(a.deckCollection)
+
+	query := bson.M{"_id": bson.M{"$in": ids}, "$or": []interface{}{
+		privateResult,
+		bson.M{"userId": userId},
+	}}
+	result, err := col.Find(ctx, query)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
+		return nil, errors.Wrap(err, "error trying to find decks by id")
+	}
+
+	deckResult = make([]*deck.Deck, 0, len(ids))
+	for result.Next(ctx) {
+		var deckElement *deck.Deck
+		err := result.Decode(&deckElement)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Deck has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Deck")
+		}
+		deckResult = append(deckResult, deckElement)
+	}
+
+	err = result.Close(ctx)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error closing context...", "Error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find decks by id")
+	}
+	return deckResult, nil
+}
+
+func (a DeckRepository) FindByUserIdAndPublic(ctx context.Context, userId string) (deckResult []*deck.Deck, err error) {
+	defer metrics.ObserveMongo("deck", "FindByUserIdAndPublic", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 
 	col := a.client.Database(a.database).Collection(a.deckCollection)
@@ -129,7 +192,7 @@ func (a DeckRepository) FindByUserIdAndPublic(userId string) (deckResult []*deck
 	result, err := col.Find(ctx, query)
 
 	if err != nil {
-		log.Logger.Errorw("Find has failed", errorString, err.Error())
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
 		return nil, errors.Wrap(err, "error trying to find decks")
 	}
 
@@ -138,59 +201,80 @@ func (a DeckRepository) FindByUserIdAndPublic(userId string) (deckResult []*deck
 		var deckElement *deck.Deck
 		err := result.Decode(&deckElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Deck has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Deck has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Deck")
 		}
 		deckResult = append(deckResult, deckElement)
 	}
 	return
 }
-func (a DeckRepository) Count(userId string) (count int64, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a DeckRepository) Count(ctx context.Context, userId string) (count int64, err error) {
+	defer metrics.ObserveMongo("deck", "Count", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 
 	count, err = a.client.Database(a.database).Collection(a.deckCollection).CountDocuments(ctx, bson.M{"userId": userId})
 	if err != nil {
-		log.Logger.Errorw("Count Decks has failed", "error", err.Error())
+		log.FromContext(ctx).Errorw("Count Decks has failed", "error", err.Error())
 		return 0, errors.Wrap(err, "error trying to count Decks")
 	}
 
 	return count, nil
 }
 
-func (a DeckRepository) Delete(userId string, id *primitive.ObjectID) (result *deck.Deck, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a DeckRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *deck.Deck, err error) {
+	defer metrics.ObserveMongo("deck", "Delete", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
 	query := bson.M{"_id": id,
 		"userId": userId,
 	}
+	if expectedVersion != nil {
+		query["version"] = mongodb.VersionFilter(*expectedVersion)
+	}
 	singleResult := a.client.Database(a.database).
 		Collection(a.deckCollection).FindOneAndDelete(ctx, query)
 
+	if singleResult.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
 	if singleResult.Err() != nil {
-		log.Logger.Errorw("Delete has failed", "Error", err)
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
 		return nil, errors.Wrap(singleResult.Err(), "error trying to delete entity")
 	}
 
 	err = singleResult.Decode(&result)
 	if err != nil {
-		log.Logger.Errorw("Parser Deck has failed", "Error", err)
+		log.FromContext(ctx).Errorw("Parser Deck has failed", "Error", err)
 		return nil, errors.Wrap(err, "Parser Deck has failed")
 	}
 
-	log.Logger.Debug("Deck deleted: ", result)
+	log.FromContext(ctx).Debug("Deck deleted: ", result)
 	return result, err
 }
 
-func (a DeckRepository) Update(id *primitive.ObjectID, userId string, deckToSave *deck.Deck) (*deck.Deck, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a DeckRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, deckToSave *deck.Deck) (saved *deck.Deck, err error) {
+	defer metrics.ObserveMongo("deck", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
-	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId}}
-	updateResult, err := a.client.Database(a.database).Collection(a.deckCollection).ReplaceOne(ctx, filterQuery, deckToSave)
+	// cardsCount is left out of the $set so concurrent card writes are not overwritten.
+	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId},
+		"version": mongodb.VersionFilter(deckToSave.Version)}
+	update := bson.M{"$set": bson.M{
+		"imageURL":         deckToSave.ImageURL,
+		"name":             deckToSave.Name,
+		"description":      deckToSave.Description,
+		"isPrivate":        deckToSave.IsPrivate,
+		"studySuggestions": deckToSave.StudySuggestions,
+		"userId":           deckToSave.UserId,
+		"lastUpdate":       deckToSave.LastUpdate,
+		"version":          deckToSave.Version + 1,
+	}}
+	updateResult, err := a.client.Database(a.database).Collection(a.deckCollection).UpdateOne(ctx, filterQuery, update)
 	if err != nil {
-		log.Logger.Errorw("Update has failed", "error", err)
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
 		return nil, errors.Wrap(err, "error trying to update entity")
 	}
 
@@ -198,11 +282,47 @@ func (a DeckRepository) Update(id *primitive.ObjectID, userId string, deckToSave
 		return nil, nil
 	}
 	deckToSave.Id = id
+	deckToSave.Version++
 	return deckToSave, nil
 }
 
-func (a DeckRepository) FindByFilters(filter, userId string) (deckResult []map[string]interface{}, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a DeckRepository) IncrementCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, delta int64) (updated bool, err error) {
+	defer metrics.ObserveMongo("deck", "IncrementCardsCount", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId}}
+	update := bson.M{"$inc": bson.M{"cardsCount": delta}}
+
+	updateResult, err := a.client.Database(a.database).Collection(a.deckCollection).UpdateOne(ctx, filterQuery, update)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Increment cards count has failed", "error", err)
+		return false, errors.Wrap(err, "error trying to increment cards count")
+	}
+
+	return updateResult.MatchedCount > 0, nil
+}
+
+func (a DeckRepository) SetCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, count int64) (updated bool, err error) {
+	defer metrics.ObserveMongo("deck", "SetCardsCount", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId}}
+	update := bson.M{"$set": bson.M{"cardsCount": count}}
+
+	updateResult, err := a.client.Database(a.database).Collection(a.deckCollection).UpdateOne(ctx, filterQuery, update)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Set cards count has failed", "error", err)
+		return false, errors.Wrap(err, "error trying to set cards count")
+	}
+
+	return updateResult.MatchedCount > 0, nil
+}
+
+func (a DeckRepository) FindByFilters(ctx context.Context, filter, userId string) (deckResult []map[string]interface{}, err error) {
+	defer metrics.ObserveMongo("deck", "FindByFilters", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Search)
 	defer cancel()
 
 	col := a.client.Database(a.database).Collection(a.deckCollection)
@@ -216,7 +336,7 @@ func (a DeckRepository) FindByFilters(filter, userId string) (deckResult []map[s
 	result, err := col.Find(ctx, query)
 
 	if err != nil {
-		log.Logger.Errorw("Find has failed", errorString, err.Error())
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
 		return nil, errors.Wrap(err, "error trying to find decks")
 	}
 
@@ -225,7 +345,7 @@ func (a DeckRepository) FindByFilters(filter, userId string) (deckResult []map[s
 		var deckElement map[string]interface{}
 		err := result.Decode(&deckElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Deck has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Deck has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Deck")
 		}
 		deckResult = append(deckResult, deckElement)
@@ -0,0 +1,138 @@
+package deck_repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/cache"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/net/context"
+	"time"
+)
+
+// DeckCacheRepository reads decks through the cache. A deck is cached whoever owns it
+// and its visibility is checked on every read; the reads with private set come before a
+// write and always go to the repository. Every write invalidates what it changes once its
+// transaction has ended.
+type DeckCacheRepository struct {
+	IDeckRepository
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+func NewDeckCacheRepository(repository IDeckRepository, c cache.Cache, ttl time.Duration) DeckCacheRepository {
+	return DeckCacheRepository{IDeckRepository: repository, cache: c, ttl: ttl}
+}
+
+func (a DeckCacheRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (*deck.Deck, error) {
+	if private {
+		return a.IDeckRepository.FindById(ctx, userId, id, private)
+	}
+
+	var found *deck.Deck
+	if !cache.Load(ctx, a.cache, "deck", cache.DeckKey(id.Hex()), &found) {
+		var err error
+		found, err = a.IDeckRepository.FindById(ctx, userId, id, true)
+		if err != nil {
+			return nil, err
+		}
+		cache.Store(ctx, a.cache, cache.DeckKey(id.Hex()), found, a.ttl)
+	}
+
+	if found.IsPrivate && found.UserId != userId {
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "error trying to find deck by id")
+	}
+	return found, nil
+}
+
+// FindByIds reads the decks missing from the cache at once.
+func (a DeckCacheRepository) FindByIds(ctx context.Context, userId string, ids []*primitive.ObjectID, private bool) ([]*deck.Deck, error) {
+	if private {
+		return a.IDeckRepository.FindByIds(ctx, userId, ids, private)
+	}
+
+	decks := make([]*deck.Deck, 0, len(ids))
+	missing := make([]*primitive.ObjectID, 0)
+	for _, id := range ids {
+		var found *deck.Deck
+		if cache.Load(ctx, a.cache, "deck", cache.DeckKey(id.Hex()), &found) {
+			decks = append(decks, found)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		found, err := a.IDeckRepository.FindByIds(ctx, userId, missing, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range found {
+			cache.Store(ctx, a.cache, cache.DeckKey(d.Id.Hex()), d, a.ttl)
+		}
+		decks = append(decks, found...)
+	}
+
+	visible := make([]*deck.Deck, 0, len(decks))
+	for _, d := range decks {
+		if !d.IsPrivate || d.UserId == userId {
+			visible = append(visible, d)
+		}
+	}
+	return visible, nil
+}
+
+func (a DeckCacheRepository) FindByUserIdAndPublic(ctx context.Context, userId string) ([]*deck.Deck, error) {
+	key := cache.PublicKey(cache.DeckGroup, cache.Generation(ctx, a.cache, cache.DeckGroup), userId)
+	var decks []*deck.Deck
+	if cache.Load(ctx, a.cache, "decks", key, &decks) {
+		return decks, nil
+	}
+
+	decks, err := a.IDeckRepository.FindByUserIdAndPublic(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	cache.Store(ctx, a.cache, key, decks, a.ttl)
+	return decks, nil
+}
+
+func (a DeckCacheRepository) Persist(ctx context.Context, deckToPersist *deck.Deck) (*deck.Deck, error) {
+	defer transaction.AfterCommit(ctx, func() { cache.Invalidate(ctx, a.cache, cache.DeckGroup) })
+	return a.IDeckRepository.Persist(ctx, deckToPersist)
+}
+
+// Delete also invalidates the cards of the deck and the playlists, which lose the deck
+// on the SQL storages.
+func (a DeckCacheRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (*deck.Deck, error) {
+	defer transaction.AfterCommit(ctx, func() {
+		a.cache.Delete(ctx, cache.DeckKey(id.Hex()), cache.CardsKey(id.Hex()))
+		cache.Invalidate(ctx, a.cache, cache.DeckGroup)
+		cache.Invalidate(ctx, a.cache, cache.PlaylistGroup)
+	})
+	return a.IDeckRepository.Delete(ctx, userId, id, expectedVersion)
+}
+
+func (a DeckCacheRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, deckToSave *deck.Deck) (*deck.Deck, error) {
+	defer a.invalidate(ctx, id)
+	return a.IDeckRepository.Update(ctx, id, userId, deckToSave)
+}
+
+func (a DeckCacheRepository) IncrementCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, delta int64) (bool, error) {
+	defer a.invalidate(ctx, id)
+	return a.IDeckRepository.IncrementCardsCount(ctx, userId, id, delta)
+}
+
+func (a DeckCacheRepository) SetCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, count int64) (bool, error) {
+	defer a.invalidate(ctx, id)
+	return a.IDeckRepository.SetCardsCount(ctx, userId, id, count)
+}
+
+// invalidate removes the deck once the transaction of ctx has ended, a read made before
+// the commit would cache it again as it was.
+func (a DeckCacheRepository) invalidate(ctx context.Context, id *primitive.ObjectID) {
+	transaction.AfterCommit(ctx, func() {
+		a.cache.Delete(ctx, cache.DeckKey(id.Hex()))
+		cache.Invalidate(ctx, a.cache, cache.DeckGroup)
+	})
+}
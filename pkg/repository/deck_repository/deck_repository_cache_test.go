@@ -0,0 +1,71 @@
+package deck_repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/cache"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestCacheChecksVisibilityOnHits(t *testing.T) {
+	ctx := context.Background()
+	repository := NewDeckCacheRepository(NewDeckMemoryRepository(), cache.NewLRU(10), time.Minute)
+	private, _ := repository.Persist(ctx, &deck.Deck{Name: "Verbs", UserId: "owner", IsPrivate: true})
+
+	found, err := repository.FindById(ctx, "owner", private.Id, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "Verbs", found.Name)
+
+	_, err = repository.FindById(ctx, "other", private.Id, false)
+	assert.Error(t, err)
+}
+
+func TestCacheIsInvalidatedOnWrites(t *testing.T) {
+	ctx := context.Background()
+	memory := NewDeckMemoryRepository()
+	repository := NewDeckCacheRepository(memory, cache.NewLRU(10), time.Minute)
+	saved, _ := repository.Persist(ctx, &deck.Deck{Name: "Verbs", UserId: "owner"})
+	listed, _ := repository.FindByUserIdAndPublic(ctx, "other")
+	assert.Len(t, listed, 1)
+	_, _ = repository.FindById(ctx, "other", saved.Id, false)
+
+	_, _ = memory.Persist(ctx, &deck.Deck{Name: "Behind the cache", UserId: "owner"})
+	listed, _ = repository.FindByUserIdAndPublic(ctx, "other")
+	assert.Len(t, listed, 1, "served from the cache")
+
+	_, err := repository.IncrementCardsCount(ctx, "owner", saved.Id, 3)
+	assert.NoError(t, err)
+
+	found, err := repository.FindById(ctx, "other", saved.Id, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), found.CardsCount)
+	listed, _ = repository.FindByUserIdAndPublic(ctx, "other")
+	assert.Len(t, listed, 2)
+
+	_, _ = repository.Delete(ctx, "owner", saved.Id, nil)
+	_, err = repository.FindById(ctx, "other", saved.Id, false)
+	assert.Error(t, err)
+}
+
+func TestCacheIsInvalidatedOnceTheTransactionHasEnded(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	repository := NewDeckCacheRepository(NewDeckMemoryRepository(), c, time.Minute)
+	saved, _ := repository.Persist(ctx, &deck.Deck{Name: "Verbs", UserId: "owner"})
+	stale := *saved
+
+	err := transaction.NewAfterCommitManager(transaction.NewMemoryTransactionManager()).WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := repository.IncrementCardsCount(ctx, "owner", saved.Id, 3)
+		// A read outside of the transaction does not see the count before it commits.
+		cache.Store(ctx, c, cache.DeckKey(saved.Id.Hex()), &stale, time.Minute)
+		return err
+	})
+	assert.NoError(t, err)
+
+	found, err := repository.FindById(ctx, "other", saved.Id, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), found.CardsCount)
+}
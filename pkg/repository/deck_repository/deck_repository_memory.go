@@ -0,0 +1,190 @@
+package deck_repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/net/context"
+	"regexp"
+	"sync"
+)
+
+// DeckMemoryRepository keeps the decks in memory with the same visibility and version
+// rules as DeckRepository, so the server runs without MongoDB. Decks are stored and
+// returned as copies, like documents going through the driver.
+type DeckMemoryRepository struct {
+	mutex sync.RWMutex
+	decks []*deck.Deck
+}
+
+func NewDeckMemoryRepository() *DeckMemoryRepository {
+	return &DeckMemoryRepository{decks: make([]*deck.Deck, 0)}
+}
+
+func (a *DeckMemoryRepository) Persist(_ context.Context, deckToPersist *deck.Deck) (*deck.Deck, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if deckToPersist.Id == nil {
+		id := primitive.NewObjectID()
+		deckToPersist.Id = &id
+	} else if a.find(deckToPersist.Id) >= 0 {
+		return nil, errors.New("error trying to persist entity: duplicate key " + deckToPersist.Id.Hex())
+	}
+	a.decks = append(a.decks, cloneDeck(deckToPersist))
+	return deckToPersist, nil
+}
+
+func (a *DeckMemoryRepository) FindById(_ context.Context, userId string, id *primitive.ObjectID, private bool) (*deck.Deck, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	i := a.find(id)
+	if i < 0 || !visible(a.decks[i], userId, private) {
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "error trying to find deckReturn by id")
+	}
+	return cloneDeck(a.decks[i]), nil
+}
+
+func (a *DeckMemoryRepository) FindByIds(_ context.Context, userId string, ids []*primitive.ObjectID, private bool) ([]*deck.Deck, error) {
+	wanted := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		wanted[*id] = true
+	}
+	return a.filter(func(d *deck.Deck) bool { return wanted[*d.Id] && visible(d, userId, private) }), nil
+}
+
+func (a *DeckMemoryRepository) FindByUserIdAndPublic(_ context.Context, userId string) ([]*deck.Deck, error) {
+	return a.filter(func(d *deck.Deck) bool { return visible(d, userId, false) }), nil
+}
+
+func (a *DeckMemoryRepository) FindByUserId(_ context.Context, userId string) ([]*deck.Deck, error) {
+	return a.filter(func(d *deck.Deck) bool { return d.UserId == userId }), nil
+}
+
+func (a *DeckMemoryRepository) Count(_ context.Context, userId string) (int64, error) {
+	return int64(len(a.filter(func(d *deck.Deck) bool { return d.UserId == userId }))), nil
+}
+
+func (a *DeckMemoryRepository) Delete(_ context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (*deck.Deck, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.decks[i].UserId != userId || (expectedVersion != nil && a.decks[i].Version != *expectedVersion) {
+		return nil, nil
+	}
+	deleted := a.decks[i]
+	a.decks = append(a.decks[:i], a.decks[i+1:]...)
+	return deleted, nil
+}
+
+func (a *DeckMemoryRepository) Update(_ context.Context, id *primitive.ObjectID, userId string, deckToSave *deck.Deck) (*deck.Deck, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.decks[i].UserId != userId || a.decks[i].Version != deckToSave.Version {
+		return nil, nil
+	}
+
+	// cardsCount is kept, as in DeckRepository.Update.
+	saved := cloneDeck(deckToSave)
+	storedId := *id
+	saved.Id = &storedId
+	saved.CardsCount = a.decks[i].CardsCount
+	saved.Version++
+	a.decks[i] = saved
+
+	deckToSave.Id = id
+	deckToSave.Version++
+	return deckToSave, nil
+}
+
+func (a *DeckMemoryRepository) IncrementCardsCount(_ context.Context, userId string, id *primitive.ObjectID, delta int64) (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.decks[i].UserId != userId {
+		return false, nil
+	}
+	a.decks[i].CardsCount += delta
+	return true, nil
+}
+
+func (a *DeckMemoryRepository) SetCardsCount(_ context.Context, userId string, id *primitive.ObjectID, count int64) (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.decks[i].UserId != userId {
+		return false, nil
+	}
+	a.decks[i].CardsCount = count
+	return true, nil
+}
+
+func (a *DeckMemoryRepository) FindByFilters(_ context.Context, filter, userId string) ([]map[string]interface{}, error) {
+	name, err := regexp.Compile("(?i).*" + filter + ".*")
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to find decks")
+	}
+
+	decks := a.filter(func(d *deck.Deck) bool { return visible(d, userId, false) && name.MatchString(d.Name) })
+	deckResult := make([]map[string]interface{}, 0, len(decks))
+	for _, d := range decks {
+		document, err := bson.Marshal(d)
+		if err != nil {
+			return nil, errors.Wrap(err, "error trying to parse Deck")
+		}
+		var deckElement map[string]interface{}
+		if err := bson.Unmarshal(document, &deckElement); err != nil {
+			return nil, errors.Wrap(err, "error trying to parse Deck")
+		}
+		deckResult = append(deckResult, deckElement)
+	}
+	return deckResult, nil
+}
+
+func (a *DeckMemoryRepository) find(id *primitive.ObjectID) int {
+	for i, d := range a.decks {
+		if id != nil && *d.Id == *id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *DeckMemoryRepository) filter(matches func(d *deck.Deck) bool) []*deck.Deck {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	result := make([]*deck.Deck, 0)
+	for _, d := range a.decks {
+		if matches(d) {
+			result = append(result, cloneDeck(d))
+		}
+	}
+	return result
+}
+
+// visible is the $or of the queries above: the owner sees everything, others only the
+// public decks unless private is set.
+func visible(d *deck.Deck, userId string, private bool) bool {
+	return private || !d.IsPrivate || d.UserId == userId
+}
+
+func cloneDeck(d *deck.Deck) *deck.Deck {
+	clone := *d
+	if d.StudySuggestions != nil {
+		clone.StudySuggestions = append(make([]string, 0, len(d.StudySuggestions)), d.StudySuggestions...)
+	}
+	if d.Id != nil {
+		id := *d.Id
+		clone.Id = &id
+	}
+	return &clone
+}
@@ -0,0 +1,41 @@
+package deck_repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"testing"
+)
+
+func TestMemoryUpdateKeepsCardsCount(t *testing.T) {
+	ctx := context.Background()
+	repository := NewDeckMemoryRepository()
+	saved, _ := repository.Persist(ctx, &deck.Deck{Name: "Verbs", UserId: "owner"})
+
+	updated, err := repository.IncrementCardsCount(ctx, "owner", saved.Id, 2)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	_, err = repository.Update(ctx, saved.Id, "owner", &deck.Deck{Name: "Nouns", UserId: "owner"})
+	assert.NoError(t, err)
+
+	stored, err := repository.FindById(ctx, "owner", saved.Id, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "Nouns", stored.Name)
+	assert.Equal(t, int64(2), stored.CardsCount)
+	assert.Equal(t, int64(1), stored.Version)
+}
+
+func TestMemoryFindByFilters(t *testing.T) {
+	ctx := context.Background()
+	repository := NewDeckMemoryRepository()
+	_, _ = repository.Persist(ctx, &deck.Deck{Name: "Irregular Verbs", UserId: "owner"})
+	_, _ = repository.Persist(ctx, &deck.Deck{Name: "Private verbs", UserId: "owner", IsPrivate: true})
+	_, _ = repository.Persist(ctx, &deck.Deck{Name: "Nouns", UserId: "owner"})
+
+	result, err := repository.FindByFilters(ctx, "verb", "other")
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "Irregular Verbs", result[0]["name"])
+	assert.Contains(t, result[0], "_id")
+}
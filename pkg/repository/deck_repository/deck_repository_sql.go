@@ -0,0 +1,275 @@
+package deck_repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/sqldb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/net/context"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const deckColumns = "id, user_id, name, image_url, description, is_private, study_suggestions, cards_count, version, last_update"
+
+// DeckSQLRepository stores the decks in the decks table. The ids are still ObjectIDs,
+// so they look the same to the API whatever the storage.
+type DeckSQLRepository struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+	timeout time.Duration
+}
+
+func NewDeckSQLRepository(db *sql.DB, dialect sqldb.Dialect, timeout time.Duration) DeckSQLRepository {
+	return DeckSQLRepository{db: db, dialect: dialect, timeout: timeout}
+}
+
+func (a DeckSQLRepository) Persist(ctx context.Context, deckToPersist *deck.Deck) (saved *deck.Deck, err error) {
+	defer metrics.ObserveSQL("deck", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	id := primitive.NewObjectID()
+	if deckToPersist.Id != nil {
+		id = *deckToPersist.Id
+	}
+	suggestions, err := json.Marshal(deckToPersist.StudySuggestions)
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+
+	_, err = sqldb.Conn(ctx, a.db).ExecContext(ctx, "INSERT INTO decks ("+deckColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		id.Hex(), deckToPersist.UserId, deckToPersist.Name, deckToPersist.ImageURL, deckToPersist.Description,
+		deckToPersist.IsPrivate, string(suggestions), deckToPersist.CardsCount, deckToPersist.Version, deckToPersist.LastUpdate)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+	deckToPersist.Id = &id
+
+	return deckToPersist, nil
+}
+
+func (a DeckSQLRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (deckReturn *deck.Deck, err error) {
+	defer metrics.ObserveSQL("deck", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	query, args := "SELECT "+deckColumns+" FROM decks WHERE id = $1", []interface{}{id.Hex()}
+	if !private {
+		query, args = query+" AND (NOT is_private OR user_id = $2)", append(args, userId)
+	}
+
+	deckReturn, err = scanDeck(sqldb.Conn(ctx, a.db).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		log.FromContext(ctx).Warn("Find deckReturn by id has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to find deckReturn by id")
+	}
+	return deckReturn, nil
+}
+
+func (a DeckSQLRepository) FindByIds(ctx context.Context, userId string, ids []*primitive.ObjectID, private bool) (deckResult []*deck.Deck, err error) {
+	defer metrics.ObserveSQL("deck", "FindByIds", time.Now(), &err)
+	if len(ids) == 0 {
+		return make([]*deck.Deck, 0), nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, id.Hex())
+	}
+	query := "SELECT " + deckColumns + " FROM decks WHERE id IN (" + sqldb.Placeholders(1, len(args)) + ")"
+	if !private {
+		query, args = query+" AND (NOT is_private OR user_id = "+sqldb.Placeholders(len(args)+1, 1)+")", append(args, userId)
+	}
+	return a.list(ctx, query+" ORDER BY id", args...)
+}
+
+func (a DeckSQLRepository) FindByUserIdAndPublic(ctx context.Context, userId string) (deckResult []*deck.Deck, err error) {
+	defer metrics.ObserveSQL("deck", "FindByUserIdAndPublic", time.Now(), &err)
+	return a.list(ctx, "SELECT "+deckColumns+" FROM decks WHERE NOT is_private OR user_id = $1 ORDER BY id", userId)
+}
+
+func (a DeckSQLRepository) FindByUserId(ctx context.Context, userId string) (deckResult []*deck.Deck, err error) {
+	defer metrics.ObserveSQL("deck", "FindByUserId", time.Now(), &err)
+	return a.list(ctx, "SELECT "+deckColumns+" FROM decks WHERE user_id = $1 ORDER BY id", userId)
+}
+
+func (a DeckSQLRepository) Count(ctx context.Context, userId string) (count int64, err error) {
+	defer metrics.ObserveSQL("deck", "Count", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	err = sqldb.Conn(ctx, a.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM decks WHERE user_id = $1", userId).Scan(&count)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Count Decks has failed", "error", err.Error())
+		return 0, errors.Wrap(err, "error trying to count Decks")
+	}
+	return count, nil
+}
+
+// Delete fails while cards or playlists still reference the deck, the use case removes them first.
+func (a DeckSQLRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *deck.Deck, err error) {
+	defer metrics.ObserveSQL("deck", "Delete", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	query, args := "DELETE FROM decks WHERE id = $1 AND user_id = $2", []interface{}{id.Hex(), userId}
+	if expectedVersion != nil {
+		query, args = query+" AND version = $3", append(args, *expectedVersion)
+	}
+
+	result, err = scanDeck(sqldb.Conn(ctx, a.db).QueryRowContext(ctx, query+" RETURNING "+deckColumns, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to delete entity")
+	}
+
+	log.FromContext(ctx).Debug("Deck deleted: ", result)
+	return result, nil
+}
+
+func (a DeckSQLRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, deckToSave *deck.Deck) (saved *deck.Deck, err error) {
+	defer metrics.ObserveSQL("deck", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	suggestions, err := json.Marshal(deckToSave.StudySuggestions)
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to update entity")
+	}
+
+	// cards_count is left out so concurrent card writes are not overwritten.
+	updateResult, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, `UPDATE decks SET image_url = $1, name = $2, description = $3,
+		is_private = $4, study_suggestions = $5, user_id = $6, last_update = $7, version = $8
+		WHERE id = $9 AND user_id = $10 AND version = $11`,
+		deckToSave.ImageURL, deckToSave.Name, deckToSave.Description, deckToSave.IsPrivate, string(suggestions),
+		deckToSave.UserId, deckToSave.LastUpdate, deckToSave.Version+1, id.Hex(), userId, deckToSave.Version)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to update entity")
+	}
+
+	if matched, err := updateResult.RowsAffected(); err != nil || matched == 0 {
+		return nil, err
+	}
+	deckToSave.Id = id
+	deckToSave.Version++
+	return deckToSave, nil
+}
+
+func (a DeckSQLRepository) IncrementCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, delta int64) (updated bool, err error) {
+	defer metrics.ObserveSQL("deck", "IncrementCardsCount", time.Now(), &err)
+	return a.exec(ctx, "UPDATE decks SET cards_count = cards_count + $1 WHERE id = $2 AND user_id = $3", delta, id.Hex(), userId)
+}
+
+func (a DeckSQLRepository) SetCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, count int64) (updated bool, err error) {
+	defer metrics.ObserveSQL("deck", "SetCardsCount", time.Now(), &err)
+	return a.exec(ctx, "UPDATE decks SET cards_count = $1 WHERE id = $2 AND user_id = $3", count, id.Hex(), userId)
+}
+
+func (a DeckSQLRepository) FindByFilters(ctx context.Context, filter, userId string) (deckResult []map[string]interface{}, err error) {
+	defer metrics.ObserveSQL("deck", "FindByFilters", time.Now(), &err)
+	query, args := "SELECT "+deckColumns+" FROM decks WHERE (NOT is_private OR user_id = $1)", []interface{}{userId}
+	if a.dialect.Matches != nil {
+		query, args = query+" AND "+a.dialect.Matches("name", "$2"), append(args, filter)
+	}
+	decks, err := a.list(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	if a.dialect.Matches == nil {
+		name, err := regexp.Compile("(?i)" + filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "error trying to find decks")
+		}
+		matching := decks[:0]
+		for _, d := range decks {
+			if name.MatchString(d.Name) {
+				matching = append(matching, d)
+			}
+		}
+		decks = matching
+	}
+
+	// The same documents the MongoDB search returns.
+	deckResult = make([]map[string]interface{}, 0, len(decks))
+	for _, d := range decks {
+		document, err := bson.Marshal(d)
+		if err != nil {
+			return nil, errors.Wrap(err, "error trying to parse Deck")
+		}
+		var deckElement map[string]interface{}
+		if err := bson.Unmarshal(document, &deckElement); err != nil {
+			return nil, errors.Wrap(err, "error trying to parse Deck")
+		}
+		deckResult = append(deckResult, deckElement)
+	}
+	return deckResult, nil
+}
+
+func (a DeckSQLRepository) list(ctx context.Context, query string, args ...interface{}) ([]*deck.Deck, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
+		return nil, errors.Wrap(err, "error trying to find decks")
+	}
+	defer rows.Close()
+
+	deckResult := make([]*deck.Deck, 0)
+	for rows.Next() {
+		deckElement, err := scanDeck(rows)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Deck has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Deck")
+		}
+		deckResult = append(deckResult, deckElement)
+	}
+	return deckResult, rows.Err()
+}
+
+func (a DeckSQLRepository) exec(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	result, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update cards count has failed", "error", err)
+		return false, errors.Wrap(err, "error trying to update cards count")
+	}
+	matched, err := result.RowsAffected()
+	return matched > 0, err
+}
+
+func scanDeck(row sqldb.Scanner) (*deck.Deck, error) {
+	var d deck.Deck
+	var id string
+	var suggestions []byte
+	err := row.Scan(&id, &d.UserId, &d.Name, &d.ImageURL, &d.Description, &d.IsPrivate, &suggestions,
+		&d.CardsCount, &d.Version, &d.LastUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	d.Id = &objectID
+	if err = json.Unmarshal(suggestions, &d.StudySuggestions); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/cache"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/sqldb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/migration"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/card_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/deck_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/playlist_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/review_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/webhook_repository"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"io/fs"
+	"time"
+)
+
+// migrationFiles holds the schema migrations of each SQL dialect, in a directory named
+// after it.
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// Pinger and Checker are the readiness checks of a storage.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// Backuper copies the stored data to a file while the server runs.
+type Backuper interface {
+	Backup(ctx context.Context, path string) error
+}
+
+// Storage is what the application needs from the database it runs on. Indexes are only
+// set for MongoDB, Backup for SQLite and Migrator for the storages that keep data
+// between runs.
+type Storage struct {
+	Playlists    playlist_repository.IPlaylistRepository
+	Decks        deck_repository.IDeckRepository
+	Cards        card_repository.ICardRepository
+	Reviews      review_repository.IReviewRepository
+	Webhooks     webhook_repository.IWebhookRepository
+	Outbox       outbox_repository.IOutboxRepository
+	Transactions transaction.ITransactionManager
+	Database     Pinger
+	Schema       Checker
+	Indexes      *mongodb.IndexManager
+	Migrator     migration.Migrator
+	Backup       Backuper
+}
+
+// NewCache is the cache in front of the repositories. It is kept in the process, a cache
+// shared by every instance only has to implement cache.Cache to replace it here.
+func NewCache(cfg *config.Config) cache.Cache {
+	return cache.NewLRU(cfg.Cache.Capacity)
+}
+
+// NewStorage builds the repositories of the storage named by cfg.Storage, reading
+// through c when the cache is enabled. The returned func closes the connections once
+// the server has drained.
+func NewStorage(cfg *config.Config, c cache.Cache) (Storage, func(), error) {
+	storage, cleanup, err := newStorage(cfg)
+	if err != nil || !cfg.Cache.Enabled || cfg.Storage == config.StorageMemory {
+		return storage, cleanup, err
+	}
+
+	storage.Decks = deck_repository.NewDeckCacheRepository(storage.Decks, c, cfg.Cache.DeckTTL)
+	storage.Cards = card_repository.NewCardCacheRepository(storage.Cards, c, cfg.Cache.CardsTTL)
+	storage.Playlists = playlist_repository.NewPlaylistCacheRepository(storage.Playlists, c, cfg.Cache.PlaylistTTL)
+	storage.Transactions = transaction.NewAfterCommitManager(storage.Transactions)
+	return storage, cleanup, nil
+}
+
+func newStorage(cfg *config.Config) (Storage, func(), error) {
+	switch cfg.Storage {
+	case config.StoragePostgres:
+		return newPostgresStorage(cfg.Postgres)
+	case config.StorageSQLite:
+		return newSQLiteStorage(cfg.SQLite)
+	case config.StorageMemory:
+		return newMemoryStorage(), func() {}, nil
+	default:
+		return newMongoStorage(cfg.MongoDB)
+	}
+}
+
+func newMongoStorage(cfg config.MongoDB) (Storage, func(), error) {
+	client, cleanup, err := mongodb.NewMongoDbClient(cfg)
+	if err != nil {
+		return Storage{}, nil, err
+	}
+
+	playlists := playlist_repository.NewPlaylistRepository(client, cfg)
+	decks := deck_repository.NewDeckRepository(client, cfg)
+	cards := card_repository.NewCardRepository(client, cfg)
+	reviews := review_repository.NewReviewRepository(client, cfg)
+	webhooks := webhook_repository.NewWebhookRepository(client, cfg)
+	outbox := outbox_repository.NewOutboxRepository(client, cfg)
+	indexes := mongodb.NewIndexManager(client, cfg, NewIndexRegistry(playlists, decks, cards, reviews, webhooks, outbox))
+
+	return Storage{
+		Playlists:    playlists,
+		Decks:        decks,
+		Cards:        cards,
+		Reviews:      reviews,
+		Webhooks:     webhooks,
+		Outbox:       outbox,
+		Transactions: mongodb.NewTransactionManager(client, cfg),
+		Database:     mongoPinger{client: client},
+		Schema:       indexes,
+		Indexes:      indexes,
+		Migrator:     mongodb.NewMigrator(client, cfg, NewMigrations(cfg)),
+	}, cleanup, nil
+}
+
+// newPostgresStorage connects to PostgreSQL.
+func newPostgresStorage(cfg config.Postgres) (Storage, func(), error) {
+	db, cleanup, err := sqldb.Open(sqldb.Postgres, cfg.DSN, cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime)
+	if err != nil {
+		return Storage{}, nil, err
+	}
+	storage, err := newSQLStorage(db, sqldb.Postgres, cfg.QueryTimeout)
+	if err != nil {
+		cleanup()
+		return Storage{}, nil, err
+	}
+	return storage, cleanup, nil
+}
+
+// newSQLiteStorage opens the database file, it can be backed up while the server runs.
+func newSQLiteStorage(cfg config.SQLite) (Storage, func(), error) {
+	db, cleanup, err := sqldb.OpenSQLite(cfg.Path, cfg.BusyTimeout)
+	if err != nil {
+		return Storage{}, nil, err
+	}
+	storage, err := newSQLStorage(db, sqldb.SQLite, cfg.QueryTimeout)
+	if err != nil {
+		cleanup()
+		return Storage{}, nil, err
+	}
+	storage.Backup = sqldb.NewSQLiteBackup(db)
+	return storage, cleanup, nil
+}
+
+// newSQLStorage builds the SQL repositories on db. The schema comes from the migrations
+// of the dialect, Schema reports the pending ones as not ready.
+func newSQLStorage(db *sql.DB, dialect sqldb.Dialect, timeout time.Duration) (Storage, error) {
+	files, err := fs.Sub(migrationFiles, "migrations/"+dialect.Name)
+	if err != nil {
+		return Storage{}, err
+	}
+	migrator, err := sqldb.NewMigrator(db, files)
+	if err != nil {
+		return Storage{}, err
+	}
+
+	return Storage{
+		Playlists:    playlist_repository.NewPlaylistSQLRepository(db, dialect, timeout),
+		Decks:        deck_repository.NewDeckSQLRepository(db, dialect, timeout),
+		Cards:        card_repository.NewCardSQLRepository(db, dialect, timeout),
+		Reviews:      review_repository.NewReviewSQLRepository(db, timeout),
+		Webhooks:     webhook_repository.NewWebhookSQLRepository(db, timeout),
+		Outbox:       outbox_repository.NewOutboxSQLRepository(db, timeout),
+		Transactions: sqldb.NewTransactionManager(db, timeout),
+		Database:     sqlPinger{db: db},
+		Schema:       migrator,
+		Migrator:     migrator,
+	}, nil
+}
+
+func newMemoryStorage() Storage {
+	return Storage{
+		Playlists:    playlist_repository.NewPlaylistMemoryRepository(),
+		Decks:        deck_repository.NewDeckMemoryRepository(),
+		Cards:        card_repository.NewCardMemoryRepository(),
+		Reviews:      review_repository.NewReviewMemoryRepository(),
+		Webhooks:     webhook_repository.NewWebhookMemoryRepository(),
+		Outbox:       outbox_repository.NewOutboxMemoryRepository(),
+		Transactions: transaction.NewMemoryTransactionManager(),
+		Database:     alwaysReady{},
+		Schema:       alwaysReady{},
+	}
+}
+
+type mongoPinger struct {
+	client *mongo.Client
+}
+
+func (p mongoPinger) Ping(ctx context.Context) error {
+	return p.client.Ping(ctx, readpref.Primary())
+}
+
+type sqlPinger struct {
+	db *sql.DB
+}
+
+func (p sqlPinger) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+// alwaysReady is the readiness of the in-memory storage, there is nothing to reach.
+type alwaysReady struct{}
+
+func (alwaysReady) Ping(context.Context) error {
+	return nil
+}
+
+func (alwaysReady) Check(context.Context) error {
+	return nil
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/review"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestSQLite runs the SQL repositories on an in-memory SQLite database with the
+// migrations applied.
+func newTestSQLite(t *testing.T) Storage {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	storage, cleanup, err := newSQLiteStorage(config.SQLite{Path: ":memory:", BusyTimeout: time.Second, QueryTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	_, err = storage.Migrator.Up(context.Background(), false)
+	require.NoError(t, err)
+	require.NoError(t, storage.Schema.Check(context.Background()))
+	return storage
+}
+
+func TestSQLiteDeckVisibilityAndSearch(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	public, err := storage.Decks.Persist(ctx, &deck.Deck{Name: "Irregular Verbs", UserId: "owner", StudySuggestions: []string{"daily"}, LastUpdate: time.Now()})
+	require.NoError(t, err)
+	private, _ := storage.Decks.Persist(ctx, &deck.Deck{Name: "Private verbs", UserId: "owner", IsPrivate: true, LastUpdate: time.Now()})
+	_, _ = storage.Decks.Persist(ctx, &deck.Deck{Name: "Nouns", UserId: "owner", LastUpdate: time.Now()})
+
+	_, err = storage.Decks.FindById(ctx, "other", private.Id, false)
+	assert.Error(t, err)
+	found, err := storage.Decks.FindById(ctx, "other", public.Id, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"daily"}, found.StudySuggestions)
+
+	result, err := storage.Decks.FindByFilters(ctx, "verb", "other")
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "Irregular Verbs", result[0]["name"])
+
+	result, err = storage.Decks.FindByFilters(ctx, "^(irregular|private) verbs$", "owner")
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+}
+
+func TestSQLiteCardInMissingDeckIsNotFound(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	missing := primitive.NewObjectID()
+
+	_, err := storage.Cards.Persist(ctx, &card.Card{DeckId: missing.Hex(), UserId: "owner", Front: "a", Back: "b", LastUpdate: time.Now()})
+
+	assert.Equal(t, errors.ErrNotFound, errors.Cause(err))
+}
+
+// The use case removes the cards and the playlist entries of a deck first, each with its
+// event, the database only refuses to lose them silently.
+func TestSQLiteDeckStillReferencedIsNotDeleted(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	saved, _ := storage.Decks.Persist(ctx, &deck.Deck{Name: "Verbs", UserId: "owner", LastUpdate: time.Now()})
+	written, err := storage.Cards.Persist(ctx, &card.Card{DeckId: saved.Id.Hex(), UserId: "owner", Front: "a", Back: "b", LastUpdate: time.Now()})
+	require.NoError(t, err)
+	list, _ := storage.Playlists.Persist(ctx, &playlist.Playlist{Name: "Languages", UserId: "other", LastUpdate: time.Now(),
+		Decks: []deck.DeckPreview{{Id: saved.Id.Hex(), Name: "Verbs", UserId: "owner"}}})
+
+	_, err = storage.Decks.Delete(ctx, "owner", saved.Id, nil)
+	assert.Error(t, err, "the deck still has a card")
+
+	_, err = storage.Cards.Delete(ctx, "owner", written.Id, nil)
+	require.NoError(t, err)
+	_, err = storage.Decks.Delete(ctx, "owner", saved.Id, nil)
+	assert.Error(t, err, "the deck is still in a playlist")
+
+	holding, err := storage.Playlists.FindByDeckId(ctx, saved.Id.Hex())
+	require.NoError(t, err)
+	require.Len(t, holding, 1)
+	assert.Equal(t, *list.Id, *holding[0].Id)
+	_, err = storage.Playlists.RemoveDeck(ctx, "other", list.Id, saved.Id.Hex(), nil)
+	require.NoError(t, err)
+
+	deleted, err := storage.Decks.Delete(ctx, "owner", saved.Id, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Verbs", deleted.Name)
+}
+
+func TestSQLitePlaylistDecks(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	previews := make([]deck.DeckPreview, 0)
+	for _, name := range []string{"A", "B", "C"} {
+		saved, _ := storage.Decks.Persist(ctx, &deck.Deck{Name: name, UserId: "owner", LastUpdate: time.Now()})
+		previews = append(previews, deck.DeckPreview{Id: saved.Id.Hex(), Name: name, UserId: "owner"})
+	}
+	list, _ := storage.Playlists.Persist(ctx, &playlist.Playlist{Name: "Languages", UserId: "owner", LastUpdate: time.Now(), Decks: previews[:2]})
+
+	added, err := storage.Playlists.AddDecks(ctx, "owner", list.Id, previews[2:], nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), added.Version)
+
+	duplicate, err := storage.Playlists.AddDecks(ctx, "owner", list.Id, previews[:1], nil)
+	assert.NoError(t, err)
+	assert.Nil(t, duplicate)
+
+	stale := int64(0)
+	moved, err := storage.Playlists.MoveDeck(ctx, "owner", list.Id, previews[2].Id, 0, &stale)
+	assert.NoError(t, err)
+	assert.Nil(t, moved)
+
+	moved, err = storage.Playlists.MoveDeck(ctx, "owner", list.Id, previews[2].Id, 0, &added.Version)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"C", "A", "B"}, []string{moved.Decks[0].Name, moved.Decks[1].Name, moved.Decks[2].Name})
+
+	removed, err := storage.Playlists.RemoveDeck(ctx, "owner", list.Id, previews[0].Id, nil)
+	require.NoError(t, err)
+	assert.Len(t, removed.Decks, 2)
+	assert.Equal(t, int64(3), removed.Version)
+}
+
+func TestSQLiteFindRecentReviews(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	start := time.Now()
+	for i := 0; i < 12; i++ {
+		_, err := storage.Reviews.Persist(ctx, &review.Review{OriginType: "deck", OriginId: "d", UserId: "owner",
+			Hists: []*card.Card{{Front: "a"}}, HistsCount: 1, LastUpdate: start.Add(time.Duration(i) * time.Minute)})
+		require.NoError(t, err)
+	}
+
+	recent, err := storage.Reviews.FindRecent(ctx, "deck", "owner")
+	assert.NoError(t, err)
+	assert.Len(t, recent, 10)
+	assert.True(t, recent[0].LastUpdate.After(recent[1].LastUpdate))
+	assert.Equal(t, "a", recent[0].Hists[0].Front)
+}
+
+func TestSQLiteBackup(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	_, _ = storage.Decks.Persist(ctx, &deck.Deck{Name: "Verbs", UserId: "owner", LastUpdate: time.Now()})
+	path := filepath.Join(t.TempDir(), "backup.db")
+
+	assert.NoError(t, storage.Backup.Backup(ctx, path))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NotZero(t, info.Size())
+	assert.Error(t, storage.Backup.Backup(ctx, path), "an existing file is not overwritten")
+}
+
+func TestSQLiteClaimWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	hook, err := storage.Webhooks.Persist(ctx, &webhook.Webhook{URL: "https://example.com", Events: []string{webhook.DeckCreated}, UserId: "owner", CreatedAt: time.Now()})
+	require.NoError(t, err)
+	now := time.Now()
+	for _, next := range []time.Time{now.Add(-time.Minute), now.Add(time.Minute)} {
+		_, err = storage.Webhooks.PersistDelivery(ctx, &webhook.Delivery{WebhookId: hook.Id.Hex(), UserId: "owner", EventId: "e", Event: webhook.DeckCreated,
+			Payload: []byte(`{}`), Status: webhook.StatusPending, NextAttempt: next, CreatedAt: now})
+		require.NoError(t, err)
+	}
+
+	claimed, err := storage.Webhooks.ClaimDeliveries(ctx, now, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 1, claimed[0].Attempts)
+	again, err := storage.Webhooks.ClaimDeliveries(ctx, now, now.Add(time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, again, "a claimed delivery is not claimed again before its lease ends")
+
+	_, err = storage.Webhooks.Delete(ctx, "owner", hook.Id)
+	require.NoError(t, err)
+	deliveries, err := storage.Webhooks.FindDeliveries(ctx, "owner", hook.Id.Hex())
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestSQLiteOutboxKeepsOnlyCommittedEvents(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	now := time.Now()
+	appendEvent := func(entityId string, fail error) error {
+		return storage.Transactions.WithTransaction(ctx, func(ctx context.Context) error {
+			err := storage.Outbox.Append(ctx, &event.Event{Type: event.DeckCreated, UserId: "owner", EntityId: entityId,
+				Data: []byte(`{}`), OccurredAt: now, Status: event.StatusPending, NextAttempt: now})
+			if err != nil {
+				return err
+			}
+			return fail
+		})
+	}
+	require.Error(t, appendEvent("rolled-back", errors.ErrInternalServer))
+	require.NoError(t, appendEvent("committed", nil))
+
+	claimed, err := storage.Outbox.Claim(ctx, now, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "committed", claimed[0].EntityId)
+	assert.Empty(t, claimed[0].Handled)
+
+	claimed[0].Status = event.StatusDispatched
+	claimed[0].Handled = []string{"webhooks"}
+	require.NoError(t, storage.Outbox.Update(ctx, claimed[0]))
+	pruned, err := storage.Outbox.Prune(ctx, now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+}
+
+func TestSQLiteFindEventsAfterCursor(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLite(t)
+	now := time.Now()
+	for i, userId := range []string{"owner", "other", "owner", "owner"} {
+		occurredAt := now.Add(time.Duration(i) * time.Millisecond)
+		require.NoError(t, storage.Outbox.Append(ctx, &event.Event{Type: event.CardUpdated, UserId: userId, EntityId: "card",
+			Data: []byte(`{}`), OccurredAt: occurredAt, Status: event.StatusDispatched, NextAttempt: occurredAt}))
+	}
+
+	all, err := storage.Outbox.FindAfter(ctx, "", event.Cursor{OccurredAt: now.Add(-time.Second)}, 10)
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+
+	cursor, err := event.ParseCursor(all[0].Cursor().String())
+	require.NoError(t, err)
+	owner, err := storage.Outbox.FindAfter(ctx, "owner", cursor, 1)
+	require.NoError(t, err)
+	require.Len(t, owner, 1)
+	assert.Equal(t, *all[2].Id, *owner[0].Id)
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/card_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/deck_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/playlist_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/review_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/webhook_repository"
+)
+
+// NewIndexRegistry gathers the indexes declared by every repository.
+func NewIndexRegistry(playlistRepository playlist_repository.PlaylistRepository, deckRepository deck_repository.DeckRepository,
+	cardRepository card_repository.CardRepository, reviewRepository review_repository.ReviewRepository,
+	webhookRepository webhook_repository.WebhookRepository, outboxRepository outbox_repository.OutboxRepository) mongodb.IndexRegistry {
+	registry := make(mongodb.IndexRegistry, 0)
+	registry = append(registry, playlistRepository.Indexes()...)
+	registry = append(registry, deckRepository.Indexes()...)
+	registry = append(registry, cardRepository.Indexes()...)
+	registry = append(registry, reviewRepository.Indexes()...)
+	registry = append(registry, webhookRepository.Indexes()...)
+	registry = append(registry, outboxRepository.Indexes()...)
+	return registry
+}
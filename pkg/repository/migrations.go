@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/Enums"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+)
+
+// legacyColors maps the numbers stored while card.Color was an int to the color names,
+// in the order the constants are declared. Blue was 0, the zero value every card without
+// a color got, so 0 and any other number become no color.
+var legacyColors = map[int]Enums.Color{
+	1: Enums.Red,
+	2: Enums.Green,
+	3: Enums.Yellow,
+}
+
+// NewMigrations lists the changes to the stored documents, a new one takes the next version.
+// They are never edited once released, a fix is a new migration.
+func NewMigrations(cfg config.MongoDB) mongodb.Migrations {
+	return mongodb.Migrations{
+		{
+			Version:     1,
+			Description: "store card colors as names, also on the card copies in review sessions",
+			Up: func(ctx context.Context, db *mongo.Database, dryRun bool) (int64, error) {
+				changed, err := colorsToNames(ctx, db.Collection(cfg.Collections.Card), "", dryRun)
+				if err != nil {
+					return changed, err
+				}
+				for _, field := range []string{"hists", "mistakes"} {
+					count, err := colorsToNames(ctx, db.Collection(cfg.Collections.Review), field, dryRun)
+					changed += count
+					if err != nil {
+						return changed, err
+					}
+				}
+				return changed, nil
+			},
+		},
+		{
+			Version:     2,
+			Description: "set version 0 where it is missing, the conditional updates match on it",
+			Up: func(ctx context.Context, db *mongo.Database, dryRun bool) (int64, error) {
+				var changed int64
+				for _, collection := range []string{cfg.Collections.Playlist, cfg.Collections.Deck, cfg.Collections.Card} {
+					count, err := updateMany(ctx, db.Collection(collection), bson.M{"version": bson.M{"$exists": false}},
+						bson.M{"$set": bson.M{"version": int64(0)}}, nil, dryRun)
+					changed += count
+					if err != nil {
+						return changed, err
+					}
+				}
+				return changed, nil
+			},
+		},
+	}
+}
+
+// colorsToNames rewrites the numeric colors of the documents, or of the cards in their
+// array field when one is given. Numbers without a name lose their color.
+func colorsToNames(ctx context.Context, collection *mongo.Collection, arrayField string, dryRun bool) (int64, error) {
+	var changed int64
+	for _, conversion := range colorConversions() {
+		filter, update, arrayFilters := bson.M{"color": conversion.condition}, bson.M{"$set": bson.M{"color": conversion.name}}, []interface{}(nil)
+		if arrayField != "" {
+			// A document is matched by the card it has to change, a condition on the array
+			// itself would hold when one card is a number and another is not in the list.
+			filter = bson.M{arrayField: bson.M{"$elemMatch": bson.M{"color": conversion.condition}}}
+			update = bson.M{"$set": bson.M{arrayField + ".$[card].color": conversion.name}}
+			arrayFilters = []interface{}{bson.M{"card.color": conversion.condition}}
+		}
+		count, err := updateMany(ctx, collection, filter, update, arrayFilters, dryRun)
+		changed += count
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+type colorConversion struct {
+	condition interface{}
+	name      Enums.Color
+}
+
+// colorConversions lists the numbers to convert by name, then the numbers without one. A
+// card matches a single condition, but a document with several cards is counted once for
+// each condition one of them matches, by a dry run as by a real run.
+func colorConversions() []colorConversion {
+	named := make([]int, 0, len(legacyColors))
+	for number := range legacyColors {
+		named = append(named, number)
+	}
+	sort.Ints(named)
+
+	conversions := make([]colorConversion, 0, len(named)+1)
+	for _, number := range named {
+		conversions = append(conversions, colorConversion{condition: number, name: legacyColors[number]})
+	}
+	return append(conversions, colorConversion{condition: bson.D{{Key: "$type", Value: "number"}, {Key: "$nin", Value: named}}})
+}
+
+// updateMany applies update to the documents matching filter, with dryRun it only counts them.
+func updateMany(ctx context.Context, collection *mongo.Collection, filter, update interface{}, arrayFilters []interface{}, dryRun bool) (int64, error) {
+	if dryRun {
+		return collection.CountDocuments(ctx, filter)
+	}
+
+	opts := options.Update()
+	if arrayFilters != nil {
+		opts.SetArrayFilters(options.ArrayFilters{Filters: arrayFilters})
+	}
+	result, err := collection.UpdateMany(ctx, filter, update, opts)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
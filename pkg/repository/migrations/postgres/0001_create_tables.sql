@@ -0,0 +1,73 @@
+-- Ids are the hex of ObjectIDs, so they look the same to the API whatever the storage.
+CREATE TABLE decks (
+    id                CHAR(24)     PRIMARY KEY,
+    user_id           TEXT         NOT NULL,
+    name              VARCHAR(100) NOT NULL,
+    image_url         VARCHAR(100) NOT NULL,
+    description       VARCHAR(400) NOT NULL,
+    is_private        BOOLEAN      NOT NULL DEFAULT FALSE,
+    study_suggestions JSONB        NOT NULL DEFAULT 'null',
+    cards_count       BIGINT       NOT NULL DEFAULT 0,
+    version           BIGINT       NOT NULL DEFAULT 0,
+    last_update       TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX decks_user_id ON decks (user_id);
+
+-- A deck is only removed once nothing references it, its cards and playlist entries are
+-- deleted by the application so each of them has its event.
+CREATE TABLE cards (
+    id          CHAR(24)    PRIMARY KEY,
+    deck_id     CHAR(24)    NOT NULL REFERENCES decks (id) ON DELETE RESTRICT,
+    user_id     TEXT        NOT NULL,
+    front       TEXT        NOT NULL,
+    back        TEXT        NOT NULL,
+    color       VARCHAR(10) NOT NULL DEFAULT '' CHECK (color IN ('', 'Blue', 'Red', 'Green', 'Yellow')),
+    is_private  BOOLEAN     NOT NULL DEFAULT FALSE,
+    version     BIGINT      NOT NULL DEFAULT 0,
+    last_update TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX cards_deck_id ON cards (deck_id);
+CREATE INDEX cards_user_id ON cards (user_id);
+
+CREATE TABLE playlists (
+    id                CHAR(24)     PRIMARY KEY,
+    user_id           TEXT         NOT NULL,
+    name              VARCHAR(100) NOT NULL,
+    image_url         VARCHAR(100) NOT NULL,
+    description       VARCHAR(400) NOT NULL,
+    is_private        BOOLEAN      NOT NULL DEFAULT FALSE,
+    study_suggestions JSONB        NOT NULL DEFAULT 'null',
+    version           BIGINT       NOT NULL DEFAULT 0,
+    last_update       TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX playlists_user_id ON playlists (user_id);
+
+-- The previews are copied when a deck is added, as in the MongoDB documents.
+CREATE TABLE playlist_decks (
+    playlist_id CHAR(24)     NOT NULL REFERENCES playlists (id) ON DELETE CASCADE,
+    deck_id     CHAR(24)     NOT NULL REFERENCES decks (id) ON DELETE RESTRICT,
+    position    INTEGER      NOT NULL,
+    image_url   VARCHAR(100) NOT NULL,
+    name        VARCHAR(100) NOT NULL,
+    user_id     TEXT         NOT NULL,
+    PRIMARY KEY (playlist_id, deck_id)
+);
+
+CREATE INDEX playlist_decks_deck_id ON playlist_decks (deck_id);
+
+CREATE TABLE reviews (
+    id             CHAR(24)    PRIMARY KEY,
+    origin_type    TEXT        NOT NULL,
+    origin_id      TEXT        NOT NULL,
+    user_id        TEXT        NOT NULL,
+    hists          JSONB       NOT NULL DEFAULT 'null',
+    hists_count    BIGINT      NOT NULL DEFAULT 0,
+    mistakes       JSONB       NOT NULL DEFAULT 'null',
+    mistakes_count BIGINT      NOT NULL DEFAULT 0,
+    last_update    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX reviews_user_id_origin_type_last_update ON reviews (user_id, origin_type, last_update DESC);
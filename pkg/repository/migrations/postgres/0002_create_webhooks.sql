@@ -0,0 +1,33 @@
+CREATE TABLE webhooks (
+    id         CHAR(24)      PRIMARY KEY,
+    user_id    TEXT          NOT NULL,
+    url        VARCHAR(2000) NOT NULL,
+    events     JSONB         NOT NULL,
+    secret     VARCHAR(200)  NOT NULL,
+    created_at TIMESTAMPTZ   NOT NULL
+);
+
+CREATE INDEX webhooks_user_id ON webhooks (user_id);
+
+-- The deliveries are the log of each webhook, they go away with it.
+CREATE TABLE webhook_deliveries (
+    id              CHAR(24)    PRIMARY KEY,
+    webhook_id      CHAR(24)    NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    user_id         TEXT        NOT NULL,
+    event_id        CHAR(24)    NOT NULL,
+    event           TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          VARCHAR(10) NOT NULL CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    response_status INTEGER     NOT NULL DEFAULT 0,
+    error           TEXT        NOT NULL DEFAULT '',
+    next_attempt    TIMESTAMPTZ NOT NULL,
+    last_attempt    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_status_next_attempt ON webhook_deliveries (status, next_attempt);
+CREATE INDEX webhook_deliveries_webhook_id_created_at ON webhook_deliveries (webhook_id, created_at DESC);
+
+-- A session is completed once every card it started with has an answer.
+ALTER TABLE reviews ADD COLUMN cards_count BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- The domain events, written in the transaction of the change they describe and
+-- handed to the subscribers from here.
+CREATE TABLE outbox (
+    id           CHAR(24)    PRIMARY KEY,
+    type         TEXT        NOT NULL,
+    user_id      TEXT        NOT NULL,
+    entity_id    TEXT        NOT NULL,
+    data         JSONB       NOT NULL,
+    occurred_at  TIMESTAMPTZ NOT NULL,
+    status       VARCHAR(10) NOT NULL CHECK (status IN ('pending', 'dispatched', 'failed')),
+    handled      JSONB       NOT NULL DEFAULT '[]',
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    error        TEXT        NOT NULL DEFAULT '',
+    next_attempt TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX outbox_status_next_attempt ON outbox (status, next_attempt);
@@ -0,0 +1,4 @@
+-- The events are read in the order they occurred to stream them to the clients.
+CREATE INDEX outbox_occurred_at ON outbox (occurred_at, id);
+
+CREATE INDEX outbox_user_id_occurred_at ON outbox (user_id, occurred_at, id);
@@ -0,0 +1,73 @@
+-- The same schema as PostgreSQL. JSON is kept as TEXT and the times are declared
+-- TIMESTAMP so the driver reads them back as times.
+CREATE TABLE decks (
+    id                TEXT      PRIMARY KEY,
+    user_id           TEXT      NOT NULL,
+    name              TEXT      NOT NULL,
+    image_url         TEXT      NOT NULL,
+    description       TEXT      NOT NULL,
+    is_private        BOOLEAN   NOT NULL DEFAULT FALSE,
+    study_suggestions TEXT      NOT NULL DEFAULT 'null',
+    cards_count       INTEGER   NOT NULL DEFAULT 0,
+    version           INTEGER   NOT NULL DEFAULT 0,
+    last_update       TIMESTAMP NOT NULL
+);
+
+CREATE INDEX decks_user_id ON decks (user_id);
+
+-- A deck is only removed once nothing references it, its cards and playlist entries are
+-- deleted by the application so each of them has its event.
+CREATE TABLE cards (
+    id          TEXT      PRIMARY KEY,
+    deck_id     TEXT      NOT NULL REFERENCES decks (id) ON DELETE RESTRICT,
+    user_id     TEXT      NOT NULL,
+    front       TEXT      NOT NULL,
+    back        TEXT      NOT NULL,
+    color       TEXT      NOT NULL DEFAULT '' CHECK (color IN ('', 'Blue', 'Red', 'Green', 'Yellow')),
+    is_private  BOOLEAN   NOT NULL DEFAULT FALSE,
+    version     INTEGER   NOT NULL DEFAULT 0,
+    last_update TIMESTAMP NOT NULL
+);
+
+CREATE INDEX cards_deck_id ON cards (deck_id);
+CREATE INDEX cards_user_id ON cards (user_id);
+
+CREATE TABLE playlists (
+    id                TEXT      PRIMARY KEY,
+    user_id           TEXT      NOT NULL,
+    name              TEXT      NOT NULL,
+    image_url         TEXT      NOT NULL,
+    description       TEXT      NOT NULL,
+    is_private        BOOLEAN   NOT NULL DEFAULT FALSE,
+    study_suggestions TEXT      NOT NULL DEFAULT 'null',
+    version           INTEGER   NOT NULL DEFAULT 0,
+    last_update       TIMESTAMP NOT NULL
+);
+
+CREATE INDEX playlists_user_id ON playlists (user_id);
+
+CREATE TABLE playlist_decks (
+    playlist_id TEXT    NOT NULL REFERENCES playlists (id) ON DELETE CASCADE,
+    deck_id     TEXT    NOT NULL REFERENCES decks (id) ON DELETE RESTRICT,
+    position    INTEGER NOT NULL,
+    image_url   TEXT    NOT NULL,
+    name        TEXT    NOT NULL,
+    user_id     TEXT    NOT NULL,
+    PRIMARY KEY (playlist_id, deck_id)
+);
+
+CREATE INDEX playlist_decks_deck_id ON playlist_decks (deck_id);
+
+CREATE TABLE reviews (
+    id             TEXT      PRIMARY KEY,
+    origin_type    TEXT      NOT NULL,
+    origin_id      TEXT      NOT NULL,
+    user_id        TEXT      NOT NULL,
+    hists          TEXT      NOT NULL DEFAULT 'null',
+    hists_count    INTEGER   NOT NULL DEFAULT 0,
+    mistakes       TEXT      NOT NULL DEFAULT 'null',
+    mistakes_count INTEGER   NOT NULL DEFAULT 0,
+    last_update    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX reviews_user_id_origin_type_last_update ON reviews (user_id, origin_type, last_update DESC);
@@ -0,0 +1,31 @@
+CREATE TABLE webhooks (
+    id         TEXT      PRIMARY KEY,
+    user_id    TEXT      NOT NULL,
+    url        TEXT      NOT NULL,
+    events     TEXT      NOT NULL,
+    secret     TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhooks_user_id ON webhooks (user_id);
+
+CREATE TABLE webhook_deliveries (
+    id              TEXT      PRIMARY KEY,
+    webhook_id      TEXT      NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    user_id         TEXT      NOT NULL,
+    event_id        TEXT      NOT NULL,
+    event           TEXT      NOT NULL,
+    payload         TEXT      NOT NULL,
+    status          TEXT      NOT NULL CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts        INTEGER   NOT NULL DEFAULT 0,
+    response_status INTEGER   NOT NULL DEFAULT 0,
+    error           TEXT      NOT NULL DEFAULT '',
+    next_attempt    TIMESTAMP NOT NULL,
+    last_attempt    TIMESTAMP,
+    created_at      TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_status_next_attempt ON webhook_deliveries (status, next_attempt);
+CREATE INDEX webhook_deliveries_webhook_id_created_at ON webhook_deliveries (webhook_id, created_at DESC);
+
+ALTER TABLE reviews ADD COLUMN cards_count INTEGER NOT NULL DEFAULT 0;
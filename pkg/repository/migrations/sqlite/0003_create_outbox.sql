@@ -0,0 +1,15 @@
+CREATE TABLE outbox (
+    id           TEXT      PRIMARY KEY,
+    type         TEXT      NOT NULL,
+    user_id      TEXT      NOT NULL,
+    entity_id    TEXT      NOT NULL,
+    data         TEXT      NOT NULL,
+    occurred_at  TIMESTAMP NOT NULL,
+    status       TEXT      NOT NULL CHECK (status IN ('pending', 'dispatched', 'failed')),
+    handled      TEXT      NOT NULL DEFAULT '[]',
+    attempts     INTEGER   NOT NULL DEFAULT 0,
+    error        TEXT      NOT NULL DEFAULT '',
+    next_attempt TIMESTAMP NOT NULL
+);
+
+CREATE INDEX outbox_status_next_attempt ON outbox (status, next_attempt);
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/Enums"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+)
+
+// The mock deployment answers the commands in order and keeps them, so the test checks
+// what colorsToNames sends without a MongoDB to run it on.
+func TestColorsToNamesMatchesEachCard(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	number := func(n int) string { return fmt.Sprintf(`{"$numberInt":"%d"}`, n) }
+	unnamed := fmt.Sprintf(`{"$type": "number","$nin": [%s,%s,%s]}`, number(1), number(2), number(3))
+	expected := []struct {
+		condition string
+		name      Enums.Color
+	}{
+		{number(1), Enums.Red},
+		{number(2), Enums.Green},
+		{number(3), Enums.Yellow},
+		{unnamed, ""},
+	}
+
+	mt.Run("cards in an array", func(mt *mtest.T) {
+		for modified := range expected {
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: modified}, bson.E{Key: "nModified", Value: modified}))
+		}
+
+		changed, err := colorsToNames(context.Background(), mt.Coll, "hists", false)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0+1+2+3), changed)
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, len(expected))
+		for i, event := range events {
+			update := event.Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(t, fmt.Sprintf(`{"hists": {"$elemMatch": {"color": %s}}}`, expected[i].condition), update.Lookup("q").String(),
+				"the document is matched by one of its cards")
+			assert.Equal(t, fmt.Sprintf(`{"$set": {"hists.$[card].color": "%s"}}`, expected[i].name), update.Lookup("u").String())
+			assert.Equal(t, fmt.Sprintf(`[{"card.color": %s}]`, expected[i].condition), update.Lookup("arrayFilters").String(),
+				"only the cards matching the condition change")
+		}
+	})
+
+	mt.Run("dry run", func(mt *mtest.T) {
+		for range expected {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "flashcards.review", mtest.FirstBatch, bson.D{{Key: "n", Value: int32(2)}}))
+		}
+
+		changed, err := colorsToNames(context.Background(), mt.Coll, "hists", true)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2*len(expected)), changed)
+		for _, event := range mt.GetAllStartedEvents() {
+			assert.Equal(t, "aggregate", event.CommandName, "a dry run only counts")
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package outbox_repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type IOutboxRepository interface {
+	// Append writes the events. Within a transaction they are only kept if it commits.
+	Append(ctx context.Context, events ...*event.Event) error
+	// Claim returns up to limit pending events due at now, oldest first. Each has its attempt
+	// counted and is not due again before leaseUntil, so no other instance handles it
+	// meanwhile and it is handled again if this one stops before saving the outcome.
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*event.Event, error)
+	// Update saves the outcome of the attempt at eventToSave.
+	Update(ctx context.Context, eventToSave *event.Event) error
+	// Prune removes the events no longer pending that occurred before before.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+	// FindAfter returns up to limit events of userId, or of every user when it is empty,
+	// placed after the cursor after, in the order of the cursors.
+	FindAfter(ctx context.Context, userId string, after event.Cursor, limit int) ([]*event.Event, error)
+}
+
+// OutboxRepository keeps the events in one collection. On a standalone server there are
+// no transactions, an event is then written right after its change and lost if the
+// server stops in between.
+type OutboxRepository struct {
+	client     *mongo.Client
+	database   string
+	collection string
+	timeouts   config.Timeouts
+}
+
+func NewOutboxRepository(mongoClient *mongo.Client, cfg config.MongoDB) OutboxRepository {
+	return OutboxRepository{
+		client:     mongoClient,
+		database:   cfg.Database,
+		collection: cfg.Collections.Outbox,
+		timeouts:   cfg.Timeouts,
+	}
+}
+
+// Indexes are the indexes the queries below rely on.
+func (a OutboxRepository) Indexes() []mongodb.Index {
+	return []mongodb.Index{
+		{Collection: a.collection, Name: "status_nextAttempt",
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttempt", Value: 1}}},
+		{Collection: a.collection, Name: "occurredAt_id",
+			Keys: bson.D{{Key: "occurredAt", Value: 1}, {Key: "_id", Value: 1}}},
+		{Collection: a.collection, Name: "userId_occurredAt_id",
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "occurredAt", Value: 1}, {Key: "_id", Value: 1}}},
+	}
+}
+
+func (a OutboxRepository) Append(ctx context.Context, events ...*event.Event) (err error) {
+	defer metrics.ObserveMongo("outbox", "Append", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	documents := make([]interface{}, 0, len(events))
+	for _, e := range events {
+		if e.Id == nil {
+			id := primitive.NewObjectID()
+			e.Id = &id
+		}
+		documents = append(documents, e)
+	}
+
+	_, err = a.events().InsertMany(ctx, documents)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Append has failed", "error", err)
+		return errors.Wrap(err, "error trying to append events")
+	}
+	return nil
+}
+
+// Claim takes each due event with an update conditioned on its attempts, an instance
+// that lost the race for one does not get it.
+func (a OutboxRepository) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) (claimed []*event.Event, err error) {
+	defer metrics.ObserveMongo("outbox", "Claim", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "nextAttempt", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := a.events().Find(ctx, bson.M{"status": event.StatusPending, "nextAttempt": bson.M{"$lte": now}}, findOptions)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find due events")
+	}
+	due := make([]*event.Event, 0)
+	if err = cursor.All(ctx, &due); err != nil {
+		log.FromContext(ctx).Errorw("Parser Event has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to parse Event")
+	}
+
+	claimed = make([]*event.Event, 0, len(due))
+	for _, e := range due {
+		filter := bson.M{"_id": e.Id, "status": event.StatusPending, "attempts": e.Attempts}
+		update := bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"nextAttempt": leaseUntil}}
+		result, err := a.events().UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Claim event has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to claim event")
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		e.Attempts++
+		e.NextAttempt = leaseUntil
+		claimed = append(claimed, e)
+	}
+	return claimed, nil
+}
+
+func (a OutboxRepository) Update(ctx context.Context, eventToSave *event.Event) (err error) {
+	defer metrics.ObserveMongo("outbox", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	_, err = a.events().UpdateOne(ctx, bson.M{"_id": eventToSave.Id}, bson.M{"$set": bson.M{
+		"status":      eventToSave.Status,
+		"handled":     eventToSave.Handled,
+		"error":       eventToSave.Error,
+		"nextAttempt": eventToSave.NextAttempt,
+	}})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return errors.Wrap(err, "error trying to update entity")
+	}
+	return nil
+}
+
+func (a OutboxRepository) Prune(ctx context.Context, before time.Time) (count int64, err error) {
+	defer metrics.ObserveMongo("outbox", "Prune", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	result, err := a.events().DeleteMany(ctx, bson.M{
+		"status":     bson.M{"$in": []string{event.StatusDispatched, event.StatusFailed}},
+		"occurredAt": bson.M{"$lt": before},
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Prune has failed", "error", err)
+		return 0, errors.Wrap(err, "error trying to prune events")
+	}
+	return result.DeletedCount, nil
+}
+
+func (a OutboxRepository) FindAfter(ctx context.Context, userId string, after event.Cursor, limit int) (result []*event.Event, err error) {
+	defer metrics.ObserveMongo("outbox", "FindAfter", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"occurredAt": bson.M{"$gt": after.OccurredAt}},
+		bson.M{"occurredAt": after.OccurredAt, "_id": bson.M{"$gt": after.Id}},
+	}}
+	if userId != "" {
+		filter["userId"] = userId
+	}
+	findOptions := options.Find().SetSort(bson.D{{Key: "occurredAt", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := a.events().Find(ctx, filter, findOptions)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find events")
+	}
+	result = make([]*event.Event, 0)
+	if err = cursor.All(ctx, &result); err != nil {
+		log.FromContext(ctx).Errorw("Parser Event has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to parse Event")
+	}
+	return result, nil
+}
+
+func (a OutboxRepository) events() *mongo.Collection {
+	return a.client.Database(a.database).Collection(a.collection)
+}
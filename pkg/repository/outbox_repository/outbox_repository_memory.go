@@ -0,0 +1,117 @@
+package outbox_repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OutboxMemoryRepository keeps the events in memory with the same rules as
+// OutboxRepository. They are stored and returned as copies.
+type OutboxMemoryRepository struct {
+	mutex  sync.Mutex
+	events []*event.Event
+}
+
+func NewOutboxMemoryRepository() *OutboxMemoryRepository {
+	return &OutboxMemoryRepository{events: make([]*event.Event, 0)}
+}
+
+func (a *OutboxMemoryRepository) Append(_ context.Context, events ...*event.Event) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, e := range events {
+		if e.Id == nil {
+			id := primitive.NewObjectID()
+			e.Id = &id
+		}
+		a.events = append(a.events, cloneEvent(e))
+	}
+	return nil
+}
+
+func (a *OutboxMemoryRepository) Claim(_ context.Context, now, leaseUntil time.Time, limit int) ([]*event.Event, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	due := make([]*event.Event, 0)
+	for _, e := range a.events {
+		if e.Status == event.StatusPending && !e.NextAttempt.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttempt.Before(due[j].NextAttempt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*event.Event, 0, len(due))
+	for _, e := range due {
+		e.Attempts++
+		e.NextAttempt = leaseUntil
+		claimed = append(claimed, cloneEvent(e))
+	}
+	return claimed, nil
+}
+
+func (a *OutboxMemoryRepository) Update(_ context.Context, eventToSave *event.Event) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, stored := range a.events {
+		if *stored.Id == *eventToSave.Id {
+			stored.Status = eventToSave.Status
+			stored.Handled = append([]string(nil), eventToSave.Handled...)
+			stored.Error = eventToSave.Error
+			stored.NextAttempt = eventToSave.NextAttempt
+		}
+	}
+	return nil
+}
+
+func (a *OutboxMemoryRepository) Prune(_ context.Context, before time.Time) (int64, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	kept := a.events[:0]
+	for _, e := range a.events {
+		if e.Status == event.StatusPending || !e.OccurredAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	pruned := int64(len(a.events) - len(kept))
+	a.events = kept
+	return pruned, nil
+}
+
+func (a *OutboxMemoryRepository) FindAfter(_ context.Context, userId string, after event.Cursor, limit int) ([]*event.Event, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	result := make([]*event.Event, 0)
+	for _, e := range a.events {
+		if (userId == "" || e.UserId == userId) && after.Before(e.Cursor()) {
+			result = append(result, cloneEvent(e))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Cursor().Before(result[j].Cursor()) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func cloneEvent(e *event.Event) *event.Event {
+	clone := *e
+	if e.Id != nil {
+		id := *e.Id
+		clone.Id = &id
+	}
+	clone.Data = append([]byte(nil), e.Data...)
+	clone.Handled = append([]string(nil), e.Handled...)
+	return &clone
+}
@@ -0,0 +1,180 @@
+package outbox_repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/sqldb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+const eventColumns = "id, type, user_id, entity_id, data, occurred_at, status, handled, attempts, error, next_attempt"
+
+// OutboxSQLRepository keeps the events in the outbox table. The times are written in UTC:
+// SQLite compares them as text.
+type OutboxSQLRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewOutboxSQLRepository(db *sql.DB, timeout time.Duration) OutboxSQLRepository {
+	return OutboxSQLRepository{db: db, timeout: timeout}
+}
+
+func (a OutboxSQLRepository) Append(ctx context.Context, events ...*event.Event) (err error) {
+	defer metrics.ObserveSQL("outbox", "Append", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	for _, e := range events {
+		id := primitive.NewObjectID()
+		if e.Id != nil {
+			id = *e.Id
+		}
+		handled, err := json.Marshal(handledOrEmpty(e.Handled))
+		if err != nil {
+			return errors.Wrap(err, "error trying to append events")
+		}
+
+		_, err = sqldb.Conn(ctx, a.db).ExecContext(ctx, "INSERT INTO outbox ("+eventColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			id.Hex(), e.Type, e.UserId, e.EntityId, string(e.Data), e.OccurredAt.UTC(), e.Status, string(handled),
+			e.Attempts, e.Error, e.NextAttempt.UTC())
+		if err != nil {
+			log.FromContext(ctx).Errorw("Append has failed", "error", err)
+			return errors.Wrap(err, "error trying to append events")
+		}
+		e.Id = &id
+	}
+	return nil
+}
+
+// Claim takes each due event with an update conditioned on its attempts, an instance
+// that lost the race for one does not get it.
+func (a OutboxSQLRepository) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) (claimed []*event.Event, err error) {
+	defer metrics.ObserveSQL("outbox", "Claim", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	due, err := a.list(ctx, "SELECT "+eventColumns+` FROM outbox
+		WHERE status = $1 AND next_attempt <= $2 ORDER BY next_attempt, id LIMIT $3`, event.StatusPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed = make([]*event.Event, 0, len(due))
+	for _, e := range due {
+		updateResult, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, next_attempt = $1
+			WHERE id = $2 AND status = $3 AND attempts = $4`, leaseUntil.UTC(), e.Id.Hex(), event.StatusPending, e.Attempts)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Claim event has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to claim event")
+		}
+		if updated, err := updateResult.RowsAffected(); err != nil || updated == 0 {
+			continue
+		}
+		e.Attempts++
+		e.NextAttempt = leaseUntil
+		claimed = append(claimed, e)
+	}
+	return claimed, nil
+}
+
+func (a OutboxSQLRepository) Update(ctx context.Context, eventToSave *event.Event) (err error) {
+	defer metrics.ObserveSQL("outbox", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	handled, err := json.Marshal(handledOrEmpty(eventToSave.Handled))
+	if err != nil {
+		return errors.Wrap(err, "error trying to update entity")
+	}
+	_, err = sqldb.Conn(ctx, a.db).ExecContext(ctx, "UPDATE outbox SET status = $1, handled = $2, error = $3, next_attempt = $4 WHERE id = $5",
+		eventToSave.Status, string(handled), eventToSave.Error, eventToSave.NextAttempt.UTC(), eventToSave.Id.Hex())
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return errors.Wrap(err, "error trying to update entity")
+	}
+	return nil
+}
+
+func (a OutboxSQLRepository) Prune(ctx context.Context, before time.Time) (count int64, err error) {
+	defer metrics.ObserveSQL("outbox", "Prune", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	result, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, "DELETE FROM outbox WHERE status <> $1 AND occurred_at < $2",
+		event.StatusPending, before.UTC())
+	if err != nil {
+		log.FromContext(ctx).Errorw("Prune has failed", "error", err)
+		return 0, errors.Wrap(err, "error trying to prune events")
+	}
+	return result.RowsAffected()
+}
+
+func (a OutboxSQLRepository) FindAfter(ctx context.Context, userId string, after event.Cursor, limit int) (result []*event.Event, err error) {
+	defer metrics.ObserveSQL("outbox", "FindAfter", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	return a.list(ctx, "SELECT "+eventColumns+` FROM outbox
+		WHERE (occurred_at > $1 OR (occurred_at = $1 AND id > $2)) AND ($3 = '' OR user_id = $3)
+		ORDER BY occurred_at, id LIMIT $4`, after.OccurredAt.UTC(), after.Id.Hex(), userId, limit)
+}
+
+func (a OutboxSQLRepository) list(ctx context.Context, query string, args ...interface{}) ([]*event.Event, error) {
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find events")
+	}
+	defer rows.Close()
+
+	events := make([]*event.Event, 0)
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Event has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Event")
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// handledOrEmpty keeps the column a JSON array when no subscriber has handled the event.
+func handledOrEmpty(handled []string) []string {
+	if handled == nil {
+		return []string{}
+	}
+	return handled
+}
+
+func scanEvent(row sqldb.Scanner) (*event.Event, error) {
+	var e event.Event
+	var id string
+	var data, handled []byte
+	err := row.Scan(&id, &e.Type, &e.UserId, &e.EntityId, &data, &e.OccurredAt, &e.Status, &handled,
+		&e.Attempts, &e.Error, &e.NextAttempt)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	e.Id = &objectID
+	e.EntityId = strings.TrimSpace(e.EntityId)
+	e.Data = data
+	if err = json.Unmarshal(handled, &e.Handled); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -2,38 +2,54 @@ package playlist_repository
 
 import (
 	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"os"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
 )
 
 type IPlaylistRepository interface {
-	Persist(playlistToPersist *playlist.Playlist) (*playlist.Playlist, error)
-	FindById(userId string, id *primitive.ObjectID, private bool) (result *playlist.Playlist, err error)
-	FindByUserIdAndPublic(userId string) (result []*playlist.Playlist, err error)
-	FindByUserId(userId string) (result []*playlist.Playlist, err error)
-	Count(userId string) (count int64, err error)
-	Delete(userId string, id *primitive.ObjectID) (result *playlist.Playlist, err error)
-	Update(id *primitive.ObjectID, userId string, playlistToSave *playlist.Playlist) (*playlist.Playlist, error)
-	FindFilter(filter, userId string) (playlistResult []map[string]interface{}, err error)
+	Persist(ctx context.Context, playlistToPersist *playlist.Playlist) (*playlist.Playlist, error)
+	FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (result *playlist.Playlist, err error)
+	FindByUserIdAndPublic(ctx context.Context, userId string) (result []*playlist.Playlist, err error)
+	FindByUserId(ctx context.Context, userId string) (result []*playlist.Playlist, err error)
+	// FindByDeckId returns the playlists holding deckId, whoever they belong to.
+	FindByDeckId(ctx context.Context, deckId string) (result []*playlist.Playlist, err error)
+	Count(ctx context.Context, userId string) (count int64, err error)
+	// Delete removes the playlist, only at expectedVersion when it is given. A nil result means nothing matched.
+	Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *playlist.Playlist, err error)
+	// Update saves playlistToSave if the stored version still equals playlistToSave.Version and bumps it.
+	// A nil result means nothing matched.
+	Update(ctx context.Context, id *primitive.ObjectID, userId string, playlistToSave *playlist.Playlist) (*playlist.Playlist, error)
+	FindFilter(ctx context.Context, filter, userId string) (playlistResult []map[string]interface{}, err error)
+	// AddDecks, RemoveDeck and MoveDeck bump the playlist version and, when expectedVersion
+	// is given, only apply at that version. A nil result means nothing matched.
+	AddDecks(ctx context.Context, userId string, id *primitive.ObjectID, decks []deck.DeckPreview, expectedVersion *int64) (*playlist.Playlist, error)
+	RemoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, expectedVersion *int64) (*playlist.Playlist, error)
+	MoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, position int, expectedVersion *int64) (*playlist.Playlist, error)
 }
 
 type PlaylistRepository struct {
 	client             *mongo.Client
 	database           string
 	playlistCollection string
+	timeouts           config.Timeouts
 }
 
-func NewPlaylistRepository(mongoClient *mongo.Client) PlaylistRepository {
+func NewPlaylistRepository(mongoClient *mongo.Client, cfg config.MongoDB) PlaylistRepository {
 	return PlaylistRepository{
 		client:             mongoClient,
-		database:           os.Getenv("MONGODB_DATABASE"),
-		playlistCollection: os.Getenv("MONGODB_PLAYLIST_COLLECTION"),
+		database:           cfg.Database,
+		playlistCollection: cfg.Collections.Playlist,
+		timeouts:           cfg.Timeouts,
 	}
 }
 
@@ -41,13 +57,22 @@ const (
 	errorString = "error trying to decode result"
 )
 
-func (a PlaylistRepository) Persist(playlist *playlist.Playlist) (*playlist.Playlist, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// Indexes are the indexes the queries below rely on.
+func (a PlaylistRepository) Indexes() []mongodb.Index {
+	return []mongodb.Index{
+		{Collection: a.playlistCollection, Name: "userId", Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Collection: a.playlistCollection, Name: "isPrivate", Keys: bson.D{{Key: "isPrivate", Value: 1}}},
+	}
+}
+
+func (a PlaylistRepository) Persist(ctx context.Context, playlist *playlist.Playlist) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
 	result, err := a.client.Database(a.database).Collection(a.playlistCollection).InsertOne(ctx, playlist)
 	if err != nil {
-		log.Logger.Errorw("Persist has failed", "error", err)
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
 		return nil, errors.Wrap(err, "error trying to persist entity")
 	}
 	id := result.InsertedID.(primitive.ObjectID)
@@ -56,8 +81,9 @@ func (a PlaylistRepository) Persist(playlist *playlist.Playlist) (*playlist.Play
 	return playlist, nil
 }
 
-func (a PlaylistRepository) FindById(userId string, id *primitive.ObjectID, private bool) (playlist *playlist.Playlist, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a PlaylistRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (playlist *playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 	privateResult := bson.M{}
 	if !private {
@@ -72,28 +98,29 @@ func (a PlaylistRepository) FindById(userId string, id *primitive.ObjectID, priv
 	}}
 	result := col.FindOne(ctx, query)
 	if result.Err() != nil {
-		log.Logger.Warn("Find playlist by id has failed", "Error", result.Err())
+		log.FromContext(ctx).Warn("Find playlist by id has failed", "Error", result.Err())
 		return nil, errors.Wrap(result.Err(), "error trying to find playlist by id")
 	}
 
 	err = result.Decode(&playlist)
 	if err != nil {
-		log.Logger.Errorw(errorString, "error", err)
+		log.FromContext(ctx).Errorw(errorString, "error", err)
 		return nil, errors.Wrap(err, errorString)
 	}
 
 	return playlist, nil
 }
 
-func (a PlaylistRepository) FindByUserId(userId string) (playlistResult []*playlist.Playlist, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a PlaylistRepository) FindByUserId(ctx context.Context, userId string) (playlistResult []*playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "FindByUserId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 
 	col := a.client.Database(a.database).Collection(a.playlistCollection)
 	result, err := col.Find(ctx, bson.M{"userId": userId})
 
 	if err != nil {
-		log.Logger.Errorw("Find has failed", errorString, err.Error())
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
 		return nil, errors.Wrap(err, "error trying to find playlists")
 	}
 
@@ -102,7 +129,7 @@ func (a PlaylistRepository) FindByUserId(userId string) (playlistResult []*playl
 		var playlistElement *playlist.Playlist
 		err := result.Decode(&playlistElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Playlist has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Playlist has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Playlist")
 		}
 		playlistResult = append(playlistResult, playlistElement)
@@ -110,15 +137,48 @@ func (a PlaylistRepository) FindByUserId(userId string) (playlistResult []*playl
 
 	err = result.Close(ctx)
 	if err != nil {
-		log.Logger.Errorw("Error closing context...", "Error", err.Error())
+		log.FromContext(ctx).Errorw("Error closing context...", "Error", err.Error())
 		return nil, errors.Wrap(err, "error trying to find playlists")
 	}
 
 	return
 }
 
-func (a PlaylistRepository) FindByUserIdAndPublic(userId string) (playlistResult []*playlist.Playlist, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a PlaylistRepository) FindByDeckId(ctx context.Context, deckId string) (playlistResult []*playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "FindByDeckId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+
+	col := a.client.Database(a.database).Collection(a.playlistCollection)
+	result, err := col.Find(ctx, bson.M{"decks._id": deckId})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
+		return nil, errors.Wrap(err, "error trying to find playlists")
+	}
+
+	playlistResult = make([]*playlist.Playlist, 0)
+	for result.Next(ctx) {
+		var playlistElement *playlist.Playlist
+		err := result.Decode(&playlistElement)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Playlist has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Playlist")
+		}
+		playlistResult = append(playlistResult, playlistElement)
+	}
+
+	err = result.Close(ctx)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error closing context...", "Error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find playlists")
+	}
+
+	return
+}
+
+func (a PlaylistRepository) FindByUserIdAndPublic(ctx context.Context, userId string) (playlistResult []*playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "FindByUserIdAndPublic", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 
 	col := a.client.Database(a.database).Collection(a.playlistCollection)
@@ -129,7 +189,7 @@ func (a PlaylistRepository) FindByUserIdAndPublic(userId string) (playlistResult
 	result, err := col.Find(ctx, query)
 
 	if err != nil {
-		log.Logger.Errorw("Find has failed", errorString, err.Error())
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
 		return nil, errors.Wrap(err, "error trying to find playlists")
 	}
 
@@ -138,63 +198,78 @@ func (a PlaylistRepository) FindByUserIdAndPublic(userId string) (playlistResult
 		var playlistElement *playlist.Playlist
 		err := result.Decode(&playlistElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Playlist has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Playlist has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Playlist")
 		}
 		playlistResult = append(playlistResult, playlistElement)
 	}
 	return
 }
-func (a PlaylistRepository) Count(userId string) (count int64, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a PlaylistRepository) Count(ctx context.Context, userId string) (count int64, err error) {
+	defer metrics.ObserveMongo("playlist", "Count", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 
 	count, err = a.client.Database(a.database).Collection(a.playlistCollection).CountDocuments(ctx, bson.M{"userId": userId})
 	if err != nil {
-		log.Logger.Errorw("Count playlists has failed", "error", err.Error())
+		log.FromContext(ctx).Errorw("Count playlists has failed", "error", err.Error())
 		return 0, errors.Wrap(err, "error trying to count playlists")
 	}
 
 	return count, nil
 }
 
-func (a PlaylistRepository) Delete(userId string, id *primitive.ObjectID) (result *playlist.Playlist, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a PlaylistRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "Delete", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
 	query := bson.M{"_id": id,
 		"userId": userId,
 	}
+	if expectedVersion != nil {
+		query["version"] = mongodb.VersionFilter(*expectedVersion)
+	}
 	singleResult := a.client.Database(a.database).
 		Collection(a.playlistCollection).FindOneAndDelete(ctx, query)
 
+	if singleResult.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
 	if singleResult.Err() != nil {
-		log.Logger.Errorw("Delete has failed", "Error", err)
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
 		return nil, errors.Wrap(singleResult.Err(), "error trying to delete entity")
 	}
 
 	err = singleResult.Decode(&result)
 	if err != nil {
-		log.Logger.Errorw("Parser Playlist has failed", "Error", err)
+		log.FromContext(ctx).Errorw("Parser Playlist has failed", "Error", err)
 		return nil, errors.Wrap(err, "Parser Playlist has failed")
 	}
 
-	log.Logger.Debug("playlist deleted: ", result)
+	log.FromContext(ctx).Debug("playlist deleted: ", result)
 	return result, err
 }
 
-func (a PlaylistRepository) Update(id *primitive.ObjectID, userId string, playlist *playlist.Playlist) (*playlist.Playlist, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a PlaylistRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, playlist *playlist.Playlist) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
-	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId}}
+	expectedVersion := playlist.Version
+	playlist.Version = expectedVersion + 1
+
+	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId},
+		"version": mongodb.VersionFilter(expectedVersion)}
 	updateResult, err := a.client.Database(a.database).Collection(a.playlistCollection).ReplaceOne(ctx, filterQuery, playlist)
 	if err != nil {
-		log.Logger.Errorw("Update has failed", "error", err)
+		playlist.Version = expectedVersion
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
 		return nil, errors.Wrap(err, "error trying to update entity")
 	}
 
 	if updateResult != nil && updateResult.MatchedCount == 0 {
+		playlist.Version = expectedVersion
 		return nil, nil
 	}
 	playlist.Id = id
@@ -232,8 +307,9 @@ func (a PlaylistRepository) Update(id *primitive.ObjectID, userId string, playli
 //	return
 //}
 
-func (a PlaylistRepository) FindFilter(filter, userId string) (playlistResult []map[string]interface{}, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a PlaylistRepository) FindFilter(ctx context.Context, filter, userId string) (playlistResult []map[string]interface{}, err error) {
+	defer metrics.ObserveMongo("playlist", "FindFilter", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Search)
 	defer cancel()
 
 	col := a.client.Database(a.database).Collection(a.playlistCollection)
@@ -250,7 +326,7 @@ func (a PlaylistRepository) FindFilter(filter, userId string) (playlistResult []
 	result, err := col.Find(ctx, query)
 
 	if err != nil {
-		log.Logger.Errorw("Find has failed", errorString, err.Error())
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
 		return nil, errors.Wrap(err, "error trying to find playlists")
 	}
 
@@ -259,10 +335,103 @@ func (a PlaylistRepository) FindFilter(filter, userId string) (playlistResult []
 		var playlistElement map[string]interface{}
 		err := result.Decode(&playlistElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Playlist has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Playlist has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Playlist")
 		}
 		playlistResult = append(playlistResult, playlistElement)
 	}
 	return
 }
+
+// AddDecks appends the decks only when none of them is already in the playlist,
+// so a nil result also means the playlist would get a duplicate.
+func (a PlaylistRepository) AddDecks(ctx context.Context, userId string, id *primitive.ObjectID, decks []deck.DeckPreview, expectedVersion *int64) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "AddDecks", time.Now(), &err)
+	deckIds := make([]string, 0, len(decks))
+	for _, preview := range decks {
+		deckIds = append(deckIds, preview.Id)
+	}
+
+	filterQuery := bson.M{"_id": id, "userId": userId, "decks._id": bson.M{"$nin": deckIds}}
+	update := bson.A{bson.M{"$set": bson.M{
+		"decks": bson.M{"$concatArrays": bson.A{
+			bson.M{"$ifNull": bson.A{"$decks", bson.A{}}},
+			bson.M{"$literal": decks},
+		}},
+		"lastUpdate": time.Now(),
+		"version":    nextVersion,
+	}}}
+
+	return a.findOneAndUpdate(ctx, withVersion(filterQuery, expectedVersion), update)
+}
+
+func (a PlaylistRepository) RemoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, expectedVersion *int64) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "RemoveDeck", time.Now(), &err)
+	filterQuery := bson.M{"_id": id, "userId": userId, "decks._id": deckId}
+	update := bson.M{
+		"$pull": bson.M{"decks": bson.M{"_id": deckId}},
+		"$set":  bson.M{"lastUpdate": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+
+	return a.findOneAndUpdate(ctx, withVersion(filterQuery, expectedVersion), update)
+}
+
+// MoveDeck places the deck at position (0 based) in a single pipeline update;
+// positions past the end move the deck to the last place.
+func (a PlaylistRepository) MoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, position int, expectedVersion *int64) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveMongo("playlist", "MoveDeck", time.Now(), &err)
+	var head interface{} = bson.A{}
+	if position > 0 {
+		head = bson.M{"$slice": bson.A{"$$rest", position}}
+	}
+	tail := bson.M{"$slice": bson.A{"$$rest", position, bson.M{"$max": bson.A{bson.M{"$size": "$$rest"}, 1}}}}
+
+	filterQuery := bson.M{"_id": id, "userId": userId, "decks._id": deckId}
+	update := bson.A{bson.M{"$set": bson.M{
+		"decks": bson.M{"$let": bson.M{
+			"vars": bson.M{
+				"item": bson.M{"$filter": bson.M{"input": "$decks", "cond": bson.M{"$eq": bson.A{"$$this._id", deckId}}}},
+				"rest": bson.M{"$filter": bson.M{"input": "$decks", "cond": bson.M{"$ne": bson.A{"$$this._id", deckId}}}},
+			},
+			"in": bson.M{"$concatArrays": bson.A{head, "$$item", tail}},
+		}},
+		"lastUpdate": time.Now(),
+		"version":    nextVersion,
+	}}}
+
+	return a.findOneAndUpdate(ctx, withVersion(filterQuery, expectedVersion), update)
+}
+
+// nextVersion bumps the version inside pipeline updates, where $inc is not available.
+var nextVersion = bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}}
+
+func withVersion(filterQuery bson.M, expectedVersion *int64) bson.M {
+	if expectedVersion != nil {
+		filterQuery["version"] = mongodb.VersionFilter(*expectedVersion)
+	}
+	return filterQuery
+}
+
+func (a PlaylistRepository) findOneAndUpdate(ctx context.Context, filterQuery, update interface{}) (result *playlist.Playlist, err error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	singleResult := a.client.Database(a.database).Collection(a.playlistCollection).
+		FindOneAndUpdate(ctx, filterQuery, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if singleResult.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if singleResult.Err() != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", singleResult.Err())
+		return nil, errors.Wrap(singleResult.Err(), "error trying to update entity")
+	}
+
+	err = singleResult.Decode(&result)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Parser Playlist has failed", "Error", err)
+		return nil, errors.Wrap(err, "Parser Playlist has failed")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package playlist_repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/cache"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// PlaylistCacheRepository reads playlists through the cache with the same rules as
+// DeckCacheRepository: visibility is checked on every read and reads with private set go
+// to the repository. Every write starts a new generation of the cached playlists once its
+// transaction has ended.
+type PlaylistCacheRepository struct {
+	IPlaylistRepository
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+func NewPlaylistCacheRepository(repository IPlaylistRepository, c cache.Cache, ttl time.Duration) PlaylistCacheRepository {
+	return PlaylistCacheRepository{IPlaylistRepository: repository, cache: c, ttl: ttl}
+}
+
+func (a PlaylistCacheRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (*playlist.Playlist, error) {
+	if private {
+		return a.IPlaylistRepository.FindById(ctx, userId, id, private)
+	}
+
+	key := cache.PlaylistKey(cache.Generation(ctx, a.cache, cache.PlaylistGroup), id.Hex())
+	var found *playlist.Playlist
+	if !cache.Load(ctx, a.cache, "playlist", key, &found) {
+		var err error
+		found, err = a.IPlaylistRepository.FindById(ctx, userId, id, true)
+		if err != nil {
+			return nil, err
+		}
+		cache.Store(ctx, a.cache, key, found, a.ttl)
+	}
+
+	if found.IsPrivate && found.UserId != userId {
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "error trying to find playlist by id")
+	}
+	return found, nil
+}
+
+func (a PlaylistCacheRepository) FindByUserIdAndPublic(ctx context.Context, userId string) ([]*playlist.Playlist, error) {
+	key := cache.PublicKey(cache.PlaylistGroup, cache.Generation(ctx, a.cache, cache.PlaylistGroup), userId)
+	var playlists []*playlist.Playlist
+	if cache.Load(ctx, a.cache, "playlists", key, &playlists) {
+		return playlists, nil
+	}
+
+	playlists, err := a.IPlaylistRepository.FindByUserIdAndPublic(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	cache.Store(ctx, a.cache, key, playlists, a.ttl)
+	return playlists, nil
+}
+
+func (a PlaylistCacheRepository) Persist(ctx context.Context, playlistToPersist *playlist.Playlist) (*playlist.Playlist, error) {
+	defer a.invalidate(ctx)
+	return a.IPlaylistRepository.Persist(ctx, playlistToPersist)
+}
+
+func (a PlaylistCacheRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (*playlist.Playlist, error) {
+	defer a.invalidate(ctx)
+	return a.IPlaylistRepository.Delete(ctx, userId, id, expectedVersion)
+}
+
+func (a PlaylistCacheRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, playlistToSave *playlist.Playlist) (*playlist.Playlist, error) {
+	defer a.invalidate(ctx)
+	return a.IPlaylistRepository.Update(ctx, id, userId, playlistToSave)
+}
+
+func (a PlaylistCacheRepository) AddDecks(ctx context.Context, userId string, id *primitive.ObjectID, decks []deck.DeckPreview, expectedVersion *int64) (*playlist.Playlist, error) {
+	defer a.invalidate(ctx)
+	return a.IPlaylistRepository.AddDecks(ctx, userId, id, decks, expectedVersion)
+}
+
+func (a PlaylistCacheRepository) RemoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, expectedVersion *int64) (*playlist.Playlist, error) {
+	defer a.invalidate(ctx)
+	return a.IPlaylistRepository.RemoveDeck(ctx, userId, id, deckId, expectedVersion)
+}
+
+func (a PlaylistCacheRepository) MoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, position int, expectedVersion *int64) (*playlist.Playlist, error) {
+	defer a.invalidate(ctx)
+	return a.IPlaylistRepository.MoveDeck(ctx, userId, id, deckId, position, expectedVersion)
+}
+
+// invalidate starts a new generation once the transaction of ctx has ended.
+func (a PlaylistCacheRepository) invalidate(ctx context.Context) {
+	transaction.AfterCommit(ctx, func() { cache.Invalidate(ctx, a.cache, cache.PlaylistGroup) })
+}
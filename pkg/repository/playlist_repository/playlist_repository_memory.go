@@ -0,0 +1,264 @@
+package playlist_repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// PlaylistMemoryRepository keeps the playlists in memory with the same visibility and
+// version rules as PlaylistRepository, so the server runs without MongoDB. Playlists are
+// stored and returned as copies, like documents going through the driver.
+type PlaylistMemoryRepository struct {
+	mutex     sync.RWMutex
+	playlists []*playlist.Playlist
+}
+
+func NewPlaylistMemoryRepository() *PlaylistMemoryRepository {
+	return &PlaylistMemoryRepository{playlists: make([]*playlist.Playlist, 0)}
+}
+
+func (a *PlaylistMemoryRepository) Persist(_ context.Context, playlistToPersist *playlist.Playlist) (*playlist.Playlist, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if playlistToPersist.Id == nil {
+		id := primitive.NewObjectID()
+		playlistToPersist.Id = &id
+	} else if a.find(playlistToPersist.Id) >= 0 {
+		return nil, errors.New("error trying to persist entity: duplicate key " + playlistToPersist.Id.Hex())
+	}
+	a.playlists = append(a.playlists, clonePlaylist(playlistToPersist))
+	return playlistToPersist, nil
+}
+
+func (a *PlaylistMemoryRepository) FindById(_ context.Context, userId string, id *primitive.ObjectID, private bool) (*playlist.Playlist, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	i := a.find(id)
+	if i < 0 || !visible(a.playlists[i], userId, private) {
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "error trying to find playlist by id")
+	}
+	return clonePlaylist(a.playlists[i]), nil
+}
+
+func (a *PlaylistMemoryRepository) FindByUserIdAndPublic(_ context.Context, userId string) ([]*playlist.Playlist, error) {
+	return a.filter(func(p *playlist.Playlist) bool { return visible(p, userId, false) }), nil
+}
+
+func (a *PlaylistMemoryRepository) FindByUserId(_ context.Context, userId string) ([]*playlist.Playlist, error) {
+	return a.filter(func(p *playlist.Playlist) bool { return p.UserId == userId }), nil
+}
+
+func (a *PlaylistMemoryRepository) FindByDeckId(_ context.Context, deckId string) ([]*playlist.Playlist, error) {
+	return a.filter(func(p *playlist.Playlist) bool { return indexOfDeck(p.Decks, deckId) >= 0 }), nil
+}
+
+func (a *PlaylistMemoryRepository) Count(_ context.Context, userId string) (int64, error) {
+	return int64(len(a.filter(func(p *playlist.Playlist) bool { return p.UserId == userId }))), nil
+}
+
+func (a *PlaylistMemoryRepository) Delete(_ context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (*playlist.Playlist, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.playlists[i].UserId != userId || !atVersion(a.playlists[i], expectedVersion) {
+		return nil, nil
+	}
+	deleted := a.playlists[i]
+	a.playlists = append(a.playlists[:i], a.playlists[i+1:]...)
+	return deleted, nil
+}
+
+func (a *PlaylistMemoryRepository) Update(_ context.Context, id *primitive.ObjectID, userId string, playlistToSave *playlist.Playlist) (*playlist.Playlist, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.playlists[i].UserId != userId || a.playlists[i].Version != playlistToSave.Version {
+		return nil, nil
+	}
+
+	playlistToSave.Version++
+	playlistToSave.Id = id
+	a.playlists[i] = clonePlaylist(playlistToSave)
+	return playlistToSave, nil
+}
+
+func (a *PlaylistMemoryRepository) FindFilter(_ context.Context, filter, userId string) ([]map[string]interface{}, error) {
+	name, err := regexp.Compile("(?i).*" + filter + ".*")
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to find playlists")
+	}
+
+	playlists := a.filter(func(p *playlist.Playlist) bool { return visible(p, userId, false) && name.MatchString(p.Name) })
+	playlistResult := make([]map[string]interface{}, 0, len(playlists))
+	for _, p := range playlists {
+		document, err := bson.Marshal(p)
+		if err != nil {
+			return nil, errors.Wrap(err, "error trying to parse Playlist")
+		}
+		var playlistElement map[string]interface{}
+		if err := bson.Unmarshal(document, &playlistElement); err != nil {
+			return nil, errors.Wrap(err, "error trying to parse Playlist")
+		}
+		playlistResult = append(playlistResult, playlistElement)
+	}
+	return playlistResult, nil
+}
+
+// AddDecks appends the decks only when none of them is already in the playlist,
+// so a nil result also means the playlist would get a duplicate.
+func (a *PlaylistMemoryRepository) AddDecks(_ context.Context, userId string, id *primitive.ObjectID, decks []deck.DeckPreview, expectedVersion *int64) (*playlist.Playlist, error) {
+	return a.update(userId, id, expectedVersion, func(p *playlist.Playlist) bool { return addDecks(p, decks) })
+}
+
+func (a *PlaylistMemoryRepository) RemoveDeck(_ context.Context, userId string, id *primitive.ObjectID, deckId string, expectedVersion *int64) (*playlist.Playlist, error) {
+	return a.update(userId, id, expectedVersion, func(p *playlist.Playlist) bool { return removeDeck(p, deckId) })
+}
+
+// MoveDeck places the deck at position (0 based); positions past the end move the
+// deck to the last place.
+func (a *PlaylistMemoryRepository) MoveDeck(_ context.Context, userId string, id *primitive.ObjectID, deckId string, position int, expectedVersion *int64) (*playlist.Playlist, error) {
+	return a.update(userId, id, expectedVersion, func(p *playlist.Playlist) bool { return moveDeck(p, deckId, position) })
+}
+
+// update applies change to the playlist of userId at expectedVersion, change returns
+// false when the playlist does not match. The version is bumped and the result is
+// the playlist after the change.
+func (a *PlaylistMemoryRepository) update(userId string, id *primitive.ObjectID, expectedVersion *int64, change func(p *playlist.Playlist) bool) (*playlist.Playlist, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.playlists[i].UserId != userId || !atVersion(a.playlists[i], expectedVersion) {
+		return nil, nil
+	}
+
+	changed := clonePlaylist(a.playlists[i])
+	if !change(changed) {
+		return nil, nil
+	}
+	changed.LastUpdate = time.Now()
+	changed.Version++
+	a.playlists[i] = changed
+	return clonePlaylist(changed), nil
+}
+
+func (a *PlaylistMemoryRepository) find(id *primitive.ObjectID) int {
+	for i, p := range a.playlists {
+		if id != nil && *p.Id == *id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *PlaylistMemoryRepository) filter(matches func(p *playlist.Playlist) bool) []*playlist.Playlist {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	result := make([]*playlist.Playlist, 0)
+	for _, p := range a.playlists {
+		if matches(p) {
+			result = append(result, clonePlaylist(p))
+		}
+	}
+	return result
+}
+
+func atVersion(p *playlist.Playlist, expectedVersion *int64) bool {
+	return expectedVersion == nil || p.Version == *expectedVersion
+}
+
+// addDecks, removeDeck and moveDeck change the decks of p the way the MongoDB updates
+// do, they return false when p does not match the update filter.
+func addDecks(p *playlist.Playlist, decks []deck.DeckPreview) bool {
+	for _, preview := range decks {
+		if indexOfDeck(p.Decks, preview.Id) >= 0 {
+			return false
+		}
+	}
+	p.Decks = append(p.Decks, decks...)
+	return true
+}
+
+func removeDeck(p *playlist.Playlist, deckId string) bool {
+	if indexOfDeck(p.Decks, deckId) < 0 {
+		return false
+	}
+	rest := make([]deck.DeckPreview, 0, len(p.Decks))
+	for _, preview := range p.Decks {
+		if preview.Id != deckId {
+			rest = append(rest, preview)
+		}
+	}
+	p.Decks = rest
+	return true
+}
+
+func moveDeck(p *playlist.Playlist, deckId string, position int) bool {
+	if indexOfDeck(p.Decks, deckId) < 0 {
+		return false
+	}
+	item := make([]deck.DeckPreview, 0, 1)
+	rest := make([]deck.DeckPreview, 0, len(p.Decks))
+	for _, preview := range p.Decks {
+		if preview.Id == deckId {
+			item = append(item, preview)
+		} else {
+			rest = append(rest, preview)
+		}
+	}
+	if position > len(rest) {
+		position = len(rest)
+	}
+	if position < 0 {
+		position = 0
+	}
+
+	moved := make([]deck.DeckPreview, 0, len(p.Decks))
+	moved = append(moved, rest[:position]...)
+	moved = append(moved, item...)
+	p.Decks = append(moved, rest[position:]...)
+	return true
+}
+
+func indexOfDeck(decks []deck.DeckPreview, deckId string) int {
+	for i, preview := range decks {
+		if preview.Id == deckId {
+			return i
+		}
+	}
+	return -1
+}
+
+// visible is the $or of the queries above: the owner sees everything, others only the
+// public playlists unless private is set.
+func visible(p *playlist.Playlist, userId string, private bool) bool {
+	return private || !p.IsPrivate || p.UserId == userId
+}
+
+func clonePlaylist(p *playlist.Playlist) *playlist.Playlist {
+	clone := *p
+	if p.Id != nil {
+		id := *p.Id
+		clone.Id = &id
+	}
+	if p.StudySuggestions != nil {
+		clone.StudySuggestions = append(make([]string, 0, len(p.StudySuggestions)), p.StudySuggestions...)
+	}
+	if p.Decks != nil {
+		clone.Decks = append(make([]deck.DeckPreview, 0, len(p.Decks)), p.Decks...)
+	}
+	return &clone
+}
@@ -0,0 +1,78 @@
+package playlist_repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func decksOf(p *playlist.Playlist) []string {
+	ids := make([]string, 0, len(p.Decks))
+	for _, preview := range p.Decks {
+		ids = append(ids, preview.Id)
+	}
+	return ids
+}
+
+func TestMemoryFindByIdVisibility(t *testing.T) {
+	ctx := context.Background()
+	repository := NewPlaylistMemoryRepository()
+	private, _ := repository.Persist(ctx, &playlist.Playlist{Name: "Verbs", UserId: "owner", IsPrivate: true})
+
+	_, err := repository.FindById(ctx, "other", private.Id, false)
+	assert.Error(t, err)
+
+	found, err := repository.FindById(ctx, "owner", private.Id, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "Verbs", found.Name)
+
+	found.Name = "changed"
+	stored, _ := repository.FindById(ctx, "owner", private.Id, false)
+	assert.Equal(t, "Verbs", stored.Name, "results are copies")
+}
+
+func TestMemoryUpdateChecksVersion(t *testing.T) {
+	ctx := context.Background()
+	repository := NewPlaylistMemoryRepository()
+	saved, _ := repository.Persist(ctx, &playlist.Playlist{Name: "Verbs", UserId: "owner"})
+
+	updated, err := repository.Update(ctx, saved.Id, "owner", &playlist.Playlist{Name: "Nouns", UserId: "owner", Version: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), updated.Version)
+
+	stale, err := repository.Update(ctx, saved.Id, "owner", &playlist.Playlist{Name: "Adjectives", UserId: "owner", Version: 0})
+	assert.NoError(t, err)
+	assert.Nil(t, stale)
+
+	expected := int64(0)
+	deleted, err := repository.Delete(ctx, "owner", saved.Id, &expected)
+	assert.NoError(t, err)
+	assert.Nil(t, deleted)
+}
+
+func TestMemoryDeckOrder(t *testing.T) {
+	ctx := context.Background()
+	repository := NewPlaylistMemoryRepository()
+	saved, _ := repository.Persist(ctx, &playlist.Playlist{Name: "Verbs", UserId: "owner"})
+	previews := []deck.DeckPreview{{Id: "a"}, {Id: "b"}, {Id: "c"}}
+
+	result, err := repository.AddDecks(ctx, "owner", saved.Id, previews, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, decksOf(result))
+
+	duplicate, err := repository.AddDecks(ctx, "owner", saved.Id, previews[:1], nil)
+	assert.NoError(t, err)
+	assert.Nil(t, duplicate)
+
+	result, _ = repository.MoveDeck(ctx, "owner", saved.Id, "a", 10, nil)
+	assert.Equal(t, []string{"b", "c", "a"}, decksOf(result))
+
+	result, _ = repository.MoveDeck(ctx, "owner", saved.Id, "c", 0, nil)
+	assert.Equal(t, []string{"c", "b", "a"}, decksOf(result))
+
+	result, _ = repository.RemoveDeck(ctx, "owner", saved.Id, "b", &result.Version)
+	assert.Equal(t, []string{"c", "a"}, decksOf(result))
+	assert.Equal(t, int64(4), result.Version)
+}
@@ -1,6 +1,8 @@
 package playlist_repository
 
 import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,42 +12,71 @@ type MockPlaylistRepository struct {
 	mock.Mock
 }
 
-func (uc *MockPlaylistRepository) Persist(playlistToPersist *playlist.Playlist) (*playlist.Playlist, error) {
-	args := uc.Called(playlistToPersist)
+func (uc *MockPlaylistRepository) Persist(ctx context.Context, playlistToPersist *playlist.Playlist) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, playlistToPersist)
 	if playlistReturn := args.Get(0); playlistReturn != nil {
 		return playlistReturn.(*playlist.Playlist), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (uc *MockPlaylistRepository) FindByUserId(userId string) (result []*playlist.Playlist, err error) {
-	args := uc.Called(userId)
+func (uc *MockPlaylistRepository) FindByUserId(ctx context.Context, userId string) (result []*playlist.Playlist, err error) {
+	args := uc.Called(ctx, userId)
 	return args.Get(0).([]*playlist.Playlist), args.Error(1)
 }
-func (uc *MockPlaylistRepository) FindByUserIdAndPublic(userId string) (result []*playlist.Playlist, err error) {
-	args := uc.Called(userId)
+func (uc *MockPlaylistRepository) FindByUserIdAndPublic(ctx context.Context, userId string) (result []*playlist.Playlist, err error) {
+	args := uc.Called(ctx, userId)
 	return args.Get(0).([]*playlist.Playlist), args.Error(1)
 }
 
-func (uc *MockPlaylistRepository) FindById(userId string, id *primitive.ObjectID, private bool) (*playlist.Playlist, error) {
-	args := uc.Called(userId, id, private)
+func (uc *MockPlaylistRepository) FindByDeckId(ctx context.Context, deckId string) (result []*playlist.Playlist, err error) {
+	args := uc.Called(ctx, deckId)
+	return args.Get(0).([]*playlist.Playlist), args.Error(1)
+}
+
+func (uc *MockPlaylistRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, userId, id, private)
 	return args.Get(0).(*playlist.Playlist), args.Error(1)
 }
 
-func (uc *MockPlaylistRepository) Update(id *primitive.ObjectID, userId string, playlistToSave *playlist.Playlist) (*playlist.Playlist, error) {
-	args := uc.Called(id, userId, playlistToSave)
+func (uc *MockPlaylistRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, playlistToSave *playlist.Playlist) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, id, userId, playlistToSave)
 	if playlistReturn := args.Get(0); playlistReturn != nil {
 		return playlistReturn.(*playlist.Playlist), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (uc *MockPlaylistRepository) Delete(userId string, id *primitive.ObjectID) (*playlist.Playlist, error) {
-	args := uc.Called(id, userId)
+func (uc *MockPlaylistRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, id, userId, expectedVersion)
 	return args.Get(0).(*playlist.Playlist), args.Error(1)
 }
 
-func (db *MockPlaylistRepository) Count(userId string) (count int64, err error) {
-	args := db.Called(userId)
+func (db *MockPlaylistRepository) Count(ctx context.Context, userId string) (count int64, err error) {
+	args := db.Called(ctx, userId)
 	return args.Get(0).(int64), args.Error(1)
 }
+
+func (uc *MockPlaylistRepository) AddDecks(ctx context.Context, userId string, id *primitive.ObjectID, decks []deck.DeckPreview, expectedVersion *int64) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, userId, id, decks, expectedVersion)
+	if playlistReturn := args.Get(0); playlistReturn != nil {
+		return playlistReturn.(*playlist.Playlist), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (uc *MockPlaylistRepository) RemoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, expectedVersion *int64) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, userId, id, deckId, expectedVersion)
+	if playlistReturn := args.Get(0); playlistReturn != nil {
+		return playlistReturn.(*playlist.Playlist), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (uc *MockPlaylistRepository) MoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, position int, expectedVersion *int64) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, userId, id, deckId, position, expectedVersion)
+	if playlistReturn := args.Get(0); playlistReturn != nil {
+		return playlistReturn.(*playlist.Playlist), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
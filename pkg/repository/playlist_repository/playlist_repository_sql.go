@@ -0,0 +1,399 @@
+package playlist_repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	appErrors "github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/sqldb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const playlistColumns = "id, user_id, name, image_url, description, is_private, study_suggestions, version, last_update"
+
+// errUnchanged rolls back a deck change that does not apply to the playlist.
+var errUnchanged = errors.New("playlist unchanged")
+
+// PlaylistSQLRepository stores the playlists in the playlists table and their decks, in
+// order, in playlist_decks. The previews are kept as they were added, like the MongoDB
+// documents, and go away with their deck.
+type PlaylistSQLRepository struct {
+	db           *sql.DB
+	dialect      sqldb.Dialect
+	timeout      time.Duration
+	transactions sqldb.TransactionManager
+}
+
+func NewPlaylistSQLRepository(db *sql.DB, dialect sqldb.Dialect, timeout time.Duration) PlaylistSQLRepository {
+	return PlaylistSQLRepository{
+		db:           db,
+		dialect:      dialect,
+		timeout:      timeout,
+		transactions: sqldb.NewTransactionManager(db, timeout),
+	}
+}
+
+func (a PlaylistSQLRepository) Persist(ctx context.Context, playlistToPersist *playlist.Playlist) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "Persist", time.Now(), &err)
+	id := primitive.NewObjectID()
+	if playlistToPersist.Id != nil {
+		id = *playlistToPersist.Id
+	}
+	suggestions, err := json.Marshal(playlistToPersist.StudySuggestions)
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+
+	err = a.transactions.WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, "INSERT INTO playlists ("+playlistColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+			id.Hex(), playlistToPersist.UserId, playlistToPersist.Name, playlistToPersist.ImageURL, playlistToPersist.Description,
+			playlistToPersist.IsPrivate, string(suggestions), playlistToPersist.Version, playlistToPersist.LastUpdate)
+		if err != nil {
+			return err
+		}
+		return a.insertDecks(ctx, id.Hex(), playlistToPersist.Decks)
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
+		return nil, a.writeError(err, "error trying to persist entity")
+	}
+	playlistToPersist.Id = &id
+
+	return playlistToPersist, nil
+}
+
+func (a PlaylistSQLRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (result *playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "FindById", time.Now(), &err)
+	query, args := "SELECT "+playlistColumns+" FROM playlists WHERE id = $1", []interface{}{id.Hex()}
+	if !private {
+		query, args = query+" AND (NOT is_private OR user_id = $2)", append(args, userId)
+	}
+
+	playlists, err := a.list(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(playlists) == 0 {
+		log.FromContext(ctx).Warn("Find playlist by id has failed", "Error", sql.ErrNoRows)
+		return nil, errors.Wrap(sql.ErrNoRows, "error trying to find playlist by id")
+	}
+	return playlists[0], nil
+}
+
+func (a PlaylistSQLRepository) FindByUserIdAndPublic(ctx context.Context, userId string) (playlistResult []*playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "FindByUserIdAndPublic", time.Now(), &err)
+	return a.list(ctx, "SELECT "+playlistColumns+" FROM playlists WHERE NOT is_private OR user_id = $1 ORDER BY id", userId)
+}
+
+func (a PlaylistSQLRepository) FindByUserId(ctx context.Context, userId string) (playlistResult []*playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "FindByUserId", time.Now(), &err)
+	return a.list(ctx, "SELECT "+playlistColumns+" FROM playlists WHERE user_id = $1 ORDER BY id", userId)
+}
+
+func (a PlaylistSQLRepository) FindByDeckId(ctx context.Context, deckId string) (playlistResult []*playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "FindByDeckId", time.Now(), &err)
+	return a.list(ctx, "SELECT "+playlistColumns+" FROM playlists WHERE id IN (SELECT playlist_id FROM playlist_decks WHERE deck_id = $1) ORDER BY id", deckId)
+}
+
+func (a PlaylistSQLRepository) Count(ctx context.Context, userId string) (count int64, err error) {
+	defer metrics.ObserveSQL("playlist", "Count", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	err = sqldb.Conn(ctx, a.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM playlists WHERE user_id = $1", userId).Scan(&count)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Count Playlists has failed", "error", err.Error())
+		return 0, errors.Wrap(err, "error trying to count Playlists")
+	}
+	return count, nil
+}
+
+// Delete also removes the decks of the playlist, the foreign key cascades.
+func (a PlaylistSQLRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64) (result *playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "Delete", time.Now(), &err)
+	err = a.transactions.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = a.locked(ctx, userId, id, expectedVersion, false)
+		if err != nil || result == nil {
+			return err
+		}
+		_, err = sqldb.Conn(ctx, a.db).ExecContext(ctx, "DELETE FROM playlists WHERE id = $1", id.Hex())
+		return err
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to delete entity")
+	}
+
+	log.FromContext(ctx).Debug("Playlist deleted: ", result)
+	return result, nil
+}
+
+// Update also replaces the decks of the playlist.
+func (a PlaylistSQLRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, playlistToSave *playlist.Playlist) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "Update", time.Now(), &err)
+	suggestions, err := json.Marshal(playlistToSave.StudySuggestions)
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to update entity")
+	}
+
+	matched := false
+	err = a.transactions.WithTransaction(ctx, func(ctx context.Context) error {
+		updateResult, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, `UPDATE playlists SET image_url = $1, name = $2, description = $3,
+			is_private = $4, study_suggestions = $5, user_id = $6, last_update = $7, version = $8
+			WHERE id = $9 AND user_id = $10 AND version = $11`,
+			playlistToSave.ImageURL, playlistToSave.Name, playlistToSave.Description, playlistToSave.IsPrivate, string(suggestions),
+			playlistToSave.UserId, playlistToSave.LastUpdate, playlistToSave.Version+1, id.Hex(), userId, playlistToSave.Version)
+		if err != nil {
+			return err
+		}
+		if rows, err := updateResult.RowsAffected(); err != nil || rows == 0 {
+			return err
+		}
+		matched = true
+		return a.replaceDecks(ctx, id.Hex(), playlistToSave.Decks)
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return nil, a.writeError(err, "error trying to update entity")
+	}
+	if !matched {
+		return nil, nil
+	}
+
+	playlistToSave.Id = id
+	playlistToSave.Version++
+	return playlistToSave, nil
+}
+
+func (a PlaylistSQLRepository) FindFilter(ctx context.Context, filter, userId string) (playlistResult []map[string]interface{}, err error) {
+	defer metrics.ObserveSQL("playlist", "FindFilter", time.Now(), &err)
+	query, args := "SELECT "+playlistColumns+" FROM playlists WHERE (NOT is_private OR user_id = $1)", []interface{}{userId}
+	if a.dialect.Matches != nil {
+		query, args = query+" AND "+a.dialect.Matches("name", "$2"), append(args, filter)
+	}
+	playlists, err := a.list(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	if a.dialect.Matches == nil {
+		name, err := regexp.Compile("(?i)" + filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "error trying to find playlists")
+		}
+		matching := playlists[:0]
+		for _, p := range playlists {
+			if name.MatchString(p.Name) {
+				matching = append(matching, p)
+			}
+		}
+		playlists = matching
+	}
+
+	// The same documents the MongoDB search returns.
+	playlistResult = make([]map[string]interface{}, 0, len(playlists))
+	for _, p := range playlists {
+		document, err := bson.Marshal(p)
+		if err != nil {
+			return nil, errors.Wrap(err, "error trying to parse Playlist")
+		}
+		var playlistElement map[string]interface{}
+		if err := bson.Unmarshal(document, &playlistElement); err != nil {
+			return nil, errors.Wrap(err, "error trying to parse Playlist")
+		}
+		playlistResult = append(playlistResult, playlistElement)
+	}
+	return playlistResult, nil
+}
+
+// AddDecks appends the decks only when none of them is already in the playlist,
+// so a nil result also means the playlist would get a duplicate.
+func (a PlaylistSQLRepository) AddDecks(ctx context.Context, userId string, id *primitive.ObjectID, decks []deck.DeckPreview, expectedVersion *int64) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "AddDecks", time.Now(), &err)
+	return a.change(ctx, userId, id, expectedVersion, func(p *playlist.Playlist) bool { return addDecks(p, decks) })
+}
+
+func (a PlaylistSQLRepository) RemoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, expectedVersion *int64) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "RemoveDeck", time.Now(), &err)
+	return a.change(ctx, userId, id, expectedVersion, func(p *playlist.Playlist) bool { return removeDeck(p, deckId) })
+}
+
+// MoveDeck places the deck at position (0 based); positions past the end move the
+// deck to the last place.
+func (a PlaylistSQLRepository) MoveDeck(ctx context.Context, userId string, id *primitive.ObjectID, deckId string, position int, expectedVersion *int64) (saved *playlist.Playlist, err error) {
+	defer metrics.ObserveSQL("playlist", "MoveDeck", time.Now(), &err)
+	return a.change(ctx, userId, id, expectedVersion, func(p *playlist.Playlist) bool { return moveDeck(p, deckId, position) })
+}
+
+// change applies changeDecks to the playlist of userId at expectedVersion in a
+// transaction, changeDecks returns false when the playlist does not match. The version
+// is bumped and the result is the playlist after the change.
+func (a PlaylistSQLRepository) change(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64, changeDecks func(p *playlist.Playlist) bool) (result *playlist.Playlist, err error) {
+	err = a.transactions.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = a.locked(ctx, userId, id, expectedVersion, true)
+		if err != nil || result == nil {
+			return err
+		}
+		if !changeDecks(result) {
+			return errUnchanged
+		}
+		return a.replaceDecks(ctx, id.Hex(), result.Decks)
+	})
+	if err == errUnchanged {
+		return nil, nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return nil, a.writeError(err, "error trying to update entity")
+	}
+	return result, nil
+}
+
+// locked reads the playlist of userId at expectedVersion after writing to its row, so
+// concurrent changes wait for the transaction. bump also moves the version and
+// lastUpdate. A nil result means nothing matched.
+func (a PlaylistSQLRepository) locked(ctx context.Context, userId string, id *primitive.ObjectID, expectedVersion *int64, bump bool) (*playlist.Playlist, error) {
+	set := "version = version"
+	args := []interface{}{id.Hex(), userId}
+	if bump {
+		set, args = "version = version + 1, last_update = $3", append(args, time.Now())
+	}
+	query := "UPDATE playlists SET " + set + " WHERE id = $1 AND user_id = $2"
+	if expectedVersion != nil {
+		args = append(args, *expectedVersion)
+		query += " AND version = " + sqldb.Placeholders(len(args), 1)
+	}
+
+	updateResult, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := updateResult.RowsAffected(); err != nil || rows == 0 {
+		return nil, err
+	}
+
+	playlists, err := a.list(ctx, "SELECT "+playlistColumns+" FROM playlists WHERE id = $1", id.Hex())
+	if err != nil || len(playlists) == 0 {
+		return nil, err
+	}
+	return playlists[0], nil
+}
+
+func (a PlaylistSQLRepository) list(ctx context.Context, query string, args ...interface{}) ([]*playlist.Playlist, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", errorString, err.Error())
+		return nil, errors.Wrap(err, "error trying to find playlists")
+	}
+	defer rows.Close()
+
+	playlistResult := make([]*playlist.Playlist, 0)
+	byId := make(map[string]*playlist.Playlist)
+	for rows.Next() {
+		playlistElement, err := scanPlaylist(rows)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Playlist has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Playlist")
+		}
+		playlistResult = append(playlistResult, playlistElement)
+		byId[playlistElement.Id.Hex()] = playlistElement
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error trying to find playlists")
+	}
+	rows.Close()
+
+	if err = a.loadDecks(ctx, byId); err != nil {
+		log.FromContext(ctx).Errorw("Find playlist decks has failed", errorString, err.Error())
+		return nil, errors.Wrap(err, "error trying to find playlist decks")
+	}
+	return playlistResult, nil
+}
+
+// loadDecks fills the decks of the playlists in one query.
+func (a PlaylistSQLRepository) loadDecks(ctx context.Context, playlists map[string]*playlist.Playlist) error {
+	if len(playlists) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(playlists))
+	for id, p := range playlists {
+		p.Decks = make([]deck.DeckPreview, 0)
+		args = append(args, id)
+	}
+
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, `SELECT playlist_id, deck_id, image_url, name, user_id FROM playlist_decks
+		WHERE playlist_id IN (`+sqldb.Placeholders(1, len(args))+`) ORDER BY playlist_id, position`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var playlistId string
+		var preview deck.DeckPreview
+		if err := rows.Scan(&playlistId, &preview.Id, &preview.ImageURL, &preview.Name, &preview.UserId); err != nil {
+			return err
+		}
+		p := playlists[strings.TrimSpace(playlistId)]
+		preview.Id = strings.TrimSpace(preview.Id)
+		p.Decks = append(p.Decks, preview)
+	}
+	return rows.Err()
+}
+
+func (a PlaylistSQLRepository) replaceDecks(ctx context.Context, playlistId string, decks []deck.DeckPreview) error {
+	if _, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, "DELETE FROM playlist_decks WHERE playlist_id = $1", playlistId); err != nil {
+		return err
+	}
+	return a.insertDecks(ctx, playlistId, decks)
+}
+
+func (a PlaylistSQLRepository) insertDecks(ctx context.Context, playlistId string, decks []deck.DeckPreview) error {
+	for i, preview := range decks {
+		_, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, `INSERT INTO playlist_decks (playlist_id, deck_id, position, image_url, name, user_id)
+			VALUES ($1, $2, $3, $4, $5, $6)`, playlistId, preview.Id, i, preview.ImageURL, preview.Name, preview.UserId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeError reports a deck that does not exist as not found instead of a failed write.
+func (a PlaylistSQLRepository) writeError(err error, message string) error {
+	if a.dialect.IsForeignKeyViolation(err) {
+		return appErrors.WrapWithMessage(appErrors.ErrNotFound, "deck not found")
+	}
+	return errors.Wrap(err, message)
+}
+
+func scanPlaylist(row sqldb.Scanner) (*playlist.Playlist, error) {
+	var p playlist.Playlist
+	var id string
+	var suggestions []byte
+	err := row.Scan(&id, &p.UserId, &p.Name, &p.ImageURL, &p.Description, &p.IsPrivate, &suggestions, &p.Version, &p.LastUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	p.Id = &objectID
+	if err = json.Unmarshal(suggestions, &p.StudySuggestions); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -1,8 +1,10 @@
 package review_repository
 
 import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/review"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,14 +12,15 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/net/context"
-	"os"
 	"time"
 )
 
 type IReviewRepository interface {
-	Persist(reviewToPersist *review.Review) (*review.Review, error)
-	FindById(userId string, id *primitive.ObjectID, private bool) (reviewReturn *review.Review, err error)
-	FindRecent(originType, userId string) (reviewResult []*review.Review, err error)
+	Persist(ctx context.Context, reviewToPersist *review.Review) (*review.Review, error)
+	FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (reviewReturn *review.Review, err error)
+	FindRecent(ctx context.Context, originType, userId string) (reviewResult []*review.Review, err error)
+	// Update replaces the review session of userId. A nil result means nothing matched.
+	Update(ctx context.Context, id *primitive.ObjectID, userId string, reviewToSave *review.Review) (*review.Review, error)
 	//FindByDeckId(userId, deckId string, private bool) (reviewReturn []*review.Review, err error)
 	//Update(id *primitive.ObjectID, userId string, reviewToSave *review.Review) (*review.Review, error)
 	//Count(userId string) (count int64, err error)
@@ -28,13 +31,17 @@ type ReviewRepository struct {
 	client           *mongo.Client
 	database         string
 	reviewCollection string
+	timeouts         config.Timeouts
+	reviewTTL        time.Duration
 }
 
-func NewReviewRepository(mongoClient *mongo.Client) ReviewRepository {
+func NewReviewRepository(mongoClient *mongo.Client, cfg config.MongoDB) ReviewRepository {
 	return ReviewRepository{
 		client:           mongoClient,
-		database:         os.Getenv("MONGODB_DATABASE"),
-		reviewCollection: os.Getenv("MONGODB_REVIEW_COLLECTION"),
+		database:         cfg.Database,
+		reviewCollection: cfg.Collections.Review,
+		timeouts:         cfg.Timeouts,
+		reviewTTL:        cfg.ReviewTTL,
 	}
 }
 
@@ -42,13 +49,28 @@ const (
 	errorString = "error trying to decode result"
 )
 
-func (a ReviewRepository) Persist(review *review.Review) (*review.Review, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// Indexes are the indexes the queries below rely on. Review sessions are removed
+// reviewTTL after their last answer when it is configured.
+func (a ReviewRepository) Indexes() []mongodb.Index {
+	indexes := []mongodb.Index{
+		{Collection: a.reviewCollection, Name: "userId_originType_lastUpdate",
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "originType", Value: 1}, {Key: "lastUpdate", Value: -1}}},
+	}
+	if a.reviewTTL > 0 {
+		indexes = append(indexes, mongodb.Index{Collection: a.reviewCollection, Name: "lastUpdate_ttl",
+			Keys: bson.D{{Key: "lastUpdate", Value: 1}}, ExpireAfter: a.reviewTTL})
+	}
+	return indexes
+}
+
+func (a ReviewRepository) Persist(ctx context.Context, review *review.Review) (saved *review.Review, err error) {
+	defer metrics.ObserveMongo("review", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
 	result, err := a.client.Database(a.database).Collection(a.reviewCollection).InsertOne(ctx, review)
 	if err != nil {
-		log.Logger.Errorw("Persist has failed", "error", err)
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
 		return nil, errors.Wrap(err, "error trying to persist entity")
 	}
 	id := result.InsertedID.(primitive.ObjectID)
@@ -57,8 +79,9 @@ func (a ReviewRepository) Persist(review *review.Review) (*review.Review, error)
 	return review, nil
 }
 
-func (a ReviewRepository) FindById(userId string, id *primitive.ObjectID, private bool) (reviewReturn *review.Review, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a ReviewRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (reviewReturn *review.Review, err error) {
+	defer metrics.ObserveMongo("review", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 	privateResult := bson.M{}
 	if !private {
@@ -73,21 +96,22 @@ func (a ReviewRepository) FindById(userId string, id *primitive.ObjectID, privat
 	}}
 	result := col.FindOne(ctx, query)
 	if result.Err() != nil {
-		log.Logger.Warn("Find reviewReturn by id has failed", "Error", result.Err())
+		log.FromContext(ctx).Warn("Find reviewReturn by id has failed", "Error", result.Err())
 		return nil, errors.Wrap(result.Err(), "error trying to find reviewReturn by id")
 	}
 
 	err = result.Decode(&reviewReturn)
 	if err != nil {
-		log.Logger.Errorw(errorString, "error", err)
+		log.FromContext(ctx).Errorw(errorString, "error", err)
 		return nil, errors.Wrap(err, errorString)
 	}
 
 	return reviewReturn, nil
 }
 
-func (a ReviewRepository) FindRecent(originType, userId string) (reviewResult []*review.Review, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a ReviewRepository) FindRecent(ctx context.Context, originType, userId string) (reviewResult []*review.Review, err error) {
+	defer metrics.ObserveMongo("review", "FindRecent", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
 	defer cancel()
 	col := a.client.Database(a.database).Collection(a.reviewCollection)
 	findOptions := options.Find()
@@ -98,7 +122,7 @@ func (a ReviewRepository) FindRecent(originType, userId string) (reviewResult []
 
 	result, err := col.Find(ctx, query, findOptions)
 	if result.Err() != nil {
-		log.Logger.Warn("Find reviewReturn has failed", "Error", result.Err())
+		log.FromContext(ctx).Warn("Find reviewReturn has failed", "Error", result.Err())
 		return nil, errors.Wrap(result.Err(), "error trying to find reviewReturn by id")
 	}
 
@@ -107,7 +131,7 @@ func (a ReviewRepository) FindRecent(originType, userId string) (reviewResult []
 		var playlistElement *review.Review
 		err := result.Decode(&playlistElement)
 		if err != nil {
-			log.Logger.Errorw("Parser Playlist has failed", "error", err.Error())
+			log.FromContext(ctx).Errorw("Parser Playlist has failed", "error", err.Error())
 			return nil, errors.Wrap(err, "error trying to parse Playlist")
 		}
 		reviewResult = append(reviewResult, playlistElement)
@@ -225,14 +249,15 @@ func (a ReviewRepository) FindRecent(originType, userId string) (reviewResult []
 //}
 //
 
-func (a ReviewRepository) Update(id *primitive.ObjectID, userId string, reviewToSave *review.Review) (*review.Review, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a ReviewRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, reviewToSave *review.Review) (saved *review.Review, err error) {
+	defer metrics.ObserveMongo("review", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
 	defer cancel()
 
 	filterQuery := bson.M{"_id": bson.M{"$eq": id}, "userId": bson.M{"$eq": userId}}
 	updateResult, err := a.client.Database(a.database).Collection(a.reviewCollection).ReplaceOne(ctx, filterQuery, reviewToSave)
 	if err != nil {
-		log.Logger.Errorw("Update has failed", "error", err)
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
 		return nil, errors.Wrap(err, "error trying to update entity")
 	}
 
@@ -0,0 +1,126 @@
+package review_repository
+
+import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/review"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/net/context"
+	"sort"
+	"sync"
+)
+
+const recentLimit = 10
+
+// ReviewMemoryRepository keeps the review sessions in memory with the same rules as
+// ReviewRepository, so the server runs without MongoDB. Sessions are stored and
+// returned as copies, like documents going through the driver.
+type ReviewMemoryRepository struct {
+	mutex   sync.RWMutex
+	reviews []*review.Review
+}
+
+func NewReviewMemoryRepository() *ReviewMemoryRepository {
+	return &ReviewMemoryRepository{reviews: make([]*review.Review, 0)}
+}
+
+func (a *ReviewMemoryRepository) Persist(_ context.Context, reviewToPersist *review.Review) (*review.Review, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if reviewToPersist.Id == nil {
+		id := primitive.NewObjectID()
+		reviewToPersist.Id = &id
+	} else if a.find(reviewToPersist.Id) >= 0 {
+		return nil, errors.New("error trying to persist entity: duplicate key " + reviewToPersist.Id.Hex())
+	}
+	a.reviews = append(a.reviews, cloneReview(reviewToPersist))
+	return reviewToPersist, nil
+}
+
+// FindById only finds the sessions of userId unless private is set, review sessions
+// have no isPrivate field to match.
+func (a *ReviewMemoryRepository) FindById(_ context.Context, userId string, id *primitive.ObjectID, private bool) (*review.Review, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	i := a.find(id)
+	if i < 0 || (!private && a.reviews[i].UserId != userId) {
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "error trying to find reviewReturn by id")
+	}
+	return cloneReview(a.reviews[i]), nil
+}
+
+func (a *ReviewMemoryRepository) FindRecent(_ context.Context, originType, userId string) ([]*review.Review, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	reviewResult := make([]*review.Review, 0)
+	for _, r := range a.reviews {
+		if r.OriginType == originType && r.UserId == userId {
+			reviewResult = append(reviewResult, cloneReview(r))
+		}
+	}
+	sort.SliceStable(reviewResult, func(i, j int) bool {
+		return reviewResult[i].LastUpdate.After(reviewResult[j].LastUpdate)
+	})
+	if len(reviewResult) > recentLimit {
+		reviewResult = reviewResult[:recentLimit]
+	}
+	return reviewResult, nil
+}
+
+func (a *ReviewMemoryRepository) Update(_ context.Context, id *primitive.ObjectID, userId string, reviewToSave *review.Review) (*review.Review, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.find(id)
+	if i < 0 || a.reviews[i].UserId != userId {
+		return nil, nil
+	}
+
+	reviewToSave.Id = id
+	a.reviews[i] = cloneReview(reviewToSave)
+	return reviewToSave, nil
+}
+
+func (a *ReviewMemoryRepository) find(id *primitive.ObjectID) int {
+	for i, r := range a.reviews {
+		if id != nil && *r.Id == *id {
+			return i
+		}
+	}
+	return -1
+}
+
+func cloneReview(r *review.Review) *review.Review {
+	clone := *r
+	if r.Id != nil {
+		id := *r.Id
+		clone.Id = &id
+	}
+	clone.Hists = cloneCards(r.Hists)
+	clone.Mistakes = cloneCards(r.Mistakes)
+	return &clone
+}
+
+func cloneCards(cards []*card.Card) []*card.Card {
+	if cards == nil {
+		return nil
+	}
+	clones := make([]*card.Card, 0, len(cards))
+	for _, c := range cards {
+		if c == nil {
+			clones = append(clones, nil)
+			continue
+		}
+		clone := *c
+		if c.Id != nil {
+			id := *c.Id
+			clone.Id = &id
+		}
+		clones = append(clones, &clone)
+	}
+	return clones
+}
@@ -0,0 +1,162 @@
+package review_repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/sqldb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/review"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/net/context"
+	"strings"
+	"time"
+)
+
+const reviewColumns = "id, origin_type, origin_id, user_id, hists, hists_count, mistakes, mistakes_count, cards_count, last_update"
+
+// ReviewSQLRepository stores the review sessions in the reviews table. The answered
+// cards are copies taken during the session, so they are kept as JSON documents
+// rather than references to the cards.
+type ReviewSQLRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewReviewSQLRepository(db *sql.DB, timeout time.Duration) ReviewSQLRepository {
+	return ReviewSQLRepository{db: db, timeout: timeout}
+}
+
+func (a ReviewSQLRepository) Persist(ctx context.Context, reviewToPersist *review.Review) (saved *review.Review, err error) {
+	defer metrics.ObserveSQL("review", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	id := primitive.NewObjectID()
+	if reviewToPersist.Id != nil {
+		id = *reviewToPersist.Id
+	}
+	hists, mistakes, err := marshalCards(reviewToPersist)
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+
+	_, err = sqldb.Conn(ctx, a.db).ExecContext(ctx, "INSERT INTO reviews ("+reviewColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		id.Hex(), reviewToPersist.OriginType, reviewToPersist.OriginId, reviewToPersist.UserId, hists,
+		reviewToPersist.HistsCount, mistakes, reviewToPersist.MistakesCount, reviewToPersist.CardsCount, reviewToPersist.LastUpdate)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+	reviewToPersist.Id = &id
+
+	return reviewToPersist, nil
+}
+
+// FindById only finds the sessions of userId unless private is set, review sessions
+// have no isPrivate column to match.
+func (a ReviewSQLRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (reviewReturn *review.Review, err error) {
+	defer metrics.ObserveSQL("review", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	query, args := "SELECT "+reviewColumns+" FROM reviews WHERE id = $1", []interface{}{id.Hex()}
+	if !private {
+		query, args = query+" AND user_id = $2", append(args, userId)
+	}
+
+	reviewReturn, err = scanReview(sqldb.Conn(ctx, a.db).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		log.FromContext(ctx).Warn("Find reviewReturn by id has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to find reviewReturn by id")
+	}
+	return reviewReturn, nil
+}
+
+func (a ReviewSQLRepository) FindRecent(ctx context.Context, originType, userId string) (reviewResult []*review.Review, err error) {
+	defer metrics.ObserveSQL("review", "FindRecent", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, "SELECT "+reviewColumns+` FROM reviews
+		WHERE origin_type = $1 AND user_id = $2 ORDER BY last_update DESC LIMIT $3`, originType, userId, recentLimit)
+	if err != nil {
+		log.FromContext(ctx).Warn("Find reviewReturn has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to find reviewReturn by id")
+	}
+	defer rows.Close()
+
+	reviewResult = make([]*review.Review, 0)
+	for rows.Next() {
+		reviewElement, err := scanReview(rows)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Review has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Review")
+		}
+		reviewResult = append(reviewResult, reviewElement)
+	}
+	return reviewResult, rows.Err()
+}
+
+func (a ReviewSQLRepository) Update(ctx context.Context, id *primitive.ObjectID, userId string, reviewToSave *review.Review) (saved *review.Review, err error) {
+	defer metrics.ObserveSQL("review", "Update", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	hists, mistakes, err := marshalCards(reviewToSave)
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to update entity")
+	}
+
+	updateResult, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, `UPDATE reviews SET origin_type = $1, origin_id = $2, user_id = $3,
+		hists = $4, hists_count = $5, mistakes = $6, mistakes_count = $7, cards_count = $8, last_update = $9
+		WHERE id = $10 AND user_id = $11`,
+		reviewToSave.OriginType, reviewToSave.OriginId, reviewToSave.UserId, hists, reviewToSave.HistsCount,
+		mistakes, reviewToSave.MistakesCount, reviewToSave.CardsCount, reviewToSave.LastUpdate, id.Hex(), userId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to update entity")
+	}
+
+	if matched, err := updateResult.RowsAffected(); err != nil || matched == 0 {
+		return nil, err
+	}
+	reviewToSave.Id = id
+	return reviewToSave, nil
+}
+
+func marshalCards(r *review.Review) (hists, mistakes string, err error) {
+	histsJSON, err := json.Marshal(r.Hists)
+	if err != nil {
+		return "", "", err
+	}
+	mistakesJSON, err := json.Marshal(r.Mistakes)
+	if err != nil {
+		return "", "", err
+	}
+	return string(histsJSON), string(mistakesJSON), nil
+}
+
+func scanReview(row sqldb.Scanner) (*review.Review, error) {
+	var r review.Review
+	var id string
+	var hists, mistakes []byte
+	err := row.Scan(&id, &r.OriginType, &r.OriginId, &r.UserId, &hists, &r.HistsCount, &mistakes, &r.MistakesCount, &r.CardsCount, &r.LastUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	r.Id = &objectID
+	if err = json.Unmarshal(hists, &r.Hists); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(mistakes, &r.Mistakes); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,9 @@
+package transaction
+
+import "context"
+
+// ITransactionManager runs fn atomically. Repository methods that receive the
+// context handed to fn take part in the same transaction.
+type ITransactionManager interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
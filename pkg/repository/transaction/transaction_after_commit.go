@@ -0,0 +1,55 @@
+package transaction
+
+import (
+	"context"
+	"sync"
+)
+
+type afterCommitKey struct{}
+
+type afterCommit struct {
+	mutex sync.Mutex
+	hooks []func()
+}
+
+// AfterCommitManager runs the functions given to AfterCommit within a transaction once it
+// has ended, so what they do is not undone by a read of the data it has not committed yet.
+// They also run when the transaction fails: without transactions, the writes made before
+// the failure stay.
+type AfterCommitManager struct {
+	ITransactionManager
+}
+
+func NewAfterCommitManager(manager ITransactionManager) AfterCommitManager {
+	return AfterCommitManager{ITransactionManager: manager}
+}
+
+func (t AfterCommitManager) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, nested := ctx.Value(afterCommitKey{}).(*afterCommit); nested {
+		return t.ITransactionManager.WithTransaction(ctx, fn)
+	}
+
+	after := &afterCommit{}
+	err := t.ITransactionManager.WithTransaction(context.WithValue(ctx, afterCommitKey{}, after), fn)
+
+	after.mutex.Lock()
+	hooks := after.hooks
+	after.hooks = nil
+	after.mutex.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+	return err
+}
+
+// AfterCommit runs fn when the transaction of ctx ends, or at once outside of one.
+func AfterCommit(ctx context.Context, fn func()) {
+	after, ok := ctx.Value(afterCommitKey{}).(*afterCommit)
+	if !ok {
+		fn()
+		return
+	}
+	after.mutex.Lock()
+	defer after.mutex.Unlock()
+	after.hooks = append(after.hooks, fn)
+}
@@ -0,0 +1,24 @@
+package transaction
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryTransactionManager runs one transaction at a time for the in-memory repositories.
+// They have no rollback, so the writes fn made before failing stay, as on a standalone
+// MongoDB.
+type MemoryTransactionManager struct {
+	mutex sync.Mutex
+}
+
+func NewMemoryTransactionManager() *MemoryTransactionManager {
+	return &MemoryTransactionManager{}
+}
+
+func (t *MemoryTransactionManager) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return fn(ctx)
+}
@@ -0,0 +1,251 @@
+package webhook_repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/mongodb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/webhook"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// deliveriesLimit is how many of the latest deliveries of a webhook are listed.
+const deliveriesLimit = 50
+
+type IWebhookRepository interface {
+	Persist(ctx context.Context, webhookToPersist *webhook.Webhook) (*webhook.Webhook, error)
+	FindById(ctx context.Context, userId string, id *primitive.ObjectID) (*webhook.Webhook, error)
+	FindByUserId(ctx context.Context, userId string) ([]*webhook.Webhook, error)
+	// Delete removes the webhook and its deliveries. A nil result means nothing matched.
+	Delete(ctx context.Context, userId string, id *primitive.ObjectID) (*webhook.Webhook, error)
+	PersistDelivery(ctx context.Context, deliveryToPersist *webhook.Delivery) (*webhook.Delivery, error)
+	FindDeliveryById(ctx context.Context, userId string, id *primitive.ObjectID) (*webhook.Delivery, error)
+	// FindDeliveries returns the latest deliveries of the webhook, newest first.
+	FindDeliveries(ctx context.Context, userId, webhookId string) ([]*webhook.Delivery, error)
+	// ClaimDeliveries returns up to limit pending deliveries due at now. Each has its attempt
+	// counted and is not due again before leaseUntil, so no other instance sends it meanwhile
+	// and it is sent again if this one stops before saving the outcome.
+	ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*webhook.Delivery, error)
+	// UpdateDelivery saves the outcome of the attempt at deliveryToSave.
+	UpdateDelivery(ctx context.Context, deliveryToSave *webhook.Delivery) error
+}
+
+type WebhookRepository struct {
+	client             *mongo.Client
+	database           string
+	webhookCollection  string
+	deliveryCollection string
+	timeouts           config.Timeouts
+}
+
+func NewWebhookRepository(mongoClient *mongo.Client, cfg config.MongoDB) WebhookRepository {
+	return WebhookRepository{
+		client:             mongoClient,
+		database:           cfg.Database,
+		webhookCollection:  cfg.Collections.Webhook,
+		deliveryCollection: cfg.Collections.WebhookDelivery,
+		timeouts:           cfg.Timeouts,
+	}
+}
+
+// Indexes are the indexes the queries below rely on.
+func (a WebhookRepository) Indexes() []mongodb.Index {
+	return []mongodb.Index{
+		{Collection: a.webhookCollection, Name: "userId", Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Collection: a.deliveryCollection, Name: "status_nextAttempt",
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttempt", Value: 1}}},
+		{Collection: a.deliveryCollection, Name: "webhookId_createdAt",
+			Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+}
+
+func (a WebhookRepository) Persist(ctx context.Context, webhookToPersist *webhook.Webhook) (saved *webhook.Webhook, err error) {
+	defer metrics.ObserveMongo("webhook", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	result, err := a.webhooks().InsertOne(ctx, webhookToPersist)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+	id := result.InsertedID.(primitive.ObjectID)
+	webhookToPersist.Id = &id
+
+	return webhookToPersist, nil
+}
+
+func (a WebhookRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID) (webhookReturn *webhook.Webhook, err error) {
+	defer metrics.ObserveMongo("webhook", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+
+	err = a.webhooks().FindOne(ctx, bson.M{"_id": id, "userId": userId}).Decode(&webhookReturn)
+	if err != nil {
+		log.FromContext(ctx).Warn("Find webhook by id has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to find webhook by id")
+	}
+	return webhookReturn, nil
+}
+
+func (a WebhookRepository) FindByUserId(ctx context.Context, userId string) (webhookResult []*webhook.Webhook, err error) {
+	defer metrics.ObserveMongo("webhook", "FindByUserId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+
+	cursor, err := a.webhooks().Find(ctx, bson.M{"userId": userId}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find webhooks")
+	}
+
+	webhookResult = make([]*webhook.Webhook, 0)
+	if err = cursor.All(ctx, &webhookResult); err != nil {
+		log.FromContext(ctx).Errorw("Parser Webhook has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to parse Webhook")
+	}
+	return webhookResult, nil
+}
+
+func (a WebhookRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID) (result *webhook.Webhook, err error) {
+	defer metrics.ObserveMongo("webhook", "Delete", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	err = a.webhooks().FindOneAndDelete(ctx, bson.M{"_id": id, "userId": userId}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to delete entity")
+	}
+
+	// A delivery left behind fails when it is sent, its webhook is gone.
+	_, err = a.deliveries().DeleteMany(ctx, bson.M{"webhookId": id.Hex()})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Delete deliveries has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to delete deliveries")
+	}
+	return result, nil
+}
+
+func (a WebhookRepository) PersistDelivery(ctx context.Context, deliveryToPersist *webhook.Delivery) (saved *webhook.Delivery, err error) {
+	defer metrics.ObserveMongo("webhook", "PersistDelivery", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	result, err := a.deliveries().InsertOne(ctx, deliveryToPersist)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+	id := result.InsertedID.(primitive.ObjectID)
+	deliveryToPersist.Id = &id
+
+	return deliveryToPersist, nil
+}
+
+func (a WebhookRepository) FindDeliveryById(ctx context.Context, userId string, id *primitive.ObjectID) (deliveryReturn *webhook.Delivery, err error) {
+	defer metrics.ObserveMongo("webhook", "FindDeliveryById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+
+	err = a.deliveries().FindOne(ctx, bson.M{"_id": id, "userId": userId}).Decode(&deliveryReturn)
+	if err != nil {
+		log.FromContext(ctx).Warn("Find delivery by id has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to find delivery by id")
+	}
+	return deliveryReturn, nil
+}
+
+func (a WebhookRepository) FindDeliveries(ctx context.Context, userId, webhookId string) (deliveryResult []*webhook.Delivery, err error) {
+	defer metrics.ObserveMongo("webhook", "FindDeliveries", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Read)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: mongodb.DESC}}).SetLimit(deliveriesLimit)
+	cursor, err := a.deliveries().Find(ctx, bson.M{"webhookId": webhookId, "userId": userId}, findOptions)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find deliveries")
+	}
+
+	deliveryResult = make([]*webhook.Delivery, 0)
+	if err = cursor.All(ctx, &deliveryResult); err != nil {
+		log.FromContext(ctx).Errorw("Parser Delivery has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to parse Delivery")
+	}
+	return deliveryResult, nil
+}
+
+// ClaimDeliveries takes each due delivery with an update conditioned on its attempts, an
+// instance that lost the race for one does not get it.
+func (a WebhookRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) (claimed []*webhook.Delivery, err error) {
+	defer metrics.ObserveMongo("webhook", "ClaimDeliveries", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "nextAttempt", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := a.deliveries().Find(ctx, bson.M{"status": webhook.StatusPending, "nextAttempt": bson.M{"$lte": now}}, findOptions)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find due deliveries")
+	}
+	due := make([]*webhook.Delivery, 0)
+	if err = cursor.All(ctx, &due); err != nil {
+		log.FromContext(ctx).Errorw("Parser Delivery has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to parse Delivery")
+	}
+
+	claimed = make([]*webhook.Delivery, 0, len(due))
+	for _, delivery := range due {
+		filter := bson.M{"_id": delivery.Id, "status": webhook.StatusPending, "attempts": delivery.Attempts}
+		update := bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"nextAttempt": leaseUntil}}
+		result, err := a.deliveries().UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Claim delivery has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to claim delivery")
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		delivery.Attempts++
+		delivery.NextAttempt = leaseUntil
+		claimed = append(claimed, delivery)
+	}
+	return claimed, nil
+}
+
+func (a WebhookRepository) UpdateDelivery(ctx context.Context, deliveryToSave *webhook.Delivery) (err error) {
+	defer metrics.ObserveMongo("webhook", "UpdateDelivery", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeouts.Write)
+	defer cancel()
+
+	_, err = a.deliveries().UpdateOne(ctx, bson.M{"_id": deliveryToSave.Id}, bson.M{"$set": bson.M{
+		"status":         deliveryToSave.Status,
+		"responseStatus": deliveryToSave.ResponseStatus,
+		"error":          deliveryToSave.Error,
+		"nextAttempt":    deliveryToSave.NextAttempt,
+		"lastAttempt":    deliveryToSave.LastAttempt,
+	}})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return errors.Wrap(err, "error trying to update entity")
+	}
+	return nil
+}
+
+func (a WebhookRepository) webhooks() *mongo.Collection {
+	return a.client.Database(a.database).Collection(a.webhookCollection)
+}
+
+func (a WebhookRepository) deliveries() *mongo.Collection {
+	return a.client.Database(a.database).Collection(a.deliveryCollection)
+}
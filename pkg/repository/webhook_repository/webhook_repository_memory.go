@@ -0,0 +1,209 @@
+package webhook_repository
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/webhook"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WebhookMemoryRepository keeps the webhooks and their deliveries in memory with the
+// same rules as WebhookRepository. Both are stored and returned as copies.
+type WebhookMemoryRepository struct {
+	mutex      sync.RWMutex
+	webhooks   []*webhook.Webhook
+	deliveries []*webhook.Delivery
+}
+
+func NewWebhookMemoryRepository() *WebhookMemoryRepository {
+	return &WebhookMemoryRepository{
+		webhooks:   make([]*webhook.Webhook, 0),
+		deliveries: make([]*webhook.Delivery, 0),
+	}
+}
+
+func (a *WebhookMemoryRepository) Persist(_ context.Context, webhookToPersist *webhook.Webhook) (*webhook.Webhook, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if webhookToPersist.Id == nil {
+		id := primitive.NewObjectID()
+		webhookToPersist.Id = &id
+	} else if a.findWebhook(webhookToPersist.Id) >= 0 {
+		return nil, errors.New("error trying to persist entity: duplicate key " + webhookToPersist.Id.Hex())
+	}
+	a.webhooks = append(a.webhooks, cloneWebhook(webhookToPersist))
+	return webhookToPersist, nil
+}
+
+func (a *WebhookMemoryRepository) FindById(_ context.Context, userId string, id *primitive.ObjectID) (*webhook.Webhook, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	i := a.findWebhook(id)
+	if i < 0 || a.webhooks[i].UserId != userId {
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "error trying to find webhook by id")
+	}
+	return cloneWebhook(a.webhooks[i]), nil
+}
+
+func (a *WebhookMemoryRepository) FindByUserId(_ context.Context, userId string) ([]*webhook.Webhook, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	webhookResult := make([]*webhook.Webhook, 0)
+	for _, w := range a.webhooks {
+		if w.UserId == userId {
+			webhookResult = append(webhookResult, cloneWebhook(w))
+		}
+	}
+	return webhookResult, nil
+}
+
+func (a *WebhookMemoryRepository) Delete(_ context.Context, userId string, id *primitive.ObjectID) (*webhook.Webhook, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.findWebhook(id)
+	if i < 0 || a.webhooks[i].UserId != userId {
+		return nil, nil
+	}
+	deleted := a.webhooks[i]
+	a.webhooks = append(a.webhooks[:i], a.webhooks[i+1:]...)
+
+	kept := a.deliveries[:0]
+	for _, d := range a.deliveries {
+		if d.WebhookId != id.Hex() {
+			kept = append(kept, d)
+		}
+	}
+	a.deliveries = kept
+	return deleted, nil
+}
+
+func (a *WebhookMemoryRepository) PersistDelivery(_ context.Context, deliveryToPersist *webhook.Delivery) (*webhook.Delivery, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if deliveryToPersist.Id == nil {
+		id := primitive.NewObjectID()
+		deliveryToPersist.Id = &id
+	} else if a.findDelivery(deliveryToPersist.Id) >= 0 {
+		return nil, errors.New("error trying to persist entity: duplicate key " + deliveryToPersist.Id.Hex())
+	}
+	a.deliveries = append(a.deliveries, cloneDelivery(deliveryToPersist))
+	return deliveryToPersist, nil
+}
+
+func (a *WebhookMemoryRepository) FindDeliveryById(_ context.Context, userId string, id *primitive.ObjectID) (*webhook.Delivery, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	i := a.findDelivery(id)
+	if i < 0 || a.deliveries[i].UserId != userId {
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "error trying to find delivery by id")
+	}
+	return cloneDelivery(a.deliveries[i]), nil
+}
+
+func (a *WebhookMemoryRepository) FindDeliveries(_ context.Context, userId, webhookId string) ([]*webhook.Delivery, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	deliveryResult := make([]*webhook.Delivery, 0)
+	for _, d := range a.deliveries {
+		if d.WebhookId == webhookId && d.UserId == userId {
+			deliveryResult = append(deliveryResult, cloneDelivery(d))
+		}
+	}
+	sort.SliceStable(deliveryResult, func(i, j int) bool {
+		return deliveryResult[i].CreatedAt.After(deliveryResult[j].CreatedAt)
+	})
+	if len(deliveryResult) > deliveriesLimit {
+		deliveryResult = deliveryResult[:deliveriesLimit]
+	}
+	return deliveryResult, nil
+}
+
+func (a *WebhookMemoryRepository) ClaimDeliveries(_ context.Context, now, leaseUntil time.Time, limit int) ([]*webhook.Delivery, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	due := make([]*webhook.Delivery, 0)
+	for _, d := range a.deliveries {
+		if d.Status == webhook.StatusPending && !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttempt.Before(due[j].NextAttempt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*webhook.Delivery, 0, len(due))
+	for _, d := range due {
+		d.Attempts++
+		d.NextAttempt = leaseUntil
+		claimed = append(claimed, cloneDelivery(d))
+	}
+	return claimed, nil
+}
+
+func (a *WebhookMemoryRepository) UpdateDelivery(_ context.Context, deliveryToSave *webhook.Delivery) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i := a.findDelivery(deliveryToSave.Id)
+	if i < 0 {
+		return nil
+	}
+	stored := a.deliveries[i]
+	stored.Status = deliveryToSave.Status
+	stored.ResponseStatus = deliveryToSave.ResponseStatus
+	stored.Error = deliveryToSave.Error
+	stored.NextAttempt = deliveryToSave.NextAttempt
+	stored.LastAttempt = deliveryToSave.LastAttempt
+	return nil
+}
+
+func (a *WebhookMemoryRepository) findWebhook(id *primitive.ObjectID) int {
+	for i, w := range a.webhooks {
+		if id != nil && *w.Id == *id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *WebhookMemoryRepository) findDelivery(id *primitive.ObjectID) int {
+	for i, d := range a.deliveries {
+		if id != nil && *d.Id == *id {
+			return i
+		}
+	}
+	return -1
+}
+
+func cloneWebhook(w *webhook.Webhook) *webhook.Webhook {
+	clone := *w
+	if w.Id != nil {
+		id := *w.Id
+		clone.Id = &id
+	}
+	clone.Events = append([]string(nil), w.Events...)
+	return &clone
+}
+
+func cloneDelivery(d *webhook.Delivery) *webhook.Delivery {
+	clone := *d
+	if d.Id != nil {
+		id := *d.Id
+		clone.Id = &id
+	}
+	clone.Payload = append([]byte(nil), d.Payload...)
+	return &clone
+}
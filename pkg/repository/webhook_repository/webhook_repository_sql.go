@@ -0,0 +1,283 @@
+package webhook_repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/sqldb"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/webhook"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+const (
+	webhookColumns  = "id, user_id, url, events, secret, created_at"
+	deliveryColumns = "id, webhook_id, user_id, event_id, event, payload, status, attempts, response_status, error, next_attempt, last_attempt, created_at"
+)
+
+// WebhookSQLRepository stores the webhooks in the webhooks table and their deliveries in
+// webhook_deliveries, which are removed with the webhook. The times are written in UTC:
+// SQLite compares them as text.
+type WebhookSQLRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewWebhookSQLRepository(db *sql.DB, timeout time.Duration) WebhookSQLRepository {
+	return WebhookSQLRepository{db: db, timeout: timeout}
+}
+
+func (a WebhookSQLRepository) Persist(ctx context.Context, webhookToPersist *webhook.Webhook) (saved *webhook.Webhook, err error) {
+	defer metrics.ObserveSQL("webhook", "Persist", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	id := primitive.NewObjectID()
+	if webhookToPersist.Id != nil {
+		id = *webhookToPersist.Id
+	}
+	events, err := json.Marshal(webhookToPersist.Events)
+	if err != nil {
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+
+	_, err = sqldb.Conn(ctx, a.db).ExecContext(ctx, "INSERT INTO webhooks ("+webhookColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		id.Hex(), webhookToPersist.UserId, webhookToPersist.URL, string(events), webhookToPersist.Secret, webhookToPersist.CreatedAt.UTC())
+	if err != nil {
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+	webhookToPersist.Id = &id
+
+	return webhookToPersist, nil
+}
+
+func (a WebhookSQLRepository) FindById(ctx context.Context, userId string, id *primitive.ObjectID) (webhookReturn *webhook.Webhook, err error) {
+	defer metrics.ObserveSQL("webhook", "FindById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	webhookReturn, err = scanWebhook(sqldb.Conn(ctx, a.db).QueryRowContext(ctx,
+		"SELECT "+webhookColumns+" FROM webhooks WHERE id = $1 AND user_id = $2", id.Hex(), userId))
+	if err != nil {
+		log.FromContext(ctx).Warn("Find webhook by id has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to find webhook by id")
+	}
+	return webhookReturn, nil
+}
+
+func (a WebhookSQLRepository) FindByUserId(ctx context.Context, userId string) (webhookResult []*webhook.Webhook, err error) {
+	defer metrics.ObserveSQL("webhook", "FindByUserId", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE user_id = $1 ORDER BY id", userId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find webhooks")
+	}
+	defer rows.Close()
+
+	webhookResult = make([]*webhook.Webhook, 0)
+	for rows.Next() {
+		webhookElement, err := scanWebhook(rows)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Webhook has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Webhook")
+		}
+		webhookResult = append(webhookResult, webhookElement)
+	}
+	return webhookResult, rows.Err()
+}
+
+func (a WebhookSQLRepository) Delete(ctx context.Context, userId string, id *primitive.ObjectID) (result *webhook.Webhook, err error) {
+	defer metrics.ObserveSQL("webhook", "Delete", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	conn := sqldb.Conn(ctx, a.db)
+	result, err = scanWebhook(conn.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1 AND user_id = $2", id.Hex(), userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to delete entity")
+	}
+
+	deleteResult, err := conn.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1 AND user_id = $2", id.Hex(), userId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Delete has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to delete entity")
+	}
+	if deleted, err := deleteResult.RowsAffected(); err != nil || deleted == 0 {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a WebhookSQLRepository) PersistDelivery(ctx context.Context, deliveryToPersist *webhook.Delivery) (saved *webhook.Delivery, err error) {
+	defer metrics.ObserveSQL("webhook", "PersistDelivery", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	id := primitive.NewObjectID()
+	if deliveryToPersist.Id != nil {
+		id = *deliveryToPersist.Id
+	}
+
+	_, err = sqldb.Conn(ctx, a.db).ExecContext(ctx, "INSERT INTO webhook_deliveries ("+deliveryColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		id.Hex(), deliveryToPersist.WebhookId, deliveryToPersist.UserId, deliveryToPersist.EventId, deliveryToPersist.Event,
+		string(deliveryToPersist.Payload), deliveryToPersist.Status, deliveryToPersist.Attempts, deliveryToPersist.ResponseStatus,
+		deliveryToPersist.Error, deliveryToPersist.NextAttempt.UTC(), nullTime(deliveryToPersist.LastAttempt),
+		deliveryToPersist.CreatedAt.UTC())
+	if err != nil {
+		log.FromContext(ctx).Errorw("Persist has failed", "error", err)
+		return nil, errors.Wrap(err, "error trying to persist entity")
+	}
+	deliveryToPersist.Id = &id
+
+	return deliveryToPersist, nil
+}
+
+func (a WebhookSQLRepository) FindDeliveryById(ctx context.Context, userId string, id *primitive.ObjectID) (deliveryReturn *webhook.Delivery, err error) {
+	defer metrics.ObserveSQL("webhook", "FindDeliveryById", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	deliveryReturn, err = scanDelivery(sqldb.Conn(ctx, a.db).QueryRowContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1 AND user_id = $2", id.Hex(), userId))
+	if err != nil {
+		log.FromContext(ctx).Warn("Find delivery by id has failed", "Error", err)
+		return nil, errors.Wrap(err, "error trying to find delivery by id")
+	}
+	return deliveryReturn, nil
+}
+
+func (a WebhookSQLRepository) FindDeliveries(ctx context.Context, userId, webhookId string) (deliveryResult []*webhook.Delivery, err error) {
+	defer metrics.ObserveSQL("webhook", "FindDeliveries", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	return a.listDeliveries(ctx, "SELECT "+deliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = $1 AND user_id = $2 ORDER BY created_at DESC LIMIT $3`, webhookId, userId, deliveriesLimit)
+}
+
+// ClaimDeliveries takes each due delivery with an update conditioned on its attempts, an
+// instance that lost the race for one does not get it.
+func (a WebhookSQLRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) (claimed []*webhook.Delivery, err error) {
+	defer metrics.ObserveSQL("webhook", "ClaimDeliveries", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	due, err := a.listDeliveries(ctx, "SELECT "+deliveryColumns+` FROM webhook_deliveries
+		WHERE status = $1 AND next_attempt <= $2 ORDER BY next_attempt LIMIT $3`, webhook.StatusPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed = make([]*webhook.Delivery, 0, len(due))
+	for _, delivery := range due {
+		updateResult, err := sqldb.Conn(ctx, a.db).ExecContext(ctx, `UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt = $1
+			WHERE id = $2 AND status = $3 AND attempts = $4`, leaseUntil.UTC(), delivery.Id.Hex(), webhook.StatusPending, delivery.Attempts)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Claim delivery has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to claim delivery")
+		}
+		if updated, err := updateResult.RowsAffected(); err != nil || updated == 0 {
+			continue
+		}
+		delivery.Attempts++
+		delivery.NextAttempt = leaseUntil
+		claimed = append(claimed, delivery)
+	}
+	return claimed, nil
+}
+
+func (a WebhookSQLRepository) UpdateDelivery(ctx context.Context, deliveryToSave *webhook.Delivery) (err error) {
+	defer metrics.ObserveSQL("webhook", "UpdateDelivery", time.Now(), &err)
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	_, err = sqldb.Conn(ctx, a.db).ExecContext(ctx, `UPDATE webhook_deliveries SET status = $1, response_status = $2, error = $3,
+		next_attempt = $4, last_attempt = $5 WHERE id = $6`,
+		deliveryToSave.Status, deliveryToSave.ResponseStatus, deliveryToSave.Error, deliveryToSave.NextAttempt.UTC(),
+		nullTime(deliveryToSave.LastAttempt), deliveryToSave.Id.Hex())
+	if err != nil {
+		log.FromContext(ctx).Errorw("Update has failed", "error", err)
+		return errors.Wrap(err, "error trying to update entity")
+	}
+	return nil
+}
+
+func (a WebhookSQLRepository) listDeliveries(ctx context.Context, query string, args ...interface{}) ([]*webhook.Delivery, error) {
+	rows, err := sqldb.Conn(ctx, a.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find has failed", "error", err.Error())
+		return nil, errors.Wrap(err, "error trying to find deliveries")
+	}
+	defer rows.Close()
+
+	deliveryResult := make([]*webhook.Delivery, 0)
+	for rows.Next() {
+		deliveryElement, err := scanDelivery(rows)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Parser Delivery has failed", "error", err.Error())
+			return nil, errors.Wrap(err, "error trying to parse Delivery")
+		}
+		deliveryResult = append(deliveryResult, deliveryElement)
+	}
+	return deliveryResult, rows.Err()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func scanWebhook(row sqldb.Scanner) (*webhook.Webhook, error) {
+	var w webhook.Webhook
+	var id string
+	var events []byte
+	err := row.Scan(&id, &w.UserId, &w.URL, &events, &w.Secret, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	w.Id = &objectID
+	if err = json.Unmarshal(events, &w.Events); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func scanDelivery(row sqldb.Scanner) (*webhook.Delivery, error) {
+	var d webhook.Delivery
+	var id string
+	var payload []byte
+	var lastAttempt sql.NullTime
+	err := row.Scan(&id, &d.WebhookId, &d.UserId, &d.EventId, &d.Event, &payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.Error, &d.NextAttempt, &lastAttempt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	d.Id = &objectID
+	d.WebhookId = strings.TrimSpace(d.WebhookId)
+	d.EventId = strings.TrimSpace(d.EventId)
+	d.Payload = payload
+	d.LastAttempt = lastAttempt.Time
+	return &d, nil
+}
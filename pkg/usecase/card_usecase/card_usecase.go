@@ -1,85 +1,291 @@
 package card_usecase
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/patch"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/card_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/deck_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
 	"github.com/go-playground/validator"
-	"github.com/imdario/mergo"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type ICardUseCase interface {
-	Create(userId, deckId string, card *card.Card) (result *card.Card, err error)
-	FindByDeckId(userId, id string) (result []*card.Card, err error)
-	FindById(userId, id string) (result *card.Card, err error)
-	Update(id, userId string, isPartial bool, card *card.Card) (*card.Card, error)
-	Delete(id, userId string) (result *card.Card, err error)
+	Create(ctx context.Context, userId, deckId string, card *card.Card) (result *card.Card, err error)
+	Import(ctx context.Context, userId, deckId string, cards []*card.Card) (result []*card.Card, err error)
+	Move(ctx context.Context, id, userId, deckId string) (result *card.Card, err error)
+	FindByDeckId(ctx context.Context, userId, id string) (result []*card.Card, err error)
+	// FindByDeckIds returns the cards of every deck among deckIds that userId can see.
+	FindByDeckIds(ctx context.Context, userId string, deckIds []string) (result []*card.Card, err error)
+	FindById(ctx context.Context, userId, id string) (result *card.Card, err error)
+	Update(ctx context.Context, id, userId string, card *card.Card, version *int64) (*card.Card, error)
+	// UpdateAndMove updates the card as Update does and moves it to the deck of card in the
+	// same write, a card the update refuses stays in its deck.
+	UpdateAndMove(ctx context.Context, id, userId string, card *card.Card, version *int64) (*card.Card, error)
+	Patch(ctx context.Context, id, userId, contentType string, document []byte, version *int64) (*card.Card, error)
+	Delete(ctx context.Context, id, userId string, version *int64) (result *card.Card, err error)
 }
+
+const maxImportSize = 500
+
+// readOnlyFields are owned by the server and cannot be changed by a patch.
+var readOnlyFields = []string{"_id", "userId", "deckId", "version", "lastUpdate"}
+
 type CardUseCase struct {
 	validator   *validator.Validate
 	repo        card_repository.ICardRepository
+	deckRepo    deck_repository.IDeckRepository
+	tx          transaction.ITransactionManager
 	deckUseCase deck_usecase.DeckUseCase
+	events      event_usecase.IEventPublisher
 }
 
-func NewCardUseCase(cardRepository card_repository.ICardRepository, deckUseCase deck_usecase.DeckUseCase, validator *validator.Validate) CardUseCase {
+func NewCardUseCase(cardRepository card_repository.ICardRepository, deckRepository deck_repository.IDeckRepository,
+	tx transaction.ITransactionManager, deckUseCase deck_usecase.DeckUseCase, events event_usecase.IEventPublisher,
+	validator *validator.Validate) CardUseCase {
 	return CardUseCase{
 		validator:   validator,
 		repo:        cardRepository,
+		deckRepo:    deckRepository,
+		tx:          tx,
 		deckUseCase: deckUseCase,
+		events:      events,
 	}
 }
 
-func (uc CardUseCase) Create(userId, deckId string, card *card.Card) (result *card.Card, err error) {
+func (uc CardUseCase) Create(ctx context.Context, userId, deckId string, card *card.Card) (result *card.Card, err error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.Create")
+	defer span.End()
+	deckObjectID, err := uc.parseToObjectID(ctx, deckId)
+	if err != nil {
+		return nil, err
+	}
+
 	card.UserId = userId
 	card.DeckId = deckId
 	card.LastUpdate = time.Now()
+	card.Version = 1
 
 	err = uc.validator.Struct(card)
 	if err != nil {
 		cardBytes, _ := json.Marshal(card)
-		log.Logger.Errorf("Error to validate input:\n %v;\n error: %v", string(cardBytes), err.Error())
+		log.FromContext(ctx).Errorf("Error to validate input:\n %v;\n error: %v", string(cardBytes), err.Error())
 		return nil, &errors.InvalidPayload{Err: err}
 	}
 
-	result, err = uc.repo.Persist(card)
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.ownDeck(ctx, userId, &deckObjectID); err != nil {
+			return err
+		}
+		result, err = uc.repo.Persist(ctx, card)
+		if err != nil {
+			return err
+		}
+		err = uc.incrementCardsCount(ctx, userId, &deckObjectID, 1)
+		if err != nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.CardCreated, result.Id.Hex(), result)
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Card creation error", "Error", err.Error())
+		return nil, transactionError(err)
+	}
+
+	metrics.CardsCreated.Inc()
+	return result, nil
+}
+
+func (uc CardUseCase) Import(ctx context.Context, userId, deckId string, cards []*card.Card) (result []*card.Card, err error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.Import")
+	defer span.End()
+	deckObjectID, err := uc.parseToObjectID(ctx, deckId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cards) == 0 || len(cards) > maxImportSize {
+		log.FromContext(ctx).Errorw("invalid import size", "size", len(cards))
+		return nil, errors.WrapWithMessage(errors.ErrInvalidPayload, fmt.Sprintf("import must have between 1 and %d cards", maxImportSize))
+	}
+
+	now := time.Now()
+	for i, cardElement := range cards {
+		cardElement.Id = nil
+		cardElement.UserId = userId
+		cardElement.DeckId = deckId
+		cardElement.LastUpdate = now
+		cardElement.Version = 1
+
+		err = uc.validator.Struct(cardElement)
+		if err != nil {
+			log.FromContext(ctx).Errorf("Error to validate input at position %d: %v", i, err.Error())
+			return nil, &errors.InvalidPayload{Err: err}
+		}
+	}
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.ownDeck(ctx, userId, &deckObjectID); err != nil {
+			return err
+		}
+		result, err = uc.repo.PersistMany(ctx, cards)
+		if err != nil {
+			return err
+		}
+		err = uc.incrementCardsCount(ctx, userId, &deckObjectID, int64(len(result)))
+		if err != nil {
+			return err
+		}
+		for _, cardElement := range result {
+			err = uc.events.Publish(ctx, userId, event.CardCreated, cardElement.Id.Hex(), cardElement)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Card import error", "Error", err.Error())
+		return nil, transactionError(err)
+	}
+
+	metrics.CardsCreated.Add(float64(len(result)))
+	return result, nil
+}
+
+func (uc CardUseCase) Move(ctx context.Context, id, userId, deckId string) (result *card.Card, err error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.Move")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	deckObjectID, err := uc.parseToObjectID(ctx, deckId)
 	if err != nil {
-		log.Logger.Errorw("Card creation error", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		return nil, err
 	}
 
-	//_, err = uc.deckUseCase.AddDeckToPlaylist(deckId, userId, result)
-	//if err != nil {
-	//	return nil, err
-	//}
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.ownDeck(ctx, userId, &deckObjectID); err != nil {
+			return err
+		}
+		result, err = uc.repo.UpdateDeckId(ctx, userId, &objectID, deckId)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			return errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+		}
+		previousDeckId := result.DeckId
+		result.DeckId = deckId
+		result.Version++
 
+		if previousDeckId != deckId {
+			err = uc.incrementCardsCount(ctx, userId, &deckObjectID, 1)
+			if err != nil {
+				return err
+			}
+			err = uc.decrementCardsCount(ctx, userId, previousDeckId)
+			if err != nil {
+				return err
+			}
+		}
+		return uc.events.Publish(ctx, userId, event.CardUpdated, id, result)
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Move card error", "Error", err.Error())
+		return nil, transactionError(err)
+	}
 	return result, nil
 }
 
-func (uc CardUseCase) FindByDeckId(userId, id string) (result []*card.Card, err error) {
-	result, err = uc.repo.FindByDeckId(userId, id, false)
+// ownDeck checks the deck cards are written to belongs to userId before they are written,
+// without a transaction nothing would undo the writes of a request that fails after.
+func (uc CardUseCase) ownDeck(ctx context.Context, userId string, deckId *primitive.ObjectID) error {
+	found, err := uc.deckRepo.FindById(ctx, userId, deckId, false)
+	if err != nil || found.UserId != userId {
+		return errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("deck %s not found", deckId.Hex()))
+	}
+	return nil
+}
+
+func (uc CardUseCase) incrementCardsCount(ctx context.Context, userId string, deckId *primitive.ObjectID, delta int64) error {
+	updated, err := uc.deckRepo.IncrementCardsCount(ctx, userId, deckId, delta)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("deck %s not found", deckId.Hex()))
+	}
+	return nil
+}
+
+// decrementCardsCount tolerates a missing deck: cards can outlive the deck they were created in.
+func (uc CardUseCase) decrementCardsCount(ctx context.Context, userId, deckId string) error {
+	deckObjectID, err := primitive.ObjectIDFromHex(deckId)
+	if err != nil {
+		log.FromContext(ctx).Warnw("card has an invalid deck id", "deckId", deckId)
+		return nil
+	}
+
+	_, err = uc.deckRepo.IncrementCardsCount(ctx, userId, &deckObjectID, -1)
+	return err
+}
+
+func transactionError(err error) error {
+	switch errors.Cause(err).(type) {
+	case *errors.NotFound, *errors.InvalidPayload, *errors.Conflict:
+		return err
+	default:
+		return errors.WrapCause(errors.ErrInternalServer, err)
+	}
+}
+
+func (uc CardUseCase) FindByDeckId(ctx context.Context, userId, id string) (result []*card.Card, err error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.FindByDeckId")
+	defer span.End()
+	result, err = uc.repo.FindByDeckId(ctx, userId, id, false)
 	if err != nil {
-		log.Logger.Errorw("card not found", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("card not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
 	}
 	return result, nil
 }
 
-func (uc CardUseCase) FindById(userId, id string) (result *card.Card, err error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc CardUseCase) FindByDeckIds(ctx context.Context, userId string, deckIds []string) (result []*card.Card, err error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.FindByDeckIds")
+	defer span.End()
+	result, err = uc.repo.FindByDeckIds(ctx, userId, deckIds, false)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find cards error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	return result, nil
+}
+
+func (uc CardUseCase) FindById(ctx context.Context, userId, id string) (result *card.Card, err error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.FindById")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err = uc.repo.FindById(userId, &objectID, false)
+	result, err = uc.repo.FindById(ctx, userId, &objectID, false)
 	if err != nil {
-		log.Logger.Errorw("card not found", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("card not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
 	}
 	return result, nil
 }
@@ -115,74 +321,190 @@ func (uc CardUseCase) FindById(userId, id string) (result *card.Card, err error)
 //	return result, count, nil
 //}
 //
-func (uc CardUseCase) parseToObjectID(id string) (objID primitive.ObjectID, err error) {
+func (uc CardUseCase) parseToObjectID(ctx context.Context, id string) (objID primitive.ObjectID, err error) {
 	if id == "" {
 		err := errors.WrapWithMessage(errors.ErrInvalidPayload, "id is required")
-		log.Logger.Errorw("id is required")
+		log.FromContext(ctx).Errorw("id is required")
 		return objID, err
 	}
 
 	objID, err = primitive.ObjectIDFromHex(id)
 	if err != nil {
-		log.Logger.Errorw("invalid Id", "Error", err.Error())
+		log.FromContext(ctx).Errorw("invalid Id", "Error", err.Error())
 		return objID, errors.WrapWithMessage(errors.ErrInvalidPayload, err.Error())
 	}
 
 	return
 }
 
-func (uc CardUseCase) Delete(id, userId string) (result *card.Card, err error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc CardUseCase) Delete(ctx context.Context, id, userId string, version *int64) (result *card.Card, err error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.Delete")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err = uc.repo.Delete(userId, &objectID)
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.Delete(ctx, userId, &objectID, version)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			return uc.notApplied(ctx, userId, id, &objectID)
+		}
+		err = uc.decrementCardsCount(ctx, userId, result.DeckId)
+		if err != nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.CardDeleted, id, result)
+	})
 	if err != nil {
-		log.Logger.Errorw("Remove card error", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Remove card error", "error", err.Error())
+		return nil, transactionError(err)
 	}
 	return
 }
 
-func (uc CardUseCase) Update(id, userId string, isPartial bool, card *card.Card) (*card.Card, error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc CardUseCase) Update(ctx context.Context, id, userId string, card *card.Card, version *int64) (*card.Card, error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.Update")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	card.UserId = userId
-	card.LastUpdate = time.Now()
+	savedCard, err := uc.findForUpdate(ctx, userId, id, &objectID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.save(ctx, userId, id, &objectID, savedCard, card, savedCard.DeckId)
+}
 
-	savedCard, err := uc.repo.FindById(userId, &objectID, true)
+func (uc CardUseCase) UpdateAndMove(ctx context.Context, id, userId string, card *card.Card, version *int64) (*card.Card, error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.UpdateAndMove")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
-		log.Logger.Errorw("card not found", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		return nil, err
 	}
 
-	if isPartial {
-		if err := mergo.Merge(card, savedCard); err != nil {
-			log.Logger.Errorf("Error to merge entities")
-			return nil, &errors.BadRequest{Err: err}
-		}
+	savedCard, err := uc.findForUpdate(ctx, userId, id, &objectID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	deckId := card.DeckId
+	if deckId == "" {
+		deckId = savedCard.DeckId
 	}
+	return uc.save(ctx, userId, id, &objectID, savedCard, card, deckId)
+}
+
+func (uc CardUseCase) Patch(ctx context.Context, id, userId, contentType string, document []byte, version *int64) (*card.Card, error) {
+	ctx, span := tracing.Start(ctx, "CardUseCase.Patch")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	savedCard, err := uc.findForUpdate(ctx, userId, id, &objectID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched card.Card
+	err = patch.Apply(contentType, savedCard, document, &patched, readOnlyFields...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error to apply patch", "error", err.Error())
+		return nil, err
+	}
+
+	return uc.save(ctx, userId, id, &objectID, savedCard, &patched, savedCard.DeckId)
+}
+
+func (uc CardUseCase) findForUpdate(ctx context.Context, userId, id string, objectID *primitive.ObjectID, version *int64) (*card.Card, error) {
+	savedCard, err := uc.repo.FindById(ctx, userId, objectID, true)
+	if err != nil {
+		log.FromContext(ctx).Errorw("card not found", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
+	}
+
+	if savedCard.UserId != userId {
+		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+	}
+
+	if version != nil && *version != savedCard.Version {
+		log.FromContext(ctx).Errorw("card version mismatch", "expected", *version, "current", savedCard.Version)
+		return nil, errors.WrapWithMessage(errors.ErrVersionMismatch, fmt.Sprintf("id %s is at version %d", id, savedCard.Version))
+	}
+
+	return savedCard, nil
+}
+
+// save validates card and writes it over savedCard in deckId, keeping the server owned
+// fields. The cards counts of both decks change with the write when deckId is another deck.
+func (uc CardUseCase) save(ctx context.Context, userId, id string, objectID *primitive.ObjectID, savedCard, card *card.Card,
+	deckId string) (result *card.Card, err error) {
+	card.UserId = userId
+	card.LastUpdate = time.Now()
+	card.DeckId = deckId
+	card.Version = savedCard.Version
 
 	err = uc.validator.Struct(card)
 	if err != nil {
 		cardBytes, _ := json.Marshal(card)
-		log.Logger.Errorf("Error to validate input:\n %v;\n error: %v", string(cardBytes), err.Error())
+		log.FromContext(ctx).Errorf("Error to validate input:\n %v;\n error: %v", string(cardBytes), err.Error())
 		return nil, &errors.InvalidPayload{Err: err}
 	}
+	moved := deckId != savedCard.DeckId
+	var deckObjectID primitive.ObjectID
+	if moved {
+		if deckObjectID, err = uc.parseToObjectID(ctx, deckId); err != nil {
+			return nil, err
+		}
+	}
 
-	result, err := uc.repo.Update(&objectID, userId, card)
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if moved {
+			if err := uc.ownDeck(ctx, userId, &deckObjectID); err != nil {
+				return err
+			}
+		}
+		result, err = uc.repo.Update(ctx, objectID, userId, card)
+		if err != nil || result == nil {
+			return err
+		}
+		if moved {
+			if err = uc.incrementCardsCount(ctx, userId, &deckObjectID, 1); err != nil {
+				return err
+			}
+			if err = uc.decrementCardsCount(ctx, userId, savedCard.DeckId); err != nil {
+				return err
+			}
+		}
+		return uc.events.Publish(ctx, userId, event.CardUpdated, id, result)
+	})
 	if err != nil {
-		log.Logger.Errorw("update error", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("update error", "error", err.Error())
+		return nil, transactionError(err)
 	}
 
 	if result == nil {
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+		return nil, uc.notApplied(ctx, userId, id, objectID)
 	}
-
 	return result, nil
 }
+
+// notApplied tells a card that is gone apart from one changed since it was read.
+func (uc CardUseCase) notApplied(ctx context.Context, userId, id string, objectID *primitive.ObjectID) error {
+	current, err := uc.repo.FindById(ctx, userId, objectID, true)
+	if err != nil || current.UserId != userId {
+		return errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+	}
+
+	log.FromContext(ctx).Errorw("card version mismatch", "current", current.Version)
+	return errors.WrapWithMessage(errors.ErrVersionMismatch, fmt.Sprintf("id %s is at version %d", id, current.Version))
+}
@@ -0,0 +1,211 @@
+package card_usecase
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/card_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/deck_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
+	"github.com/go-playground/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"testing"
+)
+
+// fakeDecks keeps the decks in a map, the methods the card use case does not call are
+// left to the nil interface.
+type fakeDecks struct {
+	deck_repository.IDeckRepository
+	decks map[primitive.ObjectID]*deck.Deck
+}
+
+func (f fakeDecks) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (*deck.Deck, error) {
+	found, exists := f.decks[*id]
+	if !exists || (found.IsPrivate || private) && found.UserId != userId {
+		return nil, mongo.ErrNoDocuments
+	}
+	return found, nil
+}
+
+func (f fakeDecks) IncrementCardsCount(ctx context.Context, userId string, id *primitive.ObjectID, delta int64) (bool, error) {
+	found, exists := f.decks[*id]
+	if !exists || found.UserId != userId {
+		return false, nil
+	}
+	found.CardsCount += delta
+	return true, nil
+}
+
+// fakeCards keeps the cards in a map, as fakeDecks.
+type fakeCards struct {
+	card_repository.ICardRepository
+	cards map[primitive.ObjectID]*card.Card
+	err   error
+}
+
+func (f fakeCards) Persist(ctx context.Context, cardToPersist *card.Card) (*card.Card, error) {
+	id := primitive.NewObjectID()
+	cardToPersist.Id = &id
+	f.cards[id] = cardToPersist
+	return cardToPersist, nil
+}
+
+func (f fakeCards) PersistMany(ctx context.Context, cardsToPersist []*card.Card) ([]*card.Card, error) {
+	for _, cardToPersist := range cardsToPersist {
+		_, _ = f.Persist(ctx, cardToPersist)
+	}
+	return cardsToPersist, nil
+}
+
+func (f fakeCards) UpdateDeckId(ctx context.Context, userId string, id *primitive.ObjectID, deckId string) (*card.Card, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	found, exists := f.cards[*id]
+	if !exists || found.UserId != userId {
+		return nil, nil
+	}
+	previous := *found
+	found.DeckId = deckId
+	return &previous, nil
+}
+
+func (f fakeCards) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (*card.Card, error) {
+	found, exists := f.cards[*id]
+	if !exists || found.UserId != userId {
+		return nil, mongo.ErrNoDocuments
+	}
+	read := *found
+	return &read, nil
+}
+
+func (f fakeCards) Update(ctx context.Context, id *primitive.ObjectID, userId string, cardToSave *card.Card) (*card.Card, error) {
+	found, exists := f.cards[*id]
+	if !exists || found.UserId != userId || found.Version != cardToSave.Version {
+		return nil, nil
+	}
+	cardToSave.Id = id
+	cardToSave.Version++
+	saved := *cardToSave
+	f.cards[*id] = &saved
+	return cardToSave, nil
+}
+
+// racingCards changes a card right after it is read, as a request running alongside would.
+type racingCards struct {
+	fakeCards
+}
+
+func (r racingCards) FindById(ctx context.Context, userId string, id *primitive.ObjectID, private bool) (*card.Card, error) {
+	read, err := r.fakeCards.FindById(ctx, userId, id, private)
+	if err == nil {
+		r.cards[*id].Version++
+	}
+	return read, err
+}
+
+// withoutTransaction keeps the writes made before fn fails, as a standalone MongoDB.
+type withoutTransaction struct{}
+
+func (withoutTransaction) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestCardsAreNotWrittenToADeckOfAnotherUser(t *testing.T) {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	ctx := context.Background()
+	owned, public, missing := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	decks := fakeDecks{decks: map[primitive.ObjectID]*deck.Deck{
+		owned:  {Id: &owned, UserId: "owner"},
+		public: {Id: &public, UserId: "other"},
+	}}
+	cards := fakeCards{cards: make(map[primitive.ObjectID]*card.Card)}
+	events := event_usecase.NewEventPublisher(outbox_repository.NewOutboxMemoryRepository())
+	uc := NewCardUseCase(cards, decks, withoutTransaction{}, deck_usecase.DeckUseCase{}, events, validator.New())
+
+	kept, err := uc.Create(ctx, "owner", owned.Hex(), &card.Card{Front: "ser", Back: "to be"})
+	require.NoError(t, err)
+
+	for _, deckId := range []string{public.Hex(), missing.Hex()} {
+		_, err = uc.Create(ctx, "owner", deckId, &card.Card{Front: "estar", Back: "to be"})
+		assert.IsType(t, &errors.NotFound{}, errors.Cause(err))
+		_, err = uc.Import(ctx, "owner", deckId, []*card.Card{{Front: "ir", Back: "to go"}})
+		assert.IsType(t, &errors.NotFound{}, errors.Cause(err))
+		_, err = uc.Move(ctx, kept.Id.Hex(), "owner", deckId)
+		assert.IsType(t, &errors.NotFound{}, errors.Cause(err))
+	}
+
+	assert.Len(t, cards.cards, 1, "no card is written to a deck that is not the user's")
+	assert.Equal(t, owned.Hex(), cards.cards[*kept.Id].DeckId, "the card stays in its deck")
+	assert.Equal(t, int64(1), decks.decks[owned].CardsCount)
+	assert.Zero(t, decks.decks[public].CardsCount)
+}
+
+func TestMoveTellsAMissingCardFromAFailedWrite(t *testing.T) {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	ctx := context.Background()
+	deckId := primitive.NewObjectID()
+	decks := fakeDecks{decks: map[primitive.ObjectID]*deck.Deck{deckId: {Id: &deckId, UserId: "owner"}}}
+	cards := fakeCards{cards: make(map[primitive.ObjectID]*card.Card)}
+	events := event_usecase.NewEventPublisher(outbox_repository.NewOutboxMemoryRepository())
+	uc := NewCardUseCase(cards, decks, withoutTransaction{}, deck_usecase.DeckUseCase{}, events, validator.New())
+
+	_, err := uc.Move(ctx, primitive.NewObjectID().Hex(), "owner", deckId.Hex())
+	assert.IsType(t, &errors.NotFound{}, errors.Cause(err))
+
+	cards.err = mongo.CommandError{Message: "operation exceeded time limit"}
+	uc = NewCardUseCase(cards, decks, withoutTransaction{}, deck_usecase.DeckUseCase{}, events, validator.New())
+	_, err = uc.Move(ctx, primitive.NewObjectID().Hex(), "owner", deckId.Hex())
+	assert.IsType(t, &errors.InternalServerError{}, errors.Cause(err), "a failed write is not a missing card")
+}
+
+func TestUpdateAndMoveDoesNotMoveACardChangedSinceItWasRead(t *testing.T) {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	ctx := context.Background()
+	from, to, cardId := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	decks := fakeDecks{decks: map[primitive.ObjectID]*deck.Deck{
+		from: {Id: &from, UserId: "owner", CardsCount: 1},
+		to:   {Id: &to, UserId: "owner"},
+	}}
+	cards := fakeCards{cards: map[primitive.ObjectID]*card.Card{
+		cardId: {Id: &cardId, UserId: "owner", DeckId: from.Hex(), Front: "ser", Back: "to be"},
+	}}
+	outbox := outbox_repository.NewOutboxMemoryRepository()
+	events := event_usecase.NewEventPublisher(outbox)
+	version := int64(0)
+
+	uc := NewCardUseCase(racingCards{cards}, decks, withoutTransaction{}, deck_usecase.DeckUseCase{}, events, validator.New())
+	_, err := uc.UpdateAndMove(ctx, cardId.Hex(), "owner", &card.Card{Front: "estar", Back: "to be", DeckId: to.Hex()}, &version)
+	assert.ErrorIs(t, err, errors.ErrVersionMismatch)
+	assert.Equal(t, from.Hex(), cards.cards[cardId].DeckId, "the card stays in its deck")
+	assert.Equal(t, "ser", cards.cards[cardId].Front)
+	assert.Equal(t, int64(1), decks.decks[from].CardsCount)
+	assert.Zero(t, decks.decks[to].CardsCount)
+
+	version = cards.cards[cardId].Version
+	uc = NewCardUseCase(cards, decks, withoutTransaction{}, deck_usecase.DeckUseCase{}, events, validator.New())
+	moved, err := uc.UpdateAndMove(ctx, cardId.Hex(), "owner", &card.Card{Front: "estar", Back: "to be", DeckId: to.Hex()}, &version)
+	require.NoError(t, err)
+	assert.Equal(t, version+1, moved.Version, "the move and the update are one write")
+	assert.Equal(t, to.Hex(), cards.cards[cardId].DeckId)
+	assert.Equal(t, "estar", cards.cards[cardId].Front)
+	assert.Zero(t, decks.decks[from].CardsCount)
+	assert.Equal(t, int64(1), decks.decks[to].CardsCount)
+}
@@ -1,244 +1,452 @@
 package deck_usecase
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/patch"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/Enums"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/card_repository"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/deck_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/playlist_repository"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/review_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
 	"github.com/go-playground/validator"
-	"github.com/imdario/mergo"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type IDeckUseCase interface {
-	Create(userId string, deck *deck.Deck) (result *deck.Deck, err error)
-	FindByUserIdAndPublic(userId string) (result []*deck.Deck, count int64, err error)
-	FindById(userId, id string) (result *deck.Deck, err error)
-	FindByUserId(userId string) (result []*deck.Deck, count int64, err error)
-	Delete(id, userId string) (result *deck.Deck, err error)
-	Update(id, userId string, isPartial bool, deck *deck.Deck) (*deck.Deck, error)
-	FindBySearch(filter, userId string) (result []map[string]interface{}, count int64, err error)
-	FindRecent(userId string) (result []*deck.Deck, count int64, err error)
+	Create(ctx context.Context, userId string, deck *deck.Deck) (result *deck.Deck, err error)
+	FindByUserIdAndPublic(ctx context.Context, userId string) (result []*deck.Deck, count int64, err error)
+	FindById(ctx context.Context, userId, id string) (result *deck.Deck, err error)
+	// FindByIds returns the decks among ids that userId can see, the missing ones are left out.
+	FindByIds(ctx context.Context, userId string, ids []string) (result []*deck.Deck, err error)
+	FindByUserId(ctx context.Context, userId string) (result []*deck.Deck, count int64, err error)
+	Delete(ctx context.Context, id, userId string, version *int64) (result *deck.Deck, err error)
+	Update(ctx context.Context, id, userId string, deck *deck.Deck, version *int64) (*deck.Deck, error)
+	Patch(ctx context.Context, id, userId, contentType string, document []byte, version *int64) (*deck.Deck, error)
+	FindBySearch(ctx context.Context, filter, userId string) (result []map[string]interface{}, count int64, err error)
+	FindRecent(ctx context.Context, userId string) (result []*deck.Deck, count int64, err error)
+	RepairCardsCount(ctx context.Context, userId string) (result []*deck.Deck, err error)
 }
+
+// readOnlyFields are owned by the server and cannot be changed by a patch.
+var readOnlyFields = []string{"_id", "userId", "cardsCount", "version", "lastUpdate"}
+
 type DeckUseCase struct {
-	validator  *validator.Validate
-	repo       deck_repository.IDeckRepository
-	reviewRepo review_repository.IReviewRepository
+	validator    *validator.Validate
+	repo         deck_repository.IDeckRepository
+	cardRepo     card_repository.ICardRepository
+	playlistRepo playlist_repository.IPlaylistRepository
+	reviewRepo   review_repository.IReviewRepository
+	tx           transaction.ITransactionManager
+	events       event_usecase.IEventPublisher
 }
 
-func NewDeckUseCase(deckRepository deck_repository.IDeckRepository, reviewRepo review_repository.IReviewRepository, validator *validator.Validate) DeckUseCase {
+func NewDeckUseCase(deckRepository deck_repository.IDeckRepository, cardRepo card_repository.ICardRepository,
+	playlistRepo playlist_repository.IPlaylistRepository, reviewRepo review_repository.IReviewRepository,
+	tx transaction.ITransactionManager, events event_usecase.IEventPublisher, validator *validator.Validate) DeckUseCase {
 	return DeckUseCase{
-		validator:  validator,
-		repo:       deckRepository,
-		reviewRepo: reviewRepo,
+		validator:    validator,
+		repo:         deckRepository,
+		cardRepo:     cardRepo,
+		playlistRepo: playlistRepo,
+		reviewRepo:   reviewRepo,
+		tx:           tx,
+		events:       events,
 	}
 }
 
-func (uc DeckUseCase) Create(userId string, deck *deck.Deck) (result *deck.Deck, err error) {
+func (uc DeckUseCase) Create(ctx context.Context, userId string, deck *deck.Deck) (result *deck.Deck, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.Create")
+	defer span.End()
 	deck.UserId = userId
 	deck.LastUpdate = time.Now()
 	deck.CardsCount = 0
+	deck.Version = 1
 
 	err = uc.validator.Struct(deck)
 	if err != nil {
 		deckBytes, _ := json.Marshal(deck)
-		log.Logger.Errorf("Error to validate input:\n %v;\n error: %v", string(deckBytes), err.Error())
+		log.FromContext(ctx).Errorf("Error to validate input:\n %v;\n error: %v", string(deckBytes), err.Error())
 		return nil, &errors.InvalidPayload{Err: err}
 	}
 
-	result, err = uc.repo.Persist(deck)
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.Persist(ctx, deck)
+		if err != nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.DeckCreated, result.Id.Hex(), result)
+	})
 	if err != nil {
-		log.Logger.Errorw("Deck creation error", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Deck creation error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
-
 	return result, nil
 }
 
-func (uc DeckUseCase) FindById(userId, id string) (result *deck.Deck, err error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc DeckUseCase) FindById(ctx context.Context, userId, id string) (result *deck.Deck, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.FindById")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err = uc.repo.FindById(userId, &objectID, false)
+	result, err = uc.repo.FindById(ctx, userId, &objectID, false)
+	if err != nil {
+		log.FromContext(ctx).Errorw("deck not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
+	}
+	return result, nil
+}
+
+func (uc DeckUseCase) FindByIds(ctx context.Context, userId string, ids []string) (result []*deck.Deck, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.FindByIds")
+	defer span.End()
+	objectIDs := make([]*primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objectID, err := uc.parseToObjectID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		objectIDs = append(objectIDs, &objectID)
+	}
+
+	result, err = uc.repo.FindByIds(ctx, userId, objectIDs, false)
 	if err != nil {
-		log.Logger.Errorw("deck not found", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("Find decks error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 	return result, nil
 }
 
-func (uc DeckUseCase) FindByUserId(userId string) (result []*deck.Deck, count int64, err error) {
-	result, err = uc.repo.FindByUserId(userId)
+func (uc DeckUseCase) FindByUserId(ctx context.Context, userId string) (result []*deck.Deck, count int64, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.FindByUserId")
+	defer span.End()
+	result, err = uc.repo.FindByUserId(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("deck not found", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("deck not found", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrNotFound, err)
 	}
 
-	count, err = uc.repo.Count(userId)
+	count, err = uc.repo.Count(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("Count decks error", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Count decks error", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 
 	return result, count, nil
 }
 
-func (uc DeckUseCase) FindByUserIdAndPublic(userId string) (result []*deck.Deck, count int64, err error) {
-	result, err = uc.repo.FindByUserIdAndPublic(userId)
+func (uc DeckUseCase) FindByUserIdAndPublic(ctx context.Context, userId string) (result []*deck.Deck, count int64, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.FindByUserIdAndPublic")
+	defer span.End()
+	result, err = uc.repo.FindByUserIdAndPublic(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("deck not found", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("deck not found", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrNotFound, err)
 	}
 
-	count, err = uc.repo.Count(userId)
+	count, err = uc.repo.Count(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("Count decks error", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Count decks error", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 	return result, count, nil
 }
 
-func (uc DeckUseCase) FindRecent(userId string) (result []*deck.Deck, count int64, err error) {
-	reviews, err := uc.reviewRepo.FindRecent(Enums.Deck, userId)
+func (uc DeckUseCase) FindRecent(ctx context.Context, userId string) (result []*deck.Deck, count int64, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.FindRecent")
+	defer span.End()
+	reviews, err := uc.reviewRepo.FindRecent(ctx, Enums.Deck, userId)
 
 	for _, s := range reviews {
-		objectID, err := uc.parseToObjectID(s.OriginId)
+		objectID, err := uc.parseToObjectID(ctx, s.OriginId)
 		if err != nil {
 			return nil, 0, err
 		}
-		deckFound, err := uc.repo.FindById(userId, &objectID, false)
+		deckFound, err := uc.repo.FindById(ctx, userId, &objectID, false)
 		if err != nil {
-			log.Logger.Errorw("deck not found", "Error", err.Error())
-			return nil, 0, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+			log.FromContext(ctx).Errorw("deck not found", "Error", err.Error())
+			return nil, 0, errors.WrapCause(errors.ErrNotFound, err)
 		}
 		result = append(result, deckFound)
 	}
 
-	count, err = uc.repo.Count(userId)
+	count, err = uc.repo.Count(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("Count decks error", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Count decks error", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 	return result, count, nil
 }
 
-func (uc DeckUseCase) parseToObjectID(id string) (objID primitive.ObjectID, err error) {
+func (uc DeckUseCase) parseToObjectID(ctx context.Context, id string) (objID primitive.ObjectID, err error) {
 	if id == "" {
 		err := errors.WrapWithMessage(errors.ErrInvalidPayload, "id is required")
-		log.Logger.Errorw("id is required")
+		log.FromContext(ctx).Errorw("id is required")
 		return objID, err
 	}
 
 	objID, err = primitive.ObjectIDFromHex(id)
 	if err != nil {
-		log.Logger.Errorw("invalid Id", "Error", err.Error())
+		log.FromContext(ctx).Errorw("invalid Id", "Error", err.Error())
 		return objID, errors.WrapWithMessage(errors.ErrInvalidPayload, err.Error())
 	}
 
 	return
 }
 
-func (uc DeckUseCase) Delete(id, userId string) (result *deck.Deck, err error) {
-	objectID, err := uc.parseToObjectID(id)
+// Delete removes the deck with its cards and takes it out of the playlists holding it,
+// whoever they belong to.
+func (uc DeckUseCase) Delete(ctx context.Context, id, userId string, version *int64) (result *deck.Deck, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.Delete")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err = uc.repo.Delete(userId, &objectID)
+	// Without a transaction the cards deleted before a failure stay deleted, the deck is
+	// checked before anything is written.
+	if _, err = uc.findForUpdate(ctx, userId, id, &objectID, version); err != nil {
+		return nil, err
+	}
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		// The deck goes last, the SQL databases refuse to remove a deck still referenced.
+		if err := uc.deleteCards(ctx, userId, id); err != nil {
+			return err
+		}
+		if err := uc.removeFromPlaylists(ctx, id); err != nil {
+			return err
+		}
+		result, err = uc.repo.Delete(ctx, userId, &objectID, version)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			return uc.notApplied(ctx, userId, id, &objectID)
+		}
+		return uc.events.Publish(ctx, userId, event.DeckDeleted, id, result)
+	})
 	if err != nil {
-		log.Logger.Errorw("Remove deck error", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Remove deck error", "error", err.Error())
+		switch errors.Cause(err).(type) {
+		case *errors.NotFound, *errors.Conflict:
+			return nil, err
+		}
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 	return
 }
 
-func (uc DeckUseCase) Update(id, userId string, isPartial bool, deck *deck.Deck) (*deck.Deck, error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc DeckUseCase) deleteCards(ctx context.Context, userId, deckId string) error {
+	cards, err := uc.cardRepo.FindByDeckId(ctx, userId, deckId, true)
+	if err != nil {
+		return err
+	}
+	for _, c := range cards {
+		deleted, err := uc.cardRepo.Delete(ctx, c.UserId, c.Id, nil)
+		if err != nil {
+			return err
+		}
+		if deleted == nil {
+			continue
+		}
+		if err = uc.events.Publish(ctx, c.UserId, event.CardDeleted, c.Id.Hex(), deleted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (uc DeckUseCase) removeFromPlaylists(ctx context.Context, deckId string) error {
+	playlists, err := uc.playlistRepo.FindByDeckId(ctx, deckId)
+	if err != nil {
+		return err
+	}
+	for _, p := range playlists {
+		updated, err := uc.playlistRepo.RemoveDeck(ctx, p.UserId, p.Id, deckId, nil)
+		if err != nil {
+			return err
+		}
+		if updated == nil {
+			continue
+		}
+		if err = uc.events.Publish(ctx, p.UserId, event.PlaylistUpdated, p.Id.Hex(), updated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (uc DeckUseCase) Update(ctx context.Context, id, userId string, deck *deck.Deck, version *int64) (*deck.Deck, error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.Update")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	deck.UserId = userId
-	deck.LastUpdate = time.Now()
+	savedDeck, err := uc.findForUpdate(ctx, userId, id, &objectID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.save(ctx, userId, id, &objectID, savedDeck, deck)
+}
 
-	savedDeck, err := uc.repo.FindById(userId, &objectID, true)
+func (uc DeckUseCase) Patch(ctx context.Context, id, userId, contentType string, document []byte, version *int64) (*deck.Deck, error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.Patch")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
-		log.Logger.Errorw("deck not found", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		return nil, err
 	}
 
-	if isPartial {
-		if err := mergo.Merge(deck, savedDeck); err != nil {
-			log.Logger.Errorf("Error to merge entities")
-			return nil, &errors.BadRequest{Err: err}
-		}
+	savedDeck, err := uc.findForUpdate(ctx, userId, id, &objectID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched deck.Deck
+	err = patch.Apply(contentType, savedDeck, document, &patched, readOnlyFields...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error to apply patch", "error", err.Error())
+		return nil, err
+	}
+
+	return uc.save(ctx, userId, id, &objectID, savedDeck, &patched)
+}
+
+func (uc DeckUseCase) findForUpdate(ctx context.Context, userId, id string, objectID *primitive.ObjectID, version *int64) (*deck.Deck, error) {
+	savedDeck, err := uc.repo.FindById(ctx, userId, objectID, true)
+	if err != nil {
+		log.FromContext(ctx).Errorw("deck not found", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
+	}
+
+	if savedDeck.UserId != userId {
+		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+	}
+
+	if version != nil && *version != savedDeck.Version {
+		log.FromContext(ctx).Errorw("deck version mismatch", "expected", *version, "current", savedDeck.Version)
+		return nil, errors.WrapWithMessage(errors.ErrVersionMismatch, fmt.Sprintf("id %s is at version %d", id, savedDeck.Version))
 	}
 
+	return savedDeck, nil
+}
+
+// save validates deck and writes it over savedDeck, keeping the server owned fields.
+func (uc DeckUseCase) save(ctx context.Context, userId, id string, objectID *primitive.ObjectID, savedDeck, deck *deck.Deck) (result *deck.Deck, err error) {
+	deck.UserId = userId
+	deck.LastUpdate = time.Now()
+	deck.CardsCount = savedDeck.CardsCount
+	deck.Version = savedDeck.Version
+
 	err = uc.validator.Struct(deck)
 	if err != nil {
 		deckBytes, _ := json.Marshal(deck)
-		log.Logger.Errorf("Error to validate input:\n %v;\n error: %v", string(deckBytes), err.Error())
+		log.FromContext(ctx).Errorf("Error to validate input:\n %v;\n error: %v", string(deckBytes), err.Error())
 		return nil, &errors.InvalidPayload{Err: err}
 	}
 
-	result, err := uc.repo.Update(&objectID, userId, deck)
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.Update(ctx, objectID, userId, deck)
+		if err != nil || result == nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.DeckUpdated, id, result)
+	})
 	if err != nil {
-		log.Logger.Errorw("update error", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("update error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 	if deck.IsPrivate == !savedDeck.IsPrivate {
 		//todo cardsUsecase.updatePrivacy(deckId,boleano)
 	}
 
 	if result == nil {
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+		return nil, uc.notApplied(ctx, userId, id, objectID)
 	}
-
 	return result, nil
 }
 
-func (uc DeckUseCase) FindBySearch(filter, userId string) (result []map[string]interface{}, count int64, err error) {
-	result, err = uc.repo.FindByFilters(filter, userId)
+// notApplied tells a deck that is gone apart from one changed since it was read.
+func (uc DeckUseCase) notApplied(ctx context.Context, userId, id string, objectID *primitive.ObjectID) error {
+	current, err := uc.repo.FindById(ctx, userId, objectID, true)
+	if err != nil || current.UserId != userId {
+		return errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+	}
+
+	log.FromContext(ctx).Errorw("deck version mismatch", "current", current.Version)
+	return errors.WrapWithMessage(errors.ErrVersionMismatch, fmt.Sprintf("id %s is at version %d", id, current.Version))
+}
+
+func (uc DeckUseCase) FindBySearch(ctx context.Context, filter, userId string) (result []map[string]interface{}, count int64, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.FindBySearch")
+	defer span.End()
+	result, err = uc.repo.FindByFilters(ctx, filter, userId)
 	if err != nil {
-		log.Logger.Errorw("deck not found", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("deck not found", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrNotFound, err)
 	}
 
-	count, err = uc.repo.Count(userId)
+	count, err = uc.repo.Count(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("Count decks error", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Count decks error", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 	return result, count, nil
 }
 
-//func (uc DeckUseCase) AddDeckToPlaylist(id, userId string, card *card.Card) (*deck.Deck, error) {
-//
-//	savedDeck, err := uc.FindById(userId, id)
-//	if err != nil {
-//		log.Logger.Errorw("playlist not found", "error", err.Error())
-//		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
-//	}
-//
-//	savedDeck.Cards = append(savedDeck.Cards, card)
-//	savedDeck.CardsCount += 1
-//
-//	result, err := uc.Update(id, userId, true, savedDeck)
-//	if err != nil {
-//		log.Logger.Errorw("update error", "error", err.Error())
-//		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
-//	}
-//
-//	if result == nil {
-//		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
-//	}
-//
-//	return result, nil
-//}
+// RepairCardsCount recomputes the cards count of every deck owned by the user
+// from the card collection, fixing counts written before they were maintained.
+func (uc DeckUseCase) RepairCardsCount(ctx context.Context, userId string) (result []*deck.Deck, err error) {
+	ctx, span := tracing.Start(ctx, "DeckUseCase.RepairCardsCount")
+	defer span.End()
+	result, err = uc.repo.FindByUserId(ctx, userId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("deck not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
+	}
+
+	deckIds := make([]string, 0, len(result))
+	for _, deckElement := range result {
+		deckIds = append(deckIds, deckElement.Id.Hex())
+	}
+
+	counts, err := uc.cardRepo.CountByDeckIds(ctx, deckIds)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Count cards error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+
+	for _, deckElement := range result {
+		count := counts[deckElement.Id.Hex()]
+		if deckElement.CardsCount == count {
+			continue
+		}
+
+		_, err = uc.repo.SetCardsCount(ctx, userId, deckElement.Id, count)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Repair cards count error", "Error", err.Error())
+			return nil, errors.WrapCause(errors.ErrInternalServer, err)
+		}
+		log.FromContext(ctx).Infow("Deck cards count repaired", "deckId", deckElement.Id.Hex(), "from", deckElement.CardsCount, "to", count)
+		deckElement.CardsCount = count
+	}
+
+	return result, nil
+}
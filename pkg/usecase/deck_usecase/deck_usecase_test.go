@@ -0,0 +1,82 @@
+package deck_usecase
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
+	"github.com/go-playground/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+// The SQLite storage runs the migrations, so the test also covers the foreign keys.
+func TestDeleteRemovesTheCardsAndThePlaylistEntriesOfTheDeck(t *testing.T) {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	for _, storageName := range []string{config.StorageMemory, config.StorageSQLite} {
+		t.Run(storageName, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := &config.Config{Storage: storageName, SQLite: config.SQLite{Path: ":memory:", BusyTimeout: time.Second, QueryTimeout: time.Second}}
+			storage, cleanup, err := repository.NewStorage(cfg, nil)
+			require.NoError(t, err)
+			defer cleanup()
+			if storage.Migrator != nil {
+				_, err = storage.Migrator.Up(ctx, false)
+				require.NoError(t, err)
+			}
+
+			uc := NewDeckUseCase(storage.Decks, storage.Cards, storage.Playlists, storage.Reviews, storage.Transactions,
+				event_usecase.NewEventPublisher(storage.Outbox), validator.New())
+			removed, err := uc.Create(ctx, "owner", &deck.Deck{Name: "Verbs", ImageURL: "verbs.png", Description: "Irregular"})
+			require.NoError(t, err)
+			kept, err := uc.Create(ctx, "owner", &deck.Deck{Name: "Nouns", ImageURL: "nouns.png", Description: "Common"})
+			require.NoError(t, err)
+			cardInDeck, err := storage.Cards.Persist(ctx, &card.Card{DeckId: removed.Id.Hex(), UserId: "owner", Front: "ser", Back: "to be", LastUpdate: time.Now()})
+			require.NoError(t, err)
+			cardKept, err := storage.Cards.Persist(ctx, &card.Card{DeckId: kept.Id.Hex(), UserId: "owner", Front: "casa", Back: "house", LastUpdate: time.Now()})
+			require.NoError(t, err)
+			preview := func(d *deck.Deck) deck.DeckPreview {
+				return deck.DeckPreview{Id: d.Id.Hex(), ImageURL: d.ImageURL, Name: d.Name, UserId: d.UserId}
+			}
+			othersPlaylist, err := storage.Playlists.Persist(ctx, &playlist.Playlist{Name: "Spanish", ImageURL: "es.png", Description: "Mine",
+				UserId: "other", Decks: []deck.DeckPreview{preview(removed), preview(kept)}, Version: 1, LastUpdate: time.Now()})
+			require.NoError(t, err)
+
+			_, err = uc.Delete(ctx, removed.Id.Hex(), "owner", &removed.Version)
+			require.NoError(t, err)
+
+			_, err = storage.Cards.FindById(ctx, "owner", cardInDeck.Id, true)
+			assert.Error(t, err, "the cards of the deck are deleted")
+			_, err = storage.Cards.FindById(ctx, "owner", cardKept.Id, true)
+			assert.NoError(t, err)
+			found, err := storage.Playlists.FindById(ctx, "other", othersPlaylist.Id, true)
+			require.NoError(t, err)
+			assert.Equal(t, []deck.DeckPreview{preview(kept)}, found.Decks, "the playlist of another user only loses the deck")
+			assert.Equal(t, othersPlaylist.Version+1, found.Version)
+
+			types := func(userId string) []string {
+				events, err := storage.Outbox.FindAfter(ctx, userId, event.Cursor{}, 100)
+				require.NoError(t, err)
+				result := make([]string, 0, len(events))
+				for _, e := range events {
+					result = append(result, e.Type+" "+e.EntityId)
+				}
+				return result
+			}
+			assert.Subset(t, types("owner"), []string{event.CardDeleted + " " + cardInDeck.Id.Hex(), event.DeckDeleted + " " + removed.Id.Hex()})
+			assert.NotContains(t, types("owner"), event.CardDeleted+" "+cardKept.Id.Hex())
+			assert.Equal(t, []string{event.PlaylistUpdated + " " + othersPlaylist.Id.Hex()}, types("other"))
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package event_usecase
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/backoff"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"strings"
+	"time"
+)
+
+// pruneInterval is how often the events past their retention are removed.
+const pruneInterval = 10 * time.Minute
+
+// Subscriber is handed every domain event at least once: after a failure, or when the
+// instance handling it stops, it gets the event again. Name tells the subscribers that
+// are done with an event apart, it must not change once events have been handled.
+type Subscriber interface {
+	Name() string
+	Handle(ctx context.Context, e *event.Event) error
+}
+
+// Dispatcher hands the events in the outbox to the subscribers in the background. Every
+// instance of the server runs one, each event is claimed by one of them at a time. The
+// events are handed in the order they occurred, apart from the ones being retried.
+type Dispatcher struct {
+	repo        outbox_repository.IOutboxRepository
+	subscribers []Subscriber
+	cfg         config.Events
+	now         func() time.Time
+	pruned      time.Time
+}
+
+func NewDispatcher(repo outbox_repository.IOutboxRepository, cfg config.Events, subscribers []Subscriber) *Dispatcher {
+	return &Dispatcher{
+		repo:        repo,
+		subscribers: subscribers,
+		cfg:         cfg,
+		now:         time.Now,
+	}
+}
+
+// Run hands the due events every poll interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	log.Logger.Infow("Event dispatcher started", "pollInterval", d.cfg.PollInterval.String(), "subscribers", len(d.subscribers))
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch may have left more due, they are handed without waiting.
+		if d.DispatchDue(ctx) == d.cfg.BatchSize {
+			continue
+		}
+		d.prune(ctx)
+		select {
+		case <-ctx.Done():
+			log.Logger.Info("Event dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue hands a batch of the due events to the subscribers, one event after the
+// other, and returns how many it claimed. They are claimed for as long as the batch can
+// take.
+func (d *Dispatcher) DispatchDue(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+	now := d.now()
+	events, err := d.repo.Claim(ctx, now, now.Add(time.Duration(d.cfg.BatchSize+1)*d.cfg.Timeout), d.cfg.BatchSize)
+	if err != nil {
+		log.Logger.Errorw("Claim events error", "error", err.Error())
+		return 0
+	}
+
+	for _, e := range events {
+		if ctx.Err() != nil {
+			// Stopping, the claims expire and the rest is handled again.
+			break
+		}
+		d.dispatch(ctx, e)
+	}
+	return len(events)
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, e *event.Event) {
+	handleCtx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	failed := make([]string, 0)
+	failures := make([]string, 0)
+	for _, subscriber := range d.subscribers {
+		if e.HandledBy(subscriber.Name()) {
+			continue
+		}
+		if err := subscriber.Handle(handleCtx, e); err != nil {
+			failed = append(failed, subscriber.Name())
+			failures = append(failures, subscriber.Name()+": "+err.Error())
+			continue
+		}
+		e.Handled = append(e.Handled, subscriber.Name())
+		metrics.EventsHandled.WithLabelValues(e.Type, subscriber.Name(), "succeeded").Inc()
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := d.now()
+	outcome := "retried"
+	e.Error = strings.Join(failures, "; ")
+	switch {
+	case len(failed) == 0:
+		e.Status = event.StatusDispatched
+	case e.Attempts >= d.cfg.MaxAttempts:
+		e.Status = event.StatusFailed
+		outcome = event.StatusFailed
+		log.Logger.Errorw("Event has failed", "eventId", e.Id.Hex(), "type", e.Type, "error", e.Error)
+	default:
+		e.NextAttempt = now.Add(backoff.Exponential(d.cfg.InitialBackoff, d.cfg.MaxBackoff, e.Attempts))
+	}
+	for _, name := range failed {
+		metrics.EventsHandled.WithLabelValues(e.Type, name, outcome).Inc()
+	}
+
+	if err := d.repo.Update(context.Background(), e); err != nil {
+		log.Logger.Errorw("Update event error", "eventId", e.Id.Hex(), "error", err.Error())
+	}
+}
+
+// prune removes the events past their retention, at most once every pruneInterval.
+func (d *Dispatcher) prune(ctx context.Context) {
+	now := d.now()
+	if now.Sub(d.pruned) < pruneInterval {
+		return
+	}
+	d.pruned = now
+
+	count, err := d.repo.Prune(ctx, now.Add(-d.cfg.Retention))
+	if err != nil {
+		log.Logger.Errorw("Prune events error", "error", err.Error())
+		return
+	}
+	if count > 0 {
+		log.Logger.Infow("Events pruned", "count", count)
+	}
+}
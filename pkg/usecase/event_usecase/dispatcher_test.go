@@ -0,0 +1,94 @@
+package event_usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type fakeSubscriber struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (s *fakeSubscriber) Name() string {
+	return s.name
+}
+
+func (s *fakeSubscriber) Handle(_ context.Context, _ *event.Event) error {
+	s.calls++
+	return s.err
+}
+
+func newTestDispatcher(t *testing.T, subscribers ...Subscriber) (*Dispatcher, EventPublisher, *time.Time) {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	cfg := config.Events{BatchSize: 10, Timeout: time.Second, MaxAttempts: 3,
+		InitialBackoff: time.Minute, MaxBackoff: 3 * time.Minute, Retention: time.Hour}
+	repo := outbox_repository.NewOutboxMemoryRepository()
+	dispatcher := NewDispatcher(repo, cfg, subscribers)
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+	return dispatcher, NewEventPublisher(repo), &now
+}
+
+func TestDispatchDueHandsEachEventOnce(t *testing.T) {
+	ctx := context.Background()
+	subscriber := &fakeSubscriber{name: "first"}
+	dispatcher, publisher, now := newTestDispatcher(t, subscriber)
+
+	require.NoError(t, publisher.Publish(ctx, "owner", event.DeckCreated, "1", map[string]string{"name": "Verbs"}))
+	require.NoError(t, publisher.Publish(ctx, "owner", event.DeckDeleted, "1", map[string]string{"name": "Verbs"}))
+	*now = now.Add(time.Second)
+
+	assert.Equal(t, 2, dispatcher.DispatchDue(ctx))
+	assert.Equal(t, 0, dispatcher.DispatchDue(ctx))
+	assert.Equal(t, 2, subscriber.calls)
+}
+
+func TestDispatchDueRetriesOnlyTheFailingSubscriber(t *testing.T) {
+	ctx := context.Background()
+	succeeding := &fakeSubscriber{name: "succeeding"}
+	failing := &fakeSubscriber{name: "failing", err: errors.New("unavailable")}
+	dispatcher, publisher, now := newTestDispatcher(t, succeeding, failing)
+
+	require.NoError(t, publisher.Publish(ctx, "owner", event.CardCreated, "1", nil))
+	*now = now.Add(time.Second)
+	assert.Equal(t, 1, dispatcher.DispatchDue(ctx))
+	assert.Equal(t, 0, dispatcher.DispatchDue(ctx), "not due before the backoff")
+
+	*now = now.Add(time.Minute)
+	failing.err = nil
+	assert.Equal(t, 1, dispatcher.DispatchDue(ctx))
+	assert.Equal(t, 1, succeeding.calls)
+	assert.Equal(t, 2, failing.calls)
+
+	*now = now.Add(time.Hour)
+	assert.Equal(t, 0, dispatcher.DispatchDue(ctx))
+}
+
+func TestDispatchDueGivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	failing := &fakeSubscriber{name: "failing", err: errors.New("unavailable")}
+	dispatcher, publisher, now := newTestDispatcher(t, failing)
+
+	require.NoError(t, publisher.Publish(ctx, "owner", event.ReviewCompleted, "1", nil))
+	*now = now.Add(time.Second)
+	for attempt := 0; attempt < 3; attempt++ {
+		assert.Equal(t, 1, dispatcher.DispatchDue(ctx))
+		*now = now.Add(3 * time.Minute)
+	}
+	assert.Equal(t, 0, dispatcher.DispatchDue(ctx))
+	assert.Equal(t, 3, failing.calls)
+}
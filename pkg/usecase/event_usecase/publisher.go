@@ -0,0 +1,45 @@
+package event_usecase
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"time"
+)
+
+type IEventPublisher interface {
+	// Publish records that eventType happened to the entity entityId of userId, data is
+	// the entity after the change, or before it for a removal. Called with the context of
+	// the transaction of the change, the event is only kept if the change is.
+	Publish(ctx context.Context, userId, eventType, entityId string, data interface{}) error
+}
+
+type EventPublisher struct {
+	repo outbox_repository.IOutboxRepository
+}
+
+func NewEventPublisher(repo outbox_repository.IOutboxRepository) EventPublisher {
+	return EventPublisher{repo: repo}
+}
+
+func (p EventPublisher) Publish(ctx context.Context, userId, eventType, entityId string, data interface{}) error {
+	ctx, span := tracing.Start(ctx, "EventPublisher.Publish")
+	defer span.End()
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return p.repo.Append(ctx, &event.Event{
+		Type:        eventType,
+		UserId:      userId,
+		EntityId:    entityId,
+		Data:        payload,
+		OccurredAt:  now,
+		Status:      event.StatusPending,
+		NextAttempt: now,
+	})
+}
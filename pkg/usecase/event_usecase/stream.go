@@ -0,0 +1,241 @@
+package event_usecase
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"time"
+)
+
+type IEventStream interface {
+	// Subscribe starts listening to the events of userId. With lastEventId, the cursor of
+	// the last event a client got, the events it missed since are replayed first.
+	Subscribe(ctx context.Context, userId, lastEventId string) (*Subscription, error)
+	// Unsubscribe stops listening, the events channel of the subscription is closed.
+	Unsubscribe(subscription *Subscription)
+}
+
+// Subscription is a client listening to the events of a user.
+type Subscription struct {
+	// Reset tells the client that the events it missed are no longer all kept, it has to
+	// fetch its data again.
+	Reset bool
+	// Replay are the events missed since the cursor the client resumed from.
+	Replay []*event.Event
+	// Events are the events as they are written. It is closed when the client falls
+	// BufferSize events behind, it resumes from the last one it got.
+	Events <-chan *event.Event
+
+	events chan *event.Event
+	userId string
+	// after is where the subscription started, only the events after it are handed.
+	after    event.Cursor
+	replayed map[primitive.ObjectID]bool
+	closed   bool
+}
+
+// Stream reads the events every instance writes to the outbox and hands them to the
+// clients listening on this one. The events that occurred in the last Lookback are read
+// on every poll, so one committed after a later one is still seen; the ones already handed
+// are remembered until they leave the window.
+type Stream struct {
+	repo      outbox_repository.IOutboxRepository
+	cfg       config.Stream
+	retention time.Duration
+	now       func() time.Time
+
+	mutex     sync.Mutex
+	listeners map[string]map[*Subscription]bool
+	seen      map[primitive.ObjectID]time.Time
+	closed    bool
+}
+
+func NewStream(repo outbox_repository.IOutboxRepository, cfg config.Stream, events config.Events) *Stream {
+	return &Stream{
+		repo:      repo,
+		cfg:       cfg,
+		retention: events.Retention,
+		now:       time.Now,
+		listeners: make(map[string]map[*Subscription]bool),
+		seen:      make(map[primitive.ObjectID]time.Time),
+	}
+}
+
+func (s *Stream) Subscribe(ctx context.Context, userId, lastEventId string) (*Subscription, error) {
+	ctx, span := tracing.Start(ctx, "Stream.Subscribe")
+	defer span.End()
+
+	now := s.now()
+	events := make(chan *event.Event, s.cfg.BufferSize)
+	subscription := &Subscription{Events: events, events: events, userId: userId, after: event.Cursor{OccurredAt: now}}
+	resume := lastEventId != ""
+	cursor, err := event.ParseCursor(lastEventId)
+	if resume && (err != nil || cursor.OccurredAt.Before(now.Add(-s.retention))) {
+		log.FromContext(ctx).Infow("Stream cannot resume", "lastEventId", lastEventId)
+		subscription.Reset = true
+		resume = false
+	}
+	if resume {
+		subscription.after = cursor
+	}
+
+	// Listening before reading the replay, an event written meanwhile is in either.
+	s.mutex.Lock()
+	if s.closed {
+		// Shutting down, the client reconnects to another instance.
+		s.mutex.Unlock()
+		subscription.closed = true
+		close(events)
+		return subscription, nil
+	}
+	if s.listeners[userId] == nil {
+		s.listeners[userId] = make(map[*Subscription]bool)
+	}
+	s.listeners[userId][subscription] = true
+	metrics.StreamsOpen.Inc()
+	s.mutex.Unlock()
+	if !resume {
+		return subscription, nil
+	}
+
+	replay, err := s.repo.FindAfter(ctx, userId, cursor, s.cfg.MaxReplay+1)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find events to replay error", "Error", err.Error())
+		s.Unsubscribe(subscription)
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	if len(replay) > s.cfg.MaxReplay {
+		log.FromContext(ctx).Infow("Stream has missed too many events to resume", "lastEventId", lastEventId)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		subscription.Reset = true
+		subscription.after = event.Cursor{OccurredAt: now}
+		return subscription, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	subscription.Replay = replay
+	subscription.replayed = make(map[primitive.ObjectID]bool, len(replay))
+	for _, e := range replay {
+		subscription.replayed[*e.Id] = true
+	}
+	return subscription, nil
+}
+
+func (s *Stream) Unsubscribe(subscription *Subscription) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.remove(subscription)
+}
+
+// Run reads the events every poll interval until ctx is done, then closes every
+// subscription.
+func (s *Stream) Run(ctx context.Context) {
+	log.Logger.Infow("Event stream started", "pollInterval", s.cfg.PollInterval.String())
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.Close()
+			log.Logger.Info("Event stream stopped")
+			return
+		case <-ticker.C:
+			s.Poll(ctx)
+		}
+	}
+}
+
+// Close ends every subscription and refuses new ones, the streams end before the server
+// waits for its requests to finish.
+func (s *Stream) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	for _, subscriptions := range s.listeners {
+		for subscription := range subscriptions {
+			s.remove(subscription)
+		}
+	}
+}
+
+// Poll hands the events not seen yet to the subscriptions of their users. Nothing is
+// read while no one listens.
+func (s *Stream) Poll(ctx context.Context) {
+	s.mutex.Lock()
+	listening := len(s.listeners) > 0
+	s.mutex.Unlock()
+	if !listening {
+		return
+	}
+
+	now := s.now()
+	after := event.Cursor{OccurredAt: now.Add(-s.cfg.Lookback)}
+	for {
+		events, err := s.repo.FindAfter(ctx, "", after, s.cfg.BatchSize)
+		if err != nil {
+			log.Logger.Errorw("Find events to stream error", "error", err.Error())
+			return
+		}
+		s.hand(events)
+		if len(events) < s.cfg.BatchSize {
+			break
+		}
+		after = events[len(events)-1].Cursor()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, occurredAt := range s.seen {
+		if occurredAt.Before(now.Add(-s.cfg.Lookback)) {
+			delete(s.seen, id)
+		}
+	}
+}
+
+func (s *Stream) hand(events []*event.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, e := range events {
+		if _, seen := s.seen[*e.Id]; seen {
+			continue
+		}
+		s.seen[*e.Id] = e.OccurredAt
+		for subscription := range s.listeners[e.UserId] {
+			if !subscription.after.Before(e.Cursor()) || subscription.replayed[*e.Id] {
+				continue
+			}
+			select {
+			case subscription.events <- e:
+			default:
+				log.Logger.Infow("Stream has fallen behind", "userId", e.UserId)
+				s.remove(subscription)
+			}
+		}
+	}
+}
+
+// remove is called with the mutex held.
+func (s *Stream) remove(subscription *Subscription) {
+	if subscription.closed {
+		return
+	}
+	subscription.closed = true
+	close(subscription.events)
+	metrics.StreamsOpen.Dec()
+
+	delete(s.listeners[subscription.userId], subscription)
+	if len(s.listeners[subscription.userId]) == 0 {
+		delete(s.listeners, subscription.userId)
+	}
+}
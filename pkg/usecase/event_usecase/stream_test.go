@@ -0,0 +1,148 @@
+package event_usecase
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func newTestStream(t *testing.T) (*Stream, *outbox_repository.OutboxMemoryRepository, *time.Time) {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	cfg := config.Stream{PollInterval: time.Second, Lookback: time.Minute, BatchSize: 2, MaxReplay: 3, Heartbeat: time.Second, BufferSize: 4}
+	repo := outbox_repository.NewOutboxMemoryRepository()
+	stream := NewStream(repo, cfg, config.Events{Retention: time.Hour})
+	now := time.Now()
+	stream.now = func() time.Time { return now }
+	return stream, repo, &now
+}
+
+func appendEvent(t *testing.T, repo *outbox_repository.OutboxMemoryRepository, userId, eventType string, occurredAt time.Time) *event.Event {
+	e := &event.Event{Type: eventType, UserId: userId, EntityId: primitive.NewObjectID().Hex(), Data: []byte(`{}`),
+		OccurredAt: occurredAt, Status: event.StatusPending, NextAttempt: occurredAt}
+	require.NoError(t, repo.Append(context.Background(), e))
+	return e
+}
+
+func received(subscription *Subscription) []string {
+	types := make([]string, 0)
+	for {
+		select {
+		case e, open := <-subscription.Events:
+			if !open {
+				return append(types, "closed")
+			}
+			types = append(types, e.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestStreamHandsEachUserTheirNewEventsOnce(t *testing.T) {
+	ctx := context.Background()
+	stream, repo, now := newTestStream(t)
+	appendEvent(t, repo, "owner", event.DeckCreated, now.Add(-time.Second))
+	owner, err := stream.Subscribe(ctx, "owner", "")
+	require.NoError(t, err)
+	other, err := stream.Subscribe(ctx, "other", "")
+	require.NoError(t, err)
+
+	appendEvent(t, repo, "owner", event.CardCreated, *now)
+	appendEvent(t, repo, "owner", event.CardUpdated, *now)
+	appendEvent(t, repo, "owner", event.ReviewUpdated, *now)
+	stream.Poll(ctx)
+	stream.Poll(ctx)
+
+	assert.Equal(t, []string{event.CardCreated, event.CardUpdated, event.ReviewUpdated}, received(owner))
+	assert.Empty(t, received(other))
+
+	stream.Unsubscribe(owner)
+	assert.Equal(t, []string{"closed"}, received(owner))
+}
+
+func TestStreamSeesEventsCommittedLate(t *testing.T) {
+	ctx := context.Background()
+	stream, repo, now := newTestStream(t)
+	subscription, err := stream.Subscribe(ctx, "owner", "")
+	require.NoError(t, err)
+
+	appendEvent(t, repo, "owner", event.DeckUpdated, now.Add(2*time.Second))
+	*now = now.Add(3 * time.Second)
+	stream.Poll(ctx)
+	appendEvent(t, repo, "owner", event.CardDeleted, now.Add(-2*time.Second))
+	stream.Poll(ctx)
+
+	assert.Equal(t, []string{event.DeckUpdated, event.CardDeleted}, received(subscription))
+}
+
+func TestStreamResumesFromLastEventId(t *testing.T) {
+	ctx := context.Background()
+	stream, repo, now := newTestStream(t)
+	last := appendEvent(t, repo, "owner", event.DeckCreated, now.Add(-time.Minute))
+	appendEvent(t, repo, "owner", event.CardCreated, now.Add(-time.Second))
+	appendEvent(t, repo, "other", event.CardCreated, now.Add(-time.Second))
+
+	subscription, err := stream.Subscribe(ctx, "owner", last.Cursor().String())
+	require.NoError(t, err)
+	assert.False(t, subscription.Reset)
+	require.Len(t, subscription.Replay, 1)
+	assert.Equal(t, event.CardCreated, subscription.Replay[0].Type)
+
+	stream.Poll(ctx)
+	assert.Empty(t, received(subscription), "the replayed events are not handed again")
+}
+
+func TestStreamResetsWhenTheMissedEventsAreGone(t *testing.T) {
+	ctx := context.Background()
+	stream, repo, now := newTestStream(t)
+	last := appendEvent(t, repo, "owner", event.DeckCreated, now.Add(-2*time.Hour))
+
+	expired, err := stream.Subscribe(ctx, "owner", last.Cursor().String())
+	require.NoError(t, err)
+	assert.True(t, expired.Reset)
+
+	invalid, err := stream.Subscribe(ctx, "owner", "not-a-cursor")
+	require.NoError(t, err)
+	assert.True(t, invalid.Reset)
+
+	recent := appendEvent(t, repo, "owner", event.DeckCreated, now.Add(-time.Minute))
+	for i := 0; i < 4; i++ {
+		appendEvent(t, repo, "owner", event.CardCreated, now.Add(-time.Second))
+	}
+	tooMany, err := stream.Subscribe(ctx, "owner", recent.Cursor().String())
+	require.NoError(t, err)
+	assert.True(t, tooMany.Reset)
+	assert.Empty(t, tooMany.Replay)
+}
+
+func TestStreamClosesSlowAndRemainingSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	stream, repo, now := newTestStream(t)
+	slow, err := stream.Subscribe(ctx, "owner", "")
+	require.NoError(t, err)
+	idle, err := stream.Subscribe(ctx, "other", "")
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		appendEvent(t, repo, "owner", event.CardUpdated, *now)
+	}
+	stream.Poll(ctx)
+	assert.Equal(t, []string{event.CardUpdated, event.CardUpdated, event.CardUpdated, event.CardUpdated, "closed"}, received(slow))
+
+	stream.Close()
+	assert.Equal(t, []string{"closed"}, received(idle))
+	afterClose, err := stream.Subscribe(ctx, "owner", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"closed"}, received(afterClose))
+}
@@ -1,237 +1,463 @@
 package playlist_usecase
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/patch"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/playlist_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
 	"github.com/go-playground/validator"
-	"github.com/imdario/mergo"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type IPlaylistUseCase interface {
-	Create(userId string, playlist *playlist.Playlist) (result *playlist.Playlist, err error)
-	FindByUserIdAndPublic(userId string) (result []*playlist.Playlist, count int64, err error)
-	FindById(userId, id string) (result *playlist.Playlist, err error)
-	FindByUserId(userId string) (result []*playlist.Playlist, count int64, err error)
-	Delete(id, userId string) (result *playlist.Playlist, err error)
-	Update(id, userId string, isPartial bool, playlist *playlist.Playlist) (*playlist.Playlist, error)
-	AddDeckToPlaylist(id, userId string, deckId string) (*playlist.Playlist, error)
-	FindBySearch(filter, userId string) (result []map[string]interface{}, count int64, err error)
+	Create(ctx context.Context, userId string, playlist *playlist.Playlist) (result *playlist.Playlist, err error)
+	FindByUserIdAndPublic(ctx context.Context, userId string) (result []*playlist.Playlist, count int64, err error)
+	FindById(ctx context.Context, userId, id string) (result *playlist.Playlist, err error)
+	FindByUserId(ctx context.Context, userId string) (result []*playlist.Playlist, count int64, err error)
+	Delete(ctx context.Context, id, userId string, version *int64) (result *playlist.Playlist, err error)
+	Update(ctx context.Context, id, userId string, playlist *playlist.Playlist, version *int64) (*playlist.Playlist, error)
+	Patch(ctx context.Context, id, userId, contentType string, document []byte, version *int64) (*playlist.Playlist, error)
+	AddDeckToPlaylist(ctx context.Context, id, userId string, deckId string, version *int64) (*playlist.Playlist, error)
+	AddDecksToPlaylist(ctx context.Context, id, userId string, deckIds []string, version *int64) (*playlist.Playlist, error)
+	RemoveDeckFromPlaylist(ctx context.Context, id, userId, deckId string, version *int64) (*playlist.Playlist, error)
+	MoveDeckInPlaylist(ctx context.Context, id, userId, deckId string, position int, version *int64) (*playlist.Playlist, error)
+	FindBySearch(ctx context.Context, filter, userId string) (result []map[string]interface{}, count int64, err error)
 }
+
+// readOnlyFields are owned by the server and cannot be changed by a patch.
+var readOnlyFields = []string{"_id", "userId", "version", "lastUpdate"}
+
 type PlaylistUseCase struct {
 	validator   *validator.Validate
 	repo        playlist_repository.IPlaylistRepository
 	deckUseCase deck_usecase.DeckUseCase
+	tx          transaction.ITransactionManager
+	events      event_usecase.IEventPublisher
 }
 
-func NewPlaylistUseCase(playlistRepository playlist_repository.IPlaylistRepository, deckRepo deck_usecase.DeckUseCase, validator *validator.Validate) PlaylistUseCase {
+func NewPlaylistUseCase(playlistRepository playlist_repository.IPlaylistRepository, deckRepo deck_usecase.DeckUseCase,
+	tx transaction.ITransactionManager, events event_usecase.IEventPublisher, validator *validator.Validate) PlaylistUseCase {
 	return PlaylistUseCase{
 		validator:   validator,
 		repo:        playlistRepository,
 		deckUseCase: deckRepo,
+		tx:          tx,
+		events:      events,
 	}
 }
 
-func (uc PlaylistUseCase) Create(userId string, playlist *playlist.Playlist) (result *playlist.Playlist, err error) {
+func (uc PlaylistUseCase) Create(ctx context.Context, userId string, playlist *playlist.Playlist) (result *playlist.Playlist, err error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.Create")
+	defer span.End()
 	playlist.UserId = userId
 	playlist.LastUpdate = time.Now()
+	playlist.Version = 1
 
 	err = uc.validator.Struct(playlist)
 	if err != nil {
 		playlistBytes, _ := json.Marshal(playlist)
-		log.Logger.Errorf("Error to validate input:\n %v;\n error: %v", string(playlistBytes), err.Error())
+		log.FromContext(ctx).Errorf("Error to validate input:\n %v;\n error: %v", string(playlistBytes), err.Error())
 		return nil, &errors.InvalidPayload{Err: err}
 	}
 
-	result, err = uc.repo.Persist(playlist)
-	if err != nil {
-		log.Logger.Errorw("Playlist creation error", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+	if err = checkDuplicatedDecks(ctx, playlist.Decks); err != nil {
+		return nil, err
 	}
 
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.Persist(ctx, playlist)
+		if err != nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.PlaylistCreated, result.Id.Hex(), result)
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Playlist creation error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
 	return result, nil
 }
 
-func (uc PlaylistUseCase) FindById(userId, id string) (result *playlist.Playlist, err error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc PlaylistUseCase) FindById(ctx context.Context, userId, id string) (result *playlist.Playlist, err error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.FindById")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err = uc.repo.FindById(userId, &objectID, false)
+	result, err = uc.repo.FindById(ctx, userId, &objectID, false)
 	if err != nil {
-		log.Logger.Errorw("playlist not found", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("playlist not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
 	}
 	return result, nil
 }
 
-func (uc PlaylistUseCase) FindByUserId(userId string) (result []*playlist.Playlist, count int64, err error) {
-	result, err = uc.repo.FindByUserId(userId)
+func (uc PlaylistUseCase) FindByUserId(ctx context.Context, userId string) (result []*playlist.Playlist, count int64, err error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.FindByUserId")
+	defer span.End()
+	result, err = uc.repo.FindByUserId(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("playlist not found", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("playlist not found", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrNotFound, err)
 	}
 
-	count, err = uc.repo.Count(userId)
+	count, err = uc.repo.Count(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("Count playlists error", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Count playlists error", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 
 	return result, count, nil
 }
 
-func (uc PlaylistUseCase) FindByUserIdAndPublic(userId string) (result []*playlist.Playlist, count int64, err error) {
-	result, err = uc.repo.FindByUserIdAndPublic(userId)
+func (uc PlaylistUseCase) FindByUserIdAndPublic(ctx context.Context, userId string) (result []*playlist.Playlist, count int64, err error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.FindByUserIdAndPublic")
+	defer span.End()
+	result, err = uc.repo.FindByUserIdAndPublic(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("playlist not found", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("playlist not found", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrNotFound, err)
 	}
 
-	count, err = uc.repo.Count(userId)
+	count, err = uc.repo.Count(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("Count playlists error", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Count playlists error", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 	return result, count, nil
 }
 
-func (uc PlaylistUseCase) FindBySearch(filter, userId string) (result []map[string]interface{}, count int64, err error) {
-	result, err = uc.repo.FindFilter(filter, userId)
+func (uc PlaylistUseCase) FindBySearch(ctx context.Context, filter, userId string) (result []map[string]interface{}, count int64, err error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.FindBySearch")
+	defer span.End()
+	result, err = uc.repo.FindFilter(ctx, filter, userId)
 	if err != nil {
-		log.Logger.Errorw("playlist not found", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("playlist not found", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrNotFound, err)
 	}
 
-	count, err = uc.repo.Count(userId)
+	count, err = uc.repo.Count(ctx, userId)
 	if err != nil {
-		log.Logger.Errorw("Count playlists error", "Error", err.Error())
-		return nil, 0, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Count playlists error", "Error", err.Error())
+		return nil, 0, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 	return result, count, nil
 }
 
-func (uc PlaylistUseCase) parseToObjectID(id string) (objID primitive.ObjectID, err error) {
+func (uc PlaylistUseCase) parseToObjectID(ctx context.Context, id string) (objID primitive.ObjectID, err error) {
 	if id == "" {
 		err := errors.WrapWithMessage(errors.ErrInvalidPayload, "id is required")
-		log.Logger.Errorw("id is required")
+		log.FromContext(ctx).Errorw("id is required")
 		return objID, err
 	}
 
 	objID, err = primitive.ObjectIDFromHex(id)
 	if err != nil {
-		log.Logger.Errorw("invalid Id", "Error", err.Error())
+		log.FromContext(ctx).Errorw("invalid Id", "Error", err.Error())
 		return objID, errors.WrapWithMessage(errors.ErrInvalidPayload, err.Error())
 	}
 
 	return
 }
 
-func (uc PlaylistUseCase) Delete(id, userId string) (result *playlist.Playlist, err error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc PlaylistUseCase) Delete(ctx context.Context, id, userId string, version *int64) (result *playlist.Playlist, err error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.Delete")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err = uc.repo.Delete(userId, &objectID)
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.Delete(ctx, userId, &objectID, version)
+		if err != nil || result == nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.PlaylistDeleted, id, result)
+	})
 	if err != nil {
-		log.Logger.Errorw("Remove playlist error", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("Remove playlist error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+
+	if result == nil {
+		if err := uc.notApplied(ctx, userId, id, &objectID, version); err != nil {
+			return nil, err
+		}
+		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
 	}
 	return
 }
 
-func (uc PlaylistUseCase) Update(id, userId string, isPartial bool, playlist *playlist.Playlist) (*playlist.Playlist, error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc PlaylistUseCase) Update(ctx context.Context, id, userId string, playlist *playlist.Playlist, version *int64) (*playlist.Playlist, error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.Update")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	playlist.UserId = userId
-	playlist.LastUpdate = time.Now()
+	savedPlaylist, err := uc.findForUpdate(ctx, userId, id, &objectID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.save(ctx, userId, id, &objectID, savedPlaylist, playlist)
+}
 
-	savedPlaylist, err := uc.repo.FindById(userId, &objectID, true)
+func (uc PlaylistUseCase) Patch(ctx context.Context, id, userId, contentType string, document []byte, version *int64) (*playlist.Playlist, error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.Patch")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
-		log.Logger.Errorw("playlist not found", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		return nil, err
 	}
 
-	if isPartial {
-		if err := mergo.Merge(playlist, savedPlaylist); err != nil {
-			log.Logger.Errorf("Error to merge entities")
-			return nil, &errors.BadRequest{Err: err}
-		}
+	savedPlaylist, err := uc.findForUpdate(ctx, userId, id, &objectID, version)
+	if err != nil {
+		return nil, err
 	}
 
+	var patched playlist.Playlist
+	err = patch.Apply(contentType, savedPlaylist, document, &patched, readOnlyFields...)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error to apply patch", "error", err.Error())
+		return nil, err
+	}
+
+	return uc.save(ctx, userId, id, &objectID, savedPlaylist, &patched)
+}
+
+func (uc PlaylistUseCase) findForUpdate(ctx context.Context, userId, id string, objectID *primitive.ObjectID, version *int64) (*playlist.Playlist, error) {
+	savedPlaylist, err := uc.repo.FindById(ctx, userId, objectID, true)
+	if err != nil {
+		log.FromContext(ctx).Errorw("playlist not found", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
+	}
+
+	if savedPlaylist.UserId != userId {
+		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+	}
+
+	if version != nil && *version != savedPlaylist.Version {
+		log.FromContext(ctx).Errorw("playlist version mismatch", "expected", *version, "current", savedPlaylist.Version)
+		return nil, errors.WrapWithMessage(errors.ErrVersionMismatch, fmt.Sprintf("id %s is at version %d", id, savedPlaylist.Version))
+	}
+
+	return savedPlaylist, nil
+}
+
+// save validates playlist and writes it over savedPlaylist, keeping the server owned fields.
+func (uc PlaylistUseCase) save(ctx context.Context, userId, id string, objectID *primitive.ObjectID, savedPlaylist, playlist *playlist.Playlist) (result *playlist.Playlist, err error) {
+	playlist.UserId = userId
+	playlist.LastUpdate = time.Now()
+	playlist.Version = savedPlaylist.Version
+
 	err = uc.validator.Struct(playlist)
 	if err != nil {
 		playlistBytes, _ := json.Marshal(playlist)
-		log.Logger.Errorf("Error to validate input:\n %v;\n error: %v", string(playlistBytes), err.Error())
+		log.FromContext(ctx).Errorf("Error to validate input:\n %v;\n error: %v", string(playlistBytes), err.Error())
 		return nil, &errors.InvalidPayload{Err: err}
 	}
 
-	result, err := uc.repo.Update(&objectID, userId, playlist)
+	if err = checkDuplicatedDecks(ctx, playlist.Decks); err != nil {
+		return nil, err
+	}
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.Update(ctx, objectID, userId, playlist)
+		if err != nil || result == nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.PlaylistUpdated, id, result)
+	})
 	if err != nil {
-		log.Logger.Errorw("update error", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("update error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 
 	if result == nil {
+		if err := uc.notApplied(ctx, userId, id, objectID, &savedPlaylist.Version); err != nil {
+			return nil, err
+		}
 		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
 	}
-
 	return result, nil
 }
 
-func (uc PlaylistUseCase) AddDeckToPlaylist(id, userId string, deckId string) (*playlist.Playlist, error) {
-	var preview deck.DeckPreview
-	savedDeck, err := uc.deckUseCase.FindById(userId, deckId)
+func (uc PlaylistUseCase) AddDeckToPlaylist(ctx context.Context, id, userId string, deckId string, version *int64) (*playlist.Playlist, error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.AddDeckToPlaylist")
+	defer span.End()
+	return uc.AddDecksToPlaylist(ctx, id, userId, []string{deckId}, version)
+}
+
+func (uc PlaylistUseCase) AddDecksToPlaylist(ctx context.Context, id, userId string, deckIds []string, version *int64) (*playlist.Playlist, error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.AddDecksToPlaylist")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
-		log.Logger.Errorw("playlistToSave not found", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		return nil, err
 	}
 
-	if savedDeck != nil {
-		preview = deck.DeckPreview{
+	if len(deckIds) == 0 {
+		log.FromContext(ctx).Errorw("deckIds is required")
+		return nil, errors.WrapWithMessage(errors.ErrInvalidPayload, "deckIds is required")
+	}
+
+	previews := make([]deck.DeckPreview, 0, len(deckIds))
+	for _, deckId := range deckIds {
+		savedDeck, err := uc.deckUseCase.FindById(ctx, userId, deckId)
+		if err != nil {
+			log.FromContext(ctx).Errorw("deck not found", "error", err.Error())
+			return nil, err
+		}
+
+		preview := deck.DeckPreview{
 			Id:       savedDeck.Id.Hex(),
 			ImageURL: savedDeck.ImageURL,
 			Name:     savedDeck.Name,
 			UserId:   savedDeck.UserId,
 		}
+
+		err = uc.validator.Struct(preview)
+		if err != nil {
+			previewBytes, _ := json.Marshal(preview)
+			log.FromContext(ctx).Errorf("Error to validate input:\n %v;\n error: %v", string(previewBytes), err.Error())
+			return nil, &errors.InvalidPayload{Err: err}
+		}
+		previews = append(previews, preview)
 	}
 
-	err = uc.validator.Struct(preview)
-	if err != nil {
-		playlistBytes, _ := json.Marshal(preview)
-		log.Logger.Errorf("Error to validate input:\n %v;\n error: %v", string(playlistBytes), err.Error())
-		return nil, &errors.InvalidPayload{Err: err}
+	if err = checkDuplicatedDecks(ctx, previews); err != nil {
+		return nil, err
 	}
 
-	savedPlaylist, err := uc.FindById(userId, id)
+	var result *playlist.Playlist
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.AddDecks(ctx, userId, &objectID, previews, version)
+		if err != nil || result == nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.PlaylistUpdated, id, result)
+	})
 	if err != nil {
-		log.Logger.Errorw("playlist not found", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("update error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 
-	savedPlaylist.Decks = append(savedPlaylist.Decks, preview)
+	if result == nil {
+		// The update filter rejects missing playlists, stale versions and duplicated decks alike.
+		if err := uc.notApplied(ctx, userId, id, &objectID, version); err != nil {
+			return nil, err
+		}
+		log.FromContext(ctx).Errorw("deck already in playlist", "playlistId", id, "deckIds", deckIds)
+		return nil, errors.WrapWithMessage(errors.ErrConflict, "deck is already in the playlist")
+	}
+	return result, nil
+}
+
+func (uc PlaylistUseCase) RemoveDeckFromPlaylist(ctx context.Context, id, userId, deckId string, version *int64) (*playlist.Playlist, error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.RemoveDeckFromPlaylist")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 
-	result, err := uc.Update(id, userId, true, savedPlaylist)
+	var result *playlist.Playlist
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.RemoveDeck(ctx, userId, &objectID, deckId, version)
+		if err != nil || result == nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.PlaylistUpdated, id, result)
+	})
 	if err != nil {
-		log.Logger.Errorw("update error", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("update error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 
 	if result == nil {
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+		if err := uc.notApplied(ctx, userId, id, &objectID, version); err != nil {
+			return nil, err
+		}
+		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("deck %s not found in playlist %s", deckId, id))
+	}
+	return result, nil
+}
+
+func (uc PlaylistUseCase) MoveDeckInPlaylist(ctx context.Context, id, userId, deckId string, position int, version *int64) (*playlist.Playlist, error) {
+	ctx, span := tracing.Start(ctx, "PlaylistUseCase.MoveDeckInPlaylist")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if position < 0 {
+		log.FromContext(ctx).Errorw("invalid position", "position", position)
+		return nil, errors.WrapWithMessage(errors.ErrInvalidPayload, "position must not be negative")
+	}
+
+	var result *playlist.Playlist
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.MoveDeck(ctx, userId, &objectID, deckId, position, version)
+		if err != nil || result == nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.PlaylistUpdated, id, result)
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("update error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 
+	if result == nil {
+		if err := uc.notApplied(ctx, userId, id, &objectID, version); err != nil {
+			return nil, err
+		}
+		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("deck %s not found in playlist %s", deckId, id))
+	}
 	return result, nil
 }
+
+// notApplied explains why a conditional write matched nothing: the playlist is
+// gone (NotFound), it moved past version (ErrVersionMismatch), or it is still
+// there at that version and the caller has to look for another reason (nil).
+func (uc PlaylistUseCase) notApplied(ctx context.Context, userId, id string, objectID *primitive.ObjectID, version *int64) error {
+	current, err := uc.repo.FindById(ctx, userId, objectID, true)
+	if err != nil || current.UserId != userId {
+		return errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+	}
+
+	if version != nil && *version != current.Version {
+		log.FromContext(ctx).Errorw("playlist version mismatch", "expected", *version, "current", current.Version)
+		return errors.WrapWithMessage(errors.ErrVersionMismatch, fmt.Sprintf("id %s is at version %d", id, current.Version))
+	}
+	return nil
+}
+
+func checkDuplicatedDecks(ctx context.Context, decks []deck.DeckPreview) error {
+	seen := make(map[string]bool, len(decks))
+	for _, preview := range decks {
+		if seen[preview.Id] {
+			log.FromContext(ctx).Errorw("duplicated deck", "deckId", preview.Id)
+			return errors.WrapWithMessage(errors.ErrConflict, fmt.Sprintf("deck %s is duplicated", preview.Id))
+		}
+		seen[preview.Id] = true
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package playlist_usecase
 
 import (
+	"context"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,31 +10,36 @@ type MockPlaylistUseCase struct {
 	mock.Mock
 }
 
-func (uc *MockPlaylistUseCase) FindByUserId(customerId string) (playlists []*playlist.Playlist, count int64, err error) {
-	args := uc.Called(customerId)
+func (uc *MockPlaylistUseCase) FindByUserId(ctx context.Context, customerId string) (playlists []*playlist.Playlist, count int64, err error) {
+	args := uc.Called(ctx, customerId)
 	return args.Get(0).([]*playlist.Playlist), args.Get(1).(int64), args.Error(2)
 }
-func (uc *MockPlaylistUseCase) FindByUserIdAndPublic(customerId string) (playlists []*playlist.Playlist, count int64, err error) {
-	args := uc.Called(customerId)
+func (uc *MockPlaylistUseCase) FindByUserIdAndPublic(ctx context.Context, customerId string) (playlists []*playlist.Playlist, count int64, err error) {
+	args := uc.Called(ctx, customerId)
 	return args.Get(0).([]*playlist.Playlist), args.Get(1).(int64), args.Error(2)
 }
 
-func (uc *MockPlaylistUseCase) FindByID(userId, id string) (*playlist.Playlist, error) {
-	args := uc.Called(userId, id)
+func (uc *MockPlaylistUseCase) FindByID(ctx context.Context, userId, id string) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, userId, id)
 	return args.Get(0).(*playlist.Playlist), args.Error(1)
 }
 
-func (uc *MockPlaylistUseCase) Create(userId string, playlists *playlist.Playlist) (*playlist.Playlist, error) {
-	args := uc.Called(userId, playlists)
+func (uc *MockPlaylistUseCase) Create(ctx context.Context, userId string, playlists *playlist.Playlist) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, userId, playlists)
 	return args.Get(0).(*playlist.Playlist), args.Error(1)
 }
 
-func (uc *MockPlaylistUseCase) Update(id, userId string, isPartial bool, playlists *playlist.Playlist) (*playlist.Playlist, error) {
-	args := uc.Called(id, userId, isPartial, playlists)
+func (uc *MockPlaylistUseCase) Update(ctx context.Context, id, userId string, playlists *playlist.Playlist, version *int64) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, id, userId, playlists, version)
 	return args.Get(0).(*playlist.Playlist), args.Error(1)
 }
 
-func (uc *MockPlaylistUseCase) Delete(id, userId string) (*playlist.Playlist, error) {
-	args := uc.Called(id, userId)
+func (uc *MockPlaylistUseCase) Patch(ctx context.Context, id, userId, contentType string, document []byte, version *int64) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, id, userId, contentType, document, version)
+	return args.Get(0).(*playlist.Playlist), args.Error(1)
+}
+
+func (uc *MockPlaylistUseCase) Delete(ctx context.Context, id, userId string, version *int64) (*playlist.Playlist, error) {
+	args := uc.Called(ctx, id, userId, version)
 	return args.Get(0).(*playlist.Playlist), args.Error(1)
 }
@@ -3,9 +3,12 @@ package usecase
 import (
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/card_usecase"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/review_usecase"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/search_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/sync_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/webhook_usecase"
 	"github.com/google/wire"
 )
 
@@ -29,10 +32,35 @@ var searchSet = wire.NewSet(
 	search_usecase.NewSearchUseCase,
 	wire.Bind(new(search_usecase.ISearchUseCase), new(search_usecase.SearchUseCase)))
 
+var webhookSet = wire.NewSet(
+	webhook_usecase.NewWebhookUseCase,
+	webhook_usecase.NewDispatcher,
+	wire.Bind(new(webhook_usecase.IWebhookUseCase), new(webhook_usecase.WebhookUseCase)))
+
+var eventSet = wire.NewSet(
+	event_usecase.NewEventPublisher,
+	event_usecase.NewDispatcher,
+	event_usecase.NewStream,
+	NewSubscribers,
+	wire.Bind(new(event_usecase.IEventPublisher), new(event_usecase.EventPublisher)),
+	wire.Bind(new(event_usecase.IEventStream), new(*event_usecase.Stream)))
+
+var syncSet = wire.NewSet(
+	sync_usecase.NewSyncUseCase,
+	wire.Bind(new(sync_usecase.ISyncUseCase), new(sync_usecase.SyncUseCase)))
+
+// NewSubscribers lists what is handed the domain events.
+func NewSubscribers(webhooks webhook_usecase.WebhookUseCase) []event_usecase.Subscriber {
+	return []event_usecase.Subscriber{webhooks}
+}
+
 var Set = wire.NewSet(
 	playlistSet,
 	deckSet,
 	cardSet,
 	reviewSet,
 	searchSet,
+	webhookSet,
+	eventSet,
+	syncSet,
 )
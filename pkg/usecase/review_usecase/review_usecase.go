@@ -1,61 +1,71 @@
 package review_usecase
 
 import (
+	"context"
 	"fmt"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/Enums"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/review"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/review_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/card_usecase"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type IReviewUseCase interface {
-	ReviewPlaylists(id, userId string) (map[string]interface{}, error)
-	ReviewDecks(id, userId string) (map[string]interface{}, error)
-	FindById(userId, id string) (result *review.Review, err error)
-	AddCardResult(sessionId, userId string, card *card.Card, isRight bool) (*review.Review, error)
-	FindRecentDecks(userId string) (result []*review.Review, err error)
+	ReviewPlaylists(ctx context.Context, id, userId string) (map[string]interface{}, error)
+	ReviewDecks(ctx context.Context, id, userId string) (map[string]interface{}, error)
+	FindById(ctx context.Context, userId, id string) (result *review.Review, err error)
+	Start(ctx context.Context, userId string, session *review.Review) (*review.Review, error)
+	AddCardResult(ctx context.Context, sessionId, userId string, card *card.Card, isRight bool) (*review.Review, error)
+	FindRecentDecks(ctx context.Context, userId string) (result []*review.Review, err error)
 }
 
 type ReviewUseCase struct {
-	repo            review_repository.ReviewRepository
+	repo            review_repository.IReviewRepository
 	playlistUseCase playlist_usecase.PlaylistUseCase
 	deckUseCase     deck_usecase.DeckUseCase
 	cardUseCase     card_usecase.CardUseCase
+	tx              transaction.ITransactionManager
+	events          event_usecase.IEventPublisher
 }
 
-func NewReviewUseCase(playlistUseCase playlist_usecase.PlaylistUseCase, repo review_repository.ReviewRepository, cardUseCase card_usecase.CardUseCase, deckUseCase deck_usecase.DeckUseCase) ReviewUseCase {
+func NewReviewUseCase(playlistUseCase playlist_usecase.PlaylistUseCase, repo review_repository.IReviewRepository, cardUseCase card_usecase.CardUseCase,
+	deckUseCase deck_usecase.DeckUseCase, tx transaction.ITransactionManager, events event_usecase.IEventPublisher) ReviewUseCase {
 	return ReviewUseCase{
 		repo:            repo,
 		playlistUseCase: playlistUseCase,
 		deckUseCase:     deckUseCase,
 		cardUseCase:     cardUseCase,
+		tx:              tx,
+		events:          events,
 	}
 }
 
-func (uc ReviewUseCase) ReviewPlaylists(id, userId string) (map[string]interface{}, error) {
-	playlistToReview, err := uc.playlistUseCase.FindById(userId, id)
+func (uc ReviewUseCase) ReviewPlaylists(ctx context.Context, id, userId string) (map[string]interface{}, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUseCase.ReviewPlaylists")
+	defer span.End()
+	playlistToReview, err := uc.playlistUseCase.FindById(ctx, userId, id)
 	if err != nil {
 		return nil, err
 	}
-	var list []*card.Card
-
-	for _, element := range playlistToReview.Decks {
-		cards, err := uc.cardUseCase.FindByDeckId(userId, element.Id)
-		if err != nil {
-			log.Logger.Errorw("error to find cards by deckId", "error", err.Error())
-			return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
-		}
-		list = append(list, cards...)
+	list, err := uc.playlistCards(ctx, userId, playlistToReview)
+	if err != nil {
+		return nil, err
 	}
 
-	review, err := uc.repo.Persist(&review.Review{
+	review, err := uc.start(ctx, userId, &review.Review{
 		OriginType:    Enums.Playlist,
 		OriginId:      playlistToReview.Id.Hex(),
 		UserId:        userId,
@@ -63,32 +73,32 @@ func (uc ReviewUseCase) ReviewPlaylists(id, userId string) (map[string]interface
 		HistsCount:    0,
 		Mistakes:      nil,
 		MistakesCount: 0,
+		CardsCount:    int64(len(list)),
 		LastUpdate:    time.Time{},
 	})
 	if err != nil {
 		return nil, err
 	}
+	metrics.ReviewsStarted.WithLabelValues(Enums.Playlist).Inc()
 	return map[string]interface{}{
 		"cards":   list,
 		"session": review,
 	}, nil
 }
 
-func (uc ReviewUseCase) ReviewDecks(id, userId string) (map[string]interface{}, error) {
-	deck, err := uc.deckUseCase.FindById(userId, id)
+func (uc ReviewUseCase) ReviewDecks(ctx context.Context, id, userId string) (map[string]interface{}, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUseCase.ReviewDecks")
+	defer span.End()
+	deck, err := uc.deckUseCase.FindById(ctx, userId, id)
 	if err != nil {
 		return nil, err
 	}
-	var list []*card.Card
-
-	cards, err := uc.cardUseCase.FindByDeckId(userId, deck.Id.Hex())
+	list, err := uc.deckCards(ctx, userId, deck)
 	if err != nil {
-		log.Logger.Errorw("error to find cards by deckId", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		return nil, err
 	}
-	list = append(list, cards...)
 
-	review, err := uc.repo.Persist(&review.Review{
+	review, err := uc.start(ctx, userId, &review.Review{
 		OriginType:    Enums.Deck,
 		OriginId:      deck.Id.Hex(),
 		UserId:        userId,
@@ -96,22 +106,105 @@ func (uc ReviewUseCase) ReviewDecks(id, userId string) (map[string]interface{},
 		HistsCount:    0,
 		Mistakes:      nil,
 		MistakesCount: 0,
+		CardsCount:    int64(len(list)),
 		LastUpdate:    time.Now(),
 	})
 	if err != nil {
 		return nil, err
 	}
+	metrics.ReviewsStarted.WithLabelValues(Enums.Deck).Inc()
 	return map[string]interface{}{
 		"cards":   list,
 		"session": review,
 	}, nil
 }
 
-func (uc ReviewUseCase) AddCardResult(sessionId, userId string, card *card.Card, isRight bool) (*review.Review, error) {
-	savedReview, err := uc.FindById(userId, sessionId)
+// Start begins a session on the deck or playlist session names, under the id of session
+// when the client chose one.
+func (uc ReviewUseCase) Start(ctx context.Context, userId string, session *review.Review) (*review.Review, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUseCase.Start")
+	defer span.End()
+	var list []*card.Card
+	var err error
+	switch session.OriginType {
+	case Enums.Deck:
+		var deckToReview *deck.Deck
+		deckToReview, err = uc.deckUseCase.FindById(ctx, userId, session.OriginId)
+		if err != nil {
+			return nil, err
+		}
+		list, err = uc.deckCards(ctx, userId, deckToReview)
+	case Enums.Playlist:
+		var playlistToReview *playlist.Playlist
+		playlistToReview, err = uc.playlistUseCase.FindById(ctx, userId, session.OriginId)
+		if err != nil {
+			return nil, err
+		}
+		list, err = uc.playlistCards(ctx, userId, playlistToReview)
+	default:
+		return nil, errors.WrapWithMessage(errors.ErrInvalidPayload, fmt.Sprintf("origin type %q is not %s or %s", session.OriginType, Enums.Deck, Enums.Playlist))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := uc.start(ctx, userId, &review.Review{
+		Id:         session.Id,
+		OriginType: session.OriginType,
+		OriginId:   session.OriginId,
+		UserId:     userId,
+		CardsCount: int64(len(list)),
+		LastUpdate: time.Now(),
+	})
 	if err != nil {
-		log.Logger.Errorw("review not found", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("Review creation error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	metrics.ReviewsStarted.WithLabelValues(session.OriginType).Inc()
+	return result, nil
+}
+
+func (uc ReviewUseCase) deckCards(ctx context.Context, userId string, deckToReview *deck.Deck) ([]*card.Card, error) {
+	cards, err := uc.cardUseCase.FindByDeckId(ctx, userId, deckToReview.Id.Hex())
+	if err != nil {
+		log.FromContext(ctx).Errorw("error to find cards by deckId", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	return cards, nil
+}
+
+func (uc ReviewUseCase) playlistCards(ctx context.Context, userId string, playlistToReview *playlist.Playlist) ([]*card.Card, error) {
+	var list []*card.Card
+	for _, element := range playlistToReview.Decks {
+		cards, err := uc.cardUseCase.FindByDeckId(ctx, userId, element.Id)
+		if err != nil {
+			log.FromContext(ctx).Errorw("error to find cards by deckId", "error", err.Error())
+			return nil, errors.WrapCause(errors.ErrInternalServer, err)
+		}
+		list = append(list, cards...)
+	}
+	return list, nil
+}
+
+// start persists a new review session.
+func (uc ReviewUseCase) start(ctx context.Context, userId string, session *review.Review) (result *review.Review, err error) {
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.Persist(ctx, session)
+		if err != nil {
+			return err
+		}
+		return uc.events.Publish(ctx, userId, event.ReviewStarted, result.Id.Hex(), result)
+	})
+	return result, err
+}
+
+func (uc ReviewUseCase) AddCardResult(ctx context.Context, sessionId, userId string, card *card.Card, isRight bool) (*review.Review, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUseCase.AddCardResult")
+	defer span.End()
+	savedReview, err := uc.FindById(ctx, userId, sessionId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("review not found", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
 	}
 	savedReview.LastUpdate = time.Now()
 	if isRight {
@@ -122,55 +215,74 @@ func (uc ReviewUseCase) AddCardResult(sessionId, userId string, card *card.Card,
 		savedReview.Mistakes = append(savedReview.Hists, card)
 		savedReview.MistakesCount += 1
 	}
-	id, err := uc.parseToObjectID(sessionId)
+	id, err := uc.parseToObjectID(ctx, sessionId)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := uc.repo.Update(&id, userId, savedReview)
+	var result *review.Review
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err = uc.repo.Update(ctx, &id, userId, savedReview)
+		if err != nil || result == nil {
+			return err
+		}
+		err = uc.events.Publish(ctx, userId, event.ReviewUpdated, sessionId, result)
+		if err != nil {
+			return err
+		}
+		if result.CardsCount > 0 && result.HistsCount+result.MistakesCount == result.CardsCount {
+			return uc.events.Publish(ctx, userId, event.ReviewCompleted, sessionId, result)
+		}
+		return nil
+	})
 	if err != nil {
-		log.Logger.Errorw("update error", "error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrInternalServer, err.Error())
+		log.FromContext(ctx).Errorw("update error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
 	}
 
 	if result == nil {
 		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
 	}
 
+	metrics.AnswersRecorded.WithLabelValues(metrics.Answer(isRight)).Inc()
 	return result, nil
 }
 
-func (uc ReviewUseCase) FindById(userId, id string) (result *review.Review, err error) {
-	objectID, err := uc.parseToObjectID(id)
+func (uc ReviewUseCase) FindById(ctx context.Context, userId, id string) (result *review.Review, err error) {
+	ctx, span := tracing.Start(ctx, "ReviewUseCase.FindById")
+	defer span.End()
+	objectID, err := uc.parseToObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err = uc.repo.FindById(userId, &objectID, false)
+	result, err = uc.repo.FindById(ctx, userId, &objectID, false)
 	if err != nil {
-		log.Logger.Errorw("deck not found", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("deck not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
 	}
 	return result, nil
 }
-func (uc ReviewUseCase) FindRecentDecks(userId string) (result []*review.Review, err error) {
-	result, err = uc.repo.FindRecent(Enums.Deck, userId)
+func (uc ReviewUseCase) FindRecentDecks(ctx context.Context, userId string) (result []*review.Review, err error) {
+	ctx, span := tracing.Start(ctx, "ReviewUseCase.FindRecentDecks")
+	defer span.End()
+	result, err = uc.repo.FindRecent(ctx, Enums.Deck, userId)
 	if err != nil {
-		log.Logger.Errorw("deck not found", "Error", err.Error())
-		return nil, errors.WrapWithMessage(errors.ErrNotFound, err.Error())
+		log.FromContext(ctx).Errorw("deck not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
 	}
 	return result, nil
 }
-func (uc ReviewUseCase) parseToObjectID(id string) (objID primitive.ObjectID, err error) {
+func (uc ReviewUseCase) parseToObjectID(ctx context.Context, id string) (objID primitive.ObjectID, err error) {
 	if id == "" {
 		err := errors.WrapWithMessage(errors.ErrInvalidPayload, "id is required")
-		log.Logger.Errorw("id is required")
+		log.FromContext(ctx).Errorw("id is required")
 		return objID, err
 	}
 
 	objID, err = primitive.ObjectIDFromHex(id)
 	if err != nil {
-		log.Logger.Errorw("invalid Id", "Error", err.Error())
+		log.FromContext(ctx).Errorw("invalid Id", "Error", err.Error())
 		return objID, errors.WrapWithMessage(errors.ErrInvalidPayload, err.Error())
 	}
 
@@ -1,8 +1,10 @@
 package search_usecase
 
 import (
+	"context"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
 	"github.com/go-playground/validator"
@@ -10,7 +12,7 @@ import (
 )
 
 type ISearchUseCase interface {
-	Search(filter, userId string) (result []map[string]interface{}, err error)
+	Search(ctx context.Context, filter, userId string) (result []map[string]interface{}, err error)
 }
 
 type SearchUseCase struct {
@@ -27,14 +29,16 @@ func NewSearchUseCase(playlistUseCase playlist_usecase.PlaylistUseCase, deckUseC
 	}
 }
 
-func (uc SearchUseCase) Search(filter, userId string) (result []map[string]interface{}, err error) {
-	playlistSearched, _, err := uc.playlistUseCase.FindBySearch(filter, userId)
+func (uc SearchUseCase) Search(ctx context.Context, filter, userId string) (result []map[string]interface{}, err error) {
+	ctx, span := tracing.Start(ctx, "SearchUseCase.Search")
+	defer span.End()
+	playlistSearched, _, err := uc.playlistUseCase.FindBySearch(ctx, filter, userId)
 	if err != nil {
 		return nil, err
 	}
 	result = append(result, playlistSearched...)
 
-	decksSearched, _, err := uc.deckUseCase.FindBySearch(filter, userId)
+	decksSearched, _, err := uc.deckUseCase.FindBySearch(ctx, filter, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -46,16 +50,16 @@ func (uc SearchUseCase) Search(filter, userId string) (result []map[string]inter
 	return
 }
 
-func (uc SearchUseCase) parseToObjectID(id string) (objID primitive.ObjectID, err error) {
+func (uc SearchUseCase) parseToObjectID(ctx context.Context, id string) (objID primitive.ObjectID, err error) {
 	if id == "" {
 		err := errors.WrapWithMessage(errors.ErrInvalidPayload, "id is required")
-		log.Logger.Errorw("id is required")
+		log.FromContext(ctx).Errorw("id is required")
 		return objID, err
 	}
 
 	objID, err = primitive.ObjectIDFromHex(id)
 	if err != nil {
-		log.Logger.Errorw("invalid Id", "Error", err.Error())
+		log.FromContext(ctx).Errorw("invalid Id", "Error", err.Error())
 		return objID, errors.WrapWithMessage(errors.ErrInvalidPayload, err.Error())
 	}
 
@@ -0,0 +1,180 @@
+package sync_usecase
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/card"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/delta"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/playlist"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/review"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (uc SyncUseCase) deckOperations() operations {
+	return operations{
+		find: func(ctx context.Context, userId, id string) (*stored, error) {
+			result, err := uc.decks.FindById(ctx, userId, id)
+			if err != nil {
+				return missing(err)
+			}
+			return &stored{value: result, userId: result.UserId, version: result.Version, lastUpdate: result.LastUpdate}, nil
+		},
+		create: func(ctx context.Context, userId, id string, data []byte) (interface{}, error) {
+			var deckToCreate deck.Deck
+			objectID, err := parse(id, data, &deckToCreate)
+			if err != nil {
+				return nil, err
+			}
+			deckToCreate.Id = &objectID
+			return uc.decks.Create(ctx, userId, &deckToCreate)
+		},
+		update: func(ctx context.Context, userId, id string, data []byte, current *stored) (interface{}, error) {
+			var deckToSave deck.Deck
+			if err := decode(data, &deckToSave); err != nil {
+				return nil, err
+			}
+			return uc.decks.Update(ctx, id, userId, &deckToSave, &current.version)
+		},
+		delete: func(ctx context.Context, userId, id string, version int64) error {
+			_, err := uc.decks.Delete(ctx, id, userId, &version)
+			return err
+		},
+	}
+}
+
+func (uc SyncUseCase) cardOperations() operations {
+	return operations{
+		find: func(ctx context.Context, userId, id string) (*stored, error) {
+			result, err := uc.cards.FindById(ctx, userId, id)
+			if err != nil {
+				return missing(err)
+			}
+			return &stored{value: result, userId: result.UserId, version: result.Version, lastUpdate: result.LastUpdate}, nil
+		},
+		create: func(ctx context.Context, userId, id string, data []byte) (interface{}, error) {
+			var cardToCreate card.Card
+			objectID, err := parse(id, data, &cardToCreate)
+			if err != nil {
+				return nil, err
+			}
+			cardToCreate.Id = &objectID
+			return uc.cards.Create(ctx, userId, cardToCreate.DeckId, &cardToCreate)
+		},
+		update: func(ctx context.Context, userId, id string, data []byte, current *stored) (interface{}, error) {
+			var cardToSave card.Card
+			if err := decode(data, &cardToSave); err != nil {
+				return nil, err
+			}
+			return uc.cards.UpdateAndMove(ctx, id, userId, &cardToSave, &current.version)
+		},
+		delete: func(ctx context.Context, userId, id string, version int64) error {
+			_, err := uc.cards.Delete(ctx, id, userId, &version)
+			return err
+		},
+	}
+}
+
+func (uc SyncUseCase) playlistOperations() operations {
+	return operations{
+		find: func(ctx context.Context, userId, id string) (*stored, error) {
+			result, err := uc.playlists.FindById(ctx, userId, id)
+			if err != nil {
+				return missing(err)
+			}
+			return &stored{value: result, userId: result.UserId, version: result.Version, lastUpdate: result.LastUpdate}, nil
+		},
+		create: func(ctx context.Context, userId, id string, data []byte) (interface{}, error) {
+			var playlistToCreate playlist.Playlist
+			objectID, err := parse(id, data, &playlistToCreate)
+			if err != nil {
+				return nil, err
+			}
+			playlistToCreate.Id = &objectID
+			return uc.playlists.Create(ctx, userId, &playlistToCreate)
+		},
+		update: func(ctx context.Context, userId, id string, data []byte, current *stored) (interface{}, error) {
+			var playlistToSave playlist.Playlist
+			if err := decode(data, &playlistToSave); err != nil {
+				return nil, err
+			}
+			return uc.playlists.Update(ctx, id, userId, &playlistToSave, &current.version)
+		},
+		delete: func(ctx context.Context, userId, id string, version int64) error {
+			_, err := uc.playlists.Delete(ctx, id, userId, &version)
+			return err
+		},
+	}
+}
+
+// resolveReview starts a session or answers a card of one. A session started again is
+// left as it is, and so is a card answered again; the answers to a session that is gone
+// conflict.
+func (uc SyncUseCase) resolveReview(ctx context.Context, userId string, op *delta.Operation) (string, interface{}, error) {
+	session, err := uc.reviews.FindById(ctx, userId, op.Id)
+	if err != nil {
+		if _, err = missing(err); err != nil {
+			return "", nil, err
+		}
+		session = nil
+	}
+
+	switch op.Op {
+	case delta.Create:
+		if session != nil {
+			return delta.Applied, session, nil
+		}
+		var sessionToStart review.Review
+		objectID, err := parse(op.Id, op.Data, &sessionToStart)
+		if err != nil {
+			return "", nil, err
+		}
+		sessionToStart.Id = &objectID
+		result, err := uc.reviews.Start(ctx, userId, &sessionToStart)
+		if err != nil {
+			return "", nil, err
+		}
+		return delta.Applied, result, nil
+	case delta.Answer:
+		if session == nil {
+			return delta.Conflict, nil, nil
+		}
+		var answer delta.CardResult
+		if err = decode(op.Data, &answer); err != nil {
+			return "", nil, err
+		}
+		if err = uc.validator.Struct(answer); err != nil || answer.Card.Id == nil {
+			return "", nil, errors.WrapWithMessage(errors.ErrInvalidPayload, "the answer needs the card and its id")
+		}
+		if answered(session, answer.Card.Id) {
+			return delta.Applied, session, nil
+		}
+		result, err := uc.reviews.AddCardResult(ctx, op.Id, userId, answer.Card, answer.IsRight)
+		if err != nil {
+			return "", nil, err
+		}
+		return delta.Applied, result, nil
+	}
+	return "", nil, unsupported(op)
+}
+
+// answered tells whether the session already has an answer to the card.
+func answered(session *review.Review, cardId *primitive.ObjectID) bool {
+	for _, cards := range [][]*card.Card{session.Hists, session.Mistakes} {
+		for _, c := range cards {
+			if c.Id != nil && *c.Id == *cardId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parse reads the id the client chose and the data of a create operation into value.
+func parse(id string, data []byte, value interface{}) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return objectID, errors.WrapWithMessage(errors.ErrInvalidPayload, err.Error())
+	}
+	return objectID, decode(data, value)
+}
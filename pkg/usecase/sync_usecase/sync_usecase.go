@@ -0,0 +1,349 @@
+package sync_usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/Enums"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/delta"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/review_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/card_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/review_usecase"
+	"github.com/go-playground/validator"
+	"strings"
+	"time"
+)
+
+type ISyncUseCase interface {
+	// Pull returns the changes to the entities of userId since checkpoint, or every entity
+	// when checkpoint is empty or older than the events are kept.
+	Pull(ctx context.Context, userId, checkpoint string) (*delta.Pull, error)
+	// Push applies the operations in order and tells what became of each. An operation
+	// the server fails to apply stops the batch, the client sends it again: the
+	// operations applied before are recognized.
+	Push(ctx context.Context, userId string, push *delta.Push) ([]*delta.Outcome, error)
+}
+
+type SyncUseCase struct {
+	validator  *validator.Validate
+	outbox     outbox_repository.IOutboxRepository
+	reviewRepo review_repository.IReviewRepository
+	decks      deck_usecase.IDeckUseCase
+	cards      card_usecase.ICardUseCase
+	playlists  playlist_usecase.IPlaylistUseCase
+	reviews    review_usecase.IReviewUseCase
+	cfg        config.Sync
+	retention  time.Duration
+	now        func() time.Time
+}
+
+func NewSyncUseCase(outbox outbox_repository.IOutboxRepository, reviewRepo review_repository.IReviewRepository,
+	decks deck_usecase.IDeckUseCase, cards card_usecase.ICardUseCase, playlists playlist_usecase.IPlaylistUseCase,
+	reviews review_usecase.IReviewUseCase, cfg config.Sync, events config.Events, validator *validator.Validate) SyncUseCase {
+	return SyncUseCase{
+		validator:  validator,
+		outbox:     outbox,
+		reviewRepo: reviewRepo,
+		decks:      decks,
+		cards:      cards,
+		playlists:  playlists,
+		reviews:    reviews,
+		cfg:        cfg,
+		retention:  events.Retention,
+		now:        time.Now,
+	}
+}
+
+func (uc SyncUseCase) Pull(ctx context.Context, userId, checkpoint string) (*delta.Pull, error) {
+	ctx, span := tracing.Start(ctx, "SyncUseCase.Pull")
+	defer span.End()
+
+	// The events that occurred in the last Lookback may still be joined by ones committed
+	// late, the checkpoint stays behind them and they are returned again.
+	now := uc.now()
+	settled := event.Cursor{OccurredAt: now.Add(-uc.cfg.Lookback)}
+	cursor, err := event.ParseCursor(checkpoint)
+	if checkpoint == "" || err != nil || cursor.OccurredAt.Before(now.Add(-uc.retention)) {
+		changes, err := uc.snapshot(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		return &delta.Pull{Checkpoint: settled.String(), Reset: true, Changes: changes}, nil
+	}
+
+	events, err := uc.outbox.FindAfter(ctx, userId, cursor, uc.cfg.PageSize)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find changes error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+
+	pull := &delta.Pull{HasMore: len(events) == uc.cfg.PageSize, Changes: collapse(events)}
+	next := settled
+	if len(events) > 0 {
+		// A full page moves on even within the window, or the next pull would be the same.
+		last := events[len(events)-1].Cursor()
+		if pull.HasMore || last.Before(settled) {
+			next = last
+		}
+	}
+	if next.Before(cursor) {
+		next = cursor
+	}
+	pull.Checkpoint = next.String()
+	return pull, nil
+}
+
+// collapse keeps the last change to each entity, in the order of those last changes.
+func collapse(events []*event.Event) []*delta.Change {
+	last := make(map[string]int, len(events))
+	for i, e := range events {
+		last[e.Type[:strings.Index(e.Type, ".")]+"/"+e.EntityId] = i
+	}
+
+	changes := make([]*delta.Change, 0, len(last))
+	for i, e := range events {
+		parts := strings.SplitN(e.Type, ".", 2)
+		if last[parts[0]+"/"+e.EntityId] != i {
+			continue
+		}
+		changes = append(changes, &delta.Change{
+			Entity:    parts[0],
+			Id:        e.EntityId,
+			Deleted:   parts[1] == "deleted",
+			ChangedAt: e.OccurredAt,
+			Data:      e.Data,
+		})
+	}
+	return changes
+}
+
+// snapshot returns every deck, card and playlist of userId and the latest review sessions.
+func (uc SyncUseCase) snapshot(ctx context.Context, userId string) ([]*delta.Change, error) {
+	changes := make([]*delta.Change, 0)
+	add := func(entity, id string, changedAt time.Time, value interface{}) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return errors.WrapCause(errors.ErrInternalServer, err)
+		}
+		changes = append(changes, &delta.Change{Entity: entity, Id: id, ChangedAt: changedAt, Data: data})
+		return nil
+	}
+
+	decks, _, err := uc.decks.FindByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range decks {
+		if err = add(delta.Deck, d.Id.Hex(), d.LastUpdate, d); err != nil {
+			return nil, err
+		}
+		cards, err := uc.cards.FindByDeckId(ctx, userId, d.Id.Hex())
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cards {
+			if c.UserId != userId {
+				continue
+			}
+			if err = add(delta.Card, c.Id.Hex(), c.LastUpdate, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	playlists, _, err := uc.playlists.FindByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range playlists {
+		if err = add(delta.Playlist, p.Id.Hex(), p.LastUpdate, p); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, originType := range []string{Enums.Deck, Enums.Playlist} {
+		sessions, err := uc.reviewRepo.FindRecent(ctx, originType, userId)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Find review sessions error", "Error", err.Error())
+			return nil, errors.WrapCause(errors.ErrInternalServer, err)
+		}
+		for _, r := range sessions {
+			if err = add(delta.Review, r.Id.Hex(), r.LastUpdate, r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+func (uc SyncUseCase) Push(ctx context.Context, userId string, push *delta.Push) ([]*delta.Outcome, error) {
+	ctx, span := tracing.Start(ctx, "SyncUseCase.Push")
+	defer span.End()
+
+	err := uc.validator.Struct(push)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error to validate operations", "error", err.Error())
+		return nil, &errors.InvalidPayload{Err: err}
+	}
+	if len(push.Operations) > uc.cfg.MaxOperations {
+		return nil, errors.WrapWithMessage(errors.ErrInvalidPayload, fmt.Sprintf("at most %d operations can be sent at once", uc.cfg.MaxOperations))
+	}
+
+	outcomes := make([]*delta.Outcome, 0, len(push.Operations))
+	for _, op := range push.Operations {
+		status, result, err := uc.apply(ctx, userId, op)
+		if err != nil {
+			log.FromContext(ctx).Errorw("Apply operation error", "entity", op.Entity, "op", op.Op, "id", op.Id, "error", err.Error())
+			return nil, err
+		}
+		outcome := &delta.Outcome{Entity: op.Entity, Op: op.Op, Id: op.Id, Status: status}
+		if rejection, ok := result.(error); ok {
+			outcome.Error = rejection.Error()
+		} else if outcome.Data, err = json.Marshal(result); err != nil {
+			return nil, errors.WrapCause(errors.ErrInternalServer, err)
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes, nil
+}
+
+// apply returns the status of op and the entity as the server keeps it, or the error
+// that rejected op. A failure of the server is returned as err.
+func (uc SyncUseCase) apply(ctx context.Context, userId string, op *delta.Operation) (status string, result interface{}, err error) {
+	switch op.Entity {
+	case delta.Deck:
+		status, result, err = uc.resolve(ctx, userId, op, uc.deckOperations())
+	case delta.Card:
+		status, result, err = uc.resolve(ctx, userId, op, uc.cardOperations())
+	case delta.Playlist:
+		status, result, err = uc.resolve(ctx, userId, op, uc.playlistOperations())
+	case delta.Review:
+		status, result, err = uc.resolveReview(ctx, userId, op)
+	}
+	if err == nil {
+		return status, result, nil
+	}
+
+	switch errors.Cause(err).(type) {
+	case *errors.InvalidPayload, *errors.BadRequest, *errors.NotFound:
+		return delta.Rejected, err, nil
+	}
+	return "", nil, err
+}
+
+// stored is an entity as the server keeps it.
+type stored struct {
+	value      interface{}
+	userId     string
+	version    int64
+	lastUpdate time.Time
+}
+
+// operations reach one kind of entity. find returns nil when the entity is gone.
+type operations struct {
+	find   func(ctx context.Context, userId, id string) (*stored, error)
+	create func(ctx context.Context, userId, id string, data []byte) (interface{}, error)
+	update func(ctx context.Context, userId, id string, data []byte, current *stored) (interface{}, error)
+	delete func(ctx context.Context, userId, id string, version int64) error
+}
+
+// resolve applies op unless it conflicts with the entity. An entity created again is left
+// as it is, the client sent the operation before. An update or delete of a version changed
+// meanwhile is applied only when the client changed it after the server did, and a
+// deleted entity stays deleted.
+func (uc SyncUseCase) resolve(ctx context.Context, userId string, op *delta.Operation, entity operations) (string, interface{}, error) {
+	current, err := entity.find(ctx, userId, op.Id)
+	if err != nil {
+		return "", nil, err
+	}
+	if current != nil && current.userId != userId {
+		return "", nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s belongs to another user", op.Id))
+	}
+
+	switch op.Op {
+	case delta.Create:
+		if current != nil {
+			return delta.Applied, current.value, nil
+		}
+		result, err := entity.create(ctx, userId, op.Id, op.Data)
+		if err != nil {
+			return "", nil, err
+		}
+		return delta.Applied, result, nil
+	case delta.Update:
+		if current == nil {
+			return delta.Conflict, nil, nil
+		}
+		if !wins(op, current) {
+			return delta.Conflict, current.value, nil
+		}
+		result, err := entity.update(ctx, userId, op.Id, op.Data, current)
+		if errors.Cause(err) == errors.ErrVersionMismatch {
+			return uc.changedMeanwhile(ctx, userId, op, entity)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return delta.Applied, result, nil
+	case delta.Delete:
+		if current == nil {
+			return delta.Applied, nil, nil
+		}
+		if !wins(op, current) {
+			return delta.Conflict, current.value, nil
+		}
+		err = entity.delete(ctx, userId, op.Id, current.version)
+		if errors.Cause(err) == errors.ErrVersionMismatch {
+			return uc.changedMeanwhile(ctx, userId, op, entity)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return delta.Applied, nil, nil
+	}
+	return "", nil, unsupported(op)
+}
+
+// changedMeanwhile answers an operation that lost a race with another change: the server
+// keeps the other one.
+func (uc SyncUseCase) changedMeanwhile(ctx context.Context, userId string, op *delta.Operation, entity operations) (string, interface{}, error) {
+	current, err := entity.find(ctx, userId, op.Id)
+	if err != nil || current == nil {
+		return delta.Conflict, nil, err
+	}
+	return delta.Conflict, current.value, nil
+}
+
+// wins tells whether op applies to current: the client changed the version the server
+// has, or changed it after the server did.
+func wins(op *delta.Operation, current *stored) bool {
+	return op.Version != nil && *op.Version == current.version || op.ChangedAt.After(current.lastUpdate)
+}
+
+// missing turns the not found error of a lookup into a nil entity.
+func missing(err error) (*stored, error) {
+	if errors.Cause(err) == errors.ErrNotFound {
+		return nil, nil
+	}
+	return nil, err
+}
+
+func unsupported(op *delta.Operation) error {
+	return errors.WrapWithMessage(errors.ErrInvalidPayload, fmt.Sprintf("%s is not an operation on a %s", op.Op, op.Entity))
+}
+
+// decode reads the data of an operation into value.
+func decode(data []byte, value interface{}) error {
+	if err := json.Unmarshal(data, value); err != nil {
+		return &errors.BadRequest{Err: err}
+	}
+	return nil
+}
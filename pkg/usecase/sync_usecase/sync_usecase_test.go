@@ -0,0 +1,216 @@
+package sync_usecase
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/deck"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/delta"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/review"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/card_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/deck_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/outbox_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/playlist_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/review_repository"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/transaction"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/card_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/deck_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/event_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/playlist_usecase"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/usecase/review_usecase"
+	"github.com/go-playground/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func newTestSync(t *testing.T) SyncUseCase {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	validate := validator.New()
+	outbox := outbox_repository.NewOutboxMemoryRepository()
+	events := event_usecase.NewEventPublisher(outbox)
+	tx := transaction.NewMemoryTransactionManager()
+	deckRepo := deck_repository.NewDeckMemoryRepository()
+	cardRepo := card_repository.NewCardMemoryRepository()
+	playlistRepo := playlist_repository.NewPlaylistMemoryRepository()
+	reviewRepo := review_repository.NewReviewMemoryRepository()
+
+	decks := deck_usecase.NewDeckUseCase(deckRepo, cardRepo, playlistRepo, reviewRepo, tx, events, validate)
+	cards := card_usecase.NewCardUseCase(cardRepo, deckRepo, tx, decks, events, validate)
+	playlists := playlist_usecase.NewPlaylistUseCase(playlistRepo, decks, tx, events, validate)
+	reviews := review_usecase.NewReviewUseCase(playlists, reviewRepo, cards, decks, tx, events)
+	cfg := config.Sync{PageSize: 100, Lookback: time.Minute, MaxOperations: 10}
+	return NewSyncUseCase(outbox, reviewRepo, decks, cards, playlists, reviews, cfg, config.Events{Retention: time.Hour}, validate)
+}
+
+func operation(entity, op, id string, changedAt time.Time, version *int64, data interface{}) *delta.Operation {
+	raw, _ := json.Marshal(data)
+	return &delta.Operation{Entity: entity, Op: op, Id: id, Version: version, ChangedAt: changedAt, Data: raw}
+}
+
+func statuses(outcomes []*delta.Outcome) []string {
+	result := make([]string, 0, len(outcomes))
+	for _, outcome := range outcomes {
+		result = append(result, outcome.Status)
+	}
+	return result
+}
+
+func TestPushIsAppliedOnceWhenSentAgain(t *testing.T) {
+	ctx := context.Background()
+	uc := newTestSync(t)
+	deckId, cardId, sessionId := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	now := time.Now()
+	answer := delta.CardResult{IsRight: true}
+	push := &delta.Push{Operations: []*delta.Operation{
+		operation(delta.Deck, delta.Create, deckId.Hex(), now, nil, map[string]string{"name": "Verbs", "imageURL": "verbs.png", "description": "Irregular"}),
+		operation(delta.Card, delta.Create, cardId.Hex(), now, nil, map[string]string{"front": "ser", "back": "to be", "deckId": deckId.Hex()}),
+		operation(delta.Review, delta.Create, sessionId.Hex(), now, nil, review.Review{OriginType: "Deck", OriginId: deckId.Hex()}),
+		nil,
+	}}
+
+	outcomes, err := uc.Push(ctx, "owner", push)
+	require.Error(t, err, "an empty operation is refused")
+	assert.Nil(t, outcomes)
+
+	cardOutcome, err := uc.Push(ctx, "owner", &delta.Push{Operations: push.Operations[:3]})
+	require.NoError(t, err)
+	require.Equal(t, []string{delta.Applied, delta.Applied, delta.Applied}, statuses(cardOutcome))
+	require.NoError(t, json.Unmarshal(cardOutcome[1].Data, &answer.Card))
+	push.Operations[3] = operation(delta.Review, delta.Answer, sessionId.Hex(), now, nil, answer)
+
+	for i := 0; i < 2; i++ {
+		outcomes, err = uc.Push(ctx, "owner", push)
+		require.NoError(t, err)
+		assert.Equal(t, []string{delta.Applied, delta.Applied, delta.Applied, delta.Applied}, statuses(outcomes))
+	}
+	var session review.Review
+	require.NoError(t, json.Unmarshal(outcomes[3].Data, &session))
+	assert.Equal(t, int64(1), session.HistsCount)
+	assert.Equal(t, int64(1), session.CardsCount)
+
+	deckFound, err := uc.decks.FindById(ctx, "owner", deckId.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deckFound.CardsCount)
+}
+
+func TestPushResolvesConflictsByWhoChangedLast(t *testing.T) {
+	ctx := context.Background()
+	uc := newTestSync(t)
+	deckId, goneId := primitive.NewObjectID(), primitive.NewObjectID()
+	created, created1 := time.Now(), int64(1)
+	deckData := map[string]string{"name": "Verbs", "imageURL": "verbs.png", "description": "Irregular"}
+	outcomes, err := uc.Push(ctx, "owner", &delta.Push{Operations: []*delta.Operation{
+		operation(delta.Deck, delta.Create, deckId.Hex(), created, nil, deckData),
+		operation(delta.Deck, delta.Create, goneId.Hex(), created, nil, deckData),
+		operation(delta.Deck, delta.Delete, goneId.Hex(), created, &created1, nil),
+	}})
+	require.NoError(t, err)
+	require.Equal(t, []string{delta.Applied, delta.Applied, delta.Applied}, statuses(outcomes))
+
+	// Changed on the server after the client went offline.
+	server, err := uc.decks.Update(ctx, deckId.Hex(), "owner", &deck.Deck{Name: "Server", ImageURL: "verbs.png", Description: "Irregular"}, nil)
+	require.NoError(t, err)
+	stale := created1
+
+	outcomes, err = uc.Push(ctx, "owner", &delta.Push{Operations: []*delta.Operation{
+		operation(delta.Deck, delta.Update, deckId.Hex(), server.LastUpdate.Add(-time.Second), &stale, map[string]string{"name": "Earlier", "imageURL": "verbs.png", "description": "Irregular"}),
+		operation(delta.Deck, delta.Update, goneId.Hex(), time.Now().Add(time.Hour), &stale, deckData),
+		operation(delta.Deck, delta.Update, deckId.Hex(), server.LastUpdate.Add(time.Second), &stale, map[string]string{"name": "Later", "imageURL": "verbs.png", "description": "Irregular"}),
+		operation(delta.Card, delta.Update, "not-an-id", created, nil, nil),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{delta.Conflict, delta.Conflict, delta.Applied, delta.Rejected}, statuses(outcomes))
+	assert.Contains(t, string(outcomes[0].Data), `"name":"Server"`)
+	assert.Equal(t, "null", string(outcomes[1].Data), "a deleted deck stays deleted")
+	assert.Contains(t, string(outcomes[2].Data), `"name":"Later"`)
+	assert.NotEmpty(t, outcomes[3].Error)
+}
+
+func TestPushDoesNotMoveACardItCannotUpdate(t *testing.T) {
+	ctx := context.Background()
+	uc := newTestSync(t)
+	fromId, toId, cardId := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	now, version := time.Now(), int64(0)
+	deckData := map[string]string{"name": "Verbs", "imageURL": "verbs.png", "description": "Irregular"}
+	outcomes, err := uc.Push(ctx, "owner", &delta.Push{Operations: []*delta.Operation{
+		operation(delta.Deck, delta.Create, fromId.Hex(), now, nil, deckData),
+		operation(delta.Deck, delta.Create, toId.Hex(), now, nil, deckData),
+		operation(delta.Card, delta.Create, cardId.Hex(), now, nil, map[string]string{"front": "ser", "back": "to be", "deckId": fromId.Hex()}),
+	}})
+	require.NoError(t, err)
+	require.Equal(t, []string{delta.Applied, delta.Applied, delta.Applied}, statuses(outcomes))
+
+	outcomes, err = uc.Push(ctx, "owner", &delta.Push{Operations: []*delta.Operation{
+		operation(delta.Card, delta.Update, cardId.Hex(), time.Now().Add(time.Second), &version, map[string]string{"front": "", "deckId": toId.Hex()}),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{delta.Rejected}, statuses(outcomes))
+
+	found, err := uc.cards.FindById(ctx, "owner", cardId.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, fromId.Hex(), found.DeckId, "the card stays in its deck")
+	assert.Equal(t, "ser", found.Front)
+	for deckId, count := range map[string]int64{fromId.Hex(): 1, toId.Hex(): 0} {
+		deckFound, err := uc.decks.FindById(ctx, "owner", deckId)
+		require.NoError(t, err)
+		assert.Equal(t, count, deckFound.CardsCount)
+	}
+}
+
+func TestPullReturnsTheLastChangesSinceTheCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	uc := newTestSync(t)
+	keptId, goneId := primitive.NewObjectID(), primitive.NewObjectID()
+	deckData := map[string]string{"name": "Verbs", "imageURL": "verbs.png", "description": "Irregular"}
+	_, err := uc.Push(ctx, "owner", &delta.Push{Operations: []*delta.Operation{
+		operation(delta.Deck, delta.Create, keptId.Hex(), time.Now(), nil, deckData),
+	}})
+	require.NoError(t, err)
+
+	first, err := uc.Pull(ctx, "owner", "")
+	require.NoError(t, err)
+	assert.True(t, first.Reset)
+	require.Len(t, first.Changes, 1)
+	assert.Equal(t, keptId.Hex(), first.Changes[0].Id)
+
+	created := int64(1)
+	renamed := map[string]string{"name": "Renamed", "imageURL": "verbs.png", "description": "Irregular"}
+	_, err = uc.Push(ctx, "owner", &delta.Push{Operations: []*delta.Operation{
+		operation(delta.Deck, delta.Create, goneId.Hex(), time.Now(), nil, deckData),
+		operation(delta.Deck, delta.Delete, goneId.Hex(), time.Now(), &created, nil),
+		operation(delta.Deck, delta.Update, keptId.Hex(), time.Now(), &created, renamed),
+	}})
+	require.NoError(t, err)
+
+	second, err := uc.Pull(ctx, "owner", first.Checkpoint)
+	require.NoError(t, err)
+	assert.False(t, second.Reset)
+	assert.False(t, second.HasMore)
+	require.Len(t, second.Changes, 2)
+	assert.Equal(t, goneId.Hex(), second.Changes[0].Id)
+	assert.True(t, second.Changes[0].Deleted)
+	assert.Equal(t, keptId.Hex(), second.Changes[1].Id)
+	assert.Contains(t, string(second.Changes[1].Data), `"name":"Renamed"`)
+	checkpoint, err := event.ParseCursor(second.Checkpoint)
+	require.NoError(t, err)
+	assert.True(t, checkpoint.OccurredAt.Before(second.Changes[0].ChangedAt), "the checkpoint stays behind the recent changes")
+
+	uc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	expired, err := uc.Pull(ctx, "owner", second.Checkpoint)
+	require.NoError(t, err)
+	assert.True(t, expired.Reset)
+	require.Len(t, expired.Changes, 1)
+
+	other, err := uc.Pull(ctx, "other", "")
+	require.NoError(t, err)
+	assert.Empty(t, other.Changes)
+}
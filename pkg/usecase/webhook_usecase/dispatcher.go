@@ -0,0 +1,136 @@
+package webhook_usecase
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/backoff"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/metrics"
+	sender "github.com/Guilhermemzlima/FlashCardsBackEnd/internal/webhook"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/webhook"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/webhook_repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"time"
+)
+
+// Dispatcher sends the queued deliveries in the background. Every instance of the server
+// runs one, each delivery is claimed by one of them at a time.
+type Dispatcher struct {
+	repo   webhook_repository.IWebhookRepository
+	sender sender.Sender
+	cfg    config.Webhooks
+	now    func() time.Time
+}
+
+func NewDispatcher(repo webhook_repository.IWebhookRepository, cfg config.Webhooks) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		sender: sender.NewSender(cfg.Timeout, cfg.AllowPrivateNetworks),
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Run sends the due deliveries every poll interval until ctx is done. A delivery whose
+// attempt is cut short is sent again once its claim expires.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if !d.cfg.Enabled {
+		return
+	}
+	log.Logger.Infow("Webhook dispatcher started", "pollInterval", d.cfg.PollInterval.String())
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch may have left more due, they are sent without waiting.
+		if d.DispatchDue(ctx) == d.cfg.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			log.Logger.Info("Webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue sends a batch of the due deliveries, Concurrency at a time, and returns how
+// many it claimed. They are claimed for as long as the batch can take.
+func (d *Dispatcher) DispatchDue(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+	rounds := (d.cfg.BatchSize + d.cfg.Concurrency - 1) / d.cfg.Concurrency
+	now := d.now()
+	deliveries, err := d.repo.ClaimDeliveries(ctx, now, now.Add(time.Duration(rounds+1)*d.cfg.Timeout), d.cfg.BatchSize)
+	if err != nil {
+		log.Logger.Errorw("Claim deliveries error", "error", err.Error())
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, d.cfg.Concurrency)
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(delivery *webhook.Delivery) {
+			defer func() { <-slots; wg.Done() }()
+			d.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *webhook.Delivery) {
+	var status int
+	target, err := d.webhook(ctx, delivery)
+	if err == nil {
+		status, err = d.sender.Send(ctx, sender.Request{
+			URL:        target.URL,
+			Secret:     target.Secret,
+			Event:      delivery.Event,
+			EventId:    delivery.EventId,
+			DeliveryId: delivery.Id.Hex(),
+			Payload:    delivery.Payload,
+		})
+	}
+	if ctx.Err() != nil {
+		// Stopping, the claim expires and the delivery is sent again.
+		return
+	}
+
+	now := d.now()
+	delivery.LastAttempt = now
+	delivery.ResponseStatus = status
+	delivery.Error = ""
+	outcome := webhook.StatusSucceeded
+	switch {
+	case err == nil:
+		delivery.Status = webhook.StatusSucceeded
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status, delivery.Error = webhook.StatusFailed, err.Error()
+		outcome = webhook.StatusFailed
+	default:
+		delivery.Status, delivery.Error = webhook.StatusPending, err.Error()
+		delivery.NextAttempt = now.Add(backoff.Exponential(d.cfg.InitialBackoff, d.cfg.MaxBackoff, delivery.Attempts))
+		outcome = "retried"
+	}
+	metrics.WebhookAttempts.WithLabelValues(delivery.Event, outcome).Inc()
+
+	if err = d.repo.UpdateDelivery(context.Background(), delivery); err != nil {
+		log.Logger.Errorw("Update delivery error", "deliveryId", delivery.Id.Hex(), "error", err.Error())
+	}
+}
+
+// webhook is the webhook of delivery. Its deliveries go away with it, so one that is not
+// found is retried like any other failure.
+func (d *Dispatcher) webhook(ctx context.Context, delivery *webhook.Delivery) (*webhook.Webhook, error) {
+	id, err := primitive.ObjectIDFromHex(delivery.WebhookId)
+	if err != nil {
+		return nil, err
+	}
+	return d.repo.FindById(ctx, delivery.UserId, &id)
+}
@@ -0,0 +1,97 @@
+package webhook_usecase
+
+import (
+	"context"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/webhook"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/webhook_repository"
+	"github.com/go-playground/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestDispatcher(t *testing.T, status int32) (*Dispatcher, WebhookUseCase, *int32, string) {
+	previous := log.Logger
+	log.Logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { log.Logger = previous })
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := config.Webhooks{Enabled: true, BatchSize: 10, Concurrency: 2, Timeout: time.Second, MaxAttempts: 3,
+		InitialBackoff: time.Minute, MaxBackoff: 3 * time.Minute, AllowPrivateNetworks: true}
+	repo := webhook_repository.NewWebhookMemoryRepository()
+	return NewDispatcher(repo, cfg), NewWebhookUseCase(repo, cfg, validator.New()), &calls, server.URL
+}
+
+func handle(t *testing.T, useCase WebhookUseCase, eventType, data string) {
+	id := primitive.NewObjectID()
+	err := useCase.Handle(context.Background(), &event.Event{Id: &id, Type: eventType, UserId: "owner",
+		Data: []byte(data), OccurredAt: time.Now()})
+	require.NoError(t, err)
+}
+
+func TestDispatchDueDeliversOnce(t *testing.T) {
+	ctx := context.Background()
+	dispatcher, useCase, calls, url := newTestDispatcher(t, http.StatusOK)
+	hook, err := useCase.Create(ctx, "owner", &webhook.Webhook{URL: url, Events: []string{webhook.DeckCreated}})
+	require.NoError(t, err)
+
+	handle(t, useCase, webhook.DeckCreated, `{"name":"Verbs"}`)
+	handle(t, useCase, webhook.DeckDeleted, `{"name":"Verbs"}`)
+
+	assert.Equal(t, 1, dispatcher.DispatchDue(ctx))
+	assert.Equal(t, 0, dispatcher.DispatchDue(ctx))
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	deliveries, err := useCase.FindDeliveries(ctx, "owner", hook.Id.Hex())
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, webhook.StatusSucceeded, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+}
+
+func TestDispatchDueRetriesWithBackoffUntilFailed(t *testing.T) {
+	ctx := context.Background()
+	dispatcher, useCase, calls, url := newTestDispatcher(t, http.StatusInternalServerError)
+	hook, err := useCase.Create(ctx, "owner", &webhook.Webhook{URL: url, Events: []string{webhook.ReviewCompleted}})
+	require.NoError(t, err)
+	handle(t, useCase, webhook.ReviewCompleted, `null`)
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	waits := []time.Duration{time.Minute, 2 * time.Minute}
+	for _, wait := range waits {
+		assert.Equal(t, 1, dispatcher.DispatchDue(ctx))
+		deliveries, _ := useCase.FindDeliveries(ctx, "owner", hook.Id.Hex())
+		assert.Equal(t, webhook.StatusPending, deliveries[0].Status)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+		assert.True(t, deliveries[0].NextAttempt.Equal(now.Add(wait)))
+
+		assert.Equal(t, 0, dispatcher.DispatchDue(ctx), "not due before its backoff")
+		now = now.Add(wait)
+	}
+
+	assert.Equal(t, 1, dispatcher.DispatchDue(ctx))
+	deliveries, _ := useCase.FindDeliveries(ctx, "owner", hook.Id.Hex())
+	assert.Equal(t, webhook.StatusFailed, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+
+	redelivered, err := useCase.Redeliver(ctx, "owner", hook.Id.Hex(), deliveries[0].Id.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, deliveries[0].EventId, redelivered.EventId)
+	assert.Equal(t, 1, dispatcher.DispatchDue(ctx))
+}
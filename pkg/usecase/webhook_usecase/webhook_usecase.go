@@ -0,0 +1,282 @@
+package webhook_usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config/log"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/errors"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/infra/tracing"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/event"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/model/webhook"
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg/repository/webhook_repository"
+	"github.com/go-playground/validator"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"time"
+)
+
+type IWebhookUseCase interface {
+	Create(ctx context.Context, userId string, webhook *webhook.Webhook) (result *webhook.Webhook, err error)
+	FindByUserId(ctx context.Context, userId string) (result []*webhook.Webhook, err error)
+	FindById(ctx context.Context, userId, id string) (result *webhook.Webhook, err error)
+	Delete(ctx context.Context, id, userId string) (result *webhook.Webhook, err error)
+	FindDeliveries(ctx context.Context, userId, id string) (result []*webhook.Delivery, err error)
+	Redeliver(ctx context.Context, userId, id, deliveryId string) (result *webhook.Delivery, err error)
+}
+
+// maxWebhooks bounds the webhooks of a user, each event is delivered to all of them.
+const maxWebhooks = 20
+
+// Payload is the body posted to the webhooks.
+type Payload struct {
+	Id         string          `json:"id"`
+	Event      string          `json:"event"`
+	UserId     string          `json:"userId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type WebhookUseCase struct {
+	validator *validator.Validate
+	repo      webhook_repository.IWebhookRepository
+	enabled   bool
+}
+
+func NewWebhookUseCase(repo webhook_repository.IWebhookRepository, cfg config.Webhooks, validator *validator.Validate) WebhookUseCase {
+	return WebhookUseCase{
+		validator: validator,
+		repo:      repo,
+		enabled:   cfg.Enabled,
+	}
+}
+
+func (uc WebhookUseCase) Create(ctx context.Context, userId string, webhook *webhook.Webhook) (result *webhook.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.Create")
+	defer span.End()
+	webhook.Id = nil
+	webhook.UserId = userId
+	webhook.CreatedAt = time.Now()
+
+	err = uc.validator.Struct(webhook)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Error to validate input", "url", webhook.URL, "error", err.Error())
+		return nil, &errors.InvalidPayload{Err: err}
+	}
+	if parsed, err := url.Parse(webhook.URL); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return nil, errors.WrapWithMessage(errors.ErrInvalidPayload, "url must be an http or https URL")
+	}
+
+	existing, err := uc.repo.FindByUserId(ctx, userId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find webhooks error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	if len(existing) >= maxWebhooks {
+		return nil, errors.WrapWithMessage(errors.ErrConflict, fmt.Sprintf("a user can have up to %d webhooks", maxWebhooks))
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = newSecret()
+		if err != nil {
+			return nil, errors.WrapCause(errors.ErrInternalServer, err)
+		}
+	}
+
+	result, err = uc.repo.Persist(ctx, webhook)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Webhook creation error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	return result, nil
+}
+
+func (uc WebhookUseCase) FindByUserId(ctx context.Context, userId string) (result []*webhook.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.FindByUserId")
+	defer span.End()
+	result, err = uc.repo.FindByUserId(ctx, userId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("webhook not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
+	}
+	for _, w := range result {
+		w.Secret = ""
+	}
+	return result, nil
+}
+
+func (uc WebhookUseCase) FindById(ctx context.Context, userId, id string) (result *webhook.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.FindById")
+	defer span.End()
+	result, err = uc.find(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+	result.Secret = ""
+	return result, nil
+}
+
+func (uc WebhookUseCase) Delete(ctx context.Context, id, userId string) (result *webhook.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.Delete")
+	defer span.End()
+	objectID, err := parseToObjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = uc.repo.Delete(ctx, userId, &objectID)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Remove webhook error", "error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	if result == nil {
+		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("id %s not found", id))
+	}
+	result.Secret = ""
+	return result, nil
+}
+
+func (uc WebhookUseCase) FindDeliveries(ctx context.Context, userId, id string) (result []*webhook.Delivery, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.FindDeliveries")
+	defer span.End()
+	if _, err = uc.find(ctx, userId, id); err != nil {
+		return nil, err
+	}
+
+	result, err = uc.repo.FindDeliveries(ctx, userId, id)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find deliveries error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	return result, nil
+}
+
+// Redeliver queues the payload of a previous delivery again as a new delivery, whatever
+// the outcome of the previous one.
+func (uc WebhookUseCase) Redeliver(ctx context.Context, userId, id, deliveryId string) (result *webhook.Delivery, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.Redeliver")
+	defer span.End()
+	deliveryObjectID, err := parseToObjectID(ctx, deliveryId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = uc.find(ctx, userId, id); err != nil {
+		return nil, err
+	}
+
+	previous, err := uc.repo.FindDeliveryById(ctx, userId, &deliveryObjectID)
+	if err != nil || previous.WebhookId != id {
+		return nil, errors.WrapWithMessage(errors.ErrNotFound, fmt.Sprintf("delivery %s not found", deliveryId))
+	}
+
+	now := time.Now()
+	result, err = uc.repo.PersistDelivery(ctx, &webhook.Delivery{
+		WebhookId:   previous.WebhookId,
+		UserId:      userId,
+		EventId:     previous.EventId,
+		Event:       previous.Event,
+		Payload:     previous.Payload,
+		Status:      webhook.StatusPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorw("Redelivery error", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrInternalServer, err)
+	}
+	return result, nil
+}
+
+// Name makes the webhooks a subscriber of the domain events.
+func (uc WebhookUseCase) Name() string {
+	return "webhooks"
+}
+
+// Handle queues e for each webhook of its user subscribed to it. Handled again after a
+// failure, the webhooks queued the first time get the event twice; receivers drop the
+// repeat by its id.
+func (uc WebhookUseCase) Handle(ctx context.Context, e *event.Event) error {
+	if !uc.enabled {
+		return nil
+	}
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.Handle")
+	defer span.End()
+
+	webhooks, err := uc.repo.FindByUserId(ctx, e.UserId)
+	if err != nil {
+		log.FromContext(ctx).Errorw("Find webhooks to publish error", "event", e.Type, "Error", err.Error())
+		return err
+	}
+
+	var payload []byte
+	now := time.Now()
+	for _, w := range webhooks {
+		if !w.Subscribes(e.Type) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(Payload{Id: e.Id.Hex(), Event: e.Type, UserId: e.UserId, OccurredAt: e.OccurredAt, Data: e.Data})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = uc.repo.PersistDelivery(ctx, &webhook.Delivery{
+			WebhookId:   w.Id.Hex(),
+			UserId:      e.UserId,
+			EventId:     e.Id.Hex(),
+			Event:       e.Type,
+			Payload:     payload,
+			Status:      webhook.StatusPending,
+			NextAttempt: now,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			log.FromContext(ctx).Errorw("Queue delivery error", "event", e.Type, "webhookId", w.Id.Hex(), "Error", err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+func (uc WebhookUseCase) find(ctx context.Context, userId, id string) (*webhook.Webhook, error) {
+	objectID, err := parseToObjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := uc.repo.FindById(ctx, userId, &objectID)
+	if err != nil {
+		log.FromContext(ctx).Errorw("webhook not found", "Error", err.Error())
+		return nil, errors.WrapCause(errors.ErrNotFound, err)
+	}
+	return result, nil
+}
+
+func parseToObjectID(ctx context.Context, id string) (objID primitive.ObjectID, err error) {
+	if id == "" {
+		err := errors.WrapWithMessage(errors.ErrInvalidPayload, "id is required")
+		log.FromContext(ctx).Errorw("id is required")
+		return objID, err
+	}
+
+	objID, err = primitive.ObjectIDFromHex(id)
+	if err != nil {
+		log.FromContext(ctx).Errorw("invalid Id", "Error", err.Error())
+		return objID, errors.WrapWithMessage(errors.ErrInvalidPayload, err.Error())
+	}
+
+	return
+}
+
+// newSecret is the signing secret of a webhook registered without one.
+func newSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -3,11 +3,12 @@
 package main
 
 import (
+	"github.com/Guilhermemzlima/FlashCardsBackEnd/internal/config"
 	"github.com/Guilhermemzlima/FlashCardsBackEnd/pkg"
 	"github.com/google/wire"
 )
 
-func SetupApplication() (pkg.Application, error) {
+func SetupApplication(cfg *config.Config) (pkg.Application, func(), error) {
 	wire.Build(pkg.Container)
-	return pkg.Application{}, nil
+	return pkg.Application{}, nil, nil
 }